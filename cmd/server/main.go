@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"go-digital-wallet/internal/commons/inflight"
 	"go-digital-wallet/internal/config"
 	"go-digital-wallet/pkg/database"
+	"go-digital-wallet/pkg/notify"
 	"log"
 	"net/http"
 	"os"
@@ -13,6 +15,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -23,30 +28,90 @@ func main() {
 	cfg := config.LoadConfig()
 	appLogger := config.NewLogger()
 
-	db, err := database.NewPostgresConnection(&cfg.Database)
+	if runMigrateCommand(os.Args[1:], cfg, appLogger) {
+		return
+	}
+
+	// Dependencies may still be starting alongside the service, so wait for
+	// them before running migrations or accepting traffic.
+	startupTimeout := time.Duration(cfg.Server.DependencyTimeout) * time.Second
+
+	var db *gorm.DB
+	err := database.WaitFor(context.Background(), "postgres", appLogger, startupTimeout, func() (err error) {
+		db, err = database.NewPostgresConnection(&cfg.Database, appLogger)
+		return err
+	})
 	if err != nil {
-		appLogger.WithError(err).Fatal("Failed to connect to database")
+		appLogger.WithError(err).Fatal("Database did not become available, giving up")
+	}
+
+	var replicaDB *gorm.DB
+	if cfg.Database.ReplicaDSN != "" {
+		err = database.WaitFor(context.Background(), "postgres replica", appLogger, startupTimeout, func() (err error) {
+			replicaDB, err = database.NewPostgresReplicaConnection(&cfg.Database, appLogger)
+			return err
+		})
+		if err != nil {
+			appLogger.WithError(err).Fatal("Read replica did not become available, giving up")
+		}
 	}
 
 	if err := database.RunMigrations(&cfg.Database, appLogger); err != nil {
 		appLogger.Fatalf("Failed to run migrations: %v", err)
 	}
 
-	// Connect to Redis
-	redisClient := database.ConnectRedis(&cfg.Redis, appLogger)
+	var redisClient *redis.Client
+	err = database.WaitFor(context.Background(), "redis", appLogger, startupTimeout, func() (err error) {
+		redisClient, err = database.ConnectRedis(&cfg.Redis, appLogger)
+		return err
+	})
+	if err != nil {
+		appLogger.WithError(err).Fatal("Redis did not become available, giving up")
+	}
 	defer redisClient.Close()
 
+	notifier, err := notify.New(cfg.Notifier.Driver, appLogger)
+	if err != nil {
+		appLogger.WithError(err).Fatal("Failed to setup notifier")
+	}
+
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
-	validator := config.NewValidator()
+	if err := cfg.Description.Validate(); err != nil {
+		appLogger.WithError(err).Fatal("Invalid DESCRIPTION_PATTERN")
+	}
+	validator := config.NewValidator(cfg.Password, cfg.Description)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	inFlight := inflight.New()
 
 	config.Bootstrap(&config.BootstrapConfig{
-		DB:        db,
-		App:       router,
-		Redis:     redisClient,
-		Log:       appLogger,
-		Validate:  validator,
-		JWTConfig: &cfg.JWT,
+		DB:                db,
+		ReplicaDB:         replicaDB,
+		App:               router,
+		Redis:             redisClient,
+		Log:               appLogger,
+		Validate:          validator,
+		JWTConfig:         &cfg.JWT,
+		DatabaseConfig:    &cfg.Database,
+		InterestConfig:    &cfg.Interest,
+		RetentionConfig:   &cfg.Retention,
+		WalletConfig:      &cfg.Wallet,
+		MaintenanceConfig: &cfg.Maintenance,
+		RateLimitConfig:   &cfg.RateLimit,
+		CacheWarmConfig:   &cfg.CacheWarm,
+		ApprovalConfig:    &cfg.Approval,
+		TimeoutConfig:     &cfg.Timeout,
+		ConcurrencyConfig: &cfg.Concurrency,
+		AccessLogConfig:   &cfg.AccessLog,
+		FeatureFlags:      &cfg.FeatureFlags,
+		Notifier:          notifier,
+		EmailChangeConfig: &cfg.EmailChange,
+		ReadinessConfig:   &cfg.Readiness,
+		StatementConfig:   &cfg.Statement,
+		WorkerCtx:         workerCtx,
+		InFlight:          inFlight,
 	})
 
 	server := &http.Server{
@@ -69,8 +134,9 @@ func main() {
 	<-quit
 
 	appLogger.Info("Shutting down server...")
+	stopWorkers()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
@@ -78,4 +144,24 @@ func main() {
 	} else {
 		appLogger.Info("Server exited gracefully")
 	}
+
+	// Workers and requests that outlived their handler may still be moving
+	// money; closing the database under them would abort their transactions.
+	if err := inFlight.Wait(ctx); err != nil {
+		appLogger.WithField("pending", inFlight.Pending()).Error("Shutdown timed out with money movements in flight")
+	}
+	closeDatabase(db, appLogger)
+	if replicaDB != nil {
+		closeDatabase(replicaDB, appLogger)
+	}
+}
+
+func closeDatabase(db *gorm.DB, log *logrus.Logger) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.WithError(err).Warn("Failed to close database connection")
+	}
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-digital-wallet/internal/config"
+	"go-digital-wallet/pkg/database"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+const migrateUsage = `usage:
+  server migrate-version
+      print the schema version the database is at
+  server migrate-down -confirm-version=N
+      roll back migration N, which must be the latest one applied`
+
+// runMigrateCommand handles the migration subcommands and reports whether
+// args named one. The server itself is not started for them, so the
+// automatic migration to the latest version does not undo a rollback.
+func runMigrateCommand(args []string, cfg *config.Config, log *logrus.Logger) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "migrate-version":
+		version, dirty, err := database.MigrationVersion(&cfg.Database, log)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to read migration version")
+		}
+		fmt.Printf("version=%d dirty=%t\n", version, dirty)
+	case "migrate-down":
+		fs := flag.NewFlagSet("migrate-down", flag.ExitOnError)
+		confirm := fs.Uint("confirm-version", 0, "version being rolled back, as printed by migrate-version")
+		fs.Parse(args[1:])
+		if *confirm == 0 {
+			fmt.Fprintln(os.Stderr, "migrate-down drops schema and the data in it; pass -confirm-version with the current version to proceed")
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+
+		version, err := database.RollbackMigration(&cfg.Database, log, *confirm)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to roll back migration")
+		}
+		fmt.Printf("version=%d\n", version)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	return true
+}
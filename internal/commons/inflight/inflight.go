@@ -0,0 +1,61 @@
+// Package inflight tracks operations that must be allowed to finish before
+// the process exits, such as money movements holding database locks.
+package inflight
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Tracker counts the operations in flight. A nil *Tracker tracks nothing.
+type Tracker struct {
+	wg      sync.WaitGroup
+	pending atomic.Int64
+}
+
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Start records an operation as in flight until the returned function is
+// called, typically deferred: defer tracker.Start()().
+func (t *Tracker) Start() func() {
+	if t == nil {
+		return func() {}
+	}
+	t.wg.Add(1)
+	t.pending.Add(1)
+	return func() {
+		t.pending.Add(-1)
+		t.wg.Done()
+	}
+}
+
+// Pending returns how many operations are in flight.
+func (t *Tracker) Pending() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.pending.Load()
+}
+
+// Wait blocks until no operation is in flight, or returns ctx's error once
+// ctx is done first.
+func (t *Tracker) Wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,22 @@
+// Package readpref lets a request ask for its database reads to be served by
+// the primary instead of a read replica, so that it sees its own writes
+// before they have been replicated.
+package readpref
+
+import "context"
+
+type contextKey struct{}
+
+// WithPrimary returns a copy of ctx whose reads go to the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, true)
+}
+
+// PrimaryRequested reports whether ctx asks for reads from the primary.
+func PrimaryRequested(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	primary, _ := ctx.Value(contextKey{}).(bool)
+	return primary
+}
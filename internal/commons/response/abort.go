@@ -0,0 +1,27 @@
+package response
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+const jsonContentType = "application/json; charset=utf-8"
+
+// Abort stops the request chain and writes err as the JSON response body. The
+// content type is set explicitly so that clients can always parse errors, even
+// when an earlier middleware already touched the response headers.
+//
+// A server error raised after the request deadline passed is most likely
+// caused by it, so it is reported as a timeout instead.
+func Abort(c *gin.Context, err *CustomError) {
+	if err.StatusCode >= 500 && c.Request != nil && errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		err = TimeoutError()
+	}
+	if err.RequestID == "" {
+		err.RequestID = c.GetString("request_id")
+	}
+	c.Header("Content-Type", jsonContentType)
+	c.AbortWithStatusJSON(err.StatusCode, err)
+}
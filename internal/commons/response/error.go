@@ -4,12 +4,56 @@ import (
 	"net/http"
 )
 
+// ErrorCategory groups errors for metrics and alerting.
+type ErrorCategory string
+
+const (
+	CategoryGeneral       ErrorCategory = "general"
+	CategoryRepository    ErrorCategory = "repository"
+	CategoryNotFound      ErrorCategory = "not_found"
+	CategoryUnauthorized  ErrorCategory = "unauthorized"
+	CategoryBadRequest    ErrorCategory = "bad_request"
+	CategoryConflict      ErrorCategory = "conflict"
+	CategoryUnprocessable ErrorCategory = "unprocessable"
+	CategoryForbidden     ErrorCategory = "forbidden"
+	CategoryUnavailable   ErrorCategory = "unavailable"
+	CategoryRateLimited   ErrorCategory = "rate_limited"
+	CategoryRouting       ErrorCategory = "routing"
+)
+
 type CustomError struct {
-	Code           string      `json:"code"`
-	StatusCode     int         `json:"status_code"`
-	Status         bool        `json:"status"`
-	Message        string      `json:"message"`
-	AdditionalInfo interface{} `json:"additional_info,omitempty"`
+	Code           string        `json:"code"`
+	StatusCode     int           `json:"status_code"`
+	Status         bool          `json:"status"`
+	Message        string        `json:"message"`
+	AdditionalInfo interface{}   `json:"additional_info,omitempty"`
+	RequestID      string        `json:"request_id,omitempty"`
+	Category       ErrorCategory `json:"-"`
+}
+
+// ErrorHook is called for every CustomError built by this package.
+type ErrorHook func(err *CustomError)
+
+var errorHook ErrorHook
+
+// SetErrorHook registers a hook invoked each time an error is constructed,
+// e.g. to count errors by category. It must be set during startup, before
+// requests are served.
+func SetErrorHook(hook ErrorHook) {
+	errorHook = hook
+}
+
+// newError is the single factory behind every exported constructor.
+func newError(base CustomError, info interface{}, message ...string) *CustomError {
+	err := base
+	err.AdditionalInfo = info
+	if len(message) != 0 {
+		err.Message = message[0]
+	}
+	if errorHook != nil {
+		errorHook(&err)
+	}
+	return &err
 }
 
 var (
@@ -18,112 +62,179 @@ var (
 		StatusCode: http.StatusInternalServerError,
 		Status:     false,
 		Message:    "INTERNAL SERVER ERROR",
+		Category:   CategoryGeneral,
 	}
 	repositoryError = CustomError{
 		Code:       "ERR0002",
 		StatusCode: http.StatusInternalServerError,
 		Status:     false,
 		Message:    "REPOSITORY ERROR",
+		Category:   CategoryRepository,
 	}
 	notFoundError = CustomError{
 		Code:       "ERR0003",
 		StatusCode: http.StatusBadRequest,
 		Status:     false,
 		Message:    "NOT FOUND ERROR",
+		Category:   CategoryNotFound,
 	}
 	unauthorizedError = CustomError{
 		Code:       "ERR0004",
 		StatusCode: http.StatusUnauthorized,
 		Status:     false,
 		Message:    "UNAUTHORIZED",
+		Category:   CategoryUnauthorized,
 	}
 	badRequestError = CustomError{
 		Code:       "ERR0005",
 		StatusCode: http.StatusBadRequest,
 		Status:     false,
 		Message:    "BAD REQUEST ERROR",
+		Category:   CategoryBadRequest,
+	}
+	conflictError = CustomError{
+		Code:       "ERR0006",
+		StatusCode: http.StatusConflict,
+		Status:     false,
+		Message:    "CONFLICT",
+		Category:   CategoryConflict,
+	}
+	unprocessableEntityError = CustomError{
+		Code:       "ERR0007",
+		StatusCode: http.StatusUnprocessableEntity,
+		Status:     false,
+		Message:    "UNPROCESSABLE ENTITY",
+		Category:   CategoryUnprocessable,
+	}
+	forbiddenError = CustomError{
+		Code:       "ERR0008",
+		StatusCode: http.StatusForbidden,
+		Status:     false,
+		Message:    "FORBIDDEN",
+		Category:   CategoryForbidden,
+	}
+	serviceUnavailableError = CustomError{
+		Code:       "ERR0009",
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     false,
+		Message:    "SERVICE UNAVAILABLE",
+		Category:   CategoryUnavailable,
+	}
+	tooManyRequestsError = CustomError{
+		Code:       "ERR0010",
+		StatusCode: http.StatusTooManyRequests,
+		Status:     false,
+		Message:    "TOO MANY REQUESTS",
+		Category:   CategoryRateLimited,
+	}
+	routeNotFoundError = CustomError{
+		Code:       "ERR0011",
+		StatusCode: http.StatusNotFound,
+		Status:     false,
+		Message:    "ROUTE NOT FOUND",
+		Category:   CategoryRouting,
+	}
+	methodNotAllowedError = CustomError{
+		Code:       "ERR0012",
+		StatusCode: http.StatusMethodNotAllowed,
+		Status:     false,
+		Message:    "METHOD NOT ALLOWED",
+		Category:   CategoryRouting,
 	}
 )
 
 func GeneralError(message ...string) *CustomError {
-	err := generalError
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(generalError, nil, message...)
 }
+
 func GeneralErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
-	err := generalError
-	err.AdditionalInfo = info
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(generalError, info, message...)
 }
 
 func RepositoryError(message ...string) *CustomError {
-	err := repositoryError
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(repositoryError, nil, message...)
 }
 
 func RepositoryErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
-	err := repositoryError
-	err.AdditionalInfo = info
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(repositoryError, info, message...)
 }
 
 func NotFoundError(message ...string) *CustomError {
-	err := notFoundError
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(notFoundError, nil, message...)
 }
 
 func NotFoundErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
-	err := repositoryError
-	err.AdditionalInfo = info
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(notFoundError, info, message...)
 }
+
 func UnauthorizedError(message ...string) *CustomError {
-	err := unauthorizedError
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(unauthorizedError, nil, message...)
 }
 
 func UnauthorizedErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
-	err := unauthorizedError
-	err.AdditionalInfo = info
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(unauthorizedError, info, message...)
 }
 
 func BadRequestError(message ...string) *CustomError {
-	err := badRequestError
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(badRequestError, nil, message...)
 }
 
 func BadRequestErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
-	err := badRequestError
-	err.AdditionalInfo = info
-	if len(message) != 0 {
-		err.Message = message[0]
-	}
-	return &err
+	return newError(badRequestError, info, message...)
+}
+
+func ConflictError(message ...string) *CustomError {
+	return newError(conflictError, nil, message...)
+}
+
+func ConflictErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
+	return newError(conflictError, info, message...)
+}
+
+func UnprocessableEntityError(message ...string) *CustomError {
+	return newError(unprocessableEntityError, nil, message...)
+}
+
+func UnprocessableEntityErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
+	return newError(unprocessableEntityError, info, message...)
+}
+
+func ForbiddenError(message ...string) *CustomError {
+	return newError(forbiddenError, nil, message...)
+}
+
+func ForbiddenErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
+	return newError(forbiddenError, info, message...)
+}
+
+func ServiceUnavailableError(message ...string) *CustomError {
+	return newError(serviceUnavailableError, nil, message...)
+}
+
+func ServiceUnavailableErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
+	return newError(serviceUnavailableError, info, message...)
+}
+
+// TimeoutError reports that the request ran past its deadline.
+func TimeoutError() *CustomError {
+	return newError(serviceUnavailableError, nil, "request timed out")
+}
+
+func TooManyRequestsError(message ...string) *CustomError {
+	return newError(tooManyRequestsError, nil, message...)
+}
+
+func TooManyRequestsErrorWithAdditionalInfo(info interface{}, message ...string) *CustomError {
+	return newError(tooManyRequestsError, info, message...)
+}
+
+// RouteNotFoundError reports a request to a path the API does not serve.
+// Unlike NotFoundError it carries a real 404, since no resource lookup took
+// place.
+func RouteNotFoundError(message ...string) *CustomError {
+	return newError(routeNotFoundError, nil, message...)
+}
+
+func MethodNotAllowedError(message ...string) *CustomError {
+	return newError(methodNotAllowedError, nil, message...)
 }
@@ -0,0 +1,80 @@
+// Package timing collects how long a request spent in each phase, such as
+// cache lookups and database queries, so that it can be reported back in a
+// Server-Timing header. Requests without a collector record nothing.
+package timing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type contextKey struct{}
+
+// Collector adds up the time spent per phase. It is safe for concurrent use.
+type Collector struct {
+	mu     sync.Mutex
+	phases []string
+	totals map[string]time.Duration
+}
+
+func NewCollector() *Collector {
+	return &Collector{totals: make(map[string]time.Duration)}
+}
+
+// NewContext returns a copy of ctx carrying c.
+func NewContext(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the collector of ctx, or nil when there is none.
+func FromContext(ctx context.Context) *Collector {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(contextKey{}).(*Collector)
+	return c
+}
+
+// Add records d against phase on the collector of ctx, if any.
+func Add(ctx context.Context, phase string, d time.Duration) {
+	FromContext(ctx).Add(phase, d)
+}
+
+// Track starts timing phase and returns the function that stops it:
+//
+//	defer timing.Track(ctx, "cache")()
+func Track(ctx context.Context, phase string) func() {
+	c := FromContext(ctx)
+	if c == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { c.Add(phase, time.Since(start)) }
+}
+
+func (c *Collector) Add(phase string, d time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.totals[phase]; !ok {
+		c.phases = append(c.phases, phase)
+	}
+	c.totals[phase] += d
+}
+
+// ServerTiming formats the phases, in the order they were first recorded, as
+// a Server-Timing header value with durations in milliseconds.
+func (c *Collector) ServerTiming() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]string, len(c.phases))
+	for i, phase := range c.phases {
+		entries[i] = fmt.Sprintf("%s;dur=%.2f", phase, float64(c.totals[phase].Microseconds())/1000)
+	}
+	return strings.Join(entries, ", ")
+}
@@ -1,15 +1,29 @@
 package config
 
 import (
+	"context"
+	"go-digital-wallet/internal/commons/inflight"
+	"go-digital-wallet/internal/commons/response"
 	"go-digital-wallet/internal/handler"
 	"go-digital-wallet/internal/middleware"
 	"go-digital-wallet/internal/repository"
 	"go-digital-wallet/internal/router"
 	"go-digital-wallet/internal/usecase"
+	"go-digital-wallet/internal/worker"
+	"go-digital-wallet/pkg/featureflag"
+	"go-digital-wallet/pkg/fxrate"
+	"go-digital-wallet/pkg/health"
+	"go-digital-wallet/pkg/metrics"
+	"go-digital-wallet/pkg/money"
+	"go-digital-wallet/pkg/notify"
+	"go-digital-wallet/pkg/statementsig"
 	"go-digital-wallet/pkg/token"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -22,32 +36,283 @@ type BootstrapConfig struct {
 	Log       *logrus.Logger
 	Validate  *validator.Validate
 	JWTConfig *JWTConfig
+
+	DatabaseConfig *DatabaseConfig
+	// ReplicaDB serves lag-tolerant reads. Nil reads everything from DB.
+	ReplicaDB *gorm.DB
+
+	InterestConfig    *InterestConfig
+	RetentionConfig   *RetentionConfig
+	WalletConfig      *WalletConfig
+	MaintenanceConfig *MaintenanceConfig
+	RateLimitConfig   *RateLimitConfig
+	CacheWarmConfig   *CacheWarmConfig
+	ApprovalConfig    *TransferApprovalConfig
+	TimeoutConfig     *TimeoutConfig
+	ConcurrencyConfig *ConcurrencyConfig
+	AccessLogConfig   *AccessLogConfig
+	EmailChangeConfig *EmailChangeConfig
+	ReadinessConfig   *ReadinessConfig
+	StatementConfig   *StatementSigningConfig
+	// FeatureFlags switches optional features on and off. Nil uses
+	// featureflag.Defaults.
+	FeatureFlags *featureflag.Flags
+	// Notifier delivers user notifications. Nil discards them.
+	Notifier notify.Notifier
+	// WorkerCtx controls the lifetime of background workers.
+	WorkerCtx context.Context
+	// InFlight tracks money movements so shutdown can wait for them. Nil
+	// tracks nothing.
+	InFlight *inflight.Tracker
 }
 
 func Bootstrap(config *BootstrapConfig) {
-	jwtManager := token.NewTokenManager(config.JWTConfig.SecretKey, config.JWTConfig.ExpirationTime)
+	response.SetErrorHook(func(err *response.CustomError) {
+		metrics.IncError(string(err.Category), err.StatusCode)
+	})
+
+	jwtManager, err := token.NewTokenManagerWithConfig(token.Config{
+		Secret:           config.JWTConfig.SecretKey,
+		PreviousSecrets:  config.JWTConfig.PreviousSecretKeys,
+		AccessExpiry:     time.Duration(config.JWTConfig.ExpirationTime) * time.Hour,
+		RefreshExpiry:    time.Duration(config.JWTConfig.RefreshExpirationTime) * time.Hour,
+		MaxAccessExpiry:  time.Duration(config.JWTConfig.MaxExpirationTime) * time.Hour,
+		MaxRefreshExpiry: time.Duration(config.JWTConfig.MaxRefreshExpirationTime) * time.Hour,
+	})
+	if err != nil {
+		config.Log.WithError(err).Fatal("Invalid JWT configuration")
+	}
+
 	// setup repositories
-	walletRepository := repository.NewWalletRepository(config.DB, config.Log)
+	walletRepository := repository.NewWalletRepositoryWithOptions(config.DB, config.Log, repository.WalletRepositoryOptions{
+		LockTimeout: time.Duration(config.DatabaseConfig.LockTimeoutMs) * time.Millisecond,
+		Replica:     config.ReplicaDB,
+	})
 	userRepository := repository.NewUserRepository(config.DB, config.Log)
 
+	// One rounding mode for every amount the service computes, so that
+	// features do not round the same value differently.
+	var roundingName string
+	if config.WalletConfig != nil {
+		roundingName = config.WalletConfig.RoundingMode
+	}
+	rounding, err := money.ParseRoundingMode(roundingName)
+	if err != nil {
+		config.Log.WithError(err).Fatal("Invalid ROUNDING_MODE")
+	}
+
 	// setup use cases
-	walletUseCase := usecase.NewWalletUsecase(walletRepository, config.Log, config.Redis)
-	authUsecase := usecase.NewAuthUsecase(userRepository, config.Log, jwtManager)
+	walletUsecaseConfig := usecase.WalletUsecaseConfig{Flags: config.FeatureFlags, InFlight: config.InFlight, Rounding: rounding}
+	if config.WalletConfig != nil {
+		walletUsecaseConfig.AlertThreshold = config.WalletConfig.AlertThreshold
+		walletUsecaseConfig.MaxAmount = config.WalletConfig.MaxTransactionAmount
+		walletUsecaseConfig.DefaultCurrency = config.WalletConfig.DefaultCurrency
+		walletUsecaseConfig.DisplayIDPrefix = config.WalletConfig.TransactionIDPrefix
+		walletUsecaseConfig.DedupWindow = time.Duration(config.WalletConfig.DedupWindowSeconds) * time.Second
+		walletUsecaseConfig.OperationLockTTL = time.Duration(config.WalletConfig.OperationLockSeconds) * time.Second
+		walletUsecaseConfig.CacheInvalidationRetries = config.WalletConfig.CacheInvalidationRetries
+		walletUsecaseConfig.QueueFailedInvalidations = config.WalletConfig.CacheInvalidationRetrySeconds > 0
+		walletUsecaseConfig.DepositHold = time.Duration(config.WalletConfig.DepositHoldHours) * time.Hour
+		walletUsecaseConfig.WithdrawConfirmationThreshold = config.WalletConfig.WithdrawConfirmationThreshold
+		walletUsecaseConfig.WithdrawConfirmationTTL = time.Duration(config.WalletConfig.WithdrawConfirmationTTLSeconds) * time.Second
+		if config.WalletConfig.AutoDescription {
+			tmpl, err := usecase.ParseDescriptionTemplate(config.WalletConfig.DescriptionTemplate)
+			if err != nil {
+				config.Log.WithError(err).Fatal("Invalid AUTO_DESCRIPTION_TEMPLATE")
+			}
+			walletUsecaseConfig.DescriptionTemplate = tmpl
+		}
+		if config.WalletConfig.SystemWalletID != "" {
+			systemWalletID, err := uuid.Parse(config.WalletConfig.SystemWalletID)
+			if err != nil {
+				config.Log.WithError(err).Fatal("Invalid SYSTEM_WALLET_ID")
+			}
+			walletUsecaseConfig.SystemWalletID = systemWalletID
+		}
+		if config.WalletConfig.ExchangeRates != "" {
+			rates, err := fxrate.ParseRates(config.WalletConfig.ExchangeRates)
+			if err != nil {
+				config.Log.WithError(err).Fatal("Invalid EXCHANGE_RATES")
+			}
+			walletUsecaseConfig.RateProvider = fxrate.NewStatic(rates)
+		}
+		if config.WalletConfig.MinimumBalances != "" {
+			floors, err := parseCurrencyAmounts(config.WalletConfig.MinimumBalances)
+			if err != nil {
+				config.Log.WithError(err).Fatal("Invalid MINIMUM_BALANCES")
+			}
+			walletUsecaseConfig.MinimumBalances = floors
+		}
+		if config.WalletConfig.MinimumDeposits != "" {
+			minimums, err := parseCurrencyAmounts(config.WalletConfig.MinimumDeposits)
+			if err != nil {
+				config.Log.WithError(err).Fatal("Invalid MINIMUM_DEPOSITS")
+			}
+			walletUsecaseConfig.MinimumDeposits = minimums
+		}
+	}
+	if config.ApprovalConfig != nil {
+		walletUsecaseConfig.TransferRequestTTL = time.Duration(config.ApprovalConfig.ExpiryHours) * time.Hour
+		walletUsecaseConfig.TransferRequestMaxTTL = time.Duration(config.ApprovalConfig.MaxExpiryHours) * time.Hour
+	}
+	if config.StatementConfig != nil {
+		walletUsecaseConfig.StatementSigner = newStatementSigner(config.StatementConfig, config.Log)
+	}
+	if config.RetentionConfig != nil && config.FeatureFlags.RetentionEnabled() {
+		walletUsecaseConfig.TransactionRetention = time.Duration(config.RetentionConfig.Days) * 24 * time.Hour
+	}
+	walletUseCase := usecase.NewWalletUsecase(walletRepository, config.Log, config.Redis, config.Notifier, walletUsecaseConfig)
+	authUsecaseConfig := usecase.AuthUsecaseConfig{}
+	if config.EmailChangeConfig != nil {
+		authUsecaseConfig.EmailVerificationURL = config.EmailChangeConfig.VerificationURL
+		authUsecaseConfig.EmailChangeTTL = time.Duration(config.EmailChangeConfig.TTLHours) * time.Hour
+	}
+	authUsecase := usecase.NewAuthUsecase(userRepository, config.Log, jwtManager, config.Notifier, authUsecaseConfig)
+	maintenanceUsecase := usecase.NewMaintenanceUsecase(config.Redis, config.Log, config.MaintenanceConfig.Enabled)
+	adminUsecase := usecase.NewAdminUsecase(userRepository, config.Log)
+	dataExportUsecase := usecase.NewDataExportUsecase(userRepository, walletRepository, config.Log, walletUsecaseConfig.DisplayIDPrefix)
 
 	// setup handlers
-	walletHandler := handler.NewWalletHandler(walletUseCase, config.Log, config.Validate)
-	authHandler := handler.NewAuthHandler(authUsecase, config.Log, config.Validate)
+	walletHandlerOptions := handler.WalletHandlerOptions{Rounding: rounding}
+	if config.WalletConfig != nil {
+		walletHandlerOptions.LenientAmounts = config.WalletConfig.LenientAmounts
+	}
+	walletHandler := handler.NewWalletHandlerWithOptions(walletUseCase, config.Log, config.Validate, walletHandlerOptions)
+	overviewUsecase := usecase.NewOverviewUsecase(userRepository, walletRepository, config.Log)
+	authHandler := handler.NewAuthHandler(authUsecase, dataExportUsecase, overviewUsecase, config.Log, config.Validate)
+	adminHandler := handler.NewAdminHandler(maintenanceUsecase, adminUsecase, config.Log, config.Validate)
 
 	// setup middleware
 	authMiddleware := middleware.NewAuthMiddleware(config.JWTConfig.SecretKey, config.Log, jwtManager)
+	if config.JWTConfig.CookieAuthEnabled {
+		authMiddleware = middleware.NewAuthMiddlewareWithCookie(config.JWTConfig.SecretKey, config.Log, jwtManager, config.JWTConfig.CookieName)
+	}
 	LoggerMiddleware := middleware.LoggerMiddleware(config.Log)
+	if config.AccessLogConfig != nil {
+		LoggerMiddleware = middleware.SampledLoggerMiddleware(config.Log, config.AccessLogConfig.SampleEvery, time.Duration(config.AccessLogConfig.SlowMs)*time.Millisecond)
+	}
+	maintenanceMiddleware := middleware.NewMaintenanceMiddleware(maintenanceUsecase, config.Log, config.MaintenanceConfig.RetryAfterSeconds)
+	rateLimiter := middleware.NewRateLimiter(config.Redis, config.Log)
+	var idempotencyTTL time.Duration
+	if config.WalletConfig != nil {
+		idempotencyTTL = time.Duration(config.WalletConfig.IdempotencyTTLHours) * time.Hour
+	}
 
 	routeConfig := router.RouteConfig{
 		App:              config.App,
 		WalletHandler:    walletHandler,
 		AuthHandler:      authHandler,
+		AdminHandler:     adminHandler,
 		AuthMiddleware:   authMiddleware,
 		LoggerMiddleware: LoggerMiddleware,
+
+		MaintenanceMiddleware: maintenanceMiddleware,
+		RequestIDMiddleware:   middleware.RequestIDMiddleware(),
+		RecoveryMiddleware:    middleware.RecoveryMiddleware(config.Log),
+		RateLimiter:           rateLimiter,
+		IdempotencyMiddleware: middleware.NewIdempotencyMiddleware(config.Redis, config.Log, idempotencyTTL),
+		ReadinessHandler:      handler.Readiness(newReadinessChecker(config)),
+	}
+	var concurrency ConcurrencyConfig
+	if config.ConcurrencyConfig != nil {
+		concurrency = *config.ConcurrencyConfig
+	}
+	routeConfig.ConcurrencyMiddleware = middleware.ConcurrencyLimit(concurrency.MaxInFlight, time.Duration(concurrency.QueueTimeoutMs)*time.Millisecond, concurrency.RetryAfterSeconds, config.Log)
+	if config.RateLimitConfig != nil {
+		routeConfig.AdminSearchPerMinute = config.RateLimitConfig.AdminSearchPerMinute
+		routeConfig.AdminTransactionsPerMinute = config.RateLimitConfig.AdminTransactionsPerMinute
+		routeConfig.DataExportPerHour = config.RateLimitConfig.DataExportPerHour
+		routeConfig.EmailChangePerHour = config.RateLimitConfig.EmailChangePerHour
+		routeConfig.CacheBypassPerMinute = config.RateLimitConfig.CacheBypassPerMinute
+		routeConfig.StatementVerifyPerMinute = config.RateLimitConfig.StatementVerifyPerMinute
+	}
+	if config.TimeoutConfig != nil {
+		routeConfig.DefaultTimeout = time.Duration(config.TimeoutConfig.DefaultMs) * time.Millisecond
+		routeConfig.BalanceTimeout = time.Duration(config.TimeoutConfig.BalanceMs) * time.Millisecond
+		routeConfig.ExportTimeout = time.Duration(config.TimeoutConfig.ExportMs) * time.Millisecond
 	}
 	routeConfig.SetupRoute()
+
+	// setup background workers
+	if config.InterestConfig != nil && config.FeatureFlags.InterestEnabled() {
+		interestUsecase := usecase.NewInterestUsecase(walletRepository, config.Log, config.Redis, config.InterestConfig.DefaultRate, config.InterestConfig.DaysInYear, rounding)
+		interestWorker, err := worker.NewInterestWorker(interestUsecase, config.Log, config.InterestConfig.RunAt)
+		if err != nil {
+			config.Log.WithError(err).Fatal("Failed to setup interest worker")
+		}
+		interestWorker.Start(config.WorkerCtx)
+	}
+
+	if config.RetentionConfig != nil && config.FeatureFlags.RetentionEnabled() {
+		retentionUsecase := usecase.NewRetentionUsecase(walletRepository, config.Log, walletUsecaseConfig.TransactionRetention, config.RetentionConfig.BatchSize)
+		retentionWorker := worker.NewRetentionWorker(retentionUsecase, config.Log, time.Duration(config.RetentionConfig.IntervalHours)*time.Hour)
+		retentionWorker.Start(config.WorkerCtx)
+	}
+
+	if config.ApprovalConfig != nil && config.ApprovalConfig.CheckIntervalMinutes > 0 {
+		expiryWorker := worker.NewTransferRequestWorker(walletUseCase, config.Log, time.Duration(config.ApprovalConfig.CheckIntervalMinutes)*time.Minute)
+		expiryWorker.Start(config.WorkerCtx)
+	}
+
+	if walletUsecaseConfig.QueueFailedInvalidations {
+		invalidationWorker := worker.NewCacheInvalidationWorker(walletUseCase, config.Log, time.Duration(config.WalletConfig.CacheInvalidationRetrySeconds)*time.Second)
+		invalidationWorker.Start(config.WorkerCtx)
+	}
+
+	if config.CacheWarmConfig != nil && len(config.CacheWarmConfig.UserIDs) > 0 {
+		cacheWarmer, err := worker.NewCacheWarmer(walletUseCase, config.Log, config.CacheWarmConfig.UserIDs)
+		if err != nil {
+			config.Log.WithError(err).Fatal("Failed to setup cache warmer")
+		}
+		cacheWarmer.Start(config.WorkerCtx)
+	}
+}
+
+// newStatementSigner returns the signer for the configured key, or nil when
+// none is configured.
+func newStatementSigner(config *StatementSigningConfig, log *logrus.Logger) statementsig.Signer {
+	if config.RSAKeyFile != "" {
+		data, err := os.ReadFile(config.RSAKeyFile)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to read STATEMENT_SIGNING_RSA_KEY_FILE")
+		}
+		key, err := statementsig.ParseRSAPrivateKey(data)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid STATEMENT_SIGNING_RSA_KEY_FILE")
+		}
+		return statementsig.NewRSA(key)
+	}
+	if config.HMACKey != "" {
+		return statementsig.NewHMAC([]byte(config.HMACKey))
+	}
+	return nil
+}
+
+// newReadinessChecker checks that the database and Redis answer.
+func newReadinessChecker(config *BootstrapConfig) *health.Checker {
+	var readiness ReadinessConfig
+	if config.ReadinessConfig != nil {
+		readiness = *config.ReadinessConfig
+	}
+
+	checks := map[string]health.Check{
+		"database": func(ctx context.Context) error {
+			sqlDB, err := config.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	}
+	if config.Redis != nil {
+		checks["redis"] = func(ctx context.Context) error {
+			return config.Redis.Ping(ctx).Err()
+		}
+	}
+
+	return health.NewChecker(health.Config{
+		SuccessThreshold: readiness.SuccessThreshold,
+		FailureThreshold: readiness.FailureThreshold,
+		Timeout:          time.Duration(readiness.CheckTimeoutMs) * time.Millisecond,
+	}, checks)
 }
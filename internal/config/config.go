@@ -1,21 +1,49 @@
 package config
 
 import (
+	"fmt"
+	"go-digital-wallet/pkg/featureflag"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Redis    RedisConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Redis       RedisConfig
+	Interest    InterestConfig
+	Retention   RetentionConfig
+	Wallet      WalletConfig
+	Maintenance MaintenanceConfig
+	Notifier    NotifierConfig
+	EmailChange EmailChangeConfig
+	Password    PasswordPolicyConfig
+	Description DescriptionPolicyConfig
+	RateLimit   RateLimitConfig
+	CacheWarm   CacheWarmConfig
+	Approval    TransferApprovalConfig
+	Timeout     TimeoutConfig
+	Concurrency ConcurrencyConfig
+	AccessLog   AccessLogConfig
+	Readiness   ReadinessConfig
+	Statement   StatementSigningConfig
+	// FeatureFlags switches optional features on and off.
+	FeatureFlags featureflag.Flags
 }
 
 type ServerConfig struct {
 	Port         string
 	ReadTimeout  int
 	WriteTimeout int
+	// DependencyTimeout is how many seconds startup waits for the database
+	// and Redis before giving up.
+	DependencyTimeout int
+	// ShutdownTimeout is how many seconds shutdown waits for requests and
+	// money movements in flight to finish before exiting anyway.
+	ShutdownTimeout int
 }
 
 type DatabaseConfig struct {
@@ -25,6 +53,21 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// SlowQueryMs is the duration above which queries are logged as slow.
+	// Zero disables slow query logging.
+	SlowQueryMs int
+	// LockTimeoutMs bounds how long a locking read waits for a wallet row
+	// held by another transaction. Zero waits indefinitely.
+	LockTimeoutMs int
+	// ReplicaDSN points balance and transaction history reads at a read
+	// replica. Empty reads everything from the primary.
+	ReplicaDSN string
+	// MigrationRetries is how many more times the migration connection
+	// pings a database that is not ready yet before failing.
+	MigrationRetries int
+	// MigrationRetryIntervalMs is the wait before the first retry; it
+	// doubles with each retry after that, up to 5 seconds.
+	MigrationRetryIntervalMs int
 }
 
 type RedisConfig struct {
@@ -35,8 +78,226 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey      string
-	ExpirationTime int // in hours
+	SecretKey                string
+	ExpirationTime           int // in hours
+	RefreshExpirationTime    int // in hours
+	MaxExpirationTime        int // in hours
+	MaxRefreshExpirationTime int // in hours
+	// PreviousSecretKeys still validate tokens during a secret rotation.
+	PreviousSecretKeys []string
+	// CookieAuthEnabled lets clients send the access token in the CookieName
+	// cookie when they cannot set the Authorization header.
+	CookieAuthEnabled bool
+	CookieName        string
+}
+
+type InterestConfig struct {
+	DefaultRate float64 // annual rate applied to wallets without their own rate
+	RunAt       string  // daily accrual time in UTC, HH:MM
+	DaysInYear  int
+}
+
+type RetentionConfig struct {
+	Days          int // transactions older than this are archived
+	IntervalHours int
+	BatchSize     int
+}
+
+type MaintenanceConfig struct {
+	Enabled           bool
+	RetryAfterSeconds int
+}
+
+// ConcurrencyConfig bounds the number of API requests processed at once.
+type ConcurrencyConfig struct {
+	// MaxInFlight is the number of requests processed concurrently. Zero
+	// disables the limit.
+	MaxInFlight int
+	// QueueTimeoutMs is how long a request waits for a free slot. Zero
+	// rejects it immediately when all slots are taken.
+	QueueTimeoutMs    int
+	RetryAfterSeconds int
+}
+
+// TimeoutConfig sets request deadlines in milliseconds. Zero disables a
+// deadline.
+// AccessLogConfig thins out the per-request access log. Errors are always
+// logged.
+type AccessLogConfig struct {
+	// SampleEvery logs one in this many successful requests. 1 logs all.
+	SampleEvery int
+	// SlowMs is the latency above which a request is always logged. Zero
+	// disables it.
+	SlowMs int
+}
+
+// ReadinessConfig debounces the readiness probe, so that dependency blips
+// during a reconnect do not churn pods. Thresholds of 1 report every probe
+// as it is.
+type ReadinessConfig struct {
+	// SuccessThreshold is how many passing probes in a row it takes to
+	// report ready, at startup and after having reported not ready.
+	SuccessThreshold int
+	// FailureThreshold is how many failing probes in a row it takes to
+	// report not ready once ready.
+	FailureThreshold int
+	// CheckTimeoutMs bounds the dependency checks of one probe.
+	CheckTimeoutMs int
+}
+
+type TimeoutConfig struct {
+	DefaultMs int // applied to every API route without its own deadline
+	BalanceMs int
+	ExportMs  int
+}
+
+// TransferApprovalConfig controls transfer requests awaiting a second
+// approver.
+type TransferApprovalConfig struct {
+	ExpiryHours int // pending requests older than this are expired
+	// MaxExpiryHours is the longest approval window a request may ask for
+	// instead of ExpiryHours.
+	MaxExpiryHours int
+	// CheckIntervalMinutes is how often expired requests are released.
+	CheckIntervalMinutes int
+}
+
+// CacheWarmConfig lists the users whose wallet caches are populated at
+// startup. An empty list disables warming.
+type CacheWarmConfig struct {
+	UserIDs []string
+}
+
+// DescriptionPolicyConfig makes transaction descriptions mandatory per
+// operation, e.g. for bookkeeping, and restricts the characters deposit and
+// withdrawal descriptions may use, for payment rails that reject others.
+// Everything is optional and any character allowed by default.
+type DescriptionPolicyConfig struct {
+	RequireWithdraw bool
+	RequireDeposit  bool
+	// ASCIIOnly rejects descriptions with characters outside printable
+	// ASCII, such as accented letters, emoji or line breaks.
+	ASCIIOnly bool
+	// Pattern is a regular expression the whole description must match,
+	// e.g. `[A-Za-z0-9 .,/-]*`. Empty allows any description.
+	Pattern string
+}
+
+// RateLimitConfig caps how often callers may hit expensive endpoints. Zero
+// disables a limit.
+type RateLimitConfig struct {
+	AdminSearchPerMinute int
+	// AdminTransactionsPerMinute caps admin listings of all transactions,
+	// which scan the whole transactions table.
+	AdminTransactionsPerMinute int
+	DataExportPerHour          int
+	// EmailChangePerHour caps email change requests, each of which sends a
+	// verification email.
+	EmailChangePerHour int
+	// CacheBypassPerMinute caps transaction history requests sent with
+	// Cache-Control: no-cache.
+	CacheBypassPerMinute int
+	// StatementVerifyPerMinute caps statement verifications per client IP.
+	StatementVerifyPerMinute int
+}
+
+// StatementSigningConfig sets the key statements exported with sign=true are
+// signed with. RSAKeyFile takes precedence over HMACKey; with neither,
+// statements cannot be signed.
+type StatementSigningConfig struct {
+	// HMACKey signs with HMAC-SHA256, which only this service can verify.
+	HMACKey string
+	// RSAKeyFile is a PEM RSA private key to sign with RSA-SHA256, which
+	// anyone with the public key can verify.
+	RSAKeyFile string
+}
+
+// PasswordPolicyConfig sets the rules new passwords must follow. The default
+// only requires six characters.
+type PasswordPolicyConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+type NotifierConfig struct {
+	// Driver selects the notifier implementation: "noop" or "log".
+	Driver string
+}
+
+type EmailChangeConfig struct {
+	// VerificationURL is the page the link sent to a new email opens. The
+	// token is appended as the "token" query parameter.
+	VerificationURL string
+	// TTLHours is how long the link stays valid.
+	TTLHours int
+}
+
+type WalletConfig struct {
+	IdempotencyTTLHours int
+	// AlertThreshold is the default amount above which a deposit or
+	// withdrawal triggers a user alert. Zero disables the default alert.
+	AlertThreshold float64
+	// MaxTransactionAmount caps a single deposit, withdrawal or transfer.
+	// Zero uses the largest amount the database column can store.
+	MaxTransactionAmount float64
+	// DefaultCurrency is applied to new wallets created without a currency.
+	// Empty keeps the currency required.
+	DefaultCurrency string
+	// TransactionIDPrefix starts the short display ids of transactions.
+	TransactionIDPrefix string
+	// SystemWalletID is the wallet deposits and withdrawals are balanced
+	// against. Empty books them against no counterparty.
+	SystemWalletID string
+	// DedupWindowSeconds rejects an identical deposit, withdrawal or transfer
+	// without an Idempotency-Key sent again within this many seconds. Zero
+	// disables it.
+	DedupWindowSeconds int
+	// OperationLockSeconds rejects a deposit, withdrawal or transfer while
+	// another one of the same user is in flight, and is how long the lock
+	// outlives a crashed request. Zero disables it.
+	OperationLockSeconds int
+	// CacheInvalidationRetries is how many more times a failed invalidation
+	// of the transaction history cache is tried within the request.
+	CacheInvalidationRetries int
+	// CacheInvalidationRetrySeconds is how often invalidations that still
+	// failed are retried in the background. Zero disables the background
+	// retries.
+	CacheInvalidationRetrySeconds int
+	// DepositHoldHours is how long deposited funds stay on hold before they
+	// can be withdrawn or transferred. Zero makes deposits available at once.
+	DepositHoldHours int
+	// WithdrawConfirmationThreshold is the amount from which a withdrawal
+	// must be initiated and then confirmed. Zero disables confirmation.
+	WithdrawConfirmationThreshold float64
+	// WithdrawConfirmationTTLSeconds is how long a confirmation token stays
+	// valid.
+	WithdrawConfirmationTTLSeconds int
+	// LenientAmounts rounds request amounts with more than two decimal places
+	// to the cent instead of rejecting them.
+	LenientAmounts bool
+	// AutoDescription generates a description from DescriptionTemplate for
+	// deposits and withdrawals sent without one. Off stores it empty.
+	AutoDescription bool
+	// DescriptionTemplate is a Go text/template over .Operation, .Amount,
+	// .Currency and .Date. Empty uses "{{.Operation}} of {{.Currency}}
+	// {{.Amount}} on {{.Date}}".
+	DescriptionTemplate string
+	// RoundingMode is how computed amounts, such as converted amounts and
+	// interest, are rounded to the cent: half_up, half_even or floor.
+	RoundingMode string
+	// ExchangeRates are the fixed rates transfers between currencies are
+	// converted at, as "USD/IDR=16000,EUR/IDR=17500". Empty rejects such
+	// transfers.
+	ExchangeRates string
+	// MinimumBalances is the balance withdrawals must leave in wallets of a
+	// currency, as "IDR=10000,USD=5". Currencies not listed have no floor.
+	MinimumBalances string
+	// MinimumDeposits is the smallest deposit accepted in wallets of a
+	// currency, as "IDR=10000,USD=1". Currencies not listed have no minimum.
+	MinimumDeposits string
 }
 
 func LoadConfig() *Config {
@@ -45,6 +306,9 @@ func LoadConfig() *Config {
 			Port:         getEnv("APP_PORT", "8080"),
 			ReadTimeout:  getEnvInt("SERVER_READ_TIMEOUT", 30),
 			WriteTimeout: getEnvInt("SERVER_WRITE_TIMEOUT", 30),
+
+			DependencyTimeout: getEnvInt("STARTUP_DEPENDENCY_TIMEOUT", 60),
+			ShutdownTimeout:   getEnvInt("SHUTDOWN_TIMEOUT", 30),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "db"),
@@ -53,10 +317,23 @@ func LoadConfig() *Config {
 			Password: getEnv("DB_PASSWORD", "password"),
 			DBName:   getEnv("DB_NAME", "digital_wallet"),
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+
+			SlowQueryMs:   getEnvInt("DB_SLOW_QUERY_MS", 200),
+			LockTimeoutMs: getEnvInt("DB_LOCK_TIMEOUT_MS", 5000),
+			ReplicaDSN:    getEnv("DB_REPLICA_DSN", ""),
+
+			MigrationRetries:         getEnvInt("DB_MIGRATION_RETRIES", 5),
+			MigrationRetryIntervalMs: getEnvInt("DB_MIGRATION_RETRY_INTERVAL_MS", 500),
 		},
 		JWT: JWTConfig{
-			SecretKey:      getEnv("JWT_SECRET", "your-secret-key"),
-			ExpirationTime: getEnvInt("JWT_EXPIRY", 24),
+			SecretKey:                getEnv("JWT_SECRET", "your-secret-key"),
+			PreviousSecretKeys:       getEnvList("JWT_PREVIOUS_SECRETS"),
+			ExpirationTime:           getEnvInt("JWT_EXPIRY", 24),
+			RefreshExpirationTime:    getEnvInt("JWT_REFRESH_EXPIRY", 168),
+			MaxExpirationTime:        getEnvInt("JWT_MAX_EXPIRY", 168),
+			MaxRefreshExpirationTime: getEnvInt("JWT_MAX_REFRESH_EXPIRY", 720),
+			CookieAuthEnabled:        getEnvBool("JWT_COOKIE_AUTH_ENABLED", false),
+			CookieName:               getEnv("JWT_COOKIE_NAME", "access_token"),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "redis"),
@@ -64,6 +341,109 @@ func LoadConfig() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvInt("REDIS_DB", 0),
 		},
+		Interest: InterestConfig{
+			DefaultRate: getEnvFloat("INTEREST_DEFAULT_RATE", 0),
+			RunAt:       getEnv("INTEREST_RUN_AT", "00:05"),
+			DaysInYear:  getEnvInt("INTEREST_DAYS_IN_YEAR", 365),
+		},
+		Retention: RetentionConfig{
+			Days:          getEnvInt("TRANSACTION_RETENTION_DAYS", 365),
+			IntervalHours: getEnvInt("TRANSACTION_RETENTION_INTERVAL_HOURS", 24),
+			BatchSize:     getEnvInt("TRANSACTION_RETENTION_BATCH_SIZE", 1000),
+		},
+		Wallet: WalletConfig{
+			IdempotencyTTLHours:           getEnvInt("IDEMPOTENCY_TTL_HOURS", 24),
+			AlertThreshold:                getEnvFloat("BALANCE_ALERT_THRESHOLD", 0),
+			MaxTransactionAmount:          getEnvFloat("MAX_TRANSACTION_AMOUNT", 0),
+			DefaultCurrency:               strings.ToUpper(getEnv("DEFAULT_CURRENCY", "")),
+			TransactionIDPrefix:           getEnv("TRANSACTION_ID_PREFIX", "TXN"),
+			SystemWalletID:                getEnv("SYSTEM_WALLET_ID", ""),
+			DedupWindowSeconds:            getEnvInt("DUPLICATE_REQUEST_WINDOW_SECONDS", 0),
+			OperationLockSeconds:          getEnvInt("OPERATION_LOCK_SECONDS", 0),
+			CacheInvalidationRetries:      getEnvInt("CACHE_INVALIDATION_RETRIES", 3),
+			CacheInvalidationRetrySeconds: getEnvInt("CACHE_INVALIDATION_RETRY_INTERVAL_SECONDS", 30),
+			DepositHoldHours:              getEnvInt("DEPOSIT_HOLD_HOURS", 0),
+
+			WithdrawConfirmationThreshold:  getEnvFloat("WITHDRAW_CONFIRMATION_THRESHOLD", 0),
+			WithdrawConfirmationTTLSeconds: getEnvInt("WITHDRAW_CONFIRMATION_TTL_SECONDS", 120),
+			LenientAmounts:                 getEnvBool("LENIENT_AMOUNTS", false),
+			RoundingMode:                   getEnv("ROUNDING_MODE", "half_up"),
+			AutoDescription:                getEnvBool("AUTO_DESCRIPTION", false),
+			DescriptionTemplate:            getEnv("AUTO_DESCRIPTION_TEMPLATE", ""),
+			ExchangeRates:                  getEnv("EXCHANGE_RATES", ""),
+			MinimumBalances:                getEnv("MINIMUM_BALANCES", ""),
+			MinimumDeposits:                getEnv("MINIMUM_DEPOSITS", ""),
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:           getEnvBool("MAINTENANCE_MODE", false),
+			RetryAfterSeconds: getEnvInt("MAINTENANCE_RETRY_AFTER", 120),
+		},
+		Notifier: NotifierConfig{
+			Driver: getEnv("NOTIFIER_DRIVER", "noop"),
+		},
+		EmailChange: EmailChangeConfig{
+			VerificationURL: getEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+			TTLHours:        getEnvInt("EMAIL_CHANGE_TTL_HOURS", 24),
+		},
+		Password: PasswordPolicyConfig{
+			MinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 6),
+			RequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+			RequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+			RequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+			RequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		},
+		Description: DescriptionPolicyConfig{
+			RequireWithdraw: getEnvBool("REQUIRE_WITHDRAW_DESCRIPTION", false),
+			RequireDeposit:  getEnvBool("REQUIRE_DEPOSIT_DESCRIPTION", false),
+			ASCIIOnly:       getEnvBool("DESCRIPTION_ASCII_ONLY", false),
+			Pattern:         getEnv("DESCRIPTION_PATTERN", ""),
+		},
+		RateLimit: RateLimitConfig{
+			AdminSearchPerMinute:       getEnvInt("RATE_LIMIT_ADMIN_SEARCH_PER_MINUTE", 30),
+			AdminTransactionsPerMinute: getEnvInt("RATE_LIMIT_ADMIN_TRANSACTIONS_PER_MINUTE", 10),
+			DataExportPerHour:          getEnvInt("RATE_LIMIT_DATA_EXPORT_PER_HOUR", 3),
+			EmailChangePerHour:         getEnvInt("RATE_LIMIT_EMAIL_CHANGE_PER_HOUR", 5),
+			CacheBypassPerMinute:       getEnvInt("RATE_LIMIT_CACHE_BYPASS_PER_MINUTE", 10),
+			StatementVerifyPerMinute:   getEnvInt("RATE_LIMIT_STATEMENT_VERIFY_PER_MINUTE", 30),
+		},
+		CacheWarm: CacheWarmConfig{
+			UserIDs: getEnvList("CACHE_WARM_USER_IDS"),
+		},
+		Timeout: TimeoutConfig{
+			DefaultMs: getEnvInt("REQUEST_TIMEOUT_MS", 10000),
+			BalanceMs: getEnvInt("REQUEST_TIMEOUT_BALANCE_MS", 2000),
+			ExportMs:  getEnvInt("REQUEST_TIMEOUT_EXPORT_MS", 30000),
+		},
+		Concurrency: ConcurrencyConfig{
+			MaxInFlight:       getEnvInt("MAX_IN_FLIGHT_REQUESTS", 0),
+			QueueTimeoutMs:    getEnvInt("IN_FLIGHT_QUEUE_TIMEOUT_MS", 0),
+			RetryAfterSeconds: getEnvInt("IN_FLIGHT_RETRY_AFTER_SECONDS", 1),
+		},
+		AccessLog: AccessLogConfig{
+			SampleEvery: getEnvInt("ACCESS_LOG_SAMPLE_EVERY", 1),
+			SlowMs:      getEnvInt("ACCESS_LOG_SLOW_MS", 1000),
+		},
+		Readiness: ReadinessConfig{
+			SuccessThreshold: getEnvInt("READINESS_SUCCESS_THRESHOLD", 1),
+			FailureThreshold: getEnvInt("READINESS_FAILURE_THRESHOLD", 1),
+			CheckTimeoutMs:   getEnvInt("READINESS_CHECK_TIMEOUT_MS", 2000),
+		},
+		Statement: StatementSigningConfig{
+			HMACKey:    getEnv("STATEMENT_SIGNING_KEY", ""),
+			RSAKeyFile: getEnv("STATEMENT_SIGNING_RSA_KEY_FILE", ""),
+		},
+		Approval: TransferApprovalConfig{
+			ExpiryHours:          getEnvInt("TRANSFER_REQUEST_EXPIRY_HOURS", 24),
+			MaxExpiryHours:       getEnvInt("TRANSFER_REQUEST_MAX_EXPIRY_HOURS", 168),
+			CheckIntervalMinutes: getEnvInt("TRANSFER_REQUEST_CHECK_INTERVAL_MINUTES", 5),
+		},
+		FeatureFlags: featureflag.Flags{
+			HistoryCache: getEnvBool("FEATURE_HISTORY_CACHE", true),
+			Interest:     getEnvBool("INTEREST_ENABLED", false),
+			Retention:    getEnvBool("TRANSACTION_RETENTION_ENABLED", false),
+
+			AutoCreateWallet: getEnvBool("FEATURE_AUTO_CREATE_WALLET", false),
+		},
 	}
 }
 
@@ -74,6 +454,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -82,3 +473,45 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// parseCurrencyAmounts reads amounts per currency written as
+// "IDR=10000,USD=5".
+func parseCurrencyAmounts(spec string) (map[string]float64, error) {
+	amounts := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		currency, value, ok := strings.Cut(entry, "=")
+		currency = strings.TrimSpace(currency)
+		if !ok || len(currency) != 3 {
+			return nil, fmt.Errorf("invalid entry %q, want CURRENCY=amount", entry)
+		}
+		amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || !(amount >= 0) || math.IsInf(amount, 0) {
+			return nil, fmt.Errorf("invalid entry %q, amount must be a non-negative number", entry)
+		}
+		amounts[strings.ToUpper(currency)] = amount
+	}
+	return amounts, nil
+}
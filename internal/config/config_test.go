@@ -0,0 +1,21 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCurrencyAmounts(t *testing.T) {
+	amounts, err := parseCurrencyAmounts(" idr=10000, USD=5.5,")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"IDR": 10000, "USD": 5.5}, amounts)
+}
+
+func TestParseCurrencyAmounts_RejectsInvalidEntries(t *testing.T) {
+	for _, spec := range []string{"IDR", "RUPIAH=10", "IDR=abc", "IDR=-1", "IDR=NaN"} {
+		_, err := parseCurrencyAmounts(spec)
+		assert.Error(t, err, spec)
+	}
+}
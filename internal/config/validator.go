@@ -1,9 +1,103 @@
 package config
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
 	"github.com/go-playground/validator/v10"
 )
 
-func NewValidator() *validator.Validate {
-	return validator.New()
+// NewValidator builds the request validator. The `password` tag is an alias
+// expanded from policy, so a failing field reports the exact rule it broke
+// (min, password_upper, password_lower, password_digit or password_symbol).
+// Likewise `withdraw_description` and `deposit_description` expand to a
+// required rule only when descriptions expects one for that operation, and
+// to the description_charset rule only when it restricts characters.
+//
+// descriptions.Pattern must have passed Validate; NewValidator panics on an
+// invalid pattern.
+func NewValidator(policy PasswordPolicyConfig, descriptions DescriptionPolicyConfig) *validator.Validate {
+	v := validator.New()
+
+	v.RegisterValidation("password_upper", containsRune(unicode.IsUpper))
+	v.RegisterValidation("password_lower", containsRune(unicode.IsLower))
+	v.RegisterValidation("password_digit", containsRune(unicode.IsDigit))
+	v.RegisterValidation("password_symbol", containsRune(func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}))
+
+	rules := []string{fmt.Sprintf("min=%d", max(policy.MinLength, 1))}
+	if policy.RequireUpper {
+		rules = append(rules, "password_upper")
+	}
+	if policy.RequireLower {
+		rules = append(rules, "password_lower")
+	}
+	if policy.RequireDigit {
+		rules = append(rules, "password_digit")
+	}
+	if policy.RequireSymbol {
+		rules = append(rules, "password_symbol")
+	}
+	v.RegisterAlias("password", strings.Join(rules, ","))
+
+	charset := descriptions.ASCIIOnly || descriptions.Pattern != ""
+	if charset {
+		v.RegisterValidation("description_charset", descriptionCharset(descriptions))
+	}
+	v.RegisterAlias("withdraw_description", descriptionRules(descriptions.RequireWithdraw, charset))
+	v.RegisterAlias("deposit_description", descriptionRules(descriptions.RequireDeposit, charset))
+
+	return v
+}
+
+// Validate reports whether Pattern is a valid regular expression.
+func (c DescriptionPolicyConfig) Validate() error {
+	_, err := c.pattern()
+	return err
+}
+
+func (c DescriptionPolicyConfig) pattern() (*regexp.Regexp, error) {
+	if c.Pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(`^(?:` + c.Pattern + `)$`)
+}
+
+func descriptionRules(required, charset bool) string {
+	rules := "max=500"
+	if required {
+		rules = "required," + rules
+	}
+	if charset {
+		rules += ",description_charset"
+	}
+	return rules
+}
+
+// descriptionCharset accepts a description made only of the characters the
+// policy allows. An empty description is left to the required rule.
+func descriptionCharset(policy DescriptionPolicyConfig) validator.Func {
+	pattern, err := policy.pattern()
+	if err != nil {
+		panic(fmt.Sprintf("invalid description pattern: %v", err))
+	}
+	return func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		if s == "" {
+			return true
+		}
+		if policy.ASCIIOnly && strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r > 0x7e }) >= 0 {
+			return false
+		}
+		return pattern == nil || pattern.MatchString(s)
+	}
+}
+
+func containsRune(match func(rune) bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return strings.IndexFunc(fl.Field().String(), match) >= 0
+	}
 }
@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"go-digital-wallet/internal/config"
+	"go-digital-wallet/internal/params"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func registerRequest(password string) *params.RegisterRequest {
+	return &params.RegisterRequest{Name: "Alice", Email: "alice@example.com", Password: password}
+}
+
+func failedRule(t *testing.T, err error) string {
+	t.Helper()
+	errs, ok := err.(validator.ValidationErrors)
+	if !assert.True(t, ok) || !assert.Len(t, errs, 1) {
+		return ""
+	}
+	return errs[0].ActualTag()
+}
+
+func TestNewValidator_DefaultPolicyKeepsMinSix(t *testing.T) {
+	v := config.NewValidator(config.PasswordPolicyConfig{MinLength: 6}, config.DescriptionPolicyConfig{})
+
+	assert.NoError(t, v.Struct(registerRequest("secret")))
+	assert.Equal(t, "min", failedRule(t, v.Struct(registerRequest("short"))))
+}
+
+func TestNewValidator_StrictPolicyReportsFailingRule(t *testing.T) {
+	v := config.NewValidator(config.PasswordPolicyConfig{
+		MinLength:     8,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}, config.DescriptionPolicyConfig{})
+
+	assert.Equal(t, "min", failedRule(t, v.Struct(registerRequest("Ab1!"))))
+	assert.Equal(t, "password_upper", failedRule(t, v.Struct(registerRequest("abcdef1!"))))
+	assert.Equal(t, "password_lower", failedRule(t, v.Struct(registerRequest("ABCDEF1!"))))
+	assert.Equal(t, "password_digit", failedRule(t, v.Struct(registerRequest("Abcdefg!"))))
+	assert.Equal(t, "password_symbol", failedRule(t, v.Struct(registerRequest("Abcdefg1"))))
+	assert.NoError(t, v.Struct(registerRequest("Abcdef1!")))
+}
+
+func TestNewValidator_DescriptionOptionalByDefault(t *testing.T) {
+	v := config.NewValidator(config.PasswordPolicyConfig{MinLength: 6}, config.DescriptionPolicyConfig{})
+
+	assert.NoError(t, v.Struct(&params.WithdrawRequest{Amount: 100}))
+	assert.NoError(t, v.Struct(&params.DepositRequest{Amount: 100}))
+}
+
+func TestNewValidator_RequiredWithdrawDescription(t *testing.T) {
+	v := config.NewValidator(config.PasswordPolicyConfig{MinLength: 6}, config.DescriptionPolicyConfig{RequireWithdraw: true})
+
+	err := v.Struct(&params.WithdrawRequest{Amount: 100})
+	assert.Equal(t, "required", failedRule(t, err))
+	assert.Equal(t, "Description", err.(validator.ValidationErrors)[0].Field())
+	assert.NoError(t, v.Struct(&params.WithdrawRequest{Amount: 100, Description: "office supplies"}))
+	assert.NoError(t, v.Struct(&params.DepositRequest{Amount: 100}))
+}
+
+func TestNewValidator_DescriptionStillLimitedWhenRequired(t *testing.T) {
+	v := config.NewValidator(config.PasswordPolicyConfig{MinLength: 6}, config.DescriptionPolicyConfig{RequireDeposit: true})
+
+	long := strings.Repeat("a", 501)
+	assert.Equal(t, "max", failedRule(t, v.Struct(&params.DepositRequest{Amount: 100, Description: long})))
+}
+
+func TestNewValidator_DescriptionCharsetPermissiveByDefault(t *testing.T) {
+	v := config.NewValidator(config.PasswordPolicyConfig{MinLength: 6}, config.DescriptionPolicyConfig{})
+
+	assert.NoError(t, v.Struct(&params.DepositRequest{Amount: 100, Description: "café ☕"}))
+}
+
+func TestNewValidator_DescriptionASCIIOnly(t *testing.T) {
+	v := config.NewValidator(config.PasswordPolicyConfig{MinLength: 6}, config.DescriptionPolicyConfig{ASCIIOnly: true})
+
+	err := v.Struct(&params.WithdrawRequest{Amount: 100, Description: "coffee ☕"})
+	assert.Equal(t, "description_charset", failedRule(t, err))
+	assert.Equal(t, "description_charset", failedRule(t, v.Struct(&params.DepositRequest{Amount: 100, Description: "line\nbreak"})))
+	assert.NoError(t, v.Struct(&params.DepositRequest{Amount: 100, Description: "Invoice #42, paid"}))
+	assert.NoError(t, v.Struct(&params.DepositRequest{Amount: 100}))
+}
+
+func TestNewValidator_DescriptionPatternMatchesWholeDescription(t *testing.T) {
+	policy := config.DescriptionPolicyConfig{Pattern: `[A-Za-z0-9 ]*`}
+	assert.NoError(t, policy.Validate())
+	v := config.NewValidator(config.PasswordPolicyConfig{MinLength: 6}, policy)
+
+	assert.NoError(t, v.Struct(&params.DepositRequest{Amount: 100, Description: "Invoice 42"}))
+	assert.Equal(t, "description_charset", failedRule(t, v.Struct(&params.DepositRequest{Amount: 100, Description: "Invoice #42"})))
+}
+
+func TestDescriptionPolicy_InvalidPattern(t *testing.T) {
+	assert.Error(t, config.DescriptionPolicyConfig{Pattern: `[a-z`}.Validate())
+}
@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChange is a requested change of a user's email, applied once the user
+// follows the verification link sent to the new address.
+type EmailChange struct {
+	UserID   uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
+	NewEmail string    `gorm:"type:varchar(255);not null" json:"new_email"`
+	// TokenHash is the hex SHA-256 of the token in the verification link.
+	TokenHash string    `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (c *EmailChange) Expired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+func (EmailChange) TableName() string {
+	return "email_changes"
+}
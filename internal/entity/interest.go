@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InterestAccrual struct {
+	WalletID      uuid.UUID `gorm:"type:uuid;primary_key" json:"wallet_id"`
+	AccrualDate   time.Time `gorm:"type:date;primary_key" json:"accrual_date"`
+	TransactionID uuid.UUID `gorm:"type:uuid;not null" json:"transaction_id"`
+	Amount        float64   `gorm:"type:decimal(15,2);not null" json:"amount"`
+	CreatedAt     time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (InterestAccrual) TableName() string {
+	return "interest_accruals"
+}
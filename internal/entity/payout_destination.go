@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PayoutDestinationType string
+
+const (
+	PayoutDestinationBankAccount PayoutDestinationType = "bank_account"
+	PayoutDestinationEWallet     PayoutDestinationType = "e_wallet"
+)
+
+// PayoutDestination is an external account a user withdraws to. It cannot be
+// used until an admin has verified it.
+type PayoutDestination struct {
+	ID            uuid.UUID             `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID             `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type          PayoutDestinationType `gorm:"type:varchar(20);not null" json:"type"`
+	Provider      string                `gorm:"type:varchar(100);not null" json:"provider"`
+	AccountName   string                `gorm:"type:varchar(255);not null" json:"account_name"`
+	AccountNumber string                `gorm:"type:varchar(64);not null" json:"account_number"`
+	VerifiedAt    *time.Time            `json:"verified_at,omitempty"`
+	VerifiedBy    *uuid.UUID            `gorm:"type:uuid" json:"verified_by,omitempty"`
+	CreatedAt     time.Time             `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time             `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+	// DeletedAt soft-deletes the destination so that withdrawals made to it
+	// keep a valid reference.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (d *PayoutDestination) Verified() bool {
+	return d.VerifiedAt != nil
+}
+
+func (PayoutDestination) TableName() string {
+	return "payout_destinations"
+}
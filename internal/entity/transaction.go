@@ -12,6 +12,11 @@ type TransactionType string
 const (
 	TransactionTypeWithdraw TransactionType = "withdraw"
 	TransactionTypeDeposit  TransactionType = "deposit"
+	TransactionTypeInterest TransactionType = "interest"
+	// A transfer is recorded as a transfer_out on the sender's wallet and a
+	// transfer_in on the recipient's.
+	TransactionTypeTransferIn  TransactionType = "transfer_in"
+	TransactionTypeTransferOut TransactionType = "transfer_out"
 )
 
 type TransactionStatus string
@@ -25,13 +30,32 @@ const (
 type Transaction struct {
 	ID          uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	WalletID    uuid.UUID         `gorm:"type:uuid;not null;index" json:"wallet_id"`
-	Type        TransactionType   `gorm:"type:varchar(20);not null;check:type IN ('withdraw','deposit')" json:"type"`
+	Type        TransactionType   `gorm:"type:varchar(20);not null;check:type IN ('withdraw','deposit','interest','transfer_in','transfer_out')" json:"type"`
 	Amount      float64           `gorm:"type:decimal(15,2);not null;check:amount > 0" json:"amount"`
 	Status      TransactionStatus `gorm:"type:varchar(20);not null;default:'pending';check:status IN ('pending','completed','failed')" json:"status"`
 	Description string            `gorm:"type:text" json:"description"`
 	CreatedAt   time.Time         `gorm:"not null;default:CURRENT_TIMESTAMP;index:idx_transactions_created_at,sort:desc" json:"created_at"`
 	UpdatedAt   time.Time         `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
 
+	// BalanceAfter is the wallet balance once this transaction is applied. It
+	// is nil for transactions recorded before snapshots were introduced.
+	BalanceAfter *float64 `gorm:"type:decimal(15,2)" json:"balance_after,omitempty"`
+	// PayoutDestinationID is where a withdrawal was paid out to, if the
+	// user named a destination.
+	PayoutDestinationID *uuid.UUID `gorm:"type:uuid" json:"payout_destination_id,omitempty"`
+	// ExchangeRate, OriginalAmount and ConvertedAmount are set on both legs
+	// of a transfer between currencies: the rate applied, the amount in the
+	// sender's currency and the amount in the recipient's.
+	ExchangeRate    *float64 `gorm:"type:decimal(20,10)" json:"exchange_rate,omitempty"`
+	OriginalAmount  *float64 `gorm:"type:decimal(15,2)" json:"original_amount,omitempty"`
+	ConvertedAmount *float64 `gorm:"type:decimal(15,2)" json:"converted_amount,omitempty"`
+	// TransferID links the legs of one transfer, including the credit of a
+	// tip, by the id of the sender's leg.
+	TransferID *uuid.UUID `gorm:"type:uuid;index" json:"transfer_id,omitempty"`
+	// ClearedAt is when a deposit made during a hold period can be withdrawn
+	// or sent on. It is nil for deposits that were available at once.
+	ClearedAt *time.Time `json:"cleared_at,omitempty"`
+
 	Wallet Wallet `gorm:"foreignKey:WalletID;constraint:OnDelete:CASCADE" json:"wallet,omitempty"`
 }
 
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TransferRequestStatus string
+
+const (
+	TransferRequestStatusPending  TransferRequestStatus = "pending"
+	TransferRequestStatusApproved TransferRequestStatus = "approved"
+	TransferRequestStatusRejected TransferRequestStatus = "rejected"
+	TransferRequestStatusExpired  TransferRequestStatus = "expired"
+)
+
+// TransferRequest is a transfer awaiting a second approver. While pending its
+// amount is held on the sender's wallet; approval moves the money, rejection
+// or expiry releases the hold.
+type TransferRequest struct {
+	ID           uuid.UUID             `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FromWalletID uuid.UUID             `gorm:"type:uuid;not null" json:"from_wallet_id"`
+	ToWalletID   uuid.UUID             `gorm:"type:uuid;not null" json:"to_wallet_id"`
+	RequestedBy  uuid.UUID             `gorm:"type:uuid;not null" json:"requested_by"`
+	Amount       float64               `gorm:"type:decimal(15,2);not null;check:amount > 0" json:"amount"`
+	Description  string                `gorm:"type:text" json:"description"`
+	Status       TransferRequestStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	DecidedBy    *uuid.UUID            `gorm:"type:uuid" json:"decided_by,omitempty"`
+	DecidedAt    *time.Time            `json:"decided_at,omitempty"`
+	// TransactionID is the sender's transfer_out leg once approved.
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedAt     time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (TransferRequest) TableName() string {
+	return "transfer_requests"
+}
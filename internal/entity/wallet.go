@@ -7,18 +7,60 @@ import (
 	"gorm.io/gorm"
 )
 
+// WalletType decides which operations a wallet allows; see the usecase's
+// wallet operation policy.
+type WalletType string
+
+const (
+	WalletTypePersonal WalletType = "personal"
+	WalletTypeMerchant WalletType = "merchant"
+	WalletTypeSystem   WalletType = "system"
+)
+
 type Wallet struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
-	Balance   float64   `gorm:"type:decimal(15,2);not null;default:0.00;check:balance >= 0" json:"balance"`
-	Currency  string    `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
-	Version   int       `gorm:"not null;default:1" json:"version"`
-	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	Balance   float64    `gorm:"type:decimal(15,2);not null;default:0.00;check:balance >= 0 OR is_system" json:"balance"`
+	Currency  string     `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Type      WalletType `gorm:"type:varchar(20);not null;default:'personal'" json:"type"`
+	Version   int        `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	// InterestRate is the annual interest rate (0.03 = 3%). A nil rate falls
+	// back to the configured default rate.
+	InterestRate *float64 `gorm:"type:decimal(9,6)" json:"interest_rate,omitempty"`
+	// AlertThreshold is the transaction amount above which the owner is
+	// notified. It is kept on the wallet since it is in the wallet's
+	// currency; a nil threshold falls back to the configured default.
+	AlertThreshold *float64 `gorm:"type:decimal(15,2)" json:"alert_threshold,omitempty"`
+	// HeldBalance is the part of Balance reserved by pending transfer
+	// requests.
+	HeldBalance float64 `gorm:"type:decimal(15,2);not null;default:0.00" json:"held_balance"`
+	// IsSystem marks the system account that deposits and withdrawals are
+	// booked against. Its balance is allowed to go negative.
+	IsSystem bool `gorm:"not null;default:false" json:"is_system,omitempty"`
+	// LockedUntil is the end of a temporary soft-lock during which no money
+	// may move in or out of the wallet. It lapses on its own once passed.
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	// ClearingBalance is the part of Balance from deposits still on hold. It
+	// is not stored; the usecase loads it before checking a withdrawal or
+	// transfer against AvailableBalance.
+	ClearingBalance float64 `gorm:"-" json:"-"`
 
 	Transactions []Transaction `gorm:"foreignKey:WalletID;constraint:OnDelete:CASCADE" json:"transactions,omitempty"`
 }
 
+// AvailableBalance is what can still be withdrawn or transferred.
+func (w *Wallet) AvailableBalance() float64 {
+	return w.Balance - w.HeldBalance - w.ClearingBalance
+}
+
+// SoftLocked reports whether a soft-lock is still active at now.
+func (w *Wallet) SoftLocked(now time.Time) bool {
+	return w.LockedUntil != nil && now.Before(*w.LockedUntil)
+}
+
 func (w *Wallet) BeforeCreate(tx *gorm.DB) error {
 	if w.ID == uuid.Nil {
 		w.ID = uuid.New()
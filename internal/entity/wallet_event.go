@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WalletEventType names a non-financial change to a wallet's state.
+type WalletEventType string
+
+const (
+	WalletEventAlertThresholdUpdated WalletEventType = "alert_threshold_updated"
+	WalletEventSoftLockPlaced        WalletEventType = "soft_lock_placed"
+	WalletEventSoftLockCleared       WalletEventType = "soft_lock_cleared"
+)
+
+// WalletEvent records a change to a wallet's settings or state, with the
+// values before and after the change as JSON. Money movements are recorded as
+// transactions instead.
+type WalletEvent struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WalletID  uuid.UUID       `gorm:"type:uuid;not null;index" json:"wallet_id"`
+	Type      WalletEventType `gorm:"type:varchar(50);not null" json:"type"`
+	ActorID   uuid.UUID       `gorm:"type:uuid;not null" json:"actor_id"`
+	Before    *string         `gorm:"type:jsonb" json:"before,omitempty"`
+	After     *string         `gorm:"type:jsonb" json:"after,omitempty"`
+	CreatedAt time.Time       `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (WalletEvent) TableName() string {
+	return "wallet_events"
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type AdminHandler interface {
+	GetMaintenance(c *gin.Context)
+	SetMaintenance(c *gin.Context)
+	SearchUsers(c *gin.Context)
+}
+
+type AdminHandlerImpl struct {
+	maintenance usecase.MaintenanceUsecase
+	admin       usecase.AdminUsecase
+	logger      *logrus.Logger
+	validator   *validator.Validate
+}
+
+func NewAdminHandler(maintenance usecase.MaintenanceUsecase, admin usecase.AdminUsecase, logger *logrus.Logger, validator *validator.Validate) AdminHandler {
+	return &AdminHandlerImpl{
+		maintenance: maintenance,
+		admin:       admin,
+		logger:      logger,
+		validator:   validator,
+	}
+}
+
+func (h *AdminHandlerImpl) GetMaintenance(c *gin.Context) {
+	status, custErr := h.maintenance.GetStatus(c.Request.Context())
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Maintenance status retrieved successfully", status)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *AdminHandlerImpl) SetMaintenance(c *gin.Context) {
+	var req params.MaintenanceRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Invalid request payload",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	h.logger.WithFields(logrus.Fields{
+		"admin_id": userID,
+		"enabled":  *req.Enabled,
+	}).Info("Maintenance mode change requested")
+
+	status, custErr := h.maintenance.SetEnabled(c.Request.Context(), *req.Enabled)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Maintenance status updated successfully", status)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *AdminHandlerImpl) SearchUsers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	result, custErr := h.admin.SearchUsers(c.Request.Context(), c.Query("q"), limit, offset)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Users retrieved successfully", result)
+	c.JSON(resp.StatusCode, resp)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"fmt"
 	"go-digital-wallet/internal/commons/response"
 	"go-digital-wallet/internal/params"
 	"go-digital-wallet/internal/usecase"
@@ -8,23 +9,32 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 type AuthHandler interface {
 	Register(c *gin.Context)
 	Login(c *gin.Context)
+	ExportData(c *gin.Context)
+	ChangeEmail(c *gin.Context)
+	ConfirmEmailChange(c *gin.Context)
+	GetOverview(c *gin.Context)
 }
 
 type AuthHandlerImpl struct {
 	authService usecase.AuthUsecase
+	dataExport  usecase.DataExportUsecase
+	overview    usecase.OverviewUsecase
 	logger      *logrus.Logger
 	validator   *validator.Validate
 }
 
-func NewAuthHandler(authService usecase.AuthUsecase, logger *logrus.Logger, validator *validator.Validate) AuthHandler {
+func NewAuthHandler(authService usecase.AuthUsecase, dataExport usecase.DataExportUsecase, overview usecase.OverviewUsecase, logger *logrus.Logger, validator *validator.Validate) AuthHandler {
 	return &AuthHandlerImpl{
 		authService: authService,
+		dataExport:  dataExport,
+		overview:    overview,
 		logger:      logger,
 		validator:   validator,
 	}
@@ -33,7 +43,7 @@ func NewAuthHandler(authService usecase.AuthUsecase, logger *logrus.Logger, vali
 func (h *AuthHandlerImpl) Register(c *gin.Context) {
 	var req params.RegisterRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		h.logger.WithError(err).Error("Failed to parse register request")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  false,
@@ -58,7 +68,7 @@ func (h *AuthHandlerImpl) Register(c *gin.Context) {
 
 	authResponse, custErr := h.authService.Register(&req)
 	if custErr != nil {
-		c.AbortWithStatusJSON(custErr.StatusCode, custErr)
+		response.Abort(c, custErr)
 		return
 	}
 
@@ -69,7 +79,7 @@ func (h *AuthHandlerImpl) Register(c *gin.Context) {
 func (h *AuthHandlerImpl) Login(c *gin.Context) {
 	var req params.LoginRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		h.logger.WithError(err).Error("Failed to parse login request")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  false,
@@ -94,7 +104,7 @@ func (h *AuthHandlerImpl) Login(c *gin.Context) {
 
 	authResponse, custErr := h.authService.Login(&req)
 	if custErr != nil {
-		c.AbortWithStatusJSON(custErr.StatusCode, custErr)
+		response.Abort(c, custErr)
 		return
 	}
 
@@ -103,7 +113,8 @@ func (h *AuthHandlerImpl) Login(c *gin.Context) {
 }
 
 func getValidationErrorMessage(err validator.FieldError) string {
-	switch err.Tag() {
+	// ActualTag resolves aliases such as `password` to the rule that failed.
+	switch err.ActualTag() {
 	case "required":
 		return "This field is required"
 	case "max":
@@ -114,7 +125,142 @@ func getValidationErrorMessage(err validator.FieldError) string {
 		return "This field must be a valid email"
 	case "oneof":
 		return "This field must be one of: " + err.Param()
+	case "password_upper":
+		return "This field must contain an uppercase letter"
+	case "password_lower":
+		return "This field must contain a lowercase letter"
+	case "password_digit":
+		return "This field must contain a digit"
+	case "password_symbol":
+		return "This field must contain a symbol"
+	case "description_charset":
+		return "This field contains characters that are not allowed"
 	default:
 		return "This field is invalid"
 	}
 }
+
+// ExportData streams all data stored about the authenticated user as a JSON
+// download. Once streaming has started errors can no longer change the
+// status, so they are only logged and the download is cut short.
+func (h *AuthHandlerImpl) ExportData(c *gin.Context) {
+	value, _ := c.Get("user_id")
+	userID, ok := value.(uuid.UUID)
+	if !ok {
+		response.Abort(c, response.UnauthorizedError("unauthorized"))
+		return
+	}
+
+	file, custErr := h.dataExport.ExportUserData(c.Request.Context(), userID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Header("Content-Type", file.ContentType)
+	c.Status(http.StatusOK)
+	if err := file.Write(c.Writer); err != nil {
+		h.logger.WithError(err).WithField("user_id", userID).Error("Data export interrupted")
+	}
+}
+
+// GetOverview returns the authenticated user's profile and wallets, sparing
+// clients a second call on startup.
+func (h *AuthHandlerImpl) GetOverview(c *gin.Context) {
+	value, _ := c.Get("user_id")
+	userID, ok := value.(uuid.UUID)
+	if !ok {
+		response.Abort(c, response.UnauthorizedError("unauthorized"))
+		return
+	}
+
+	overview, custErr := h.overview.GetOverview(c.Request.Context(), userID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Overview retrieved successfully", overview)
+	c.JSON(resp.StatusCode, resp)
+}
+
+// ChangeEmail sends a verification link to the requested email. The email on
+// record only changes once the link is confirmed.
+func (h *AuthHandlerImpl) ChangeEmail(c *gin.Context) {
+	value, _ := c.Get("user_id")
+	userID, ok := value.(uuid.UUID)
+	if !ok {
+		response.Abort(c, response.UnauthorizedError("unauthorized"))
+		return
+	}
+
+	var req params.ChangeEmailRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Failed to parse change email request")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	if custErr := h.authService.RequestEmailChange(c.Request.Context(), userID, &req); custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Verification link sent to the new email", nil)
+	c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmEmailChange applies an email change from the token of its
+// verification link. It needs no login, as the link may be opened on another
+// device.
+func (h *AuthHandlerImpl) ConfirmEmailChange(c *gin.Context) {
+	var req params.ConfirmEmailChangeRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Failed to parse confirm email change request")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Invalid JSON format",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	if custErr := h.authService.ConfirmEmailChange(c.Request.Context(), &req); custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Email changed", nil)
+	c.JSON(http.StatusOK, resp)
+}
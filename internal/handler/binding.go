@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-digital-wallet/pkg/money"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// amountPlaces is how many decimal places an amount may carry: the scale of
+// the decimal(15,2) amount columns.
+const amountPlaces = 2
+
+// bindJSON decodes the request body into req and normalizes its string fields.
+//
+// Every exported string field (including nested structs and pointers to
+// strings) has leading and trailing whitespace trimmed. The `normalize` struct
+// tag adjusts this per field:
+//
+//	normalize:"lower" trims and lower-cases (e.g. emails)
+//	normalize:"upper" trims and upper-cases (e.g. currency codes)
+//	normalize:"-"     leaves the value untouched (e.g. passwords)
+//
+// Fields tagged money:"decimal" are validated as exact decimals, given as a
+// JSON number or a numeric string, and rejected with an *amountError when
+// malformed or more precise than a cent; see bindJSONAmounts. This only checks
+// precision at the boundary: the value is still decoded into a float64 field.
+func bindJSON(c *gin.Context, req interface{}) error {
+	return bindJSONAmounts(c, req, WalletHandlerOptions{})
+}
+
+// bindJSONAmounts is bindJSON rounding amounts with more decimal places than
+// the columns store to the cent, by the configured rounding mode, instead of
+// rejecting them when options.LenientAmounts is set. Checking and rounding
+// are exact, but the result is written back as decimal text and decoded like
+// any other number, so the float64 field holds the nearest float64 to it.
+// Carrying the exact decimal past binding is not implemented: params, the
+// usecases and the repository all compute amounts as float64.
+func bindJSONAmounts(c *gin.Context, req interface{}, options WalletHandlerOptions) error {
+	if hasMoneyFields(reflect.TypeOf(req)) && c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if body, err = decimalAmounts(body, reflect.TypeOf(req), options); err != nil {
+				return err
+			}
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := c.ShouldBindJSON(req); err != nil {
+		return err
+	}
+	normalizeStrings(reflect.ValueOf(req))
+	return nil
+}
+
+// amountError reports an amount that is not an exact decimal the columns can
+// store. Field is the Go name of the field, as in validation errors.
+type amountError struct {
+	Field string
+	Err   error
+}
+
+func (e *amountError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *amountError) Unwrap() error {
+	return e.Err
+}
+
+// invalidPayload answers a request whose body could not be bound. An invalid
+// amount is named like a failed validation, so the client knows which field
+// to fix; anything else is an invalid payload.
+func invalidPayload(c *gin.Context, err error) {
+	var amountErr *amountError
+	if errors.As(err, &amountErr) {
+		message := amountErr.Err.Error()
+		if errors.Is(amountErr.Err, money.ErrTooPrecise) {
+			message = fmt.Sprintf("amount must have at most %d decimal places", amountPlaces)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  map[string]string{amountErr.Field: message},
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"status":  false,
+		"message": "Invalid request payload",
+	})
+}
+
+// decimalAmounts rewrites the money fields of body, a JSON object decoded
+// into t, as decimal text with amountPlaces places. A body that is not an
+// object of the expected shape is returned as is for binding to reject.
+func decimalAmounts(body []byte, t reflect.Type, options WalletHandlerOptions) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return body, nil
+	}
+	if err := rewriteAmounts(tree, t, options); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
+
+func rewriteAmounts(tree interface{}, t reflect.Type, options WalletHandlerOptions) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	object, ok := tree.(map[string]interface{})
+	if t.Kind() != reflect.Struct || !ok {
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if !sf.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		// Keys match field names case-insensitively, as when binding.
+		key, found := name, false
+		for k := range object {
+			if strings.EqualFold(k, name) {
+				key, found = k, true
+				break
+			}
+		}
+		value := object[key]
+		if !found || value == nil {
+			continue
+		}
+
+		if sf.Tag.Get("money") != "decimal" {
+			if err := rewriteAmounts(value, sf.Type, options); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var text string
+		switch v := value.(type) {
+		case json.Number:
+			text = v.String()
+		case string:
+			text = v
+		default:
+			return &amountError{Field: sf.Name, Err: money.ErrMalformed}
+		}
+		amount, err := money.Parse(text)
+		if err != nil {
+			return &amountError{Field: sf.Name, Err: err}
+		}
+		formatted, err := money.Format(amount, amountPlaces)
+		if err != nil {
+			if !options.LenientAmounts {
+				return &amountError{Field: sf.Name, Err: err}
+			}
+			formatted = options.Rounding.RoundDecimal(amount, amountPlaces).FloatString(amountPlaces)
+		}
+		object[key] = json.Number(formatted)
+	}
+	return nil
+}
+
+// hasMoneyFields reports whether t, or a struct nested in it, has a field
+// tagged money:"decimal".
+func hasMoneyFields(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Tag.Get("money") == "decimal" || hasMoneyFields(sf.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeStrings(v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		sf := t.Field(i)
+		if !sf.IsExported() || !field.CanSet() {
+			continue
+		}
+
+		tag := sf.Tag.Get("normalize")
+		if tag == "-" {
+			continue
+		}
+
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString(normalizeString(field.String(), tag))
+		case field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.String:
+			if !field.IsNil() {
+				field.Elem().SetString(normalizeString(field.Elem().String(), tag))
+			}
+		case field.Kind() == reflect.Struct, field.Kind() == reflect.Ptr:
+			normalizeStrings(field.Addr())
+		}
+	}
+}
+
+func normalizeString(s, tag string) string {
+	s = strings.TrimSpace(s)
+	switch tag {
+	case "lower":
+		return strings.ToLower(s)
+	case "upper":
+		return strings.ToUpper(s)
+	default:
+		return s
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"go-digital-wallet/pkg/money"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bindingAddress struct {
+	City    string `json:"city"`
+	Country string `json:"country" normalize:"upper"`
+}
+
+type bindingRequest struct {
+	Name     string          `json:"name"`
+	Email    string          `json:"email" normalize:"lower"`
+	Currency string          `json:"currency" normalize:"upper"`
+	Password string          `json:"password" normalize:"-"`
+	Note     *string         `json:"note"`
+	Address  bindingAddress  `json:"address"`
+	Billing  *bindingAddress `json:"billing"`
+	Amount   float64         `json:"amount" money:"decimal"`
+}
+
+func bindingContext(body string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c
+}
+
+func TestBindJSON_NormalizesStrings(t *testing.T) {
+	var req bindingRequest
+	err := bindJSON(bindingContext(`{
+		"name": "  Jane Doe ",
+		"email": " Jane.Doe@Example.COM ",
+		"currency": " idr",
+		"password": "  Secret  ",
+		"note": " rent ",
+		"address": {"city": " Jakarta ", "country": "id "},
+		"billing": {"city": "Bandung", "country": " id"},
+		"amount": "10.5"
+	}`), &req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Jane Doe", req.Name)
+	assert.Equal(t, "jane.doe@example.com", req.Email)
+	assert.Equal(t, "IDR", req.Currency)
+	assert.Equal(t, "  Secret  ", req.Password)
+	require.NotNil(t, req.Note)
+	assert.Equal(t, "rent", *req.Note)
+	assert.Equal(t, bindingAddress{City: "Jakarta", Country: "ID"}, req.Address)
+	require.NotNil(t, req.Billing)
+	assert.Equal(t, bindingAddress{City: "Bandung", Country: "ID"}, *req.Billing)
+	assert.Equal(t, 10.5, req.Amount)
+}
+
+func TestNormalizeStrings_SkipsNilPointers(t *testing.T) {
+	req := bindingRequest{Email: " A@B.C "}
+	normalizeStrings(reflect.ValueOf(&req))
+	assert.Equal(t, "a@b.c", req.Email)
+	assert.Nil(t, req.Note)
+	assert.Nil(t, req.Billing)
+
+	var nilReq *bindingRequest
+	assert.NotPanics(t, func() { normalizeStrings(reflect.ValueOf(nilReq)) })
+}
+
+func TestBindJSON_Amounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		options WalletHandlerOptions
+		want    float64
+		wantErr error
+	}{
+		{name: "number", body: `{"amount": 12.34}`, want: 12.34},
+		{name: "numeric string", body: `{"amount": "12.30"}`, want: 12.3},
+		{name: "too precise", body: `{"amount": 1.005}`, wantErr: money.ErrTooPrecise},
+		{name: "malformed", body: `{"amount": "ten"}`, wantErr: money.ErrMalformed},
+		{name: "not a number", body: `{"amount": true}`, wantErr: money.ErrMalformed},
+		{name: "lenient half up", body: `{"amount": 1.005}`, options: WalletHandlerOptions{LenientAmounts: true}, want: 1.01},
+		{name: "lenient floor", body: `{"amount": 1.009}`, options: WalletHandlerOptions{LenientAmounts: true, Rounding: money.RoundFloor}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req bindingRequest
+			err := bindJSONAmounts(bindingContext(tt.body), &req, tt.options)
+			if tt.wantErr != nil {
+				var amountErr *amountError
+				require.True(t, errors.As(err, &amountErr), "got %v", err)
+				assert.Equal(t, "Amount", amountErr.Field)
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, req.Amount)
+		})
+	}
+}
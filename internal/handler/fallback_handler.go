@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoRoute answers requests to unknown paths with the same JSON error body as
+// every other failure, instead of gin's plain-text 404.
+func NoRoute(c *gin.Context) {
+	response.Abort(c, response.RouteNotFoundError(fmt.Sprintf("route %s %s not found", c.Request.Method, c.Request.URL.Path)))
+}
+
+// NoMethod answers requests to a known path with an unsupported method.
+func NoMethod(c *gin.Context) {
+	response.Abort(c, response.MethodNotAllowedError(fmt.Sprintf("method %s is not allowed on %s", c.Request.Method, c.Request.URL.Path)))
+}
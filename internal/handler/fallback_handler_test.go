@@ -0,0 +1,59 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/handler"
+	"go-digital-wallet/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupFallbackRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.RequestIDMiddleware())
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handler.NoRoute)
+	router.NoMethod(handler.NoMethod)
+	router.GET("/api/v1/wallets/balance", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestNoRoute_ReturnsJSONError(t *testing.T) {
+	router := setupFallbackRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/bogus", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	var body response.CustomError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ERR0011", body.Code)
+	assert.Equal(t, "route GET /api/v1/bogus not found", body.Message)
+	assert.Equal(t, "req-123", body.RequestID)
+}
+
+func TestNoMethod_ReturnsJSONError(t *testing.T) {
+	router := setupFallbackRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/wallets/balance", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	var body response.CustomError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "ERR0012", body.Code)
+	assert.NotEmpty(t, body.RequestID)
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"go-digital-wallet/pkg/health"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness answers readiness probes with the debounced outcome of the
+// dependency checks: 200 while the service can take traffic, 503 otherwise.
+// The checks are listed either way, so a blip that is being ridden out still
+// shows.
+func Readiness(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result := checker.Probe(c.Request.Context())
+
+		status, code := "ready", http.StatusOK
+		if !result.Ready {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{
+			"status":    status,
+			"checks":    result.Checks,
+			"timestamp": time.Now().Format(time.RFC3339),
+			"service":   "digital-wallet-api",
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transactionFields are the transaction fields a client may select with the
+// fields query parameter, by their JSON name.
+var transactionFields = []string{
+	"id",
+	"display_id",
+	"type",
+	"amount",
+	"description",
+	"status",
+	"created_at",
+	"updated_at",
+	"payout_destination_id",
+	"exchange_rate",
+	"original_amount",
+	"converted_amount",
+	"transfer_id",
+	"cleared_at",
+}
+
+// parseTransactionFields reads the comma-separated fields query parameter,
+// aborting with a bad request when it names a field not in
+// transactionFields. A missing parameter returns nil, selecting every field.
+func parseTransactionFields(c *gin.Context) ([]string, bool) {
+	value := strings.TrimSpace(c.Query("fields"))
+	if value == "" {
+		return nil, true
+	}
+
+	allowed := make(map[string]bool, len(transactionFields))
+	for _, name := range transactionFields {
+		allowed[name] = true
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if !allowed[name] {
+			response.Abort(c, response.BadRequestErrorWithAdditionalInfo(
+				map[string][]string{"allowed_fields": transactionFields},
+				fmt.Sprintf("unknown field %q", name),
+			))
+			return nil, false
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return fields, true
+}
+
+// projectedTransactionHistory is a history page whose transactions carry
+// only the fields the client selected.
+type projectedTransactionHistory struct {
+	*params.TransactionHistoryResponse
+	Transactions []map[string]json.RawMessage `json:"transactions"`
+}
+
+// projectTransactionHistory cuts every transaction in history down to fields.
+// Selected fields that are empty and omitted from the full response stay
+// omitted.
+func projectTransactionHistory(history *params.TransactionHistoryResponse, fields []string) (*projectedTransactionHistory, error) {
+	projected := &projectedTransactionHistory{
+		TransactionHistoryResponse: history,
+		Transactions:               make([]map[string]json.RawMessage, 0, len(history.Transactions)),
+	}
+	for _, transaction := range history.Transactions {
+		raw, err := json.Marshal(transaction)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &all); err != nil {
+			return nil, err
+		}
+
+		selected := make(map[string]json.RawMessage, len(fields))
+		for _, name := range fields {
+			if value, ok := all[name]; ok {
+				selected[name] = value
+			}
+		}
+		projected.Transactions = append(projected.Transactions, selected)
+	}
+	return projected, nil
+}
@@ -1,11 +1,21 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/readpref"
 	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
 	"go-digital-wallet/internal/params"
 	"go-digital-wallet/internal/usecase"
+	"go-digital-wallet/pkg/money"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -15,25 +25,75 @@ import (
 
 type WalletHandler interface {
 	CreateWallet(c *gin.Context)
+	EnsureWallet(c *gin.Context)
 	GetBalance(c *gin.Context)
+	ListHolds(c *gin.Context)
+	WalletExists(c *gin.Context)
 	Withdraw(c *gin.Context)
+	InitiateWithdraw(c *gin.Context)
+	ConfirmWithdraw(c *gin.Context)
 	Deposit(c *gin.Context)
+	Transfer(c *gin.Context)
+	CreateTransferRequest(c *gin.Context)
+	ApproveTransferRequest(c *gin.Context)
+	RejectTransferRequest(c *gin.Context)
+	GetTotalBalance(c *gin.Context)
+	GetTransactionVelocity(c *gin.Context)
+	ListPlatformTransactions(c *gin.Context)
+	SoftLockWallet(c *gin.Context)
+	ClearSoftLock(c *gin.Context)
+	CreatePayoutDestination(c *gin.Context)
+	ListPayoutDestinations(c *gin.Context)
+	DeletePayoutDestination(c *gin.Context)
+	VerifyPayoutDestination(c *gin.Context)
 	GetTransactionHistory(c *gin.Context)
+	SearchTransactions(c *gin.Context)
+	GetTransaction(c *gin.Context)
+	GetInsights(c *gin.Context)
+	GetActivity(c *gin.Context)
+	SetAlertThreshold(c *gin.Context)
+	GetBalanceAt(c *gin.Context)
+	GetBalanceHistory(c *gin.Context)
+	ExportStatement(c *gin.Context)
+	VerifyStatement(c *gin.Context)
+	ImportTransactions(c *gin.Context)
+	GetWalletEvents(c *gin.Context)
 }
 
 type WalletHandlerImpl struct {
 	usecase   usecase.WalletUsecase
 	logger    *logrus.Logger
 	validator *validator.Validate
+	options   WalletHandlerOptions
+}
+
+// WalletHandlerOptions tunes how wallet requests are read.
+type WalletHandlerOptions struct {
+	// LenientAmounts rounds amounts with more than two decimal places to the
+	// cent instead of rejecting them, for clients that cannot be fixed yet.
+	LenientAmounts bool
+	// Rounding is how LenientAmounts rounds. Empty is half up.
+	Rounding money.RoundingMode
 }
 
 func NewWalletHandler(usecase usecase.WalletUsecase, logger *logrus.Logger, validator *validator.Validate) WalletHandler {
+	return NewWalletHandlerWithOptions(usecase, logger, validator, WalletHandlerOptions{})
+}
+
+func NewWalletHandlerWithOptions(usecase usecase.WalletUsecase, logger *logrus.Logger, validator *validator.Validate, options WalletHandlerOptions) WalletHandler {
 	return &WalletHandlerImpl{
 		usecase:   usecase,
 		logger:    logger,
 		validator: validator,
+		options:   options,
 	}
 }
+
+// bindJSON is the package bindJSON reading amounts as the options say.
+func (h *WalletHandlerImpl) bindJSON(c *gin.Context, req interface{}) error {
+	return bindJSONAmounts(c, req, h.options)
+}
+
 func (h *WalletHandlerImpl) getUserIDFromContext(c *gin.Context) (uuid.UUID, bool) {
 	userIDVal, exists := c.Get("user_id")
 	if !exists {
@@ -65,8 +125,7 @@ func (h *WalletHandlerImpl) CreateWallet(c *gin.Context) {
 	}
 
 	var req params.CreateWalletRequest
-	req.UserID = userID
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		h.logger.WithError(err).Error("Invalid request payload")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  false,
@@ -74,6 +133,7 @@ func (h *WalletHandlerImpl) CreateWallet(c *gin.Context) {
 		})
 		return
 	}
+	req.UserID = userID
 
 	if err := h.validator.Struct(&req); err != nil {
 		details := make(map[string]string)
@@ -91,22 +151,72 @@ func (h *WalletHandlerImpl) CreateWallet(c *gin.Context) {
 
 	walletResp, err := h.usecase.CreateWallet(c.Request.Context(), &req)
 	if err != nil {
-		c.AbortWithStatusJSON(err.StatusCode, err)
+		response.Abort(c, err)
 		return
 	}
 	resp := response.CreatedSuccessWithPayload(walletResp)
 	c.JSON(resp.StatusCode, resp)
 }
 
+// EnsureWallet returns the user's wallet, creating it if needed. The body is
+// optional; it may name the currency of a new wallet.
+func (h *WalletHandlerImpl) EnsureWallet(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.CreateWalletRequest
+	if err := bindJSON(c, &req); err != nil && !errors.Is(err, io.EOF) {
+		h.logger.WithError(err).Error("Invalid request payload")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Invalid request payload",
+		})
+		return
+	}
+	req.UserID = userID
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	walletResp, custErr := h.usecase.EnsureWallet(c.Request.Context(), &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	if walletResp.Created {
+		resp := response.CreatedSuccessWithPayload(walletResp)
+		c.JSON(resp.StatusCode, resp)
+		return
+	}
+	resp := response.GeneralSuccessCustomMessageAndPayload("Wallet retrieved successfully", walletResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
 func (h *WalletHandlerImpl) GetBalance(c *gin.Context) {
 	userID, ok := h.getUserIDFromContext(c)
 	if !ok {
 		return
 	}
 
-	balanceResp, custErr := h.usecase.GetBalance(c.Request.Context(), userID)
+	includeCount, _ := strconv.ParseBool(c.Query("includeCount"))
+
+	balanceResp, custErr := h.usecase.GetBalance(readContext(c), userID, includeCount)
 	if custErr != nil {
-		c.AbortWithStatusJSON(custErr.StatusCode, custErr)
+		response.Abort(c, custErr)
 		return
 	}
 
@@ -114,6 +224,39 @@ func (h *WalletHandlerImpl) GetBalance(c *gin.Context) {
 	c.JSON(resp.StatusCode, resp)
 }
 
+// ListHolds lists the amounts on the user's wallet that cannot be spent yet.
+func (h *WalletHandlerImpl) ListHolds(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	holds, custErr := h.usecase.ListHolds(readContext(c), userID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Holds retrieved successfully", holds)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) WalletExists(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	existsResp, custErr := h.usecase.WalletExists(c.Request.Context(), userID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Wallet existence checked successfully", existsResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
 func (h *WalletHandlerImpl) Withdraw(c *gin.Context) {
 	userID, ok := h.getUserIDFromContext(c)
 	if !ok {
@@ -121,8 +264,84 @@ func (h *WalletHandlerImpl) Withdraw(c *gin.Context) {
 	}
 
 	var req params.WithdrawRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := h.bindJSON(c, &req); err != nil {
 		h.logger.WithError(err).Error("Invalid request payload")
+		invalidPayload(c, err)
+		return
+	}
+	req.IdempotencyKey = strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	withdrawResp, custErr := h.usecase.Withdraw(c.Request.Context(), userID, &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Withdrawal completed successfully", withdrawResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
+// InitiateWithdraw starts a withdrawal that ConfirmWithdraw executes.
+func (h *WalletHandlerImpl) InitiateWithdraw(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.WithdrawRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for withdrawal initiation")
+		invalidPayload(c, err)
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	initiated, custErr := h.usecase.InitiateWithdraw(c.Request.Context(), userID, &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Withdrawal initiated, confirm it with the token", initiated)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) ConfirmWithdraw(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.ConfirmWithdrawRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for withdrawal confirmation")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  false,
 			"message": "Invalid request payload",
@@ -144,9 +363,9 @@ func (h *WalletHandlerImpl) Withdraw(c *gin.Context) {
 		return
 	}
 
-	withdrawResp, custErr := h.usecase.Withdraw(c.Request.Context(), userID, &req)
+	withdrawResp, custErr := h.usecase.ConfirmWithdraw(c.Request.Context(), userID, &req)
 	if custErr != nil {
-		c.JSON(custErr.StatusCode, custErr)
+		response.Abort(c, custErr)
 		return
 	}
 
@@ -161,19 +380,17 @@ func (h *WalletHandlerImpl) Deposit(c *gin.Context) {
 	}
 
 	var req params.DepositRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := h.bindJSON(c, &req); err != nil {
 		h.logger.WithError(err).Error("Invalid request payload for deposit")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  false,
-			"message": "Invalid request payload",
-		})
+		invalidPayload(c, err)
 		return
 	}
+	req.IdempotencyKey = strings.TrimSpace(c.GetHeader("Idempotency-Key"))
 
 	if err := h.validator.Struct(&req); err != nil {
 		details := make(map[string]string)
 		for _, err := range err.(validator.ValidationErrors) {
-			details[err.Field()] = "Validation error on this field"
+			details[err.Field()] = getValidationErrorMessage(err)
 		}
 
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -186,7 +403,7 @@ func (h *WalletHandlerImpl) Deposit(c *gin.Context) {
 
 	depositResp, custErr := h.usecase.Deposit(c.Request.Context(), userID, &req)
 	if custErr != nil {
-		c.JSON(custErr.StatusCode, custErr)
+		response.Abort(c, custErr)
 		return
 	}
 
@@ -194,37 +411,923 @@ func (h *WalletHandlerImpl) Deposit(c *gin.Context) {
 	c.JSON(resp.StatusCode, resp)
 }
 
-func (h *WalletHandlerImpl) GetTransactionHistory(c *gin.Context) {
+func (h *WalletHandlerImpl) ExportStatement(c *gin.Context) {
 	userID, ok := h.getUserIDFromContext(c)
 	if !ok {
 		return
 	}
 
-	// Parse pagination parameters
-	limitStr := c.DefaultQuery("limit", "10")
-	pageStr := c.DefaultQuery("page", "1")
+	filter, ok := parseHistoryFilter(c)
+	if !ok {
+		return
+	}
 
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 10
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	sign, _ := strconv.ParseBool(c.Query("sign"))
+
+	file, custErr := h.usecase.ExportStatement(c.Request.Context(), userID, format, filter, sign)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
 	}
-	if limit > 100 {
-		limit = 100
+
+	if sig := file.Signature; sig != nil {
+		c.Header(statementSignatureHeader, sig.Value)
+		c.Header(statementAlgorithmHeader, sig.Algorithm)
+		c.Header(statementWalletIDHeader, sig.WalletID.String())
+		c.Header(statementWalletVersionHeader, strconv.Itoa(sig.WalletVersion))
+		c.Header(statementGeneratedAtHeader, sig.GeneratedAt.Format(time.RFC3339Nano))
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// Headers carrying the detached signature of a statement exported with
+// sign=true. VerifyStatement takes the same values as form fields.
+const (
+	statementSignatureHeader     = "X-Statement-Signature"
+	statementAlgorithmHeader     = "X-Statement-Signature-Algorithm"
+	statementWalletIDHeader      = "X-Statement-Wallet-Id"
+	statementWalletVersionHeader = "X-Statement-Wallet-Version"
+	statementGeneratedAtHeader   = "X-Statement-Generated-At"
+)
+
+// maxVerifiedStatementSize bounds the statement file VerifyStatement reads.
+const maxVerifiedStatementSize = 32 << 20
+
+// VerifyStatement checks a statement file uploaded in the "file" form field
+// against the signature, algorithm, wallet_id, wallet_version and
+// generated_at fields it was exported with. It needs no login, so that a
+// third party handed the statement can check it too.
+func (h *WalletHandlerImpl) VerifyStatement(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.Abort(c, response.BadRequestError("file is required"))
+		return
+	}
+	if header.Size > maxVerifiedStatementSize {
+		response.Abort(c, response.BadRequestError(fmt.Sprintf("file exceeds %d MB", maxVerifiedStatementSize>>20)))
+		return
 	}
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page <= 0 {
-		page = 1
+	walletID, err := uuid.Parse(c.PostForm("wallet_id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("wallet_id must be a valid id"))
+		return
+	}
+	walletVersion, err := strconv.Atoi(c.PostForm("wallet_version"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("wallet_version must be a number"))
+		return
+	}
+	generatedAt, err := time.Parse(time.RFC3339Nano, c.PostForm("generated_at"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("generated_at must be an RFC 3339 time"))
+		return
+	}
+	signature := &params.StatementSignature{
+		Algorithm:     strings.ToLower(strings.TrimSpace(c.PostForm("algorithm"))),
+		Value:         strings.TrimSpace(c.PostForm("signature")),
+		WalletID:      walletID,
+		WalletVersion: walletVersion,
+		GeneratedAt:   generatedAt,
+	}
+	if signature.Algorithm == "" || signature.Value == "" {
+		response.Abort(c, response.BadRequestError("algorithm and signature are required"))
+		return
 	}
 
-	offset := (page - 1) * limit
+	file, err := header.Open()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to open uploaded statement")
+		response.Abort(c, response.BadRequestError("failed to read file"))
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to read uploaded statement")
+		response.Abort(c, response.BadRequestError("failed to read file"))
+		return
+	}
 
-	transactions, custErr := h.usecase.GetTransactionHistory(c.Request.Context(), userID, limit, offset)
+	result, custErr := h.usecase.VerifyStatement(c.Request.Context(), content, signature)
 	if custErr != nil {
-		c.AbortWithStatusJSON(custErr.StatusCode, custErr)
+		response.Abort(c, custErr)
 		return
 	}
 
-	resp := response.GeneralSuccessCustomMessageAndPayload("Transaction history retrieved successfully", transactions)
+	message := "Statement is authentic"
+	if !result.Valid {
+		message = "Statement does not match its signature"
+	}
+	resp := response.GeneralSuccessCustomMessageAndPayload(message, result)
+	c.JSON(resp.StatusCode, resp)
+}
+
+// ImportTransactions imports historical transactions from an uploaded CSV or
+// JSON file in the "file" form field. The format is taken from the format
+// query parameter or else the file extension; dry_run=true only validates.
+// Rejected rows are listed in the report rather than failing the request.
+func (h *WalletHandlerImpl) ImportTransactions(c *gin.Context) {
+	adminID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.Abort(c, response.BadRequestError("file is required"))
+		return
+	}
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	file, err := header.Open()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to open uploaded import file")
+		response.Abort(c, response.BadRequestError("failed to read file"))
+		return
+	}
+	defer file.Close()
+
+	report, custErr := h.usecase.ImportTransactions(c.Request.Context(), adminID, format, file, dryRun)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	message := "Transactions imported"
+	if dryRun {
+		message = "Import validated"
+	}
+	resp := response.GeneralSuccessCustomMessageAndPayload(message, report)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) GetTransaction(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	transaction, custErr := h.usecase.GetTransaction(c.Request.Context(), userID, c.Param("id"))
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Transaction retrieved successfully", transaction)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) GetBalanceAt(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid wallet id"))
+		return
+	}
+
+	at, err := parseTimeQuery(c, "timestamp")
+	if err != nil || at == nil {
+		response.Abort(c, response.BadRequestError("timestamp must be an RFC3339 timestamp or YYYY-MM-DD date"))
+		return
+	}
+
+	balance, custErr := h.usecase.GetBalanceAt(c.Request.Context(), userID, walletID, *at)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Balance retrieved successfully", balance)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) GetBalanceHistory(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid wallet id"))
+		return
+	}
+
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
+		response.Abort(c, response.BadRequestError("from must be an RFC3339 timestamp or YYYY-MM-DD date"))
+		return
+	}
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
+		response.Abort(c, response.BadRequestError("to must be an RFC3339 timestamp or YYYY-MM-DD date"))
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", "day")
+
+	history, custErr := h.usecase.GetBalanceHistory(c.Request.Context(), userID, walletID, granularity, from, to)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Balance history retrieved successfully", history)
 	c.JSON(resp.StatusCode, resp)
 }
+
+func (h *WalletHandlerImpl) GetWalletEvents(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid wallet id"))
+		return
+	}
+
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	isAdmin := c.GetString("role") == entity.RoleAdmin
+
+	events, custErr := h.usecase.GetWalletEvents(c.Request.Context(), userID, walletID, isAdmin, limit, offset)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Wallet events retrieved successfully", events)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) SetAlertThreshold(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.AlertThresholdRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for alert threshold")
+		invalidPayload(c, err)
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	thresholdResp, custErr := h.usecase.SetAlertThreshold(c.Request.Context(), userID, &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Alert threshold updated successfully", thresholdResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) Transfer(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.TransferRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for transfer")
+		invalidPayload(c, err)
+		return
+	}
+	req.IdempotencyKey = strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	transferResp, custErr := h.usecase.Transfer(c.Request.Context(), userID, &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Transfer completed successfully", transferResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) CreateTransferRequest(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.PendingTransferRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for transfer request")
+		invalidPayload(c, err)
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	requestResp, custErr := h.usecase.CreateTransferRequest(c.Request.Context(), userID, &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.CreatedSuccessWithPayload(requestResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) ApproveTransferRequest(c *gin.Context) {
+	h.decideTransferRequest(c, h.usecase.ApproveTransferRequest, "Transfer request approved successfully")
+}
+
+func (h *WalletHandlerImpl) RejectTransferRequest(c *gin.Context) {
+	h.decideTransferRequest(c, h.usecase.RejectTransferRequest, "Transfer request rejected successfully")
+}
+
+func (h *WalletHandlerImpl) GetTotalBalance(c *gin.Context) {
+	totals, custErr := h.usecase.GetTotalBalance(c.Request.Context())
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Total balance retrieved successfully", totals)
+	c.JSON(resp.StatusCode, resp)
+}
+
+// GetTransactionVelocity returns a wallet's recent transaction counts and
+// totals for admins reviewing it for fraud.
+func (h *WalletHandlerImpl) GetTransactionVelocity(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid wallet id"))
+		return
+	}
+
+	velocity, custErr := h.usecase.GetTransactionVelocity(c.Request.Context(), walletID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Transaction velocity retrieved successfully", velocity)
+	c.JSON(resp.StatusCode, resp)
+}
+
+// ListPlatformTransactions lists transactions across every wallet for admins,
+// optionally narrowed to one type and status.
+func (h *WalletHandlerImpl) ListPlatformTransactions(c *gin.Context) {
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseHistoryFilter(c)
+	if !ok {
+		return
+	}
+
+	if value := c.Query("type"); value != "" {
+		switch transactionType := entity.TransactionType(strings.ToLower(value)); transactionType {
+		case entity.TransactionTypeDeposit, entity.TransactionTypeWithdraw, entity.TransactionTypeInterest,
+			entity.TransactionTypeTransferIn, entity.TransactionTypeTransferOut:
+			filter.Types = []entity.TransactionType{transactionType}
+		default:
+			response.Abort(c, response.BadRequestError("type must be one of: deposit, withdraw, interest, transfer_in, transfer_out"))
+			return
+		}
+	}
+	if value := c.Query("status"); value != "" {
+		switch status := entity.TransactionStatus(strings.ToLower(value)); status {
+		case entity.TransactionStatusPending, entity.TransactionStatusCompleted, entity.TransactionStatusFailed:
+			filter.Status = status
+		default:
+			response.Abort(c, response.BadRequestError("status must be one of: pending, completed, failed"))
+			return
+		}
+	}
+
+	transactions, custErr := h.usecase.ListPlatformTransactions(c.Request.Context(), limit, offset, filter)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Transactions retrieved successfully", transactions)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) SoftLockWallet(c *gin.Context) {
+	actorID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid wallet id"))
+		return
+	}
+
+	var req params.SoftLockRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for soft-lock")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Invalid request payload",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	lockResp, custErr := h.usecase.SoftLockWallet(c.Request.Context(), actorID, walletID, &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Wallet soft-locked successfully", lockResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) ClearSoftLock(c *gin.Context) {
+	actorID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid wallet id"))
+		return
+	}
+
+	lockResp, custErr := h.usecase.ClearSoftLock(c.Request.Context(), actorID, walletID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Wallet soft-lock cleared successfully", lockResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) CreatePayoutDestination(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.PayoutDestinationRequest
+	if err := bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for payout destination")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Invalid request payload",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	destination, custErr := h.usecase.CreatePayoutDestination(c.Request.Context(), userID, &req)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.CreatedSuccessWithPayload(destination)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) ListPayoutDestinations(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	destinations, custErr := h.usecase.ListPayoutDestinations(c.Request.Context(), userID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Payout destinations retrieved successfully", destinations)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) DeletePayoutDestination(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	destinationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid payout destination id"))
+		return
+	}
+
+	if custErr := h.usecase.DeletePayoutDestination(c.Request.Context(), userID, destinationID); custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Payout destination deleted successfully", nil)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) VerifyPayoutDestination(c *gin.Context) {
+	adminID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	destinationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid payout destination id"))
+		return
+	}
+
+	destination, custErr := h.usecase.VerifyPayoutDestination(c.Request.Context(), adminID, destinationID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Payout destination verified successfully", destination)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) decideTransferRequest(c *gin.Context, decide func(ctx context.Context, approverID, requestID uuid.UUID) (*params.TransferRequestResponse, *response.CustomError), message string) {
+	approverID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Abort(c, response.BadRequestError("invalid transfer request id"))
+		return
+	}
+
+	requestResp, custErr := decide(c.Request.Context(), approverID, requestID)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload(message, requestResp)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) GetTransactionHistory(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseHistoryFilter(c)
+	if !ok {
+		return
+	}
+
+	fields, ok := parseTransactionFields(c)
+	if !ok {
+		return
+	}
+
+	filter.IncludeTotals, _ = strconv.ParseBool(c.Query("includeTotals"))
+	filter.SkipCache = NoCacheRequested(c)
+
+	transactions, custErr := h.usecase.GetTransactionHistory(readContext(c), userID, limit, offset, filter)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	var payload interface{} = transactions
+	if fields != nil {
+		projected, err := projectTransactionHistory(transactions, fields)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to project transaction history")
+			response.Abort(c, response.GeneralError("failed to project transaction history"))
+			return
+		}
+		payload = projected
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Transaction history retrieved successfully", payload)
+	c.JSON(resp.StatusCode, resp)
+}
+
+// SearchTransactions is GetTransactionHistory with the filters in a JSON body.
+func (h *WalletHandlerImpl) SearchTransactions(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	var req params.TransactionSearchRequest
+	if err := h.bindJSON(c, &req); err != nil {
+		h.logger.WithError(err).Error("Invalid request payload for transaction search")
+		invalidPayload(c, err)
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		details := make(map[string]string)
+		for _, err := range err.(validator.ValidationErrors) {
+			details[err.Field()] = getValidationErrorMessage(err)
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  false,
+			"message": "Validation failed",
+			"errors":  details,
+		})
+		return
+	}
+
+	if req.Page > 0 && req.Offset != nil {
+		response.Abort(c, response.BadRequestError("page and offset are mutually exclusive"))
+		return
+	}
+	if req.From != nil && req.To != nil && req.From.After(*req.To) {
+		response.Abort(c, response.BadRequestError("from must not be after to"))
+		return
+	}
+	if req.MinAmount != nil && req.MaxAmount != nil && *req.MinAmount > *req.MaxAmount {
+		response.Abort(c, response.BadRequestError("min_amount must not exceed max_amount"))
+		return
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = 10
+	}
+	offset := 0
+	switch {
+	case req.Offset != nil:
+		offset = *req.Offset
+	case req.Page > 0:
+		offset = (req.Page - 1) * limit
+	}
+
+	filter := params.TransactionHistoryFilter{
+		From:          req.From,
+		To:            req.To,
+		IncludeTotals: req.IncludeTotals,
+		Ascending:     req.Order == "asc",
+		SkipCache:     NoCacheRequested(c),
+		Types:         req.Types,
+		MinAmount:     req.MinAmount,
+		MaxAmount:     req.MaxAmount,
+		Search:        strings.TrimSpace(req.Search),
+	}
+
+	transactions, custErr := h.usecase.GetTransactionHistory(readContext(c), userID, limit, offset, filter)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Transaction history retrieved successfully", transactions)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) GetInsights(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", "day")
+
+	filter, ok := parseHistoryFilter(c)
+	if !ok {
+		return
+	}
+
+	insights, custErr := h.usecase.GetInsights(c.Request.Context(), userID, granularity, filter)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Insights retrieved successfully", insights)
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (h *WalletHandlerImpl) GetActivity(c *gin.Context) {
+	userID, ok := h.getUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseHistoryFilter(c)
+	if !ok {
+		return
+	}
+
+	activity, custErr := h.usecase.GetActivity(c.Request.Context(), userID, limit, offset, filter)
+	if custErr != nil {
+		response.Abort(c, custErr)
+		return
+	}
+
+	resp := response.GeneralSuccessCustomMessageAndPayload("Activity retrieved successfully", activity)
+	c.JSON(resp.StatusCode, resp)
+}
+
+// NoCacheRequested reports whether the client sent Cache-Control: no-cache,
+// asking for data fresh from the database, e.g. right after a write.
+func NoCacheRequested(c *gin.Context) bool {
+	for _, directive := range strings.Split(c.GetHeader("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// readContext returns the request context, asking for reads from the primary
+// database when the client sent Cache-Control: no-cache, so that a read right
+// after a write is not served by a lagging replica.
+func readContext(c *gin.Context) context.Context {
+	if NoCacheRequested(c) {
+		return readpref.WithPrimary(c.Request.Context())
+	}
+	return c.Request.Context()
+}
+
+// parsePagination reads the limit and either page or offset from the query
+// string, falling back to the first page of 10 items and capping the limit at
+// 100. It aborts with a bad request when page is not a positive integer,
+// offset is negative, or both are given.
+func parsePagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	pageStr, hasPage := c.GetQuery("page")
+	offsetStr, hasOffset := c.GetQuery("offset")
+	switch {
+	case hasPage && hasOffset:
+		response.Abort(c, response.BadRequestError("page and offset are mutually exclusive"))
+		return 0, 0, false
+	case hasOffset:
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			response.Abort(c, response.BadRequestError("offset must be a non-negative integer"))
+			return 0, 0, false
+		}
+		return limit, offset, true
+	case hasPage:
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			response.Abort(c, response.BadRequestError("page must be an integer of at least 1"))
+			return 0, 0, false
+		}
+		return limit, (page - 1) * limit, true
+	default:
+		return limit, 0, true
+	}
+}
+
+// parseHistoryFilter reads the optional from/to range and sort order from the
+// query string, aborting with a bad request when either is malformed.
+func parseHistoryFilter(c *gin.Context) (params.TransactionHistoryFilter, bool) {
+	var filter params.TransactionHistoryFilter
+	var err error
+
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		resp := response.BadRequestError("from must be an RFC3339 timestamp or YYYY-MM-DD date")
+		response.Abort(c, resp)
+		return filter, false
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		resp := response.BadRequestError("to must be an RFC3339 timestamp or YYYY-MM-DD date")
+		response.Abort(c, resp)
+		return filter, false
+	}
+
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		response.Abort(c, response.BadRequestError("order must be asc or desc"))
+		return filter, false
+	}
+
+	return filter, true
+}
+
+// parseTimeQuery reads an optional RFC3339 timestamp or YYYY-MM-DD date from
+// the query string. A missing parameter returns nil.
+func parseTimeQuery(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,297 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/config"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/handler"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/usecase"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubWalletUsecase records the requests it receives; other methods are not
+// used by these tests.
+type stubWalletUsecase struct {
+	usecase.WalletUsecase
+	created      *params.CreateWalletRequest
+	history      *params.TransactionHistoryFilter
+	offset       int
+	transactions []*params.TransactionResponse
+}
+
+func (s *stubWalletUsecase) GetTransactionHistory(ctx context.Context, userID uuid.UUID, limit, offset int, filter params.TransactionHistoryFilter) (*params.TransactionHistoryResponse, *response.CustomError) {
+	s.history = &filter
+	s.offset = offset
+	return &params.TransactionHistoryResponse{Transactions: s.transactions, Total: int64(len(s.transactions))}, nil
+}
+
+func (s *stubWalletUsecase) ListPlatformTransactions(ctx context.Context, limit, offset int, filter params.TransactionHistoryFilter) (*params.PlatformTransactionsResponse, *response.CustomError) {
+	s.history = &filter
+	return &params.PlatformTransactionsResponse{}, nil
+}
+
+func (s *stubWalletUsecase) CreateWallet(ctx context.Context, req *params.CreateWalletRequest) (*params.WalletResponse, *response.CustomError) {
+	s.created = req
+	return &params.WalletResponse{ID: uuid.New(), UserID: req.UserID, Currency: req.Currency}, nil
+}
+
+func (s *stubWalletUsecase) Deposit(ctx context.Context, userID uuid.UUID, req *params.DepositRequest) (*params.DepositResponse, *response.CustomError) {
+	return &params.DepositResponse{Amount: req.Amount}, nil
+}
+
+func TestCreateWallet_IgnoresUserIDInBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	stub := &stubWalletUsecase{}
+	h := handler.NewWalletHandler(stub, logger, validator.New())
+
+	tokenUserID, otherUserID := uuid.New(), uuid.New()
+	router := gin.New()
+	router.POST("/wallets", func(c *gin.Context) {
+		c.Set("user_id", tokenUserID)
+		c.Next()
+	}, h.CreateWallet)
+
+	body := `{"user_id":"` + otherUserID.String() + `","currency":"idr"}`
+	req := httptest.NewRequest(http.MethodPost, "/wallets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, tokenUserID, stub.created.UserID)
+	assert.Equal(t, "IDR", stub.created.Currency)
+}
+
+func TestGetTransactionHistory_NoCacheHeaderSkipsCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	stub := &stubWalletUsecase{}
+	h := handler.NewWalletHandler(stub, logger, validator.New())
+
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+		c.Next()
+	}, h.GetTransactionHistory)
+
+	for header, skip := range map[string]bool{"": false, "max-age=0, No-Cache": true} {
+		req := httptest.NewRequest(http.MethodGet, "/transactions", nil)
+		req.Header.Set("Cache-Control", header)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, skip, stub.history.SkipCache, "Cache-Control: %q", header)
+	}
+}
+
+func TestGetTransactionHistory_ProjectsSelectedFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	stub := &stubWalletUsecase{transactions: []*params.TransactionResponse{
+		{ID: uuid.New(), Type: entity.TransactionTypeDeposit, Amount: 100, Status: entity.TransactionStatusCompleted},
+	}}
+	h := handler.NewWalletHandler(stub, logger, validator.New())
+
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+		c.Next()
+	}, h.GetTransactionHistory)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/transactions"+query, nil))
+		return w
+	}
+
+	w := get("?fields=id,%20Amount,description")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data struct {
+			Transactions []map[string]interface{} `json:"transactions"`
+			Total        int64                    `json:"total"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, int64(1), body.Data.Total)
+	assert.Equal(t, []map[string]interface{}{
+		{"id": stub.transactions[0].ID.String(), "amount": 100.0},
+	}, body.Data.Transactions)
+
+	assert.Equal(t, http.StatusBadRequest, get("?fields=id,user_id").Code)
+}
+
+func TestSearchTransactions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	stub := &stubWalletUsecase{}
+	h := handler.NewWalletHandler(stub, logger, validator.New())
+
+	router := gin.New()
+	router.POST("/transactions/search", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+		c.Next()
+	}, h.SearchTransactions)
+
+	search := func(body string) int {
+		stub.history = nil
+		req := httptest.NewRequest(http.MethodPost, "/transactions/search", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, search(`{"types":["deposit"],"min_amount":10,"max_amount":20,"search":" rent ","order":"asc","page":2,"limit":5}`))
+	assert.Equal(t, []entity.TransactionType{entity.TransactionTypeDeposit}, stub.history.Types)
+	assert.Equal(t, 10.0, *stub.history.MinAmount)
+	assert.Equal(t, "rent", stub.history.Search)
+	assert.True(t, stub.history.Ascending)
+	assert.Equal(t, 5, stub.offset)
+
+	for _, body := range []string{
+		`{"types":["refund"]}`,
+		`{"min_amount":20,"max_amount":10}`,
+		`{"page":2,"offset":10}`,
+		`{"from":"2025-02-01T00:00:00Z","to":"2025-01-01T00:00:00Z"}`,
+		`{"limit":1000}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, search(body), body)
+		assert.Nil(t, stub.history, body)
+	}
+}
+
+func TestDeposit_ReadsAmountAsExactDecimal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	validate := config.NewValidator(config.PasswordPolicyConfig{}, config.DescriptionPolicyConfig{})
+	deposit := func(options handler.WalletHandlerOptions, body string) *httptest.ResponseRecorder {
+		h := handler.NewWalletHandlerWithOptions(&stubWalletUsecase{}, logger, validate, options)
+		router := gin.New()
+		router.POST("/deposit", func(c *gin.Context) {
+			c.Set("user_id", uuid.New())
+			c.Next()
+		}, h.Deposit)
+
+		req := httptest.NewRequest(http.MethodPost, "/deposit", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	amountOf := func(w *httptest.ResponseRecorder) float64 {
+		var resp struct {
+			Data params.DepositResponse `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data.Amount
+	}
+
+	for body, want := range map[string]float64{
+		`{"amount":0.1}`:      0.1,
+		`{"amount":"0.30"}`:   0.3,
+		`{"Amount":"1.5e2"}`:  150,
+		`{"amount":12345.67}`: 12345.67,
+	} {
+		w := deposit(handler.WalletHandlerOptions{}, body)
+		assert.Equal(t, http.StatusOK, w.Code, body)
+		assert.Equal(t, want, amountOf(w), body)
+	}
+
+	for body, message := range map[string]string{
+		`{"amount":10.005}`:  "amount must have at most 2 decimal places",
+		`{"amount":"1,000"}`: "amount must be a decimal number such as 10 or 10.50",
+		`{"amount":true}`:    "amount must be a decimal number such as 10 or 10.50",
+	} {
+		w := deposit(handler.WalletHandlerOptions{}, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.JSONEq(t, `{"status":false,"message":"Validation failed","errors":{"Amount":"`+message+`"}}`, w.Body.String(), body)
+	}
+
+	w := deposit(handler.WalletHandlerOptions{LenientAmounts: true}, `{"amount":10.005}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 10.01, amountOf(w))
+}
+
+func TestDeposit_RequiredDescription(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	deposit := func(policy config.DescriptionPolicyConfig, body string) *httptest.ResponseRecorder {
+		validate := config.NewValidator(config.PasswordPolicyConfig{}, policy)
+		h := handler.NewWalletHandler(&stubWalletUsecase{}, logger, validate)
+		router := gin.New()
+		router.POST("/deposit", func(c *gin.Context) {
+			c.Set("user_id", uuid.New())
+			c.Next()
+		}, h.Deposit)
+
+		req := httptest.NewRequest(http.MethodPost, "/deposit", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Off by default: a deposit without a description goes through.
+	w := deposit(config.DescriptionPolicyConfig{}, `{"amount":10}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	required := config.DescriptionPolicyConfig{RequireDeposit: true}
+	w = deposit(required, `{"amount":10}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Validation failed","errors":{"Description":"This field is required"}}`, w.Body.String())
+
+	w = deposit(required, `{"amount":10,"description":"Top up"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestListPlatformTransactions_ParsesTypeAndStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	stub := &stubWalletUsecase{}
+	h := handler.NewWalletHandler(stub, logger, validator.New())
+
+	router := gin.New()
+	router.GET("/admin/transactions", h.ListPlatformTransactions)
+
+	list := func(query string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/transactions"+query, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, list("?type=Withdraw&status=failed"))
+	assert.Equal(t, []entity.TransactionType{entity.TransactionTypeWithdraw}, stub.history.Types)
+	assert.Equal(t, entity.TransactionStatusFailed, stub.history.Status)
+
+	assert.Equal(t, http.StatusBadRequest, list("?status=lost"))
+	assert.Equal(t, http.StatusBadRequest, list("?type=refund"))
+}
@@ -14,6 +14,9 @@ type AuthMiddleware struct {
 	secretKey  string
 	logger     *logrus.Logger
 	jwtManager *token.TokenManager
+	// cookieName is the cookie read when no Authorization header is sent.
+	// Empty disables cookie authentication.
+	cookieName string
 }
 
 func NewAuthMiddleware(secretKey string, logger *logrus.Logger, jwtManager *token.TokenManager) *AuthMiddleware {
@@ -24,38 +27,93 @@ func NewAuthMiddleware(secretKey string, logger *logrus.Logger, jwtManager *toke
 	}
 }
 
+// NewAuthMiddlewareWithCookie builds a middleware that also accepts the token
+// from the named cookie, for browser clients that keep it in an HttpOnly
+// cookie and cannot set the Authorization header.
+func NewAuthMiddlewareWithCookie(secretKey string, logger *logrus.Logger, jwtManager *token.TokenManager, cookieName string) *AuthMiddleware {
+	m := NewAuthMiddleware(secretKey, logger, jwtManager)
+	m.cookieName = cookieName
+	return m
+}
+
 func (m *AuthMiddleware) JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			resp := response.UnauthorizedErrorWithAdditionalInfo(nil, "Authorization header is required")
-			c.AbortWithStatusJSON(resp.StatusCode, resp)
+		bearerToken, errMessage := m.extractToken(c)
+		if errMessage != "" {
+			resp := response.UnauthorizedErrorWithAdditionalInfo(nil, errMessage)
+			response.Abort(c, resp)
 			return
 		}
 
-		bearerToken := strings.Split(authHeader, "Bearer ")
-
-		if len(bearerToken) != 2 {
-			resp := response.UnauthorizedErrorWithAdditionalInfo(nil, "len token must be 2")
-			c.AbortWithStatusJSON(resp.StatusCode, resp)
-			return
-		}
-
-		payload, err := m.jwtManager.ValidateToken(bearerToken[1])
+		payload, err := m.jwtManager.ValidateToken(bearerToken)
 		if err != nil {
 			resp := response.UnauthorizedErrorWithAdditionalInfo(err.Error())
-			c.AbortWithStatusJSON(resp.StatusCode, resp)
+			response.Abort(c, resp)
 			return
 		}
 
 		userID, err := uuid.Parse(payload.AuthId)
 		if err != nil {
 			resp := response.UnauthorizedErrorWithAdditionalInfo(nil, "Invalid user ID in token")
-			c.AbortWithStatusJSON(resp.StatusCode, resp)
+			response.Abort(c, resp)
 			return
 		}
 
 		c.Set("user_id", userID)
+		c.Set("role", payload.Role)
+		c.Next()
+	}
+}
+
+// RequireRole only lets through requests whose token carries the given role.
+// It must run after JWTAuth.
+func (m *AuthMiddleware) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != role {
+			userID, _ := c.Get("user_id")
+			m.logger.WithFields(logrus.Fields{
+				"user_id": userID,
+				"path":    c.Request.URL.Path,
+			}).Warn("Forbidden access attempt")
+			resp := response.ForbiddenError("insufficient permissions")
+			response.Abort(c, resp)
+			return
+		}
 		c.Next()
 	}
 }
+
+// extractToken returns the token from the Authorization header or, when the
+// header is absent and cookie authentication is enabled, from the cookie. The
+// header always wins so that an explicit credential is never overridden.
+func (m *AuthMiddleware) extractToken(c *gin.Context) (string, string) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "" {
+		return parseBearerToken(authHeader)
+	}
+
+	if m.cookieName != "" {
+		if cookie, err := c.Cookie(m.cookieName); err == nil && cookie != "" {
+			return cookie, ""
+		}
+	}
+
+	return "", "Authorization header is required"
+}
+
+// parseBearerToken extracts the token from an Authorization header value. The
+// scheme is matched case-insensitively and surrounding whitespace is ignored.
+// On failure it returns a message suitable for the client.
+func parseBearerToken(header string) (string, string) {
+	fields := strings.Fields(header)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "Bearer") {
+		return "", "Authorization header must use the Bearer scheme"
+	}
+	if len(fields) == 1 {
+		return "", "Bearer token is missing"
+	}
+	if len(fields) > 2 {
+		return "", "Authorization header must be in the format: Bearer <token>"
+	}
+	return fields[1], ""
+}
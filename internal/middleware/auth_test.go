@@ -0,0 +1,154 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/middleware"
+	"go-digital-wallet/pkg/token"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuthTest(t *testing.T) (*gin.Engine, string) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	jwtManager := token.NewTokenManager("test-secret", 1)
+	authMiddleware := middleware.NewAuthMiddleware("test-secret", logger, jwtManager)
+
+	router := gin.New()
+	router.GET("/protected", authMiddleware.JWTAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tokenStr, err := jwtManager.GenerateToken(uuid.New(), "user")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	return router, tokenStr
+}
+
+func performAuthRequest(router *gin.Engine, authHeader string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestJWTAuth_LowercaseScheme(t *testing.T) {
+	router, tokenStr := setupAuthTest(t)
+
+	w := performAuthRequest(router, "bearer "+tokenStr)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestJWTAuth_DoubleSpace(t *testing.T) {
+	router, tokenStr := setupAuthTest(t)
+
+	w := performAuthRequest(router, "Bearer  "+tokenStr)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestJWTAuth_LeadingAndTrailingSpaces(t *testing.T) {
+	router, tokenStr := setupAuthTest(t)
+
+	w := performAuthRequest(router, "  Bearer "+tokenStr+"  ")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestJWTAuth_MissingToken(t *testing.T) {
+	router, _ := setupAuthTest(t)
+
+	w := performAuthRequest(router, "Bearer")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	var resp response.CustomError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Bearer token is missing", resp.Message)
+}
+
+func TestJWTAuth_WrongScheme(t *testing.T) {
+	router, tokenStr := setupAuthTest(t)
+
+	w := performAuthRequest(router, "Basic "+tokenStr)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	var resp response.CustomError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Authorization header must use the Bearer scheme", resp.Message)
+}
+
+func setupCookieAuthTest(t *testing.T) (*gin.Engine, string) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	jwtManager := token.NewTokenManager("test-secret", 1)
+	authMiddleware := middleware.NewAuthMiddlewareWithCookie("test-secret", logger, jwtManager, "access_token")
+
+	router := gin.New()
+	router.GET("/protected", authMiddleware.JWTAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tokenStr, err := jwtManager.GenerateToken(uuid.New(), "user")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	return router, tokenStr
+}
+
+func performCookieRequest(router *gin.Engine, authHeader, cookie string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: cookie})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestJWTAuth_CookieFallback(t *testing.T) {
+	router, tokenStr := setupCookieAuthTest(t)
+
+	w := performCookieRequest(router, "", tokenStr)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestJWTAuth_HeaderTakesPrecedenceOverCookie(t *testing.T) {
+	router, tokenStr := setupCookieAuthTest(t)
+
+	w := performCookieRequest(router, "Bearer invalid-token", tokenStr)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestJWTAuth_CookieIgnoredWhenDisabled(t *testing.T) {
+	router, tokenStr := setupAuthTest(t)
+
+	w := performCookieRequest(router, "", tokenStr)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	var resp response.CustomError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Authorization header is required", resp.Message)
+}
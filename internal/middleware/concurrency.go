@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"go-digital-wallet/internal/commons/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ConcurrencyLimit caps how many requests are processed at once, so that a
+// traffic surge is shed at the edge instead of piling up on the database
+// connection pool. A request arriving when all maxInFlight slots are taken
+// waits up to queueTimeout for one to free up; with a zero queueTimeout it is
+// rejected straight away. Rejected requests get a 503 with Retry-After.
+// A maxInFlight of zero or less disables the limit.
+func ConcurrencyLimit(maxInFlight int, queueTimeout time.Duration, retryAfterSeconds int, logger *logrus.Logger) gin.HandlerFunc {
+	if maxInFlight <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	slots := make(chan struct{}, maxInFlight)
+
+	return func(c *gin.Context) {
+		if !acquireSlot(c, slots, queueTimeout) {
+			logger.WithFields(logrus.Fields{
+				"path":          c.FullPath(),
+				"max_in_flight": maxInFlight,
+			}).Warn("Too many requests in flight, shedding load")
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+			response.Abort(c, response.ServiceUnavailableError("server is busy, please retry later"))
+			return
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
+
+// acquireSlot takes a slot, waiting at most queueTimeout, and gives up early
+// when the client goes away.
+func acquireSlot(c *gin.Context, slots chan struct{}, queueTimeout time.Duration) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+	if queueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
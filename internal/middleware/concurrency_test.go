@@ -0,0 +1,96 @@
+package middleware_test
+
+import (
+	"go-digital-wallet/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupConcurrencyTest serves /slow, which holds its slot until release is
+// closed, and /fast.
+func setupConcurrencyTest(maxInFlight int, queueTimeout time.Duration) (*gin.Engine, chan struct{}, chan struct{}) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	router := gin.New()
+	router.Use(middleware.ConcurrencyLimit(maxInFlight, queueTimeout, 2, logger))
+	router.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return router, started, release
+}
+
+func serve(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestConcurrencyLimit_RejectsWhenFull(t *testing.T) {
+	router, started, release := setupConcurrencyTest(1, 0)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve(router, "/slow") }()
+	<-started
+
+	w := serve(router, "/fast")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, http.StatusOK, serve(router, "/fast").Code)
+}
+
+func TestConcurrencyLimit_QueuesUntilSlotFrees(t *testing.T) {
+	router, started, release := setupConcurrencyTest(1, time.Second)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve(router, "/slow") }()
+	<-started
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	assert.Equal(t, http.StatusOK, serve(router, "/fast").Code)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+}
+
+func TestConcurrencyLimit_QueueTimesOut(t *testing.T) {
+	router, started, release := setupConcurrencyTest(1, 20*time.Millisecond)
+	defer close(release)
+
+	go serve(router, "/slow")
+	<-started
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(router, "/fast").Code)
+}
+
+func TestConcurrencyLimit_ZeroDisablesLimit(t *testing.T) {
+	router, started, release := setupConcurrencyTest(0, 0)
+	defer close(release)
+
+	go serve(router, "/slow")
+	<-started
+
+	assert.Equal(t, http.StatusOK, serve(router, "/fast").Code)
+}
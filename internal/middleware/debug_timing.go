@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"go-digital-wallet/internal/commons/timing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugTimingHeader asks for a Server-Timing breakdown of the request.
+const DebugTimingHeader = "X-Debug-Timing"
+
+// DebugTiming answers requests sent with X-Debug-Timing by a user of the
+// given role with a Server-Timing header splitting the time spent into cache,
+// db and serialize phases plus the total. The header is ignored for everyone
+// else, since timings tell a lot about how the service works. It must run
+// after JWTAuth.
+func DebugTiming(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(DebugTimingHeader) == "" || c.GetString("role") != role {
+			c.Next()
+			return
+		}
+
+		collector := timing.NewCollector()
+		c.Request = c.Request.WithContext(timing.NewContext(c.Request.Context(), collector))
+		// A route Timeout rebuilds the request context from the one saved
+		// by the group Timeout, so the collector must be on that one too.
+		if base, ok := c.Get(timeoutBaseKey); ok {
+			c.Set(timeoutBaseKey, timing.NewContext(base.(context.Context), collector))
+		}
+		c.Writer = &timingWriter{ResponseWriter: c.Writer, collector: collector, start: time.Now()}
+
+		c.Next()
+	}
+}
+
+// timingWriter sets the Server-Timing header just before the response is
+// written. Gin sets the status before encoding the body and writes it right
+// after, so the time in between is the serialization.
+type timingWriter struct {
+	gin.ResponseWriter
+	collector *timing.Collector
+	start     time.Time
+	statusAt  time.Time
+	attached  bool
+}
+
+func (w *timingWriter) WriteHeader(code int) {
+	w.statusAt = time.Now()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.attach()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	w.attach()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.attach()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timingWriter) attach() {
+	if w.attached || w.Written() {
+		return
+	}
+	w.attached = true
+
+	now := time.Now()
+	if !w.statusAt.IsZero() {
+		w.collector.Add("serialize", now.Sub(w.statusAt))
+	}
+	w.collector.Add("total", now.Sub(w.start))
+	w.Header().Set("Server-Timing", w.collector.ServerTiming())
+}
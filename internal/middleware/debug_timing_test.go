@@ -0,0 +1,52 @@
+package middleware_test
+
+import (
+	"go-digital-wallet/internal/commons/timing"
+	"go-digital-wallet/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDebugTimingTest(role string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.Timeout(time.Second), func(c *gin.Context) {
+		c.Set("role", role)
+		c.Next()
+	}, middleware.DebugTiming("admin"))
+	// The route Timeout rebuilds the context and must keep the collector.
+	router.GET("/balance", middleware.Timeout(time.Second), func(c *gin.Context) {
+		timing.Add(c.Request.Context(), "db", 3*time.Millisecond)
+		c.JSON(http.StatusOK, gin.H{"balance": 100})
+	})
+	return router
+}
+
+func requestTiming(router *gin.Engine, debug bool) string {
+	req := httptest.NewRequest(http.MethodGet, "/balance", nil)
+	if debug {
+		req.Header.Set(middleware.DebugTimingHeader, "1")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Header().Get("Server-Timing")
+}
+
+func TestDebugTiming_ReportsPhasesToAdmins(t *testing.T) {
+	header := requestTiming(setupDebugTimingTest("admin"), true)
+
+	assert.Contains(t, header, "db;dur=3.00")
+	assert.Contains(t, header, "serialize;dur=")
+	assert.Contains(t, header, "total;dur=")
+}
+
+func TestDebugTiming_IgnoredForOthers(t *testing.T) {
+	assert.Empty(t, requestTiming(setupDebugTimingTest("user"), true))
+	assert.Empty(t, requestTiming(setupDebugTimingTest("admin"), false))
+}
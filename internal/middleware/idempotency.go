@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// IdempotencyKeyHeader names the client's key for one logical operation.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a repeated
+	// key, so that clients can tell a new operation from one already done.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	defaultIdempotencyTTL   = 24 * time.Hour
+
+	idempotencyStatusProcessing = "processing"
+	idempotencyStatusCompleted  = "completed"
+)
+
+// idempotencyEntry is what is stored under a key: the hash of the request
+// body it was first used with and, once completed, the response to replay.
+type idempotencyEntry struct {
+	RequestHash string `json:"request_hash"`
+	Status      string `json:"status"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+type IdempotencyMiddleware struct {
+	cache  *redis.Client
+	logger *logrus.Logger
+	ttl    time.Duration
+}
+
+// NewIdempotencyMiddleware remembers keys and their responses for ttl; zero
+// uses 24 hours.
+func NewIdempotencyMiddleware(cache *redis.Client, logger *logrus.Logger, ttl time.Duration) *IdempotencyMiddleware {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return &IdempotencyMiddleware{
+		cache:  cache,
+		logger: logger,
+		ttl:    ttl,
+	}
+}
+
+// Idempotent makes a route safe to retry with an Idempotency-Key. The first
+// request with a key runs and, when it succeeds, its status and body are
+// stored; a repeat with the same key, caller, method and path gets them
+// replayed verbatim without running the handler. Keys are only remembered for
+// successful responses, so a request that failed can be retried with the same
+// key.
+//
+// Reusing a key with a different body is rejected with 422, and a key whose
+// first request is still running with 409. Without Redis a key cannot be
+// honoured, and refusing with 503 is safer than risking the double charge the
+// client sent the key to prevent. Requests without a key pass through. It
+// must run after JWTAuth.
+func (m *IdempotencyMiddleware) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			response.Abort(c, response.BadRequestError(fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)))
+			return
+		}
+		if m.cache == nil {
+			response.Abort(c, response.ServiceUnavailableError("idempotency keys are temporarily unavailable"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.Abort(c, response.BadRequestError("failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+
+		caller := c.ClientIP()
+		if userID, ok := c.Get("user_id"); ok {
+			caller = fmt.Sprint(userID)
+		}
+		cacheKey := fmt.Sprintf("idempotency:%s:%s %s:%s", caller, c.Request.Method, c.Request.URL.Path, key)
+		log := m.logger.WithFields(logrus.Fields{
+			"caller":          caller,
+			"path":            c.Request.URL.Path,
+			"idempotency_key": key,
+		})
+
+		ctx := c.Request.Context()
+		processing, _ := json.Marshal(idempotencyEntry{RequestHash: hash, Status: idempotencyStatusProcessing})
+		claimed, err := m.cache.SetNX(ctx, cacheKey, processing, m.ttl).Result()
+		if err != nil {
+			log.WithError(err).Error("Failed to claim idempotency key")
+			response.Abort(c, response.GeneralError("failed to check idempotency key"))
+			return
+		}
+		if !claimed {
+			m.replay(c, log, cacheKey, hash)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		c.Next()
+
+		// The request context may be done by now; the key must still be
+		// stored or released.
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if !recorder.Written() || status < http.StatusOK || status >= http.StatusBadRequest {
+			if err := m.cache.Del(ctx, cacheKey).Err(); err != nil {
+				log.WithError(err).Warn("Failed to release idempotency key")
+			}
+			return
+		}
+		completed, _ := json.Marshal(idempotencyEntry{
+			RequestHash: hash,
+			Status:      idempotencyStatusCompleted,
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err := m.cache.Set(ctx, cacheKey, completed, m.ttl).Err(); err != nil {
+			log.WithError(err).Warn("Failed to store idempotent response")
+		}
+	}
+}
+
+// replay answers a request whose key was already claimed.
+func (m *IdempotencyMiddleware) replay(c *gin.Context, log *logrus.Entry, cacheKey, hash string) {
+	val, err := m.cache.Get(c.Request.Context(), cacheKey).Bytes()
+	if err != nil {
+		log.WithError(err).Error("Failed to read idempotency key")
+		response.Abort(c, response.GeneralError("failed to check idempotency key"))
+		return
+	}
+	var existing idempotencyEntry
+	if err := json.Unmarshal(val, &existing); err != nil {
+		response.Abort(c, response.GeneralError("failed to check idempotency key"))
+		return
+	}
+
+	if existing.RequestHash != hash {
+		log.Warn("Idempotency key reused with a different request")
+		response.Abort(c, response.UnprocessableEntityError("Idempotency-Key was already used with a different request"))
+		return
+	}
+	if existing.Status != idempotencyStatusCompleted {
+		response.Abort(c, response.ConflictError("a request with this Idempotency-Key is still being processed"))
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+	c.Abort()
+}
+
+// recordingWriter keeps a copy of the response body as it is written.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,127 @@
+package middleware_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupIdempotencyTest serves POST /operations/:id with a handler that
+// answers with how many times it ran, failing while fail is set.
+func setupIdempotencyTest(t *testing.T, client *redis.Client) (*gin.Engine, *int, *bool) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	idempotency := middleware.NewIdempotencyMiddleware(client, logger, time.Hour)
+
+	runs, fail := 0, false
+	router := gin.New()
+	router.POST("/operations/:id", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-User"))
+		c.Next()
+	}, idempotency.Idempotent(), func(c *gin.Context) {
+		runs++
+		if fail {
+			response.Abort(c, response.GeneralError("failed"))
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"run": runs})
+	})
+
+	return router, &runs, &fail
+}
+
+func performIdempotentRequest(router *gin.Engine, path, user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotent_ReplaysStoredResponse(t *testing.T) {
+	router, runs, _ := setupIdempotencyTest(t, redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+
+	first := performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+
+	replayed := performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+	assert.Equal(t, first.Header().Get("Content-Type"), replayed.Header().Get("Content-Type"))
+	assert.Equal(t, "true", replayed.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, 1, *runs)
+
+	// Keys are scoped to the caller and the path; without one every request runs.
+	assert.Equal(t, http.StatusCreated, performIdempotentRequest(router, "/operations/1", "bob", "key-1", `{"amount":100}`).Code)
+	assert.Equal(t, http.StatusCreated, performIdempotentRequest(router, "/operations/2", "alice", "key-1", `{"amount":100}`).Code)
+	assert.Equal(t, http.StatusCreated, performIdempotentRequest(router, "/operations/1", "alice", "", `{"amount":100}`).Code)
+	assert.Equal(t, 4, *runs)
+}
+
+func TestIdempotent_RejectsKeyReusedWithDifferentBody(t *testing.T) {
+	router, runs, _ := setupIdempotencyTest(t, redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+
+	assert.Equal(t, http.StatusCreated, performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":100}`).Code)
+
+	w := performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":700}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, *runs)
+}
+
+func TestIdempotent_RejectsKeyStillProcessing(t *testing.T) {
+	mr := miniredis.RunT(t)
+	router, runs, _ := setupIdempotencyTest(t, redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	// A first request holding the key that has not finished yet.
+	sum := sha256.Sum256([]byte(`{"amount":100}`))
+	mr.Set("idempotency:alice:POST /operations/1:key-1", `{"request_hash":"`+hex.EncodeToString(sum[:])+`","status":"processing"}`)
+
+	w := performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":100}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 0, *runs)
+}
+
+func TestIdempotent_FailedRequestReleasesKey(t *testing.T) {
+	router, runs, fail := setupIdempotencyTest(t, redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+
+	*fail = true
+	assert.Equal(t, http.StatusInternalServerError, performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":100}`).Code)
+
+	*fail = false
+	w := performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, 2, *runs)
+}
+
+func TestIdempotent_NilCacheRejectsKey(t *testing.T) {
+	router, runs, _ := setupIdempotencyTest(t, nil)
+
+	w := performIdempotentRequest(router, "/operations/1", "alice", "key-1", `{"amount":100}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var resp response.CustomError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ERR0009", resp.Code)
+
+	assert.Equal(t, http.StatusCreated, performIdempotentRequest(router, "/operations/1", "alice", "", `{"amount":100}`).Code)
+	assert.Equal(t, 1, *runs)
+}
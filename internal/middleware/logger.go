@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -8,6 +9,16 @@ import (
 )
 
 func LoggerMiddleware(logger *logrus.Logger) gin.HandlerFunc {
+	return SampledLoggerMiddleware(logger, 1, 0)
+}
+
+// SampledLoggerMiddleware logs only one in sampleEvery successful requests to
+// keep the access log volume down. Errors (status >= 400) and requests slower
+// than slowThreshold are always logged. A sampleEvery of 1 or less logs every
+// request, and a zero slowThreshold disables the slow request check.
+func SampledLoggerMiddleware(logger *logrus.Logger, sampleEvery int, slowThreshold time.Duration) gin.HandlerFunc {
+	var seen atomic.Uint64
+
 	return func(c *gin.Context) {
 		start := time.Now()
 
@@ -16,6 +27,11 @@ func LoggerMiddleware(logger *logrus.Logger) gin.HandlerFunc {
 		latency := time.Since(start)
 
 		statusCode := c.Writer.Status()
+		slow := slowThreshold > 0 && latency >= slowThreshold
+
+		if statusCode < 400 && !slow && sampleEvery > 1 && seen.Add(1)%uint64(sampleEvery) != 0 {
+			return
+		}
 
 		entry := logger.WithFields(logrus.Fields{
 			"method":     c.Request.Method,
@@ -25,11 +41,18 @@ func LoggerMiddleware(logger *logrus.Logger) gin.HandlerFunc {
 			"latency":    latency,
 			"ip":         c.ClientIP(),
 			"user_agent": c.Request.UserAgent(),
+			"request_id": c.GetString("request_id"),
 		})
 
-		if statusCode >= 400 {
+		switch {
+		case statusCode >= 400:
 			entry.Error("HTTP request completed with error")
-		} else {
+		case slow:
+			entry.Warn("HTTP request completed slowly")
+		default:
+			if sampleEvery > 1 {
+				entry = entry.WithField("sample_every", sampleEvery)
+			}
 			entry.Info("HTTP request completed")
 		}
 	}
@@ -0,0 +1,72 @@
+package middleware_test
+
+import (
+	"go-digital-wallet/internal/middleware"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSampledLoggerTest(sampleEvery int, slowThreshold time.Duration) (*gin.Engine, *test.Hook) {
+	gin.SetMode(gin.TestMode)
+
+	logger, hook := test.NewNullLogger()
+
+	router := gin.New()
+	router.Use(middleware.SampledLoggerMiddleware(logger, sampleEvery, slowThreshold))
+	router.GET("/ok", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.Status(http.StatusBadRequest)
+	})
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(20 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	return router, hook
+}
+
+func TestSampledLogger_KeepsAllErrors(t *testing.T) {
+	router, hook := setupSampledLoggerTest(100, 0)
+
+	for i := 0; i < 50; i++ {
+		serve(router, "/ok")
+		serve(router, "/fail")
+	}
+
+	errors := 0
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.ErrorLevel {
+			errors++
+		}
+	}
+	assert.Equal(t, 50, errors)
+	assert.Len(t, hook.AllEntries(), 50, "no successful request reached the 1 in 100 sample")
+}
+
+func TestSampledLogger_LogsOneInN(t *testing.T) {
+	router, hook := setupSampledLoggerTest(10, 0)
+
+	for i := 0; i < 30; i++ {
+		serve(router, "/ok")
+	}
+
+	assert.Len(t, hook.AllEntries(), 3)
+}
+
+func TestSampledLogger_KeepsSlowRequests(t *testing.T) {
+	router, hook := setupSampledLoggerTest(100, 10*time.Millisecond)
+
+	serve(router, "/slow")
+
+	if assert.Len(t, hook.AllEntries(), 1) {
+		assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/usecase"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type MaintenanceMiddleware struct {
+	maintenance       usecase.MaintenanceUsecase
+	logger            *logrus.Logger
+	retryAfterSeconds int
+}
+
+func NewMaintenanceMiddleware(maintenance usecase.MaintenanceUsecase, logger *logrus.Logger, retryAfterSeconds int) *MaintenanceMiddleware {
+	return &MaintenanceMiddleware{
+		maintenance:       maintenance,
+		logger:            logger,
+		retryAfterSeconds: retryAfterSeconds,
+	}
+}
+
+// BlockWrites rejects the request with 503 while maintenance mode is on. It is
+// applied to write routes only, so reads and auth keep working. If the flag
+// cannot be read the request is let through.
+func (m *MaintenanceMiddleware) BlockWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, err := m.maintenance.IsEnabled(c.Request.Context())
+		if err != nil {
+			m.logger.WithError(err).Warn("Failed to check maintenance mode")
+			c.Next()
+			return
+		}
+
+		if enabled {
+			c.Header("Retry-After", strconv.Itoa(m.retryAfterSeconds))
+			resp := response.ServiceUnavailableError("service is under maintenance, please retry later")
+			response.Abort(c, resp)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,90 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRateLimitTest(t *testing.T, limit int) (*gin.Engine, *miniredis.Miniredis) {
+	gin.SetMode(gin.TestMode)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	limiter := middleware.NewRateLimiter(client, logger)
+
+	router := gin.New()
+	router.GET("/limited", limiter.Limit("test", limit, time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return router, mr
+}
+
+func performLimitedRequest(router *gin.Engine) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_ExceedingLimit(t *testing.T) {
+	router, _ := setupRateLimitTest(t, 2)
+
+	assert.Equal(t, http.StatusOK, performLimitedRequest(router).Code)
+	assert.Equal(t, http.StatusOK, performLimitedRequest(router).Code)
+
+	w := performLimitedRequest(router)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	var resp response.CustomError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ERR0010", resp.Code)
+}
+
+func TestRateLimit_RedisDownFailsOpen(t *testing.T) {
+	router, mr := setupRateLimitTest(t, 1)
+	mr.Close()
+
+	assert.Equal(t, http.StatusOK, performLimitedRequest(router).Code)
+	assert.Equal(t, http.StatusOK, performLimitedRequest(router).Code)
+}
+
+func TestLimitWhen_CountsOnlyMatchingRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	mr := miniredis.RunT(t)
+	limiter := middleware.NewRateLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), logger)
+
+	noCache := func(c *gin.Context) bool { return c.GetHeader("Cache-Control") == "no-cache" }
+	router := gin.New()
+	router.GET("/limited", limiter.LimitWhen(noCache, "test", 1, time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	perform := func(cacheControl string) int {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.Header.Set("Cache-Control", cacheControl)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, perform("no-cache"))
+	assert.Equal(t, http.StatusTooManyRequests, perform("no-cache"))
+	assert.Equal(t, http.StatusOK, perform(""))
+}
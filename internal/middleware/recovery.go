@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"net"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RecoveryMiddleware turns a panic in any later handler into a JSON 500
+// CustomError carrying the request id, instead of gin's plain text response.
+// It must run after RequestIDMiddleware.
+func RecoveryMiddleware(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			entry := logger.WithFields(logrus.Fields{
+				"panic":      r,
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+				"request_id": c.GetString("request_id"),
+			})
+
+			// A client that went away cannot be sent a response, and the
+			// stack trace would only be noise.
+			if isBrokenConnection(r) {
+				entry.Warn("Client connection lost")
+				c.Abort()
+				return
+			}
+
+			entry.WithField("stack", string(debug.Stack())).Error("Recovered from panic")
+			response.Abort(c, response.GeneralError("internal server error"))
+		}()
+
+		c.Next()
+	}
+}
+
+func isBrokenConnection(r interface{}) bool {
+	err, ok := r.(error)
+	if !ok {
+		return false
+	}
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if !errors.As(opErr, &syscallErr) {
+		return false
+	}
+
+	msg := strings.ToLower(syscallErr.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
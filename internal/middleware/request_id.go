@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"go-digital-wallet/internal/commons/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds client supplied ids so they cannot bloat logs.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware tags every request with an id, reusing the caller's
+// X-Request-ID when present. The id is echoed in the response and stored on
+// the request context so that downstream logs can be correlated.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+
+		c.Set("request_id", id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+
+		c.Next()
+	}
+}
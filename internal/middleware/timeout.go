@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutBaseKey holds the request context as it was before any deadline was
+// applied.
+const timeoutBaseKey = "timeout_base_context"
+
+// Timeout gives the request context a deadline of d. Applied again further
+// down the chain, e.g. on a single route, it replaces the earlier deadline
+// instead of nesting within it, so a route can get more time than its group.
+// Zero leaves the request without a deadline.
+//
+// Handlers are not interrupted; they see the deadline through the context and
+// their errors are turned into a 503 by response.Abort. A handler that
+// returns without writing anything after the deadline also gets a 503.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		base := c.Request.Context()
+		if saved, ok := c.Get(timeoutBaseKey); ok {
+			base = saved.(context.Context)
+		} else {
+			c.Set(timeoutBaseKey, base)
+		}
+
+		if d <= 0 {
+			c.Request = c.Request.WithContext(base)
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(base, d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if !c.Writer.Written() && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			response.Abort(c, response.TimeoutError())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout_DeadlineErrorBecomes503(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.Timeout(10 * time.Millisecond))
+	router.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		response.Abort(c, response.RepositoryError("failed to get wallet"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var body response.CustomError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "request timed out", body.Message)
+}
+
+func TestTimeout_NothingWrittenBecomes503(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.Timeout(10 * time.Millisecond))
+	router.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestTimeout_RouteOverrideExtendsGroupDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var remaining time.Duration
+	router := gin.New()
+	router.Use(middleware.Timeout(10 * time.Millisecond))
+	router.GET("/export", middleware.Timeout(time.Minute), func(c *gin.Context) {
+		deadline, _ := c.Request.Context().Deadline()
+		remaining = time.Until(deadline)
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Greater(t, remaining, 50*time.Second)
+}
+
+func TestTimeout_ZeroRemovesDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var hasDeadline bool
+	router := gin.New()
+	router.Use(middleware.Timeout(10 * time.Millisecond))
+	router.GET("/unbounded", middleware.Timeout(0), func(c *gin.Context) {
+		_, hasDeadline = c.Request.Context().Deadline()
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unbounded", nil))
+
+	assert.False(t, hasDeadline)
+}
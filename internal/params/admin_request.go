@@ -0,0 +1,5 @@
+package params
+
+type MaintenanceRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
@@ -0,0 +1,61 @@
+package params
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MaintenanceResponse struct {
+	Enabled bool `json:"enabled"`
+	// Forced is true when maintenance is switched on by static configuration
+	// and cannot be turned off at runtime.
+	Forced bool `json:"forced"`
+}
+
+// UserSummaryResponse exposes the fields of a user that support staff may see.
+type UserSummaryResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserSearchResponse struct {
+	Users  []*UserSummaryResponse `json:"users"`
+	Total  int64                  `json:"total"`
+	Limit  int                    `json:"limit"`
+	Offset int                    `json:"offset"`
+}
+
+type CurrencyBalanceResponse struct {
+	Currency string  `json:"currency"`
+	Total    float64 `json:"total"`
+	Wallets  int64   `json:"wallets"`
+}
+
+// TotalBalanceResponse is the money held across all customer wallets, one entry per
+// currency. It may be up to a short cache lifetime old, see GeneratedAt.
+type TotalBalanceResponse struct {
+	Currencies  []*CurrencyBalanceResponse `json:"currencies"`
+	GeneratedAt time.Time                  `json:"generated_at"`
+}
+
+// VelocityWindowResponse is the completed transactions of a wallet within one
+// window, counted and totalled regardless of direction.
+type VelocityWindowResponse struct {
+	Count int64   `json:"count"`
+	Total float64 `json:"total"`
+}
+
+// TransactionVelocityResponse is a wallet's recent activity for fraud review.
+// It may be up to a short cache lifetime old, see GeneratedAt.
+type TransactionVelocityResponse struct {
+	WalletID    uuid.UUID              `json:"wallet_id"`
+	Currency    string                 `json:"currency"`
+	LastHour    VelocityWindowResponse `json:"last_hour"`
+	LastDay     VelocityWindowResponse `json:"last_day"`
+	LastWeek    VelocityWindowResponse `json:"last_week"`
+	GeneratedAt time.Time              `json:"generated_at"`
+}
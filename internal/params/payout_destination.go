@@ -0,0 +1,29 @@
+package params
+
+import (
+	"go-digital-wallet/internal/entity"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PayoutDestinationRequest registers an account to withdraw to.
+type PayoutDestinationRequest struct {
+	Type          entity.PayoutDestinationType `json:"type" validate:"required,oneof=bank_account e_wallet"`
+	Provider      string                       `json:"provider" validate:"required,max=100"`
+	AccountName   string                       `json:"account_name" validate:"required,max=255"`
+	AccountNumber string                       `json:"account_number" validate:"required,max=64,numeric"`
+}
+
+// PayoutDestinationResponse shows a destination with all but the last four
+// digits of the account number masked.
+type PayoutDestinationResponse struct {
+	ID            uuid.UUID                    `json:"id"`
+	Type          entity.PayoutDestinationType `json:"type"`
+	Provider      string                       `json:"provider"`
+	AccountName   string                       `json:"account_name"`
+	AccountNumber string                       `json:"account_number"`
+	Verified      bool                         `json:"verified"`
+	VerifiedAt    *time.Time                   `json:"verified_at,omitempty"`
+	CreatedAt     time.Time                    `json:"created_at"`
+}
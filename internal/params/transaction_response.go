@@ -8,13 +8,28 @@ import (
 )
 
 type TransactionResponse struct {
-	ID          uuid.UUID                `json:"id"`
+	ID uuid.UUID `json:"id"`
+	// DisplayID is a short id derived from ID for receipts. ID stays the
+	// canonical key.
+	DisplayID   string                   `json:"display_id"`
 	Type        entity.TransactionType   `json:"type"`
 	Amount      float64                  `json:"amount"`
 	Description *string                  `json:"description,omitempty"`
 	Status      entity.TransactionStatus `json:"status"`
 	CreatedAt   time.Time                `json:"created_at"`
 	UpdatedAt   time.Time                `json:"updated_at"`
+	// PayoutDestinationID is where a withdrawal was paid out to.
+	PayoutDestinationID *uuid.UUID `json:"payout_destination_id,omitempty"`
+	// ExchangeRate, OriginalAmount and ConvertedAmount describe the
+	// conversion of a transfer between currencies.
+	ExchangeRate    *float64 `json:"exchange_rate,omitempty"`
+	OriginalAmount  *float64 `json:"original_amount,omitempty"`
+	ConvertedAmount *float64 `json:"converted_amount,omitempty"`
+	// TransferID links the legs of one transfer, such as a payment and its
+	// tip.
+	TransferID *uuid.UUID `json:"transfer_id,omitempty"`
+	// ClearedAt is when a deposit on hold can be withdrawn.
+	ClearedAt *time.Time `json:"cleared_at,omitempty"`
 }
 
 type TransactionHistoryResponse struct {
@@ -23,4 +38,101 @@ type TransactionHistoryResponse struct {
 	Page         int                    `json:"page"`
 	Limit        int                    `json:"limit"`
 	TotalPages   int                    `json:"total_pages"`
+
+	TotalDeposited *float64 `json:"total_deposited,omitempty"`
+	TotalWithdrawn *float64 `json:"total_withdrawn,omitempty"`
+}
+
+// ActivityTransactionResponse is a transaction in the combined activity feed,
+// tagged with the wallet it belongs to.
+type ActivityTransactionResponse struct {
+	TransactionResponse
+	WalletID uuid.UUID `json:"wallet_id"`
+	Currency string    `json:"currency"`
+}
+
+type ActivityResponse struct {
+	Transactions []*ActivityTransactionResponse `json:"transactions"`
+	Total        int64                          `json:"total"`
+	Page         int                            `json:"page"`
+	Limit        int                            `json:"limit"`
+	TotalPages   int                            `json:"total_pages"`
+}
+
+// PlatformTransactionResponse is a transaction in the admin listing across
+// all wallets, tagged with its wallet and owner.
+type PlatformTransactionResponse struct {
+	ActivityTransactionResponse
+	UserID    uuid.UUID `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	UserEmail string    `json:"user_email"`
+}
+
+type PlatformTransactionsResponse struct {
+	Transactions []*PlatformTransactionResponse `json:"transactions"`
+	Total        int64                          `json:"total"`
+	Page         int                            `json:"page"`
+	Limit        int                            `json:"limit"`
+	TotalPages   int                            `json:"total_pages"`
+}
+
+type InsightBucketResponse struct {
+	Bucket        time.Time `json:"bucket"`
+	TotalDeposit  float64   `json:"total_deposit"`
+	TotalWithdraw float64   `json:"total_withdraw"`
+	Net           float64   `json:"net"`
+}
+
+type InsightsResponse struct {
+	Granularity string                   `json:"granularity"`
+	From        *time.Time               `json:"from,omitempty"`
+	To          *time.Time               `json:"to,omitempty"`
+	Buckets     []*InsightBucketResponse `json:"buckets"`
+}
+
+// StatementFile is a rendered statement export ready to be downloaded.
+type StatementFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	// Signature is set when the statement was exported signed.
+	Signature *StatementSignature
+}
+
+// StatementSignature is the detached signature of a statement file and the
+// metadata it covers besides the file. Value is base64 encoded.
+type StatementSignature struct {
+	Algorithm     string    `json:"algorithm"`
+	Value         string    `json:"signature"`
+	WalletID      uuid.UUID `json:"wallet_id"`
+	WalletVersion int       `json:"wallet_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
+
+// StatementVerification is the outcome of checking a statement against its
+// signature.
+type StatementVerification struct {
+	Valid         bool      `json:"valid"`
+	Algorithm     string    `json:"algorithm"`
+	WalletID      uuid.UUID `json:"wallet_id"`
+	WalletVersion int       `json:"wallet_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
+
+// TransactionImportReport is the outcome of a bulk transaction import. Rows
+// are numbered from 1, not counting a CSV header.
+type TransactionImportReport struct {
+	// DryRun is set when rows were only validated and nothing was written.
+	DryRun bool `json:"dry_run"`
+	Total  int  `json:"total"`
+	// Valid counts the rows that passed validation; Imported the ones
+	// written, which is zero on a dry run.
+	Valid    int                           `json:"valid"`
+	Imported int                           `json:"imported"`
+	Rejected []*TransactionImportRejection `json:"rejected"`
+}
+
+type TransactionImportRejection struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
 }
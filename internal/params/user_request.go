@@ -2,11 +2,22 @@ package params
 
 type RegisterRequest struct {
 	Name     string `json:"name" validate:"required,min=3,max=100"`
-	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email    string `json:"email" validate:"required,email,max=255" normalize:"lower"`
+	Password string `json:"password" validate:"required,password" normalize:"-"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"required,email" normalize:"lower"`
+	Password string `json:"password" validate:"required" normalize:"-"`
+}
+
+// ChangeEmailRequest asks to move the account to Email. The current password
+// is required so that a stolen session cannot take over the account.
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email,max=255" normalize:"lower"`
+	Password string `json:"password" validate:"required" normalize:"-"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
 }
@@ -1,6 +1,12 @@
 package params
 
-import "github.com/google/uuid"
+import (
+	"go-digital-wallet/internal/entity"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type AuthResponse struct {
 	Token string `json:"token"`
@@ -10,3 +16,53 @@ type AuthResponse struct {
 		Email string    `json:"email"`
 	} `json:"user"`
 }
+
+type DataExportUser struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DataExportHeader is the part of a data export loaded before streaming. The
+// export document adds a "transactions" array to it.
+type DataExportHeader struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	User       DataExportUser    `json:"user"`
+	Wallets    []*WalletResponse `json:"wallets"`
+}
+
+// DataExportFile is a data export download. Unlike StatementFile its content
+// is produced by Write while the response is sent, so it is never held in
+// memory as a whole.
+type DataExportFile struct {
+	Filename    string
+	ContentType string
+	Write       func(w io.Writer) error
+}
+
+type UserProfileResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OverviewWalletResponse struct {
+	ID          uuid.UUID         `json:"id"`
+	Balance     float64           `json:"balance"`
+	HeldBalance float64           `json:"held_balance"`
+	Currency    string            `json:"currency"`
+	Type        entity.WalletType `json:"type"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// OverviewResponse is the user's profile together with their wallets, oldest
+// first. Wallets is empty, not null, for a user without one.
+type OverviewResponse struct {
+	User    UserProfileResponse       `json:"user"`
+	Wallets []*OverviewWalletResponse `json:"wallets"`
+}
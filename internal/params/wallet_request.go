@@ -1,18 +1,127 @@
 package params
 
-import "github.com/google/uuid"
+import (
+	"go-digital-wallet/internal/entity"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Amounts tagged money:"decimal" are checked to be exact decimals with at most
+// two places when bound, but are carried as float64 from there on.
 
 type WithdrawRequest struct {
-	Amount      float64 `json:"amount" validate:"required,gt=0"`
-	Description string  `json:"description,omitempty" validate:"max=500"`
+	Amount      float64 `json:"amount" validate:"required,gt=0" money:"decimal"`
+	Description string  `json:"description,omitempty" validate:"withdraw_description"`
+	Currency    string  `json:"currency,omitempty" validate:"omitempty,len=3" normalize:"upper"`
+	// DestinationID is one of the user's verified payout destinations the
+	// money is paid out to.
+	DestinationID *uuid.UUID `json:"destination_id,omitempty"`
+
+	// IdempotencyKey comes from the Idempotency-Key header.
+	IdempotencyKey string `json:"-" validate:"max=255"`
+}
+
+// ConfirmWithdrawRequest executes a withdrawal started with the initiate
+// endpoint.
+type ConfirmWithdrawRequest struct {
+	Token string `json:"token" validate:"required" normalize:"-"`
 }
 
 type DepositRequest struct {
-	Amount      float64 `json:"amount" validate:"required,gt=0"`
-	Description string  `json:"description,omitempty" validate:"max=500"`
+	Amount      float64 `json:"amount" validate:"required,gt=0" money:"decimal"`
+	Description string  `json:"description,omitempty" validate:"deposit_description"`
+	Currency    string  `json:"currency,omitempty" validate:"omitempty,len=3" normalize:"upper"`
+
+	// IdempotencyKey comes from the Idempotency-Key header.
+	IdempotencyKey string `json:"-" validate:"max=255"`
+}
+
+type TransferRequest struct {
+	ToWalletID  uuid.UUID `json:"to_wallet_id" validate:"required"`
+	Amount      float64   `json:"amount" validate:"required,gt=0" money:"decimal"`
+	Description string    `json:"description,omitempty" validate:"max=500"`
+	// Tip optionally pays a second recipient in the same transfer. The
+	// sender is debited once for Amount plus the tip.
+	Tip *TransferTip `json:"tip,omitempty"`
+
+	// IdempotencyKey comes from the Idempotency-Key header.
+	IdempotencyKey string `json:"-" validate:"max=255"`
+}
+
+// TransferTip is the share of a transfer paid to a second recipient.
+type TransferTip struct {
+	ToWalletID uuid.UUID `json:"to_wallet_id" validate:"required"`
+	Amount     float64   `json:"amount" validate:"required,gt=0" money:"decimal"`
 }
 
+// PendingTransferRequest asks for a transfer that only happens once an
+// approver confirms it.
+type PendingTransferRequest struct {
+	ToWalletID  uuid.UUID `json:"to_wallet_id" validate:"required"`
+	Amount      float64   `json:"amount" validate:"required,gt=0" money:"decimal"`
+	Description string    `json:"description,omitempty" validate:"max=500"`
+	// ExpiresInMinutes is how long the request waits for approval before
+	// its hold is released. Omitted uses the configured window.
+	ExpiresInMinutes *int `json:"expires_in_minutes,omitempty" validate:"omitempty,gt=0"`
+}
+
+// CreateWalletRequest opens a wallet. Currency may be omitted when a default
+// currency is configured.
 type CreateWalletRequest struct {
-	UserID   uuid.UUID `json:"user_id" `
-	Currency string    `json:"currency"  validate:"required,len=3"`
+	// UserID is always the authenticated user and never read from the body.
+	UserID   uuid.UUID `json:"-"`
+	Currency string    `json:"currency"  validate:"omitempty,len=3" normalize:"upper"`
+}
+
+// AlertThresholdRequest sets the wallet's alert threshold. A null threshold
+// reverts to the default.
+type AlertThresholdRequest struct {
+	Threshold *float64 `json:"threshold" validate:"omitempty,gt=0" money:"decimal"`
+}
+
+// SoftLockRequest places a temporary soft-lock on a wallet. Placing it again
+// replaces the previous expiry.
+type SoftLockRequest struct {
+	DurationMinutes int    `json:"duration_minutes" validate:"required,gt=0,max=43200"`
+	Reason          string `json:"reason,omitempty" validate:"max=500"`
+}
+
+type TransactionHistoryFilter struct {
+	From *time.Time
+	To   *time.Time
+	// IncludeTotals adds deposit and withdrawal totals over the whole
+	// filtered set to the response.
+	IncludeTotals bool
+	// Ascending lists transactions oldest first. The default is newest first.
+	Ascending bool
+	// SkipCache reads from the database even when a cached page exists. The
+	// fresh page still replaces the cached one.
+	SkipCache bool
+	// Types, MinAmount, MaxAmount and Search are only set by the search
+	// endpoint.
+	Types     []entity.TransactionType
+	MinAmount *float64
+	MaxAmount *float64
+	Search    string
+	// Status is only set by the admin listing of all transactions.
+	Status entity.TransactionStatus
+}
+
+// TransactionSearchRequest is the body of a transaction search, for filters
+// too many to pass comfortably in the query string. Page and Offset are
+// mutually exclusive; without either the first page is returned.
+type TransactionSearchRequest struct {
+	Types     []entity.TransactionType `json:"types,omitempty" validate:"max=5,dive,oneof=withdraw deposit interest transfer_in transfer_out"`
+	From      *time.Time               `json:"from,omitempty"`
+	To        *time.Time               `json:"to,omitempty"`
+	MinAmount *float64                 `json:"min_amount,omitempty" validate:"omitempty,gte=0" money:"decimal"`
+	MaxAmount *float64                 `json:"max_amount,omitempty" validate:"omitempty,gt=0" money:"decimal"`
+	// Search matches a part of the description, ignoring case.
+	Search        string `json:"search,omitempty" validate:"max=100"`
+	IncludeTotals bool   `json:"include_totals,omitempty"`
+	Order         string `json:"order,omitempty" validate:"omitempty,oneof=asc desc"`
+	Limit         int    `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+	Page          int    `json:"page,omitempty" validate:"omitempty,min=1"`
+	Offset        *int   `json:"offset,omitempty" validate:"omitempty,min=0"`
 }
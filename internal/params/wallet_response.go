@@ -1,6 +1,7 @@
 package params
 
 import (
+	"encoding/json"
 	"go-digital-wallet/internal/entity"
 	"time"
 
@@ -8,10 +9,50 @@ import (
 )
 
 type BalanceResponse struct {
-	UserID    uuid.UUID `json:"user_id"`
-	Balance   float64   `json:"balance"`
-	Currency  string    `json:"currency"`
-	Timestamp time.Time `json:"timestamp"`
+	UserID  uuid.UUID `json:"user_id"`
+	Balance float64   `json:"balance"`
+	// AvailableBalance is what can be spent right now: Balance less the
+	// amount held for pending transfer requests and the deposits still
+	// clearing.
+	AvailableBalance float64 `json:"available_balance"`
+	HeldBalance      float64 `json:"held_balance"`
+	// ClearingBalance is the amount of deposits still on hold.
+	ClearingBalance float64   `json:"clearing_balance"`
+	Currency        string    `json:"currency"`
+	Timestamp       time.Time `json:"timestamp"`
+	// TransactionCount is the number of transactions in the history, when
+	// asked for.
+	TransactionCount *int64 `json:"transaction_count,omitempty"`
+}
+
+// HoldResponse is part of a wallet's balance that cannot be spent yet.
+type HoldResponse struct {
+	// ID is the transfer request or the deposit transaction.
+	ID uuid.UUID `json:"id"`
+	// Type is transfer_request for an amount held for a pending transfer
+	// request, or deposit for a deposit still clearing.
+	Type      string    `json:"type"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is when the hold ends: the transfer request expires or the
+	// deposit clears.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HoldsResponse lists a wallet's holds. TotalHeld is their sum, what Balance
+// exceeds AvailableBalance by.
+type HoldsResponse struct {
+	WalletID         uuid.UUID       `json:"wallet_id"`
+	Holds            []*HoldResponse `json:"holds"`
+	TotalHeld        float64         `json:"total_held"`
+	Balance          float64         `json:"balance"`
+	AvailableBalance float64         `json:"available_balance"`
+	Currency         string          `json:"currency"`
+}
+
+// WalletExistsResponse tells whether the user has opened a wallet yet.
+type WalletExistsResponse struct {
+	Exists bool `json:"exists"`
 }
 
 type WithdrawResponse struct {
@@ -20,6 +61,15 @@ type WithdrawResponse struct {
 	NewBalance    float64                  `json:"new_balance"`
 	Status        entity.TransactionStatus `json:"status"`
 	Timestamp     time.Time                `json:"timestamp"`
+	DestinationID *uuid.UUID               `json:"destination_id,omitempty"`
+}
+
+// WithdrawInitiateResponse carries the token that confirms an initiated
+// withdrawal before it expires.
+type WithdrawInitiateResponse struct {
+	Token     string    `json:"token"`
+	Amount    float64   `json:"amount"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type DepositResponse struct {
@@ -28,13 +78,108 @@ type DepositResponse struct {
 	NewBalance    float64                  `json:"new_balance"`
 	Status        entity.TransactionStatus `json:"status"`
 	Timestamp     time.Time                `json:"timestamp"`
+	// ClearedAt is when the deposit can be withdrawn, if it is on hold.
+	ClearedAt *time.Time `json:"cleared_at,omitempty"`
+}
+
+type TransferResponse struct {
+	TransactionID uuid.UUID                `json:"transaction_id"`
+	ToWalletID    uuid.UUID                `json:"to_wallet_id"`
+	Amount        float64                  `json:"amount"`
+	NewBalance    float64                  `json:"new_balance"`
+	Status        entity.TransactionStatus `json:"status"`
+	Timestamp     time.Time                `json:"timestamp"`
+	// ExchangeRate and ConvertedAmount are set when the recipient's wallet
+	// is in another currency; ConvertedAmount is what they were credited.
+	ExchangeRate    *float64 `json:"exchange_rate,omitempty"`
+	ConvertedAmount *float64 `json:"converted_amount,omitempty"`
+	// Tip is the share paid to the second recipient, if any. NewBalance is
+	// after both Amount and the tip were debited.
+	Tip *TransferTip `json:"tip,omitempty"`
+}
+
+type TransferRequestResponse struct {
+	ID            uuid.UUID                    `json:"id"`
+	FromWalletID  uuid.UUID                    `json:"from_wallet_id"`
+	ToWalletID    uuid.UUID                    `json:"to_wallet_id"`
+	Amount        float64                      `json:"amount"`
+	Description   string                       `json:"description,omitempty"`
+	Status        entity.TransferRequestStatus `json:"status"`
+	TransactionID *uuid.UUID                   `json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time                    `json:"expires_at"`
+	DecidedAt     *time.Time                   `json:"decided_at,omitempty"`
+	CreatedAt     time.Time                    `json:"created_at"`
 }
 
 type WalletResponse struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID         `json:"id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	Balance   float64           `json:"balance"`
+	Currency  string            `json:"currency"`
+	Type      entity.WalletType `json:"type"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// EnsureWalletResponse is the user's wallet and whether this call created it.
+type EnsureWalletResponse struct {
+	*WalletResponse
+	Created bool `json:"created"`
+}
+
+type AlertThresholdResponse struct {
+	WalletID  uuid.UUID `json:"wallet_id"`
+	Threshold *float64  `json:"threshold"`
+	// Effective is the threshold actually applied, after falling back to the
+	// default. Zero means no alerts are sent.
+	Effective float64 `json:"effective"`
+}
+
+type SoftLockResponse struct {
+	WalletID uuid.UUID `json:"wallet_id"`
+	// LockedUntil is nil once the soft-lock has been cleared.
+	LockedUntil *time.Time `json:"locked_until"`
+}
+
+type BalanceAtResponse struct {
+	WalletID  uuid.UUID `json:"wallet_id"`
 	Balance   float64   `json:"balance"`
 	Currency  string    `json:"currency"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Timestamp time.Time `json:"timestamp"`
+	// TransactionID is the last transaction applied at Timestamp, if any.
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty"`
+}
+
+type BalanceHistoryPoint struct {
+	Bucket  time.Time `json:"bucket"`
+	Balance float64   `json:"balance"`
+}
+
+// BalanceHistoryResponse is a wallet's balance at the end of each period
+// between From and To. Periods without activity repeat the previous balance.
+type BalanceHistoryResponse struct {
+	WalletID    uuid.UUID              `json:"wallet_id"`
+	Currency    string                 `json:"currency"`
+	Granularity string                 `json:"granularity"`
+	From        time.Time              `json:"from"`
+	To          time.Time              `json:"to"`
+	Buckets     []*BalanceHistoryPoint `json:"buckets"`
+}
+
+type WalletEventResponse struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	ActorID   uuid.UUID       `json:"actor_id"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type WalletEventsResponse struct {
+	WalletID   uuid.UUID              `json:"wallet_id"`
+	Events     []*WalletEventResponse `json:"events"`
+	Total      int64                  `json:"total"`
+	Page       int                    `json:"page"`
+	Limit      int                    `json:"limit"`
+	TotalPages int                    `json:"total_pages"`
 }
@@ -1,20 +1,34 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"go-digital-wallet/internal/entity"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
 	Create(user *entity.User) error
 	GetByEmail(email string) (*entity.User, error)
 	GetByID(id uuid.UUID) (*entity.User, error)
+	Search(query string, limit, offset int) ([]*entity.User, int64, error)
+	Update(user *entity.User) error
+	SaveEmailChange(change *entity.EmailChange) error
+	GetEmailChangeByTokenHash(tokenHash string) (*entity.EmailChange, error)
+	ApplyEmailChange(change *entity.EmailChange) error
 }
 
+// ErrEmailTaken is returned when an email is already used by another user.
+var ErrEmailTaken = errors.New("email already in use")
+
+// uniqueViolation is the Postgres SQLSTATE raised by a duplicate key.
+const uniqueViolation = "23505"
+
 type UserRepositoryImpl struct {
 	db     *gorm.DB
 	logger *logrus.Logger
@@ -42,12 +56,14 @@ func (r *UserRepositoryImpl) Create(user *entity.User) error {
 	return nil
 }
 
+// GetByEmail finds the user with email, ignoring case, so that the lookup
+// matches how emails are unique.
 func (r *UserRepositoryImpl) GetByEmail(email string) (*entity.User, error) {
 	var user entity.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Where("lower(email) = lower(?)", email).First(&user).Error
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found: %w", err)
 		}
 		r.logger.WithError(err).WithField("email", email).Error("Failed to get user by email")
 		return nil, fmt.Errorf("failed to get user: %w", err)
@@ -60,8 +76,8 @@ func (r *UserRepositoryImpl) GetByID(id uuid.UUID) (*entity.User, error) {
 	var user entity.User
 	err := r.db.Where("id = ?", id).First(&user).Error
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("user not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found: %w", err)
 		}
 		r.logger.WithError(err).WithField("user_id", id).Error("Failed to get user by ID")
 		return nil, fmt.Errorf("failed to get user: %w", err)
@@ -69,3 +85,100 @@ func (r *UserRepositoryImpl) GetByID(id uuid.UUID) (*entity.User, error) {
 
 	return &user, nil
 }
+
+// likeEscaper escapes LIKE wildcards so that user input only matches
+// literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search finds users whose email or name contains query, case-insensitively,
+// and returns one page of them together with the total number of matches.
+func (r *UserRepositoryImpl) Search(query string, limit, offset int) ([]*entity.User, int64, error) {
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+	base := r.db.Model(&entity.User{}).Where("email ILIKE ? OR name ILIKE ?", pattern, pattern)
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to count users")
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []*entity.User
+	if err := base.Order("email").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to search users")
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// Update saves the user's name and email. An email held by another user is
+// reported as ErrEmailTaken.
+func (r *UserRepositoryImpl) Update(user *entity.User) error {
+	return r.update(r.db, user)
+}
+
+func (r *UserRepositoryImpl) update(db *gorm.DB, user *entity.User) error {
+	err := db.Model(&entity.User{}).
+		Where("id = ?", user.ID).
+		Updates(map[string]interface{}{
+			"name":  user.Name,
+			"email": user.Email,
+		}).Error
+	if err != nil {
+		if hasSQLState(err, uniqueViolation) {
+			return ErrEmailTaken
+		}
+		r.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to update user")
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// SaveEmailChange records a pending email change, replacing any earlier one
+// of the user so that only the latest verification link works.
+func (r *UserRepositoryImpl) SaveEmailChange(change *entity.EmailChange) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"new_email", "token_hash", "expires_at", "created_at"}),
+	}).Create(change).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("user_id", change.UserID).Error("Failed to save email change")
+		return fmt.Errorf("failed to save email change: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepositoryImpl) GetEmailChangeByTokenHash(tokenHash string) (*entity.EmailChange, error) {
+	var change entity.EmailChange
+	err := r.db.Where("token_hash = ?", tokenHash).First(&change).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		r.logger.WithError(err).Error("Failed to get email change")
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+	return &change, nil
+}
+
+// ApplyEmailChange moves the user to the new email and discards the pending
+// change in one transaction. An email taken by another user in the meantime
+// is reported as ErrEmailTaken.
+func (r *UserRepositoryImpl) ApplyEmailChange(change *entity.EmailChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user entity.User
+		if err := tx.Where("id = ?", change.UserID).First(&user).Error; err != nil {
+			r.logger.WithError(err).WithField("user_id", change.UserID).Error("Failed to get user for email change")
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		user.Email = change.NewEmail
+		if err := r.update(tx, &user); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", change.UserID).Delete(&entity.EmailChange{}).Error; err != nil {
+			r.logger.WithError(err).WithField("user_id", change.UserID).Error("Failed to delete email change")
+			return fmt.Errorf("failed to delete email change: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,27 @@
+package repository_test
+
+import (
+	"go-digital-wallet/internal/repository"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetByEmail_IgnoresCase(t *testing.T) {
+	db, _ := setupRepositoryTest(t)
+	require.NoError(t, db.Exec(`CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT NOT NULL, email TEXT NOT NULL)`).Error)
+	userID := uuid.New()
+	// Registered before emails were lower-cased on binding.
+	require.NoError(t, db.Exec(`INSERT INTO users (id, name, email) VALUES (?, 'Jane', 'Jane.Doe@Example.com')`, userID).Error)
+	repo := repository.NewUserRepository(db, logrus.New())
+
+	user, err := repo.GetByEmail("jane.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+
+	_, err = repo.GetByEmail("john.doe@example.com")
+	assert.Error(t, err)
+}
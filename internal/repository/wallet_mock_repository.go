@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 
 	"go-digital-wallet/internal/entity"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -19,6 +21,19 @@ func (m *MockWalletRepository) Create(ctx context.Context, wallet *entity.Wallet
 	return args.Error(0)
 }
 
+func (m *MockWalletRepository) CreateIfAbsent(ctx context.Context, wallet *entity.Wallet) (bool, error) {
+	args := m.Called(ctx, wallet)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWalletRepository) GetByID(ctx context.Context, walletID uuid.UUID) (*entity.Wallet, error) {
+	args := m.Called(ctx, walletID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*entity.Wallet), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockWalletRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Wallet, error) {
 	args := m.Called(ctx, userID)
 	if args.Get(0) != nil {
@@ -27,6 +42,19 @@ func (m *MockWalletRepository) GetByUserID(ctx context.Context, userID uuid.UUID
 	return nil, args.Error(1)
 }
 
+func (m *MockWalletRepository) ExistsByUserID(ctx context.Context, userID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWalletRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.Wallet, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.Wallet), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockWalletRepository) GetByUserIDForUpdate(ctx context.Context, tx *gorm.DB, userID uuid.UUID) (*entity.Wallet, error) {
 	args := m.Called(ctx, tx, userID)
 	if args.Get(0) != nil {
@@ -40,29 +68,237 @@ func (m *MockWalletRepository) UpdateBalance(ctx context.Context, tx *gorm.DB, w
 	return args.Error(0)
 }
 
+func (m *MockWalletRepository) UpdateAlertThreshold(ctx context.Context, walletID uuid.UUID, threshold *float64) error {
+	args := m.Called(ctx, walletID, threshold)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) UpdateLockedUntil(ctx context.Context, walletID uuid.UUID, lockedUntil *time.Time) error {
+	args := m.Called(ctx, walletID, lockedUntil)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) UpdateHeldBalance(ctx context.Context, tx *gorm.DB, walletID uuid.UUID, heldBalance float64, version int) error {
+	args := m.Called(ctx, tx, walletID, heldBalance, version)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) CreateTransferRequest(ctx context.Context, tx *gorm.DB, request *entity.TransferRequest) error {
+	args := m.Called(ctx, tx, request)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) GetTransferRequestForUpdate(ctx context.Context, tx *gorm.DB, requestID uuid.UUID) (*entity.TransferRequest, error) {
+	args := m.Called(ctx, tx, requestID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*entity.TransferRequest), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) UpdateTransferRequest(ctx context.Context, tx *gorm.DB, request *entity.TransferRequest) error {
+	args := m.Called(ctx, tx, request)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) ListExpiredTransferRequests(ctx context.Context, now time.Time, limit int) ([]*entity.TransferRequest, error) {
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.TransferRequest), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) ListPendingTransferRequests(ctx context.Context, walletID uuid.UUID) ([]*entity.TransferRequest, error) {
+	args := m.Called(ctx, walletID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.TransferRequest), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) CreatePayoutDestination(ctx context.Context, destination *entity.PayoutDestination) (bool, error) {
+	args := m.Called(ctx, destination)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWalletRepository) GetPayoutDestination(ctx context.Context, destinationID uuid.UUID) (*entity.PayoutDestination, error) {
+	args := m.Called(ctx, destinationID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*entity.PayoutDestination), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) ListPayoutDestinations(ctx context.Context, userID uuid.UUID) ([]*entity.PayoutDestination, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.PayoutDestination), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) VerifyPayoutDestination(ctx context.Context, destinationID, verifiedBy uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, destinationID, verifiedBy, at)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) DeletePayoutDestination(ctx context.Context, destinationID uuid.UUID) error {
+	args := m.Called(ctx, destinationID)
+	return args.Error(0)
+}
+
 func (m *MockWalletRepository) CreateTransaction(ctx context.Context, tx *gorm.DB, transaction *entity.Transaction) error {
 	args := m.Called(ctx, tx, transaction)
 	return args.Error(0)
 }
 
+func (m *MockWalletRepository) CreateWalletEvent(ctx context.Context, tx *gorm.DB, event *entity.WalletEvent) error {
+	args := m.Called(ctx, tx, event)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) GetWalletEvents(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entity.WalletEvent, error) {
+	args := m.Called(ctx, walletID, limit, offset)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.WalletEvent), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) CountWalletEvents(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	args := m.Called(ctx, walletID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockWalletRepository) UpdateTransactionStatus(ctx context.Context, tx *gorm.DB, transactionID uuid.UUID, transaction *entity.Transaction) error {
 	args := m.Called(ctx, tx, transactionID, transaction)
 	return args.Error(0)
 }
 
-func (m *MockWalletRepository) GetTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entity.Transaction, error) {
-	args := m.Called(ctx, walletID, limit, offset)
+func (m *MockWalletRepository) GetTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, limit, offset int, filter TransactionFilter) ([]*entity.Transaction, error) {
+	args := m.Called(ctx, walletID, limit, offset, filter)
 	if args.Get(0) != nil {
 		return args.Get(0).([]*entity.Transaction), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *MockWalletRepository) CountTransactionsByWalletID(ctx context.Context, walletID uuid.UUID) (int64, error) {
-	args := m.Called(ctx, walletID)
+func (m *MockWalletRepository) GetTransactionsByIDRange(ctx context.Context, walletID, from, to uuid.UUID, limit int) ([]*entity.Transaction, error) {
+	args := m.Called(ctx, walletID, from, to, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) CountTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, filter TransactionFilter) (int64, error) {
+	args := m.Called(ctx, walletID, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWalletRepository) SumTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, filter TransactionFilter) (float64, float64, error) {
+	args := m.Called(ctx, walletID, filter)
+	return args.Get(0).(float64), args.Get(1).(float64), args.Error(2)
+}
+
+func (m *MockWalletRepository) SumClearingDeposits(ctx context.Context, tx *gorm.DB, walletID uuid.UUID, now time.Time) (float64, error) {
+	args := m.Called(ctx, tx, walletID, now)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockWalletRepository) ListClearingDeposits(ctx context.Context, walletID uuid.UUID, now time.Time) ([]*entity.Transaction, error) {
+	args := m.Called(ctx, walletID, now)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) GetTransactionsByUserID(ctx context.Context, userID uuid.UUID, limit, offset int, filter TransactionFilter) ([]*UserTransaction, error) {
+	args := m.Called(ctx, userID, limit, offset, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*UserTransaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) CountTransactionsByUserID(ctx context.Context, userID uuid.UUID, filter TransactionFilter) (int64, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWalletRepository) ListPlatformTransactions(ctx context.Context, limit, offset int, filter TransactionFilter) ([]*PlatformTransaction, error) {
+	args := m.Called(ctx, limit, offset, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*PlatformTransaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) CountPlatformTransactions(ctx context.Context, filter TransactionFilter) (int64, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockWalletRepository) GetLatestTransactionAt(ctx context.Context, walletID uuid.UUID, at time.Time, includeArchived bool) (*entity.Transaction, error) {
+	args := m.Called(ctx, walletID, at, includeArchived)
+	if args.Get(0) != nil {
+		return args.Get(0).(*entity.Transaction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) ArchiveTransactionsBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	args := m.Called(ctx, cutoff, batchSize)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWalletRepository) ListInterestBearingWallets(ctx context.Context, defaultRate float64) ([]*entity.Wallet, error) {
+	args := m.Called(ctx, defaultRate)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*entity.Wallet), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) SumAllBalances(ctx context.Context) ([]*CurrencyBalance, error) {
+	args := m.Called(ctx)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*CurrencyBalance), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) GetTransactionVelocity(ctx context.Context, walletID uuid.UUID, now time.Time) (*TransactionVelocity, error) {
+	args := m.Called(ctx, walletID, now)
+	if args.Get(0) != nil {
+		return args.Get(0).(*TransactionVelocity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) CreateInterestAccrual(ctx context.Context, tx *gorm.DB, accrual *entity.InterestAccrual) (bool, error) {
+	args := m.Called(ctx, tx, accrual)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWalletRepository) GetTransactionInsights(ctx context.Context, walletID uuid.UUID, granularity string, from, to *time.Time) ([]*InsightBucket, error) {
+	args := m.Called(ctx, walletID, granularity, from, to)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*InsightBucket), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWalletRepository) GetBalanceHistory(ctx context.Context, walletID uuid.UUID, granularity string, filter TransactionFilter) ([]*BalanceBucket, error) {
+	args := m.Called(ctx, walletID, granularity, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*BalanceBucket), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func (m *MockWalletRepository) BeginTx(ctx context.Context) *gorm.DB {
 	args := m.Called(ctx)
 	if args.Get(0) != nil {
@@ -71,6 +307,14 @@ func (m *MockWalletRepository) BeginTx(ctx context.Context) *gorm.DB {
 	return nil
 }
 
+func (m *MockWalletRepository) BeginTxWithOptions(ctx context.Context, opts *sql.TxOptions) *gorm.DB {
+	args := m.Called(ctx, opts)
+	if args.Get(0) != nil {
+		return args.Get(0).(*gorm.DB)
+	}
+	return nil
+}
+
 func (m *MockWalletRepository) WithTx(tx *gorm.DB) WalletRepository {
 	args := m.Called(tx)
 	if args.Get(0) != nil {
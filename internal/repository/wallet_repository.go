@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"go-digital-wallet/internal/commons/readpref"
 	"go-digital-wallet/internal/entity"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -12,22 +16,215 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// TransactionFilter narrows transaction history queries. Zero values mean no
+// restriction.
+type TransactionFilter struct {
+	From *time.Time
+	To   *time.Time
+	// IncludeArchived also reads rows moved to archived_transactions.
+	IncludeArchived bool
+	// Ascending lists oldest first instead of newest first. It only affects
+	// listing queries, not counts or sums.
+	Ascending bool
+	// Types keeps only transactions of these types. Empty keeps all.
+	Types []entity.TransactionType
+	// Status keeps only transactions in this status. Empty keeps all.
+	Status    entity.TransactionStatus
+	MinAmount *float64
+	MaxAmount *float64
+	// Search keeps transactions whose description contains it, ignoring
+	// case.
+	Search string
+}
+
+// apply adds the filter conditions to a transactions query whose columns are
+// qualified with prefix.
+func (f TransactionFilter) apply(query *gorm.DB, prefix string) *gorm.DB {
+	if f.From != nil {
+		query = query.Where(prefix+"created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where(prefix+"created_at <= ?", *f.To)
+	}
+	if len(f.Types) > 0 {
+		query = query.Where(prefix+"type IN ?", f.Types)
+	}
+	if f.Status != "" {
+		query = query.Where(prefix+"status = ?", f.Status)
+	}
+	if f.MinAmount != nil {
+		query = query.Where(prefix+"amount >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		query = query.Where(prefix+"amount <= ?", *f.MaxAmount)
+	}
+	if f.Search != "" {
+		query = query.Where("LOWER("+prefix+"description) LIKE ? ESCAPE '\\'", "%"+likeEscaper.Replace(strings.ToLower(f.Search))+"%")
+	}
+	return query
+}
+
+// orderClause returns the ORDER BY for listing transactions, with the id as a
+// tie breaker so that pages are stable when timestamps collide.
+func (f TransactionFilter) orderClause(prefix string) string {
+	direction := "DESC"
+	if f.Ascending {
+		direction = "ASC"
+	}
+	return fmt.Sprintf("%[1]screated_at %[2]s, %[1]sid %[2]s", prefix, direction)
+}
+
+// InsightBucket is one time bucket of aggregated completed transactions.
+type InsightBucket struct {
+	Bucket        time.Time
+	TotalDeposit  float64
+	TotalWithdraw float64
+}
+
+// BalanceBucket is a wallet's balance at the end of one period.
+type BalanceBucket struct {
+	Bucket  time.Time
+	Balance float64
+}
+
+// CurrencyBalance is the money held across all wallets of one currency.
+type CurrencyBalance struct {
+	Currency string
+	Total    float64
+	Wallets  int64
+}
+
+// TransactionVelocity is how many completed transactions a wallet made, and
+// their total amount, within the hour, day and week before a point in time.
+type TransactionVelocity struct {
+	HourCount int64
+	HourTotal float64
+	DayCount  int64
+	DayTotal  float64
+	WeekCount int64
+	WeekTotal float64
+}
+
+// UserTransaction is a transaction together with the wallet it belongs to, as
+// returned by queries spanning all of a user's wallets.
+type UserTransaction struct {
+	ID          uuid.UUID
+	WalletID    uuid.UUID
+	Currency    string
+	Type        entity.TransactionType
+	Amount      float64
+	Status      entity.TransactionStatus
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// PlatformTransaction is a transaction together with its wallet and owner, as
+// returned by admin queries spanning every wallet.
+type PlatformTransaction struct {
+	UserTransaction
+	UserID    uuid.UUID
+	UserName  string
+	UserEmail string
+}
+
+// creditTransactionTypes add money to a wallet, debitTransactionTypes take it
+// out. Aggregates use these so every report agrees on direction.
+var (
+	creditTransactionTypes = []entity.TransactionType{entity.TransactionTypeDeposit, entity.TransactionTypeInterest, entity.TransactionTypeTransferIn}
+	debitTransactionTypes  = []entity.TransactionType{entity.TransactionTypeWithdraw, entity.TransactionTypeTransferOut}
+)
+
+// transactionColumns lists the columns shared by the transactions and
+// archived_transactions tables.
+const transactionColumns = "id, wallet_id, type, amount, status, description, created_at, updated_at, balance_after, payout_destination_id, exchange_rate, original_amount, converted_amount, transfer_id, cleared_at"
+
 type WalletRepository interface {
 	Create(ctx context.Context, wallet *entity.Wallet) error
+	CreateIfAbsent(ctx context.Context, wallet *entity.Wallet) (bool, error)
+	GetByID(ctx context.Context, walletID uuid.UUID) (*entity.Wallet, error)
 	GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Wallet, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.Wallet, error)
+	ExistsByUserID(ctx context.Context, userID uuid.UUID) (bool, error)
 	GetByUserIDForUpdate(ctx context.Context, tx *gorm.DB, userID uuid.UUID) (*entity.Wallet, error)
 	UpdateBalance(ctx context.Context, tx *gorm.DB, walletID uuid.UUID, newBalance float64, version int) error
+	UpdateAlertThreshold(ctx context.Context, walletID uuid.UUID, threshold *float64) error
+	UpdateLockedUntil(ctx context.Context, walletID uuid.UUID, lockedUntil *time.Time) error
+	UpdateHeldBalance(ctx context.Context, tx *gorm.DB, walletID uuid.UUID, heldBalance float64, version int) error
+	CreateTransferRequest(ctx context.Context, tx *gorm.DB, request *entity.TransferRequest) error
+	GetTransferRequestForUpdate(ctx context.Context, tx *gorm.DB, requestID uuid.UUID) (*entity.TransferRequest, error)
+	UpdateTransferRequest(ctx context.Context, tx *gorm.DB, request *entity.TransferRequest) error
+	ListExpiredTransferRequests(ctx context.Context, now time.Time, limit int) ([]*entity.TransferRequest, error)
+	ListPendingTransferRequests(ctx context.Context, walletID uuid.UUID) ([]*entity.TransferRequest, error)
+	CreatePayoutDestination(ctx context.Context, destination *entity.PayoutDestination) (bool, error)
+	GetPayoutDestination(ctx context.Context, destinationID uuid.UUID) (*entity.PayoutDestination, error)
+	ListPayoutDestinations(ctx context.Context, userID uuid.UUID) ([]*entity.PayoutDestination, error)
+	VerifyPayoutDestination(ctx context.Context, destinationID, verifiedBy uuid.UUID, at time.Time) error
+	DeletePayoutDestination(ctx context.Context, destinationID uuid.UUID) error
 	CreateTransaction(ctx context.Context, tx *gorm.DB, transaction *entity.Transaction) error
+	CreateWalletEvent(ctx context.Context, tx *gorm.DB, event *entity.WalletEvent) error
+	GetWalletEvents(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entity.WalletEvent, error)
+	CountWalletEvents(ctx context.Context, walletID uuid.UUID) (int64, error)
 	UpdateTransactionStatus(ctx context.Context, tx *gorm.DB, transactionID uuid.UUID, transaction *entity.Transaction) error
-	GetTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entity.Transaction, error)
-	CountTransactionsByWalletID(ctx context.Context, walletID uuid.UUID) (int64, error)
+	GetTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, limit, offset int, filter TransactionFilter) ([]*entity.Transaction, error)
+	GetTransactionsByIDRange(ctx context.Context, walletID, from, to uuid.UUID, limit int) ([]*entity.Transaction, error)
+	CountTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, filter TransactionFilter) (int64, error)
+	SumTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, filter TransactionFilter) (deposited, withdrawn float64, err error)
+	SumClearingDeposits(ctx context.Context, tx *gorm.DB, walletID uuid.UUID, now time.Time) (float64, error)
+	ListClearingDeposits(ctx context.Context, walletID uuid.UUID, now time.Time) ([]*entity.Transaction, error)
+	GetLatestTransactionAt(ctx context.Context, walletID uuid.UUID, at time.Time, includeArchived bool) (*entity.Transaction, error)
+	GetTransactionsByUserID(ctx context.Context, userID uuid.UUID, limit, offset int, filter TransactionFilter) ([]*UserTransaction, error)
+	CountTransactionsByUserID(ctx context.Context, userID uuid.UUID, filter TransactionFilter) (int64, error)
+	ListPlatformTransactions(ctx context.Context, limit, offset int, filter TransactionFilter) ([]*PlatformTransaction, error)
+	CountPlatformTransactions(ctx context.Context, filter TransactionFilter) (int64, error)
+	ArchiveTransactionsBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error)
+	GetTransactionInsights(ctx context.Context, walletID uuid.UUID, granularity string, from, to *time.Time) ([]*InsightBucket, error)
+	GetBalanceHistory(ctx context.Context, walletID uuid.UUID, granularity string, filter TransactionFilter) ([]*BalanceBucket, error)
+	ListInterestBearingWallets(ctx context.Context, defaultRate float64) ([]*entity.Wallet, error)
+	SumAllBalances(ctx context.Context) ([]*CurrencyBalance, error)
+	GetTransactionVelocity(ctx context.Context, walletID uuid.UUID, now time.Time) (*TransactionVelocity, error)
+	CreateInterestAccrual(ctx context.Context, tx *gorm.DB, accrual *entity.InterestAccrual) (bool, error)
 	BeginTx(ctx context.Context) *gorm.DB
+	BeginTxWithOptions(ctx context.Context, opts *sql.TxOptions) *gorm.DB
 	WithTx(tx *gorm.DB) WalletRepository
 }
 
+// ErrLockTimeout is returned by GetByUserIDForUpdate when the wallet stayed locked
+// by another transaction for longer than the lock timeout. The surrounding
+// transaction is aborted, but the operation can be retried.
+var ErrLockTimeout = errors.New("timed out waiting for row lock")
+
+// ErrWalletExists is returned by Create when the user already has a wallet;
+// wallets are unique per user.
+var ErrWalletExists = errors.New("user already has a wallet")
+
+// lockNotAvailable is the Postgres SQLSTATE raised when lock_timeout expires.
+const lockNotAvailable = "55P03"
+
+// serializationFailure is the Postgres SQLSTATE raised when a REPEATABLE READ
+// or SERIALIZABLE transaction conflicts with a concurrent one.
+const serializationFailure = "40001"
+
 type WalletRepositoryImpl struct {
 	db     *gorm.DB
 	logger *logrus.Logger
+	// lockTimeout bounds how long locking reads wait. Zero waits forever.
+	lockTimeout time.Duration
+	// replica serves reads that tolerate replication lag. Nil reads
+	// everything from db.
+	replica *gorm.DB
+}
+
+// WalletRepositoryOptions tunes a repository built with
+// NewWalletRepositoryWithOptions. The zero value matches NewWalletRepository.
+type WalletRepositoryOptions struct {
+	// LockTimeout makes locking reads give up with ErrLockTimeout instead of
+	// blocking. Zero waits forever.
+	LockTimeout time.Duration
+	// Replica is a read replica for the balance and history reads. Writes,
+	// locking reads and anything inside a transaction stay on the primary,
+	// as do reads whose context carries readpref.WithPrimary.
+	Replica *gorm.DB
 }
 
 func NewWalletRepository(db *gorm.DB, logger *logrus.Logger) WalletRepository {
@@ -37,18 +234,74 @@ func NewWalletRepository(db *gorm.DB, logger *logrus.Logger) WalletRepository {
 	}
 }
 
+// NewWalletRepositoryWithLockTimeout builds a repository whose locking reads
+// give up after lockTimeout with ErrLockTimeout instead of blocking.
+func NewWalletRepositoryWithLockTimeout(db *gorm.DB, logger *logrus.Logger, lockTimeout time.Duration) WalletRepository {
+	return NewWalletRepositoryWithOptions(db, logger, WalletRepositoryOptions{LockTimeout: lockTimeout})
+}
+
+func NewWalletRepositoryWithOptions(db *gorm.DB, logger *logrus.Logger, opts WalletRepositoryOptions) WalletRepository {
+	return &WalletRepositoryImpl{
+		db:          db,
+		logger:      logger,
+		lockTimeout: opts.LockTimeout,
+		replica:     opts.Replica,
+	}
+}
+
+// reader returns the connection for a read that may lag behind the latest
+// writes.
+func (r *WalletRepositoryImpl) reader(ctx context.Context) *gorm.DB {
+	if r.replica == nil || readpref.PrimaryRequested(ctx) {
+		return r.db.WithContext(ctx)
+	}
+	return r.replica.WithContext(ctx)
+}
+
 func (r *WalletRepositoryImpl) Create(ctx context.Context, wallet *entity.Wallet) error {
 	if err := r.db.WithContext(ctx).Create(wallet).Error; err != nil {
+		if hasSQLState(err, uniqueViolation) {
+			return ErrWalletExists
+		}
 		r.logger.WithError(err).Error("Failed to create wallet in database")
 		return fmt.Errorf("failed to create wallet: %w", err)
 	}
 	return nil
 }
 
+// CreateIfAbsent creates the wallet unless the user already has one, and
+// reports whether it did.
+func (r *WalletRepositoryImpl) CreateIfAbsent(ctx context.Context, wallet *entity.Wallet) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(wallet)
+	if result.Error != nil {
+		r.logger.WithError(result.Error).WithField("user_id", wallet.UserID).Error("Failed to create wallet in database")
+		return false, fmt.Errorf("failed to create wallet: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *WalletRepositoryImpl) GetByID(ctx context.Context, walletID uuid.UUID) (*entity.Wallet, error) {
+	var wallet entity.Wallet
+
+	err := r.db.WithContext(ctx).Where("id = ?", walletID).First(&wallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to get wallet by id")
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	return &wallet, nil
+}
+
 func (r *WalletRepositoryImpl) GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Wallet, error) {
 	var wallet entity.Wallet
 
-	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&wallet).Error
+	err := r.reader(ctx).Where("user_id = ?", userID).First(&wallet).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, gorm.ErrRecordNotFound
@@ -60,6 +313,34 @@ func (r *WalletRepositoryImpl) GetByUserID(ctx context.Context, userID uuid.UUID
 	return &wallet, nil
 }
 
+// ExistsByUserID reports whether the user has a wallet without loading it.
+func (r *WalletRepositoryImpl) ExistsByUserID(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var exists bool
+
+	err := r.db.WithContext(ctx).
+		Raw("SELECT EXISTS (SELECT 1 FROM wallets WHERE user_id = ?)", userID).
+		Scan(&exists).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("user_id", userID).Error("Failed to check wallet existence")
+		return false, fmt.Errorf("failed to check wallet existence: %w", err)
+	}
+
+	return exists, nil
+}
+
+// ListByUserID returns every wallet of the user, oldest first.
+func (r *WalletRepositoryImpl) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*entity.Wallet, error) {
+	var wallets []*entity.Wallet
+
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&wallets).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("user_id", userID).Error("Failed to list wallets by user ID")
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+
+	return wallets, nil
+}
+
 func (r *WalletRepositoryImpl) GetByUserIDForUpdate(ctx context.Context, tx *gorm.DB, userID uuid.UUID) (*entity.Wallet, error) {
 	var wallet entity.Wallet
 
@@ -69,6 +350,10 @@ func (r *WalletRepositoryImpl) GetByUserIDForUpdate(ctx context.Context, tx *gor
 		db = tx
 	}
 
+	if err := r.setLockTimeout(ctx, db); err != nil {
+		return nil, err
+	}
+
 	err := db.WithContext(ctx).
 		Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where("user_id = ?", userID).
@@ -78,6 +363,10 @@ func (r *WalletRepositoryImpl) GetByUserIDForUpdate(ctx context.Context, tx *gor
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, gorm.ErrRecordNotFound
 		}
+		if isLockTimeout(err) {
+			r.logger.WithField("user_id", userID).Warn("Timed out waiting for wallet lock")
+			return nil, ErrLockTimeout
+		}
 		r.logger.WithError(err).WithField("user_id", userID).Error("Failed to get wallet by user ID for update")
 		return nil, fmt.Errorf("failed to get wallet for update: %w", err)
 	}
@@ -112,6 +401,214 @@ func (r *WalletRepositoryImpl) UpdateBalance(ctx context.Context, tx *gorm.DB, w
 	return nil
 }
 
+// UpdateHeldBalance sets the amount reserved on the wallet, with the same
+// optimistic version check as UpdateBalance.
+func (r *WalletRepositoryImpl) UpdateHeldBalance(ctx context.Context, tx *gorm.DB, walletID uuid.UUID, heldBalance float64, version int) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	result := db.WithContext(ctx).
+		Model(&entity.Wallet{}).
+		Where("id = ? AND version = ?", walletID, version-1).
+		Updates(map[string]interface{}{
+			"held_balance": heldBalance,
+			"version":      version,
+		})
+
+	if result.Error != nil {
+		r.logger.WithError(result.Error).WithField("wallet_id", walletID).Error("Failed to update held balance")
+		return fmt.Errorf("failed to update held balance: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("optimistic lock error: wallet was modified by another transaction")
+	}
+
+	return nil
+}
+
+func (r *WalletRepositoryImpl) CreateTransferRequest(ctx context.Context, tx *gorm.DB, request *entity.TransferRequest) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	if err := db.WithContext(ctx).Create(request).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to create transfer request")
+		return fmt.Errorf("failed to create transfer request: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WalletRepositoryImpl) GetTransferRequestForUpdate(ctx context.Context, tx *gorm.DB, requestID uuid.UUID) (*entity.TransferRequest, error) {
+	var request entity.TransferRequest
+	err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", requestID).
+		First(&request).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		r.logger.WithError(err).WithField("transfer_request_id", requestID).Error("Failed to get transfer request for update")
+		return nil, fmt.Errorf("failed to get transfer request for update: %w", err)
+	}
+
+	return &request, nil
+}
+
+func (r *WalletRepositoryImpl) UpdateTransferRequest(ctx context.Context, tx *gorm.DB, request *entity.TransferRequest) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	err := db.WithContext(ctx).
+		Model(&entity.TransferRequest{}).
+		Where("id = ?", request.ID).
+		Updates(map[string]interface{}{
+			"status":         request.Status,
+			"decided_by":     request.DecidedBy,
+			"decided_at":     request.DecidedAt,
+			"transaction_id": request.TransactionID,
+		}).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("transfer_request_id", request.ID).Error("Failed to update transfer request")
+		return fmt.Errorf("failed to update transfer request: %w", err)
+	}
+
+	return nil
+}
+
+// ListExpiredTransferRequests returns up to limit pending requests whose
+// approval window closed before now, oldest first.
+func (r *WalletRepositoryImpl) ListExpiredTransferRequests(ctx context.Context, now time.Time, limit int) ([]*entity.TransferRequest, error) {
+	var requests []*entity.TransferRequest
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", entity.TransferRequestStatusPending, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&requests).Error
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list expired transfer requests")
+		return nil, fmt.Errorf("failed to list expired transfer requests: %w", err)
+	}
+	return requests, nil
+}
+
+// ListPendingTransferRequests returns the pending requests sent from the
+// wallet, newest first. Their amounts make up the wallet's held balance, so
+// requests past their expiry that the worker has not released yet are
+// included.
+func (r *WalletRepositoryImpl) ListPendingTransferRequests(ctx context.Context, walletID uuid.UUID) ([]*entity.TransferRequest, error) {
+	var requests []*entity.TransferRequest
+	err := r.reader(ctx).
+		Where("from_wallet_id = ? AND status = ?", walletID, entity.TransferRequestStatusPending).
+		Order("created_at DESC").
+		Find(&requests).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to list pending transfer requests")
+		return nil, fmt.Errorf("failed to list pending transfer requests: %w", err)
+	}
+	return requests, nil
+}
+
+// CreatePayoutDestination stores a new destination. It returns false without
+// error when the user already registered the same account.
+func (r *WalletRepositoryImpl) CreatePayoutDestination(ctx context.Context, destination *entity.PayoutDestination) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(destination)
+	if result.Error != nil {
+		r.logger.WithError(result.Error).WithField("user_id", destination.UserID).Error("Failed to create payout destination")
+		return false, fmt.Errorf("failed to create payout destination: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (r *WalletRepositoryImpl) GetPayoutDestination(ctx context.Context, destinationID uuid.UUID) (*entity.PayoutDestination, error) {
+	var destination entity.PayoutDestination
+	err := r.db.WithContext(ctx).Where("id = ?", destinationID).First(&destination).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		r.logger.WithError(err).WithField("payout_destination_id", destinationID).Error("Failed to get payout destination")
+		return nil, fmt.Errorf("failed to get payout destination: %w", err)
+	}
+
+	return &destination, nil
+}
+
+// ListPayoutDestinations returns the user's destinations, oldest first.
+func (r *WalletRepositoryImpl) ListPayoutDestinations(ctx context.Context, userID uuid.UUID) ([]*entity.PayoutDestination, error) {
+	var destinations []*entity.PayoutDestination
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&destinations).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("user_id", userID).Error("Failed to list payout destinations")
+		return nil, fmt.Errorf("failed to list payout destinations: %w", err)
+	}
+
+	return destinations, nil
+}
+
+func (r *WalletRepositoryImpl) VerifyPayoutDestination(ctx context.Context, destinationID, verifiedBy uuid.UUID, at time.Time) error {
+	err := r.db.WithContext(ctx).
+		Model(&entity.PayoutDestination{}).
+		Where("id = ?", destinationID).
+		Updates(map[string]interface{}{
+			"verified_at": at,
+			"verified_by": verifiedBy,
+		}).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("payout_destination_id", destinationID).Error("Failed to verify payout destination")
+		return fmt.Errorf("failed to verify payout destination: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePayoutDestination soft-deletes the destination; withdrawals already
+// paid out to it keep their reference.
+func (r *WalletRepositoryImpl) DeletePayoutDestination(ctx context.Context, destinationID uuid.UUID) error {
+	err := r.db.WithContext(ctx).Where("id = ?", destinationID).Delete(&entity.PayoutDestination{}).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("payout_destination_id", destinationID).Error("Failed to delete payout destination")
+		return fmt.Errorf("failed to delete payout destination: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WalletRepositoryImpl) UpdateAlertThreshold(ctx context.Context, walletID uuid.UUID, threshold *float64) error {
+	err := r.db.WithContext(ctx).
+		Model(&entity.Wallet{}).
+		Where("id = ?", walletID).
+		Update("alert_threshold", threshold).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to update alert threshold")
+		return fmt.Errorf("failed to update alert threshold: %w", err)
+	}
+	return nil
+}
+
+// UpdateLockedUntil sets or, with nil, clears the wallet's soft-lock.
+func (r *WalletRepositoryImpl) UpdateLockedUntil(ctx context.Context, walletID uuid.UUID, lockedUntil *time.Time) error {
+	err := r.db.WithContext(ctx).
+		Model(&entity.Wallet{}).
+		Where("id = ?", walletID).
+		Update("locked_until", lockedUntil).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to update wallet soft-lock")
+		return fmt.Errorf("failed to update wallet soft-lock: %w", err)
+	}
+	return nil
+}
+
 func (r *WalletRepositoryImpl) CreateTransaction(ctx context.Context, tx *gorm.DB, transaction *entity.Transaction) error {
 	db := r.db
 	if tx != nil {
@@ -126,6 +623,45 @@ func (r *WalletRepositoryImpl) CreateTransaction(ctx context.Context, tx *gorm.D
 	return nil
 }
 
+func (r *WalletRepositoryImpl) CreateWalletEvent(ctx context.Context, tx *gorm.DB, event *entity.WalletEvent) error {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	if err := db.WithContext(ctx).Create(event).Error; err != nil {
+		r.logger.WithError(err).WithField("wallet_id", event.WalletID).Error("Failed to create wallet event")
+		return fmt.Errorf("failed to create wallet event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *WalletRepositoryImpl) GetWalletEvents(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entity.WalletEvent, error) {
+	var events []*entity.WalletEvent
+	err := r.db.WithContext(ctx).
+		Where("wallet_id = ?", walletID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&events).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to get wallet events")
+		return nil, fmt.Errorf("failed to get wallet events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *WalletRepositoryImpl) CountWalletEvents(ctx context.Context, walletID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&entity.WalletEvent{}).Where("wallet_id = ?", walletID).Count(&count).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to count wallet events")
+		return 0, fmt.Errorf("failed to count wallet events: %w", err)
+	}
+	return count, nil
+}
+
 func (r *WalletRepositoryImpl) UpdateTransactionStatus(ctx context.Context, tx *gorm.DB, transactionID uuid.UUID, transaction *entity.Transaction) error {
 	db := r.db
 	if tx != nil {
@@ -144,12 +680,11 @@ func (r *WalletRepositoryImpl) UpdateTransactionStatus(ctx context.Context, tx *
 	return nil
 }
 
-func (r *WalletRepositoryImpl) GetTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, limit, offset int) ([]*entity.Transaction, error) {
+func (r *WalletRepositoryImpl) GetTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, limit, offset int, filter TransactionFilter) ([]*entity.Transaction, error) {
 	var transactions []*entity.Transaction
 
-	err := r.db.WithContext(ctx).
-		Where("wallet_id = ?", walletID).
-		Order("created_at DESC").
+	err := r.transactionsQuery(r.reader(ctx), walletID, filter).
+		Order(filter.orderClause("")).
 		Limit(limit).
 		Offset(offset).
 		Find(&transactions).Error
@@ -162,10 +697,9 @@ func (r *WalletRepositoryImpl) GetTransactionsByWalletID(ctx context.Context, wa
 	return transactions, nil
 }
 
-func (r *WalletRepositoryImpl) CountTransactionsByWalletID(ctx context.Context, walletID uuid.UUID) (int64, error) {
+func (r *WalletRepositoryImpl) CountTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, filter TransactionFilter) (int64, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&entity.Transaction{}).
-		Where("wallet_id = ?", walletID).
+	err := r.transactionsQuery(r.reader(ctx), walletID, filter).
 		Count(&count).Error
 	if err != nil {
 		return 0, fmt.Errorf("failed to count transactions: %w", err)
@@ -173,13 +707,435 @@ func (r *WalletRepositoryImpl) CountTransactionsByWalletID(ctx context.Context,
 	return count, nil
 }
 
+// SumTransactionsByWalletID totals completed credits and debits over the whole
+// filtered set in a single aggregate query.
+func (r *WalletRepositoryImpl) SumTransactionsByWalletID(ctx context.Context, walletID uuid.UUID, filter TransactionFilter) (float64, float64, error) {
+	var totals struct {
+		Deposited float64
+		Withdrawn float64
+	}
+
+	err := r.transactionsQuery(r.reader(ctx), walletID, filter).
+		Select(
+			"COALESCE(SUM(CASE WHEN type IN ? THEN amount END), 0) AS deposited, "+
+				"COALESCE(SUM(CASE WHEN type IN ? THEN amount END), 0) AS withdrawn",
+			creditTransactionTypes, debitTransactionTypes,
+		).
+		Where("status = ?", entity.TransactionStatusCompleted).
+		Scan(&totals).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to sum transactions")
+		return 0, 0, fmt.Errorf("failed to sum transactions: %w", err)
+	}
+
+	return totals.Deposited, totals.Withdrawn, nil
+}
+
+// SumClearingDeposits returns the completed deposits into the wallet that
+// are still on hold at now. Passing tx reads within that transaction, after
+// the wallet row was locked.
+func (r *WalletRepositoryImpl) SumClearingDeposits(ctx context.Context, tx *gorm.DB, walletID uuid.UUID, now time.Time) (float64, error) {
+	db := r.reader(ctx)
+	if tx != nil {
+		db = tx.WithContext(ctx)
+	}
+
+	var clearing float64
+	err := db.Model(&entity.Transaction{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("wallet_id = ? AND type = ? AND status = ? AND cleared_at > ?", walletID, entity.TransactionTypeDeposit, entity.TransactionStatusCompleted, now).
+		Scan(&clearing).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to sum clearing deposits")
+		return 0, fmt.Errorf("failed to sum clearing deposits: %w", err)
+	}
+
+	return clearing, nil
+}
+
+// ListClearingDeposits returns the deposits SumClearingDeposits adds up,
+// newest first.
+func (r *WalletRepositoryImpl) ListClearingDeposits(ctx context.Context, walletID uuid.UUID, now time.Time) ([]*entity.Transaction, error) {
+	var deposits []*entity.Transaction
+	err := r.reader(ctx).
+		Select(transactionColumns).
+		Where("wallet_id = ? AND type = ? AND status = ? AND cleared_at > ?", walletID, entity.TransactionTypeDeposit, entity.TransactionStatusCompleted, now).
+		Order("created_at DESC").
+		Find(&deposits).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to list clearing deposits")
+		return nil, fmt.Errorf("failed to list clearing deposits: %w", err)
+	}
+	return deposits, nil
+}
+
+// GetLatestTransactionAt returns the most recent completed transaction created
+// at or before at, or nil when there is none.
+func (r *WalletRepositoryImpl) GetLatestTransactionAt(ctx context.Context, walletID uuid.UUID, at time.Time, includeArchived bool) (*entity.Transaction, error) {
+	var transactions []*entity.Transaction
+
+	err := r.transactionsQuery(r.db.WithContext(ctx), walletID, TransactionFilter{To: &at, IncludeArchived: includeArchived}).
+		Where("status = ?", entity.TransactionStatusCompleted).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&transactions).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to get latest transaction")
+		return nil, fmt.Errorf("failed to get latest transaction: %w", err)
+	}
+
+	if len(transactions) == 0 {
+		return nil, nil
+	}
+	return transactions[0], nil
+}
+
+// transactionsQuery builds the base query on db for a wallet's transactions,
+// reading from the union of the hot and archive tables when the filter asks
+// for it.
+func (r *WalletRepositoryImpl) transactionsQuery(db *gorm.DB, walletID uuid.UUID, filter TransactionFilter) *gorm.DB {
+	var query *gorm.DB
+	if filter.IncludeArchived {
+		union := db.Raw(
+			"SELECT "+transactionColumns+" FROM transactions WHERE wallet_id = ? UNION ALL SELECT "+transactionColumns+" FROM archived_transactions WHERE wallet_id = ?",
+			walletID, walletID,
+		)
+		query = db.Table("(?) AS transactions", union)
+	} else {
+		query = db.Model(&entity.Transaction{}).Where("wallet_id = ?", walletID)
+	}
+
+	return filter.apply(query, "")
+}
+
+// GetTransactionsByIDRange returns up to limit transactions of the wallet
+// whose id lies between from and to inclusive. Passing the same id twice looks
+// up a single transaction.
+func (r *WalletRepositoryImpl) GetTransactionsByIDRange(ctx context.Context, walletID, from, to uuid.UUID, limit int) ([]*entity.Transaction, error) {
+	var transactions []*entity.Transaction
+
+	err := r.db.WithContext(ctx).
+		Where("wallet_id = ? AND id BETWEEN ? AND ?", walletID, from, to).
+		Order("id").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to get transactions by id")
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// GetTransactionsByUserID lists transactions across every wallet owned by the
+// user, newest first, with the owning wallet and its currency on each row.
+func (r *WalletRepositoryImpl) GetTransactionsByUserID(ctx context.Context, userID uuid.UUID, limit, offset int, filter TransactionFilter) ([]*UserTransaction, error) {
+	var transactions []*UserTransaction
+
+	err := r.userTransactionsQuery(ctx, userID, filter).
+		Select("t.id, t.wallet_id, w.currency, t.type, t.amount, t.status, t.description, t.created_at, t.updated_at").
+		Order(filter.orderClause("t.")).
+		Limit(limit).
+		Offset(offset).
+		Scan(&transactions).Error
+
+	if err != nil {
+		r.logger.WithError(err).WithField("user_id", userID).Error("Failed to get user transactions")
+		return nil, fmt.Errorf("failed to get user transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+func (r *WalletRepositoryImpl) CountTransactionsByUserID(ctx context.Context, userID uuid.UUID, filter TransactionFilter) (int64, error) {
+	var count int64
+	err := r.userTransactionsQuery(ctx, userID, filter).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count user transactions: %w", err)
+	}
+	return count, nil
+}
+
+// userTransactionsQuery joins transactions to the wallets owned by userID,
+// aliased as t and w.
+func (r *WalletRepositoryImpl) userTransactionsQuery(ctx context.Context, userID uuid.UUID, filter TransactionFilter) *gorm.DB {
+	query := transactionsTable(r.db.WithContext(ctx), filter.IncludeArchived).
+		Joins("JOIN wallets w ON w.id = t.wallet_id").
+		Where("w.user_id = ?", userID)
+
+	return filter.apply(query, "t.")
+}
+
+// ListPlatformTransactions lists transactions across every wallet for admins.
+// It scans the whole table, so it reads from the replica when there is one.
+func (r *WalletRepositoryImpl) ListPlatformTransactions(ctx context.Context, limit, offset int, filter TransactionFilter) ([]*PlatformTransaction, error) {
+	var transactions []*PlatformTransaction
+
+	err := r.platformTransactionsQuery(ctx, filter).
+		Select("t.id, t.wallet_id, w.currency, t.type, t.amount, t.status, t.description, t.created_at, t.updated_at, w.user_id, u.name AS user_name, u.email AS user_email").
+		Order(filter.orderClause("t.")).
+		Limit(limit).
+		Offset(offset).
+		Scan(&transactions).Error
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list platform transactions")
+		return nil, fmt.Errorf("failed to list platform transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+func (r *WalletRepositoryImpl) CountPlatformTransactions(ctx context.Context, filter TransactionFilter) (int64, error) {
+	var count int64
+	err := r.platformTransactionsQuery(ctx, filter).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count platform transactions: %w", err)
+	}
+	return count, nil
+}
+
+// platformTransactionsQuery joins every transaction to its wallet and owner,
+// aliased as t, w and u.
+func (r *WalletRepositoryImpl) platformTransactionsQuery(ctx context.Context, filter TransactionFilter) *gorm.DB {
+	query := transactionsTable(r.reader(ctx), filter.IncludeArchived).
+		Joins("JOIN wallets w ON w.id = t.wallet_id").
+		Joins("JOIN users u ON u.id = w.user_id")
+
+	return filter.apply(query, "t.")
+}
+
+// transactionsTable selects from transactions aliased as t, including the
+// archived ones when asked.
+func transactionsTable(db *gorm.DB, includeArchived bool) *gorm.DB {
+	if !includeArchived {
+		return db.Table("transactions AS t")
+	}
+	union := db.Raw(
+		"SELECT " + transactionColumns + " FROM transactions UNION ALL SELECT " + transactionColumns + " FROM archived_transactions",
+	)
+	return db.Table("(?) AS t", union)
+}
+
+// ArchiveTransactionsBefore moves up to batchSize settled transactions created
+// before cutoff into archived_transactions and returns how many were moved.
+// The copy and the delete run in one database transaction, so a row is never
+// in both tables.
+func (r *WalletRepositoryImpl) ArchiveTransactionsBefore(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	var moved int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		err := tx.Model(&entity.Transaction{}).
+			Where("created_at < ? AND status <> ?", cutoff, entity.TransactionStatusPending).
+			Order("created_at").
+			Limit(batchSize).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		err = tx.Exec(
+			"INSERT INTO archived_transactions ("+transactionColumns+") SELECT "+transactionColumns+" FROM transactions WHERE id IN ?",
+			ids,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Exec("DELETE FROM transactions WHERE id IN ?", ids)
+		moved = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to archive transactions")
+		return 0, fmt.Errorf("failed to archive transactions: %w", err)
+	}
+
+	return moved, nil
+}
+
+// GetTransactionInsights groups completed transactions into granularity sized
+// buckets (any unit accepted by Postgres date_trunc) and sums money in and out
+// of the wallet per bucket.
+func (r *WalletRepositoryImpl) GetTransactionInsights(ctx context.Context, walletID uuid.UUID, granularity string, from, to *time.Time) ([]*InsightBucket, error) {
+	var buckets []*InsightBucket
+
+	query := r.db.WithContext(ctx).
+		Model(&entity.Transaction{}).
+		Select(
+			"date_trunc(?, created_at) AS bucket, "+
+				"COALESCE(SUM(CASE WHEN type IN ? THEN amount END), 0) AS total_deposit, "+
+				"COALESCE(SUM(CASE WHEN type IN ? THEN amount END), 0) AS total_withdraw",
+			granularity, creditTransactionTypes, debitTransactionTypes,
+		).
+		Where("wallet_id = ? AND status = ?", walletID, entity.TransactionStatusCompleted)
+
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at <= ?", *to)
+	}
+
+	err := query.Group("bucket").Order("bucket").Scan(&buckets).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to get transaction insights")
+		return nil, fmt.Errorf("failed to get transaction insights: %w", err)
+	}
+
+	return buckets, nil
+}
+
+// GetBalanceHistory returns, for each period of granularity within the
+// filter's range, the balance after the last completed transaction in it.
+// Periods without a transaction, and transactions recorded before balances
+// were snapshotted, are left out.
+func (r *WalletRepositoryImpl) GetBalanceHistory(ctx context.Context, walletID uuid.UUID, granularity string, filter TransactionFilter) ([]*BalanceBucket, error) {
+	var buckets []*BalanceBucket
+
+	err := r.transactionsQuery(r.reader(ctx), walletID, filter).
+		Select("DISTINCT ON (bucket) date_trunc(?, created_at) AS bucket, balance_after AS balance", granularity).
+		Where("status = ? AND balance_after IS NOT NULL", entity.TransactionStatusCompleted).
+		Order("bucket, created_at DESC").
+		Scan(&buckets).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to get balance history")
+		return nil, fmt.Errorf("failed to get balance history: %w", err)
+	}
+
+	return buckets, nil
+}
+
+func (r *WalletRepositoryImpl) ListInterestBearingWallets(ctx context.Context, defaultRate float64) ([]*entity.Wallet, error) {
+	var wallets []*entity.Wallet
+
+	err := r.db.WithContext(ctx).
+		Where("balance > 0 AND COALESCE(interest_rate, ?) > 0", defaultRate).
+		Find(&wallets).Error
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to list interest bearing wallets")
+		return nil, fmt.Errorf("failed to list interest bearing wallets: %w", err)
+	}
+
+	return wallets, nil
+}
+
+// SumAllBalances totals the balance of every customer wallet, per currency
+// since amounts in different currencies cannot be added up. The system wallet
+// is left out: it holds the negative of every deposit it funded, so counting
+// it would net each currency to about zero.
+func (r *WalletRepositoryImpl) SumAllBalances(ctx context.Context) ([]*CurrencyBalance, error) {
+	var totals []*CurrencyBalance
+
+	err := r.db.WithContext(ctx).
+		Model(&entity.Wallet{}).
+		Select("currency, COALESCE(SUM(balance), 0) AS total, COUNT(*) AS wallets").
+		Where("NOT is_system").
+		Group("currency").
+		Order("currency").
+		Scan(&totals).Error
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to sum wallet balances")
+		return nil, fmt.Errorf("failed to sum wallet balances: %w", err)
+	}
+
+	return totals, nil
+}
+
+// GetTransactionVelocity counts and totals the wallet's completed
+// transactions created within the hour, day and week before now. The week is
+// read once, with each shorter window a conditional aggregate over it.
+func (r *WalletRepositoryImpl) GetTransactionVelocity(ctx context.Context, walletID uuid.UUID, now time.Time) (*TransactionVelocity, error) {
+	hour, day, week := now.Add(-time.Hour), now.Add(-24*time.Hour), now.Add(-7*24*time.Hour)
+	var velocity TransactionVelocity
+
+	err := r.reader(ctx).
+		Model(&entity.Transaction{}).
+		Select(
+			"COUNT(CASE WHEN created_at >= ? THEN 1 END) AS hour_count, "+
+				"COALESCE(SUM(CASE WHEN created_at >= ? THEN amount END), 0) AS hour_total, "+
+				"COUNT(CASE WHEN created_at >= ? THEN 1 END) AS day_count, "+
+				"COALESCE(SUM(CASE WHEN created_at >= ? THEN amount END), 0) AS day_total, "+
+				"COUNT(*) AS week_count, "+
+				"COALESCE(SUM(amount), 0) AS week_total",
+			hour, hour, day, day,
+		).
+		Where("wallet_id = ? AND status = ? AND created_at >= ? AND created_at <= ?", walletID, entity.TransactionStatusCompleted, week, now).
+		Scan(&velocity).Error
+	if err != nil {
+		r.logger.WithError(err).WithField("wallet_id", walletID).Error("Failed to get transaction velocity")
+		return nil, fmt.Errorf("failed to get transaction velocity: %w", err)
+	}
+
+	return &velocity, nil
+}
+
+// CreateInterestAccrual records the per-wallet per-day accrual marker. It
+// returns false without error when the marker already exists, meaning the
+// interest for that day has already been paid.
+func (r *WalletRepositoryImpl) CreateInterestAccrual(ctx context.Context, tx *gorm.DB, accrual *entity.InterestAccrual) (bool, error) {
+	db := r.db
+	if tx != nil {
+		db = tx
+	}
+
+	result := db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(accrual)
+	if result.Error != nil {
+		r.logger.WithError(result.Error).WithField("wallet_id", accrual.WalletID).Error("Failed to create interest accrual")
+		return false, fmt.Errorf("failed to create interest accrual: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
 func (r *WalletRepositoryImpl) BeginTx(ctx context.Context) *gorm.DB {
 	return r.db.WithContext(ctx).Begin()
 }
 
+// BeginTxWithOptions begins a transaction with opts, such as a stricter
+// isolation level than the database default of READ COMMITTED.
+func (r *WalletRepositoryImpl) BeginTxWithOptions(ctx context.Context, opts *sql.TxOptions) *gorm.DB {
+	return r.db.WithContext(ctx).Begin(opts)
+}
+
 func (r *WalletRepositoryImpl) WithTx(tx *gorm.DB) WalletRepository {
 	return &WalletRepositoryImpl{
-		db:     tx,
-		logger: r.logger,
+		db:          tx,
+		logger:      r.logger,
+		lockTimeout: r.lockTimeout,
 	}
 }
+
+// setLockTimeout applies the lock timeout to the rest of the transaction db
+// runs in. SET LOCAL does not accept bind parameters, so the value is
+// formatted in; it is an integer and cannot carry SQL.
+func (r *WalletRepositoryImpl) setLockTimeout(ctx context.Context, db *gorm.DB) error {
+	if r.lockTimeout <= 0 {
+		return nil
+	}
+	err := db.WithContext(ctx).Exec(fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", r.lockTimeout.Milliseconds())).Error
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to set lock timeout")
+		return fmt.Errorf("failed to set lock timeout: %w", err)
+	}
+	return nil
+}
+
+func isLockTimeout(err error) bool {
+	return hasSQLState(err, lockNotAvailable)
+}
+
+// IsSerializationFailure reports whether err aborted the surrounding
+// transaction because it conflicted with a concurrent one. Nothing was
+// changed, and running the whole transaction again may succeed.
+func IsSerializationFailure(err error) bool {
+	return hasSQLState(err, serializationFailure)
+}
+
+func hasSQLState(err error, code string) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == code
+}
@@ -0,0 +1,351 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"go-digital-wallet/internal/commons/readpref"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRepositoryTest(t *testing.T) (*gorm.DB, repository.WalletRepository) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	// The Postgres migrations do not run on sqlite, so create a minimal table.
+	require.NoError(t, db.Exec(`CREATE TABLE transactions (
+		id TEXT PRIMARY KEY,
+		wallet_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		amount REAL NOT NULL,
+		status TEXT NOT NULL,
+		description TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		balance_after REAL,
+		payout_destination_id TEXT,
+		exchange_rate REAL,
+		original_amount REAL,
+		converted_amount REAL,
+		transfer_id TEXT,
+		cleared_at DATETIME
+	)`).Error)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	return db, repository.NewWalletRepository(db, logger)
+}
+
+func TestGetTransactionsByWalletID_Ordering(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	walletID := uuid.New()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Inserted out of order on purpose.
+	for _, offset := range []int{2, 0, 3, 1} {
+		require.NoError(t, db.Omit("Wallet").Create(&entity.Transaction{
+			ID:        uuid.New(),
+			WalletID:  walletID,
+			Type:      entity.TransactionTypeDeposit,
+			Amount:    100,
+			Status:    entity.TransactionStatusCompleted,
+			CreatedAt: base.Add(time.Duration(offset) * time.Hour),
+			UpdatedAt: base,
+		}).Error)
+	}
+
+	desc, err := repo.GetTransactionsByWalletID(context.Background(), walletID, 10, 0, repository.TransactionFilter{})
+	require.NoError(t, err)
+	asc, err := repo.GetTransactionsByWalletID(context.Background(), walletID, 10, 0, repository.TransactionFilter{Ascending: true})
+	require.NoError(t, err)
+
+	require.Len(t, desc, 4)
+	require.Len(t, asc, 4)
+	for i := 1; i < 4; i++ {
+		assert.True(t, desc[i-1].CreatedAt.After(desc[i].CreatedAt), "desc must be newest first")
+		assert.True(t, asc[i-1].CreatedAt.Before(asc[i].CreatedAt), "asc must be oldest first")
+	}
+}
+
+func TestGetTransactionsByWalletID_SearchFilters(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	walletID := uuid.New()
+	now := time.Now()
+
+	for _, tx := range []struct {
+		txType      entity.TransactionType
+		amount      float64
+		description string
+	}{
+		{entity.TransactionTypeDeposit, 50, "Salary March"},
+		{entity.TransactionTypeDeposit, 500, "salary april"},
+		{entity.TransactionTypeWithdraw, 500, "Salary advance"},
+		{entity.TransactionTypeDeposit, 800, "100%_bonus"},
+	} {
+		require.NoError(t, db.Omit("Wallet").Create(&entity.Transaction{
+			ID:          uuid.New(),
+			WalletID:    walletID,
+			Type:        tx.txType,
+			Amount:      tx.amount,
+			Status:      entity.TransactionStatusCompleted,
+			Description: tx.description,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}).Error)
+	}
+
+	minAmount, maxAmount := 100.0, 600.0
+	found, err := repo.GetTransactionsByWalletID(context.Background(), walletID, 10, 0, repository.TransactionFilter{
+		Types:     []entity.TransactionType{entity.TransactionTypeDeposit},
+		MinAmount: &minAmount,
+		MaxAmount: &maxAmount,
+		Search:    "SALARY",
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "salary april", found[0].Description)
+
+	// LIKE wildcards in the search text match literally.
+	found, err = repo.GetTransactionsByWalletID(context.Background(), walletID, 10, 0, repository.TransactionFilter{Search: "%_"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "100%_bonus", found[0].Description)
+}
+
+func TestGetTransactionsByIDRange_MatchesWithinWallet(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	walletID := uuid.New()
+	inside := uuid.MustParse("8f3a0000-0000-4000-8000-000000000001")
+	now := time.Now()
+
+	for _, tr := range []*entity.Transaction{
+		{ID: inside, WalletID: walletID},
+		{ID: uuid.MustParse("8f3b0000-0000-4000-8000-000000000001"), WalletID: walletID},
+		{ID: uuid.MustParse("8f3a0000-0000-4000-8000-000000000002"), WalletID: uuid.New()},
+	} {
+		tr.Type, tr.Amount, tr.Status, tr.CreatedAt, tr.UpdatedAt = entity.TransactionTypeDeposit, 10, entity.TransactionStatusCompleted, now, now
+		require.NoError(t, db.Omit("Wallet").Create(tr).Error)
+	}
+
+	from := uuid.MustParse("8f3a0000-0000-0000-0000-000000000000")
+	to := uuid.MustParse("8f3a0000-0000-ffff-ffff-ffffffffffff")
+	found, err := repo.GetTransactionsByIDRange(context.Background(), walletID, from, to, 2)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, inside, found[0].ID)
+
+	exact, err := repo.GetTransactionsByIDRange(context.Background(), walletID, inside, inside, 2)
+	require.NoError(t, err)
+	assert.Len(t, exact, 1)
+}
+
+func TestListPlatformTransactions_JoinsOwnerAndFilters(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	require.NoError(t, db.Exec(`CREATE TABLE wallets (id TEXT PRIMARY KEY, user_id TEXT NOT NULL, currency TEXT NOT NULL)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT NOT NULL, email TEXT NOT NULL)`).Error)
+
+	now := time.Now()
+	for i, status := range []entity.TransactionStatus{entity.TransactionStatusFailed, entity.TransactionStatusCompleted} {
+		userID, walletID := uuid.New(), uuid.New()
+		require.NoError(t, db.Exec(`INSERT INTO users (id, name, email) VALUES (?, ?, ?)`, userID, fmt.Sprintf("User %d", i), fmt.Sprintf("user%d@example.com", i)).Error)
+		require.NoError(t, db.Exec(`INSERT INTO wallets (id, user_id, currency) VALUES (?, ?, 'IDR')`, walletID, userID).Error)
+		for _, txType := range []entity.TransactionType{entity.TransactionTypeWithdraw, entity.TransactionTypeDeposit} {
+			require.NoError(t, db.Omit("Wallet").Create(&entity.Transaction{
+				ID:        uuid.New(),
+				WalletID:  walletID,
+				Type:      txType,
+				Amount:    100,
+				Status:    status,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}).Error)
+		}
+	}
+
+	filter := repository.TransactionFilter{
+		Types:  []entity.TransactionType{entity.TransactionTypeWithdraw},
+		Status: entity.TransactionStatusFailed,
+	}
+	found, err := repo.ListPlatformTransactions(context.Background(), 10, 0, filter)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, entity.TransactionTypeWithdraw, found[0].Type)
+	assert.Equal(t, "User 0", found[0].UserName)
+	assert.Equal(t, "user0@example.com", found[0].UserEmail)
+	assert.Equal(t, "IDR", found[0].Currency)
+
+	count, err := repo.CountPlatformTransactions(context.Background(), repository.TransactionFilter{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, count)
+}
+
+func TestSumAllBalances_ExcludesSystemWallet(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	require.NoError(t, db.Exec(`CREATE TABLE wallets (id TEXT PRIMARY KEY, currency TEXT NOT NULL, balance REAL NOT NULL, is_system BOOLEAN NOT NULL DEFAULT FALSE)`).Error)
+	for _, w := range []struct {
+		balance  float64
+		isSystem bool
+	}{{100, false}, {250.5, false}, {-350.5, true}} {
+		require.NoError(t, db.Exec(`INSERT INTO wallets (id, currency, balance, is_system) VALUES (?, 'IDR', ?, ?)`, uuid.New(), w.balance, w.isSystem).Error)
+	}
+
+	totals, err := repo.SumAllBalances(context.Background())
+	require.NoError(t, err)
+	require.Len(t, totals, 1)
+	assert.Equal(t, "IDR", totals[0].Currency)
+	assert.Equal(t, 350.5, totals[0].Total)
+	assert.Equal(t, int64(2), totals[0].Wallets)
+}
+
+func TestExistsByUserID(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	require.NoError(t, db.Exec(`CREATE TABLE wallets (id TEXT PRIMARY KEY, user_id TEXT NOT NULL)`).Error)
+	userID := uuid.New()
+	require.NoError(t, db.Exec(`INSERT INTO wallets (id, user_id) VALUES (?, ?)`, uuid.New(), userID).Error)
+
+	exists, err := repo.ExistsByUserID(context.Background(), userID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = repo.ExistsByUserID(context.Background(), uuid.New())
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestReplicaServesReadsUnlessPrimaryRequested(t *testing.T) {
+	primary, _ := setupRepositoryTest(t)
+	replica, _ := setupRepositoryTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	repo := repository.NewWalletRepositoryWithOptions(primary, logger, repository.WalletRepositoryOptions{Replica: replica})
+
+	// Written to the primary only, as if not replicated yet.
+	walletID := uuid.New()
+	require.NoError(t, primary.Omit("Wallet").Create(&entity.Transaction{
+		ID:        uuid.New(),
+		WalletID:  walletID,
+		Type:      entity.TransactionTypeDeposit,
+		Amount:    100,
+		Status:    entity.TransactionStatusCompleted,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}).Error)
+
+	count, err := repo.CountTransactionsByWalletID(context.Background(), walletID, repository.TransactionFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	count, err = repo.CountTransactionsByWalletID(readpref.WithPrimary(context.Background()), walletID, repository.TransactionFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	tx := primary.Begin()
+	defer tx.Rollback()
+	count, err = repo.WithTx(tx).CountTransactionsByWalletID(context.Background(), walletID, repository.TransactionFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestGetTransactionVelocity(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	walletID := uuid.New()
+	now := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	for _, tx := range []entity.Transaction{
+		{Type: entity.TransactionTypeDeposit, Amount: 100, Status: entity.TransactionStatusCompleted, CreatedAt: now.Add(-10 * time.Minute)},
+		{Type: entity.TransactionTypeWithdraw, Amount: 40, Status: entity.TransactionStatusCompleted, CreatedAt: now.Add(-5 * time.Hour)},
+		{Type: entity.TransactionTypeWithdraw, Amount: 25, Status: entity.TransactionStatusCompleted, CreatedAt: now.Add(-3 * 24 * time.Hour)},
+		{Type: entity.TransactionTypeDeposit, Amount: 1000, Status: entity.TransactionStatusCompleted, CreatedAt: now.Add(-8 * 24 * time.Hour)},
+		{Type: entity.TransactionTypeWithdraw, Amount: 10, Status: entity.TransactionStatusFailed, CreatedAt: now.Add(-time.Minute)},
+	} {
+		tx.ID = uuid.New()
+		tx.WalletID = walletID
+		tx.UpdatedAt = tx.CreatedAt
+		require.NoError(t, db.Omit("Wallet").Create(&tx).Error)
+	}
+
+	velocity, err := repo.GetTransactionVelocity(context.Background(), walletID, now)
+	require.NoError(t, err)
+	assert.Equal(t, &repository.TransactionVelocity{
+		HourCount: 1, HourTotal: 100,
+		DayCount: 2, DayTotal: 140,
+		WeekCount: 3, WeekTotal: 165,
+	}, velocity)
+}
+
+func TestArchiveTransactionsBefore_KeepsApprovedRequestLink(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	require.NoError(t, db.Exec(`CREATE TABLE archived_transactions AS SELECT * FROM transactions WHERE 0`).Error)
+	// As after migration 000020: transaction_id is not a foreign key, so
+	// archiving the leg an approved request points at does not fail.
+	require.NoError(t, db.Exec(`CREATE TABLE transfer_requests (
+		id TEXT PRIMARY KEY,
+		status TEXT NOT NULL,
+		transaction_id TEXT
+	)`).Error)
+
+	walletID := uuid.New()
+	old := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	leg := entity.Transaction{ID: uuid.New(), WalletID: walletID, Type: entity.TransactionTypeTransferOut, Amount: 75, Status: entity.TransactionStatusCompleted, CreatedAt: old, UpdatedAt: old}
+	recent := entity.Transaction{ID: uuid.New(), WalletID: walletID, Type: entity.TransactionTypeDeposit, Amount: 10, Status: entity.TransactionStatusCompleted, CreatedAt: old.AddDate(1, 0, 0), UpdatedAt: old.AddDate(1, 0, 0)}
+	for _, tx := range []*entity.Transaction{&leg, &recent} {
+		require.NoError(t, db.Omit("Wallet").Create(tx).Error)
+	}
+	requestID := uuid.New()
+	require.NoError(t, db.Exec(`INSERT INTO transfer_requests (id, status, transaction_id) VALUES (?, ?, ?)`, requestID, entity.TransferRequestStatusApproved, leg.ID).Error)
+
+	moved, err := repo.ArchiveTransactionsBefore(context.Background(), old.AddDate(0, 6, 0), 100)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), moved)
+
+	var hot, archived int64
+	require.NoError(t, db.Table("transactions").Count(&hot).Error)
+	require.NoError(t, db.Table("archived_transactions").Where("id = ?", leg.ID).Count(&archived).Error)
+	assert.Equal(t, int64(1), hot)
+	assert.Equal(t, int64(1), archived)
+
+	var linked string
+	require.NoError(t, db.Raw(`SELECT transaction_id FROM transfer_requests WHERE id = ?`, requestID).Scan(&linked).Error)
+	assert.Equal(t, leg.ID.String(), linked)
+}
+
+func TestSumClearingDeposits(t *testing.T) {
+	db, repo := setupRepositoryTest(t)
+	walletID := uuid.New()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	for _, tx := range []entity.Transaction{
+		{Type: entity.TransactionTypeDeposit, Amount: 100, Status: entity.TransactionStatusCompleted, ClearedAt: at(time.Hour)},
+		{Type: entity.TransactionTypeDeposit, Amount: 40, Status: entity.TransactionStatusCompleted, ClearedAt: at(-time.Hour)},
+		{Type: entity.TransactionTypeDeposit, Amount: 20, Status: entity.TransactionStatusCompleted},
+		{Type: entity.TransactionTypeDeposit, Amount: 10, Status: entity.TransactionStatusFailed, ClearedAt: at(time.Hour)},
+	} {
+		tx.ID = uuid.New()
+		tx.WalletID = walletID
+		tx.CreatedAt, tx.UpdatedAt = now, now
+		require.NoError(t, db.Omit("Wallet").Create(&tx).Error)
+	}
+
+	clearing, err := repo.SumClearingDeposits(context.Background(), nil, walletID, now)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, clearing)
+
+	deposits, err := repo.ListClearingDeposits(context.Background(), walletID, now)
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	assert.Equal(t, 100.0, deposits[0].Amount)
+}
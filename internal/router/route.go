@@ -1,8 +1,10 @@
 package router
 
 import (
+	"go-digital-wallet/internal/entity"
 	"go-digital-wallet/internal/handler"
 	"go-digital-wallet/internal/middleware"
+	"go-digital-wallet/pkg/metrics"
 	"net/http"
 	"time"
 
@@ -13,11 +15,48 @@ type RouteConfig struct {
 	App              *gin.Engine
 	AuthHandler      handler.AuthHandler
 	WalletHandler    handler.WalletHandler
+	AdminHandler     handler.AdminHandler
 	AuthMiddleware   *middleware.AuthMiddleware
 	LoggerMiddleware gin.HandlerFunc
+
+	RequestIDMiddleware   gin.HandlerFunc
+	RecoveryMiddleware    gin.HandlerFunc
+	MaintenanceMiddleware *middleware.MaintenanceMiddleware
+	RateLimiter           *middleware.RateLimiter
+	// IdempotencyMiddleware replays the response of a money-moving request
+	// repeated with the same Idempotency-Key.
+	IdempotencyMiddleware *middleware.IdempotencyMiddleware
+	// ReadinessHandler answers readiness probes on /ready.
+	ReadinessHandler gin.HandlerFunc
+	// ConcurrencyMiddleware sheds load once too many API requests are in
+	// flight.
+	ConcurrencyMiddleware gin.HandlerFunc
+	// AdminSearchPerMinute caps admin user searches per admin.
+	AdminSearchPerMinute int
+	// AdminTransactionsPerMinute caps admin listings of all transactions per
+	// admin.
+	AdminTransactionsPerMinute int
+	// DataExportPerHour caps personal data exports per user.
+	DataExportPerHour int
+	// EmailChangePerHour caps email change requests per user.
+	EmailChangePerHour int
+	// CacheBypassPerMinute caps uncached transaction history reads per user.
+	CacheBypassPerMinute int
+	// StatementVerifyPerMinute caps statement verifications per client IP.
+	StatementVerifyPerMinute int
+	// DefaultTimeout is the deadline of every API route; BalanceTimeout and
+	// ExportTimeout override it for the balance, statement, export and import
+	// routes. Zero disables a deadline.
+	DefaultTimeout time.Duration
+	BalanceTimeout time.Duration
+	ExportTimeout  time.Duration
 }
 
 func (c *RouteConfig) SetupRoute() {
+	// Registered first so that every route, including health and metrics,
+	// gets a request id and panic recovery.
+	c.App.Use(c.RequestIDMiddleware, c.RecoveryMiddleware)
+
 	c.App.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"status":    "healthy",
@@ -26,26 +65,86 @@ func (c *RouteConfig) SetupRoute() {
 		})
 	})
 
+	c.App.GET("/ready", c.ReadinessHandler)
+
+	c.App.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	c.App.Use(c.LoggerMiddleware)
 
+	// Unmatched requests still run the global middleware above, so these
+	// errors carry a request id and are logged like any other.
+	c.App.HandleMethodNotAllowed = true
+	c.App.NoRoute(handler.NoRoute)
+	c.App.NoMethod(handler.NoMethod)
+
 	v1 := c.App.Group("/api/v1")
+	v1.Use(c.ConcurrencyMiddleware, middleware.Timeout(c.DefaultTimeout))
 	{
 		// Auth routes (public)
 		auth := v1.Group("/auth")
 		{
 			auth.POST("/register", c.AuthHandler.Register)
 			auth.POST("/login", c.AuthHandler.Login)
+			auth.POST("/change-email", c.AuthMiddleware.JWTAuth(), c.RateLimiter.Limit("email_change", c.EmailChangePerHour, time.Hour), c.AuthHandler.ChangeEmail)
+			auth.POST("/change-email/confirm", c.AuthHandler.ConfirmEmailChange)
+			auth.GET("/export-data", c.AuthMiddleware.JWTAuth(), c.RateLimiter.Limit("data_export", c.DataExportPerHour, time.Hour), middleware.Timeout(c.ExportTimeout), c.AuthHandler.ExportData)
 		}
+		// Current user routes
+		me := v1.Group("/me")
+		{
+			me.GET("/overview", c.AuthMiddleware.JWTAuth(), c.AuthHandler.GetOverview)
+		}
+		// Statement verification (public, so third parties can check a
+		// statement they were handed)
+		v1.POST("/statements/verify", c.RateLimiter.Limit("statement_verify", c.StatementVerifyPerMinute, time.Minute), middleware.Timeout(c.ExportTimeout), c.WalletHandler.VerifyStatement)
 		// Wallet routes
 		protected := v1.Group("/wallets")
 		{
-			protected.Use(c.AuthMiddleware.JWTAuth())
+			protected.Use(c.AuthMiddleware.JWTAuth(), middleware.DebugTiming(entity.RoleAdmin))
+			{
+				protected.POST("/", c.MaintenanceMiddleware.BlockWrites(), c.WalletHandler.CreateWallet)
+				protected.POST("/ensure", c.MaintenanceMiddleware.BlockWrites(), c.WalletHandler.EnsureWallet)
+				protected.GET("/balance", middleware.Timeout(c.BalanceTimeout), c.WalletHandler.GetBalance)
+				protected.GET("/exists", middleware.Timeout(c.BalanceTimeout), c.WalletHandler.WalletExists)
+				protected.GET("/holds", middleware.Timeout(c.BalanceTimeout), c.WalletHandler.ListHolds)
+				protected.POST("/withdraw", c.MaintenanceMiddleware.BlockWrites(), c.IdempotencyMiddleware.Idempotent(), c.WalletHandler.Withdraw)
+				protected.POST("/withdraw/initiate", c.MaintenanceMiddleware.BlockWrites(), c.WalletHandler.InitiateWithdraw)
+				protected.POST("/withdraw/confirm", c.MaintenanceMiddleware.BlockWrites(), c.IdempotencyMiddleware.Idempotent(), c.WalletHandler.ConfirmWithdraw)
+				protected.POST("/deposit", c.MaintenanceMiddleware.BlockWrites(), c.IdempotencyMiddleware.Idempotent(), c.WalletHandler.Deposit)
+				protected.POST("/transfer", c.MaintenanceMiddleware.BlockWrites(), c.IdempotencyMiddleware.Idempotent(), c.WalletHandler.Transfer)
+				protected.POST("/transfer-requests", c.MaintenanceMiddleware.BlockWrites(), c.IdempotencyMiddleware.Idempotent(), c.WalletHandler.CreateTransferRequest)
+				protected.GET("/transactions", c.RateLimiter.LimitWhen(handler.NoCacheRequested, "history_cache_bypass", c.CacheBypassPerMinute, time.Minute), c.WalletHandler.GetTransactionHistory)
+				protected.POST("/transactions/search", c.RateLimiter.LimitWhen(handler.NoCacheRequested, "history_cache_bypass", c.CacheBypassPerMinute, time.Minute), c.WalletHandler.SearchTransactions)
+				protected.GET("/transactions/:id", c.WalletHandler.GetTransaction)
+				protected.GET("/insights", c.WalletHandler.GetInsights)
+				protected.GET("/activity", c.WalletHandler.GetActivity)
+				protected.PUT("/alert-threshold", c.WalletHandler.SetAlertThreshold)
+				protected.GET("/:id/balance-at", c.WalletHandler.GetBalanceAt)
+				protected.GET("/:id/balance-history", c.WalletHandler.GetBalanceHistory)
+				protected.GET("/:id/events", c.WalletHandler.GetWalletEvents)
+				protected.GET("/statement", middleware.Timeout(c.ExportTimeout), c.WalletHandler.ExportStatement)
+				protected.POST("/payout-destinations", c.MaintenanceMiddleware.BlockWrites(), c.WalletHandler.CreatePayoutDestination)
+				protected.GET("/payout-destinations", c.WalletHandler.ListPayoutDestinations)
+				protected.DELETE("/payout-destinations/:id", c.MaintenanceMiddleware.BlockWrites(), c.WalletHandler.DeletePayoutDestination)
+			}
+		}
+		// Admin routes
+		admin := v1.Group("/admin")
+		{
+			admin.Use(c.AuthMiddleware.JWTAuth(), c.AuthMiddleware.RequireRole(entity.RoleAdmin), middleware.DebugTiming(entity.RoleAdmin))
 			{
-				protected.POST("/", c.WalletHandler.CreateWallet)
-				protected.GET("/balance", c.WalletHandler.GetBalance)
-				protected.POST("/withdraw", c.WalletHandler.Withdraw)
-				protected.POST("/deposit", c.WalletHandler.Deposit)
-				protected.GET("/transactions", c.WalletHandler.GetTransactionHistory)
+				admin.GET("/maintenance", c.AdminHandler.GetMaintenance)
+				admin.PUT("/maintenance", c.AdminHandler.SetMaintenance)
+				admin.POST("/transfer-requests/:id/approve", c.MaintenanceMiddleware.BlockWrites(), c.IdempotencyMiddleware.Idempotent(), c.WalletHandler.ApproveTransferRequest)
+				admin.POST("/transfer-requests/:id/reject", c.MaintenanceMiddleware.BlockWrites(), c.IdempotencyMiddleware.Idempotent(), c.WalletHandler.RejectTransferRequest)
+				admin.GET("/total-balance", c.WalletHandler.GetTotalBalance)
+				admin.GET("/transactions", c.RateLimiter.Limit("admin_transactions", c.AdminTransactionsPerMinute, time.Minute), c.WalletHandler.ListPlatformTransactions)
+				admin.POST("/transactions/import", c.MaintenanceMiddleware.BlockWrites(), middleware.Timeout(c.ExportTimeout), c.WalletHandler.ImportTransactions)
+				admin.GET("/wallets/:id/velocity", c.WalletHandler.GetTransactionVelocity)
+				admin.PUT("/wallets/:id/soft-lock", c.WalletHandler.SoftLockWallet)
+				admin.DELETE("/wallets/:id/soft-lock", c.WalletHandler.ClearSoftLock)
+				admin.POST("/payout-destinations/:id/verify", c.WalletHandler.VerifyPayoutDestination)
+				admin.GET("/users", c.RateLimiter.Limit("admin_user_search", c.AdminSearchPerMinute, time.Minute), c.AdminHandler.SearchUsers)
 			}
 		}
 	}
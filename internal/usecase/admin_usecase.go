@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type AdminUsecase interface {
+	SearchUsers(ctx context.Context, query string, limit, offset int) (*params.UserSearchResponse, *response.CustomError)
+}
+
+type AdminUsecaseImpl struct {
+	userRepository repository.UserRepository
+	logger         *logrus.Logger
+}
+
+func NewAdminUsecase(userRepository repository.UserRepository, logger *logrus.Logger) AdminUsecase {
+	return &AdminUsecaseImpl{
+		userRepository: userRepository,
+		logger:         logger,
+	}
+}
+
+// SearchUsers looks users up by a partial, case-insensitive match on email or
+// name. Only fields that are safe to show support staff are returned.
+func (u *AdminUsecaseImpl) SearchUsers(ctx context.Context, query string, limit, offset int) (*params.UserSearchResponse, *response.CustomError) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, response.BadRequestError("search query is required")
+	}
+
+	users, total, err := u.userRepository.Search(query, limit, offset)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to search users")
+		return nil, response.RepositoryError("failed to search users")
+	}
+
+	summaries := make([]*params.UserSummaryResponse, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, &params.UserSummaryResponse{
+			ID:        user.ID,
+			Name:      user.Name,
+			Email:     user.Email,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+		})
+	}
+
+	return &params.UserSearchResponse{
+		Users:  summaries,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}, nil
+}
@@ -1,12 +1,16 @@
 package usecase
 
 import (
+	"context"
 	"go-digital-wallet/internal/commons/response"
 	"go-digital-wallet/internal/entity"
 	"go-digital-wallet/internal/params"
 	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/pkg/notify"
 	"go-digital-wallet/pkg/token"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,19 +18,43 @@ import (
 type AuthUsecase interface {
 	Register(req *params.RegisterRequest) (*params.AuthResponse, *response.CustomError)
 	Login(req *params.LoginRequest) (*params.AuthResponse, *response.CustomError)
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, req *params.ChangeEmailRequest) *response.CustomError
+	ConfirmEmailChange(ctx context.Context, req *params.ConfirmEmailChangeRequest) *response.CustomError
+}
+
+// AuthUsecaseConfig holds the account settings of the auth usecase.
+type AuthUsecaseConfig struct {
+	// EmailVerificationURL is the page a verification link opens; the token
+	// is added as the "token" query parameter.
+	EmailVerificationURL string
+	// EmailChangeTTL is how long a verification link stays valid. Zero uses
+	// defaultEmailChangeTTL.
+	EmailChangeTTL time.Duration
 }
 
 type AuthUsecaseImpl struct {
 	userRepo   repository.UserRepository
 	logger     *logrus.Logger
 	jwtManager *token.TokenManager
+	notifier   notify.Notifier
+	config     AuthUsecaseConfig
 }
 
-func NewAuthUsecase(userRepo repository.UserRepository, logger *logrus.Logger, jwtManager *token.TokenManager) AuthUsecase {
+// NewAuthUsecase builds the auth usecase. A nil notifier discards
+// verification emails.
+func NewAuthUsecase(userRepo repository.UserRepository, logger *logrus.Logger, jwtManager *token.TokenManager, notifier notify.Notifier, config AuthUsecaseConfig) AuthUsecase {
+	if notifier == nil {
+		notifier = notify.NewNoopNotifier()
+	}
+	if config.EmailChangeTTL <= 0 {
+		config.EmailChangeTTL = defaultEmailChangeTTL
+	}
 	return &AuthUsecaseImpl{
 		userRepo:   userRepo,
 		logger:     logger,
 		jwtManager: jwtManager,
+		notifier:   notifier,
+		config:     config,
 	}
 }
 
@@ -49,6 +77,7 @@ func (s *AuthUsecaseImpl) Register(req *params.RegisterRequest) (*params.AuthRes
 		Name:     req.Name,
 		Email:    req.Email,
 		Password: string(hashedPassword),
+		Role:     entity.RoleUser,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
@@ -57,7 +86,7 @@ func (s *AuthUsecaseImpl) Register(req *params.RegisterRequest) (*params.AuthRes
 	}
 
 	// Generate JWT token
-	token, err := s.jwtManager.GenerateToken(user.ID)
+	token, err := s.jwtManager.GenerateToken(user.ID, user.Role)
 	if err != nil {
 		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to generate token")
 		return nil, response.GeneralError("failed to generate token")
@@ -97,7 +126,7 @@ func (s *AuthUsecaseImpl) Login(req *params.LoginRequest) (*params.AuthResponse,
 	}
 
 	// Generate JWT token
-	token, err := s.jwtManager.GenerateToken(user.ID)
+	token, err := s.jwtManager.GenerateToken(user.ID, user.Role)
 	if err != nil {
 		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to generate token")
 		return nil, response.GeneralError("failed to generate token")
@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultBalanceHistoryBuckets is how many periods a balance history
+	// covers when the request gives no start.
+	defaultBalanceHistoryBuckets = 30
+	// maxBalanceHistoryBuckets bounds the periods of one balance history,
+	// about a year of days.
+	maxBalanceHistoryBuckets = 366
+)
+
+// GetBalanceHistory returns the wallet balance at the end of each period of
+// granularity from from to to, for charting the balance over time. Each
+// period takes the balance snapshot of its last completed transaction;
+// periods without one carry the previous balance forward. Without a start the
+// history covers the last defaultBalanceHistoryBuckets periods up to to,
+// which defaults to now.
+func (u *WalletUsecaseImpl) GetBalanceHistory(ctx context.Context, userID, walletID uuid.UUID, granularity string, from, to *time.Time) (*params.BalanceHistoryResponse, *response.CustomError) {
+	if !insightGranularities[granularity] {
+		return nil, response.BadRequestError("granularity must be one of: day, week, month")
+	}
+
+	end := time.Now()
+	if to != nil {
+		end = *to
+	}
+	last := truncateToBucket(end, granularity)
+	first := shiftBucket(last, granularity, 1-defaultBalanceHistoryBuckets)
+	if from != nil {
+		if from.After(end) {
+			return nil, response.BadRequestError("from must not be after to")
+		}
+		first = truncateToBucket(*from, granularity)
+	}
+
+	var bounds []time.Time
+	for b := first; !b.After(last); b = shiftBucket(b, granularity, 1) {
+		if len(bounds) == maxBalanceHistoryBuckets {
+			return nil, response.BadRequestErrorWithAdditionalInfo(
+				map[string]int{"max_buckets": maxBalanceHistoryBuckets},
+				fmt.Sprintf("range spans more than %d %ss; use a coarser granularity", maxBalanceHistoryBuckets, granularity),
+			)
+		}
+		bounds = append(bounds, b)
+	}
+
+	wallet, custErr := u.ownWallet(ctx, userID, walletID)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	// Keyed under the transactions prefix so that writes invalidate it. The
+	// resolved periods are part of the key, so a history ending now moves on
+	// to a new entry once a new period starts.
+	cacheKey := fmt.Sprintf("transactions:%s:balance-history:%s:%s:%s", userID, granularity, first.Format(time.RFC3339), last.Format(time.RFC3339))
+	if val, err := u.transactionCacheGet(ctx, cacheKey); err == nil {
+		var cached params.BalanceHistoryResponse
+		if json.Unmarshal([]byte(val), &cached) == nil {
+			return &cached, nil
+		}
+	}
+
+	// Balance before the first period, carried into it if it saw no
+	// activity. Timestamps are stored with microsecond precision.
+	balance, _, custErr := u.balanceAt(ctx, wallet.ID, first.Add(-time.Microsecond))
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	until := shiftBucket(last, granularity, 1).Add(-time.Microsecond)
+	buckets, err := u.repo.GetBalanceHistory(ctx, wallet.ID, granularity, repository.TransactionFilter{
+		From:            &first,
+		To:              &until,
+		IncludeArchived: u.includesArchived(first),
+	})
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to get balance history")
+		return nil, response.RepositoryError("failed to get balance history")
+	}
+
+	closing := make(map[time.Time]float64, len(buckets))
+	for _, b := range buckets {
+		closing[b.Bucket.UTC()] = b.Balance
+	}
+
+	points := make([]*params.BalanceHistoryPoint, len(bounds))
+	for i, b := range bounds {
+		if v, ok := closing[b]; ok {
+			balance = v
+		}
+		points[i] = &params.BalanceHistoryPoint{Bucket: b, Balance: balance}
+	}
+
+	resp := &params.BalanceHistoryResponse{
+		WalletID:    wallet.ID,
+		Currency:    wallet.Currency,
+		Granularity: granularity,
+		From:        first,
+		To:          last,
+		Buckets:     points,
+	}
+
+	if data, err := json.Marshal(resp); err == nil {
+		if err := u.transactionCacheSet(ctx, cacheKey, data); err != nil {
+			u.logger.WithError(err).Warn("Failed to cache balance history")
+		}
+	}
+
+	return resp, nil
+}
+
+// truncateToBucket returns the start of the period of granularity holding t,
+// in UTC and matching Postgres' date_trunc: weeks start on Monday.
+func truncateToBucket(t time.Time, granularity string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch granularity {
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// shiftBucket moves the period start t by n periods of granularity.
+func shiftBucket(t time.Time, granularity string, n int) time.Time {
+	switch granularity {
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "month":
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-digital-wallet/internal/commons/timing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// The wallet usecase treats a nil Redis client as caching being disabled:
+// reads miss, writes and invalidations are skipped, and every request is
+// served from the database.
+
+func (u *WalletUsecaseImpl) cacheGet(ctx context.Context, key string) (string, error) {
+	if u.cache == nil {
+		return "", redis.Nil
+	}
+	defer timing.Track(ctx, "cache")()
+	return u.cache.Get(ctx, key).Result()
+}
+
+func (u *WalletUsecaseImpl) cacheSet(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if u.cache == nil {
+		return nil
+	}
+	defer timing.Track(ctx, "cache")()
+	return u.cache.Set(ctx, key, value, ttl).Err()
+}
+
+// transactionCacheTTL is how long a cached history, insights or activity page
+// is served.
+const transactionCacheTTL = 5 * time.Minute
+
+// transactionCountCacheTTL is how long the total of a history is served. It
+// only changes with a write, which invalidates it, so it outlives the pages.
+const transactionCountCacheTTL = 30 * time.Minute
+
+// transactionCacheGet and transactionCacheSet cache the pages built from the
+// user's transactions, unless the history cache flag is off.
+func (u *WalletUsecaseImpl) transactionCacheGet(ctx context.Context, key string) (string, error) {
+	if !u.config.Flags.HistoryCacheEnabled() {
+		return "", redis.Nil
+	}
+	return u.cacheGet(ctx, key)
+}
+
+func (u *WalletUsecaseImpl) transactionCacheSet(ctx context.Context, key string, value interface{}) error {
+	if !u.config.Flags.HistoryCacheEnabled() {
+		return nil
+	}
+	return u.cacheSet(ctx, key, value, transactionCacheTTL)
+}
+
+// cacheInvalidationBackoff is how long a failed invalidation waits before its
+// first retry. The wait doubles with every further retry.
+const cacheInvalidationBackoff = 50 * time.Millisecond
+
+// invalidateTransactionCache drops every cached history page and aggregate
+// of the user after their transactions changed. A failure is retried up to
+// CacheInvalidationRetries times; if it still fails the user is queued for
+// RetryCacheInvalidations, so the cache is cleared eventually rather than
+// served stale until it expires.
+func (u *WalletUsecaseImpl) invalidateTransactionCache(ctx context.Context, userID uuid.UUID) {
+	if u.cache == nil {
+		return
+	}
+	defer timing.Track(ctx, "cache")()
+
+	err := u.deleteTransactionCache(ctx, userID)
+	backoff := cacheInvalidationBackoff
+retry:
+	for attempt := 0; err != nil && attempt < u.config.CacheInvalidationRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			break retry
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		err = u.deleteTransactionCache(ctx, userID)
+	}
+	if err != nil {
+		u.logger.WithError(err).WithFields(logrus.Fields{
+			"event":   eventCacheInvalidationFailed,
+			"user_id": userID,
+		}).Warn("Failed to invalidate transaction cache")
+		u.queueCacheInvalidation(userID)
+	}
+}
+
+func (u *WalletUsecaseImpl) deleteTransactionCache(ctx context.Context, userID uuid.UUID) error {
+	cachePattern := fmt.Sprintf("transactions:%s:*", userID.String())
+	keys, err := u.cache.Keys(ctx, cachePattern).Result()
+	if err != nil {
+		return fmt.Errorf("fetch transaction cache keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return u.cache.Del(ctx, keys...).Err()
+}
+
+// queueCacheInvalidation remembers a user whose cache could not be
+// invalidated. The queue is kept in memory, as the failure is usually Redis
+// itself being unreachable.
+func (u *WalletUsecaseImpl) queueCacheInvalidation(userID uuid.UUID) {
+	if !u.config.QueueFailedInvalidations {
+		return
+	}
+	u.pendingMu.Lock()
+	defer u.pendingMu.Unlock()
+	if u.pendingInvalidations == nil {
+		u.pendingInvalidations = make(map[uuid.UUID]bool)
+	}
+	u.pendingInvalidations[userID] = true
+}
+
+// RetryCacheInvalidations invalidates the caches of the users queued after
+// their invalidation failed, and returns how many are still queued.
+func (u *WalletUsecaseImpl) RetryCacheInvalidations(ctx context.Context) int {
+	u.pendingMu.Lock()
+	pending := u.pendingInvalidations
+	u.pendingInvalidations = nil
+	u.pendingMu.Unlock()
+
+	if u.cache == nil {
+		return 0
+	}
+	for userID := range pending {
+		if err := u.deleteTransactionCache(ctx, userID); err != nil {
+			u.logger.WithError(err).WithField("user_id", userID).Warn("Retry of transaction cache invalidation failed")
+			u.queueCacheInvalidation(userID)
+		}
+	}
+
+	u.pendingMu.Lock()
+	defer u.pendingMu.Unlock()
+	return len(u.pendingInvalidations)
+}
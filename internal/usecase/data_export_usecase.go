@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/pkg/displayid"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// exportPageSize is how many transactions are read per query while streaming
+// a data export.
+const exportPageSize = 500
+
+type DataExportUsecase interface {
+	ExportUserData(ctx context.Context, userID uuid.UUID) (*params.DataExportFile, *response.CustomError)
+}
+
+type DataExportUsecaseImpl struct {
+	userRepository   repository.UserRepository
+	walletRepository repository.WalletRepository
+	logger           *logrus.Logger
+	displayIDs       displayid.Format
+}
+
+func NewDataExportUsecase(userRepository repository.UserRepository, walletRepository repository.WalletRepository, logger *logrus.Logger, displayIDPrefix string) DataExportUsecase {
+	return &DataExportUsecaseImpl{
+		userRepository:   userRepository,
+		walletRepository: walletRepository,
+		logger:           logger,
+		displayIDs:       displayid.New(displayIDPrefix),
+	}
+}
+
+// ExportUserData gathers everything stored about the user: profile, wallets
+// and the full transaction history including archived transactions. The
+// profile and wallets are loaded up front so that failures can still be
+// reported; transactions are streamed page by page when the file is written.
+func (u *DataExportUsecaseImpl) ExportUserData(ctx context.Context, userID uuid.UUID) (*params.DataExportFile, *response.CustomError) {
+	user, err := u.userRepository.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("user not found")
+		}
+		return nil, response.RepositoryError("failed to get user")
+	}
+
+	wallets, err := u.walletRepository.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, response.RepositoryError("failed to get wallets")
+	}
+
+	now := time.Now()
+	header := params.DataExportHeader{
+		ExportedAt: now,
+		User: params.DataExportUser{
+			ID:        user.ID,
+			Name:      user.Name,
+			Email:     user.Email,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+		Wallets: make([]*params.WalletResponse, len(wallets)),
+	}
+	for i, wallet := range wallets {
+		header.Wallets[i] = &params.WalletResponse{
+			ID:        wallet.ID,
+			UserID:    wallet.UserID,
+			Balance:   wallet.Balance,
+			Currency:  wallet.Currency,
+			Type:      walletType(wallet),
+			CreatedAt: wallet.CreatedAt,
+			UpdatedAt: wallet.UpdatedAt,
+		}
+	}
+
+	return &params.DataExportFile{
+		Filename:    fmt.Sprintf("data-export-%s-%s.json", userID, now.Format("20060102")),
+		ContentType: "application/json; charset=utf-8",
+		Write: func(w io.Writer) error {
+			return u.writeExport(ctx, w, userID, &header)
+		},
+	}, nil
+}
+
+// writeExport writes the header fields followed by a transactions array,
+// oldest first, without holding the whole history in memory.
+func (u *DataExportUsecaseImpl) writeExport(ctx context.Context, w io.Writer, userID uuid.UUID, header *params.DataExportHeader) error {
+	head, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// Reopen the header object to append the transactions array to it.
+	if _, err := w.Write(head[:len(head)-1]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"transactions":[`); err != nil {
+		return err
+	}
+
+	filter := repository.TransactionFilter{IncludeArchived: true, Ascending: true}
+	written := 0
+	for offset := 0; ; offset += exportPageSize {
+		transactions, err := u.walletRepository.GetTransactionsByUserID(ctx, userID, exportPageSize, offset, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range transactions {
+			item, err := json.Marshal(&params.ActivityTransactionResponse{
+				TransactionResponse: params.TransactionResponse{
+					ID:          t.ID,
+					DisplayID:   u.displayIDs.Encode(t.ID),
+					Type:        t.Type,
+					Amount:      t.Amount,
+					Description: &t.Description,
+					Status:      t.Status,
+					CreatedAt:   t.CreatedAt,
+					UpdatedAt:   t.UpdatedAt,
+				},
+				WalletID: t.WalletID,
+				Currency: t.Currency,
+			})
+			if err != nil {
+				return err
+			}
+			if written > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if _, err := w.Write(item); err != nil {
+				return err
+			}
+			written++
+		}
+
+		if len(transactions) < exportPageSize {
+			break
+		}
+	}
+
+	if _, err := io.WriteString(w, "]}"); err != nil {
+		return err
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"user_id":      userID,
+		"transactions": written,
+	}).Info("User data exported")
+
+	return nil
+}
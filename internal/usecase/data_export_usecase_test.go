@@ -0,0 +1,100 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/internal/usecase"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// stubUserRepository serves a fixed set of users, or fails every lookup with
+// err when it is set.
+type stubUserRepository struct {
+	repository.UserRepository
+	users map[uuid.UUID]*entity.User
+	err   error
+}
+
+func (s *stubUserRepository) GetByID(id uuid.UUID) (*entity.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if user, ok := s.users[id]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func TestExportUserData_StreamsAllPages(t *testing.T) {
+	mockRepo := new(repository.MockWalletRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	user := &entity.User{ID: uuid.New(), Name: "Jane", Email: "jane@example.com", Role: entity.RoleUser}
+	users := &stubUserRepository{users: map[uuid.UUID]*entity.User{user.ID: user}}
+	uc := usecase.NewDataExportUsecase(users, mockRepo, logger, "")
+
+	walletID := uuid.New()
+	page := make([]*repository.UserTransaction, 500)
+	for i := range page {
+		page[i] = &repository.UserTransaction{ID: uuid.New(), WalletID: walletID, Currency: "IDR", Type: entity.TransactionTypeDeposit, Amount: 1}
+	}
+	mockRepo.On("ListByUserID", mock.Anything, user.ID).Return([]*entity.Wallet{{ID: walletID, UserID: user.ID, Currency: "IDR"}}, nil)
+	mockRepo.On("GetTransactionsByUserID", mock.Anything, user.ID, 500, 0, repository.TransactionFilter{IncludeArchived: true, Ascending: true}).Return(page, nil)
+	mockRepo.On("GetTransactionsByUserID", mock.Anything, user.ID, 500, 500, mock.Anything).Return(page[:2], nil)
+
+	file, custErr := uc.ExportUserData(context.Background(), user.ID)
+	require.Nil(t, custErr)
+
+	var buf bytes.Buffer
+	require.NoError(t, file.Write(&buf))
+
+	var doc struct {
+		User struct {
+			Email string `json:"email"`
+		} `json:"user"`
+		Wallets      []map[string]interface{} `json:"wallets"`
+		Transactions []map[string]interface{} `json:"transactions"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "jane@example.com", doc.User.Email)
+	assert.Len(t, doc.Wallets, 1)
+	assert.Len(t, doc.Transactions, 502)
+	assert.NotContains(t, buf.String(), "password")
+}
+
+func TestExportUserData_UnknownUser(t *testing.T) {
+	mockRepo := new(repository.MockWalletRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewDataExportUsecase(&stubUserRepository{}, mockRepo, logger, "")
+
+	file, custErr := uc.ExportUserData(context.Background(), uuid.New())
+
+	assert.Nil(t, file)
+	assert.Equal(t, "user not found", custErr.Message)
+	mockRepo.AssertNotCalled(t, "ListByUserID", mock.Anything, mock.Anything)
+}
+
+func TestExportUserData_UserLookupFails(t *testing.T) {
+	mockRepo := new(repository.MockWalletRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewDataExportUsecase(&stubUserRepository{err: errors.New("connection refused")}, mockRepo, logger, "")
+
+	file, custErr := uc.ExportUserData(context.Background(), uuid.New())
+
+	assert.Nil(t, file)
+	assert.Equal(t, "failed to get user", custErr.Message)
+	mockRepo.AssertNotCalled(t, "ListByUserID", mock.Anything, mock.Anything)
+}
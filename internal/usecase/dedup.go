@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// dedupGuard holds the duplicate-detection key claimed for one request. A nil
+// guard means no window applies and all methods are no-ops.
+type dedupGuard struct {
+	u        *WalletUsecaseImpl
+	cacheKey string
+	done     bool
+}
+
+// claimDedupWindow rejects a request identical to one the user sent within
+// the configured dedup window, which catches double-taps from clients that
+// send no Idempotency-Key. Requests with a key are left to the idempotency
+// check, which also gives clients a way to repeat a transaction on purpose.
+//
+// Identical transactions can be legitimate, so the window is off unless
+// configured, and it fails open: without Redis, or when Redis errors, the
+// request goes through.
+func (u *WalletUsecaseImpl) claimDedupWindow(ctx context.Context, userID uuid.UUID, operation, idemKey string, req interface{}) (*dedupGuard, *response.CustomError) {
+	if u.config.DedupWindow <= 0 || idemKey != "" || u.cache == nil {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, response.GeneralError("failed to hash request")
+	}
+	sum := sha256.Sum256(body)
+	cacheKey := fmt.Sprintf("dedup:%s:%s:%s", userID, operation, hex.EncodeToString(sum[:]))
+
+	claimed, err := u.cache.SetNX(ctx, cacheKey, 1, u.config.DedupWindow).Result()
+	if err != nil {
+		u.logger.WithError(err).Warn("Failed to check for duplicate request")
+		return nil, nil
+	}
+	if !claimed {
+		u.logger.WithFields(logrus.Fields{
+			"event":     eventDuplicateRejected,
+			"user_id":   userID,
+			"operation": operation,
+		}).Warn("Rejected suspected duplicate request")
+		return nil, response.ConflictErrorWithAdditionalInfo(
+			map[string]interface{}{"reason": "suspected_duplicate", "window_seconds": int(u.config.DedupWindow.Seconds())},
+			"an identical request was received moments ago; send an Idempotency-Key to repeat it on purpose",
+		)
+	}
+
+	return &dedupGuard{u: u, cacheKey: cacheKey}, nil
+}
+
+// complete keeps the key for the rest of the window once the operation went
+// through.
+func (g *dedupGuard) complete() {
+	if g == nil {
+		return
+	}
+	g.done = true
+}
+
+// release frees the key when the operation failed, so that a corrected retry
+// is not taken for a duplicate.
+func (g *dedupGuard) release(ctx context.Context) {
+	if g == nil || g.done {
+		return
+	}
+	if err := g.u.cache.Del(ctx, g.cacheKey).Err(); err != nil {
+		g.u.logger.WithError(err).Warn("Failed to release duplicate request key")
+	}
+}
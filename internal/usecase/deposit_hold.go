@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// depositClearedAt is when a deposit made at now can be withdrawn, or nil
+// when deposits are available at once.
+func (u *WalletUsecaseImpl) depositClearedAt(now time.Time) *time.Time {
+	if u.config.DepositHold <= 0 {
+		return nil
+	}
+	clearedAt := now.Add(u.config.DepositHold)
+	return &clearedAt
+}
+
+// loadClearingBalance sets the part of the locked wallet's balance that came
+// from deposits still on hold, so AvailableBalance leaves it out. Without a
+// hold period it loads nothing, which also releases deposits still on hold
+// when the period is turned off.
+func (u *WalletUsecaseImpl) loadClearingBalance(ctx context.Context, tx *gorm.DB, txRepo repository.WalletRepository, wallet *entity.Wallet) *response.CustomError {
+	if u.config.DepositHold <= 0 {
+		return nil
+	}
+	clearing, err := txRepo.SumClearingDeposits(ctx, tx, wallet.ID, time.Now())
+	if err != nil {
+		return response.RepositoryError("failed to get clearing deposits")
+	}
+	wallet.ClearingBalance = clearing
+	return nil
+}
@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"bytes"
+	"go-digital-wallet/internal/entity"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultDescriptionTemplate renders e.g. "Deposit of IDR 100,000.00 on
+// 2024-01-01".
+const DefaultDescriptionTemplate = "{{.Operation}} of {{.Currency}} {{.Amount}} on {{.Date}}"
+
+// maxDescriptionLength matches the limit on descriptions sent by clients.
+const maxDescriptionLength = 500
+
+// descriptionData is what a description template can refer to.
+type descriptionData struct {
+	// Operation is "Deposit" or "Withdrawal".
+	Operation string
+	// Amount has two decimal places and thousands separators, e.g.
+	// 100,000.00.
+	Amount   string
+	Currency string
+	// Date is the UTC date as YYYY-MM-DD.
+	Date string
+}
+
+// ParseDescriptionTemplate parses a text/template for the descriptions of
+// deposits and withdrawals sent without one; empty uses
+// DefaultDescriptionTemplate. The template is tried once, so that a field
+// that does not exist fails at startup rather than on every transaction.
+func ParseDescriptionTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultDescriptionTemplate
+	}
+	tmpl, err := template.New("description").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := descriptionData{Operation: "Deposit", Amount: "100,000.00", Currency: "IDR", Date: "2024-01-01"}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// transactionDescription returns description, or when it is empty and a
+// template is configured, one generated from the operation. A template
+// failing leaves the description empty rather than failing the operation.
+func (u *WalletUsecaseImpl) transactionDescription(description string, txType entity.TransactionType, amount float64, currency string, at time.Time) string {
+	if description != "" || u.config.DescriptionTemplate == nil {
+		return description
+	}
+
+	operation := "Deposit"
+	if txType == entity.TransactionTypeWithdraw {
+		operation = "Withdrawal"
+	}
+	var buf bytes.Buffer
+	err := u.config.DescriptionTemplate.Execute(&buf, descriptionData{
+		Operation: operation,
+		Amount:    groupThousands(formatAmount(amount)),
+		Currency:  currency,
+		Date:      at.UTC().Format("2006-01-02"),
+	})
+	if err != nil {
+		u.logger.WithError(err).WithFields(logrus.Fields{
+			"type": txType,
+		}).Warn("Failed to generate transaction description")
+		return ""
+	}
+
+	generated := strings.TrimSpace(buf.String())
+	if r := []rune(generated); len(r) > maxDescriptionLength {
+		generated = string(r[:maxDescriptionLength])
+	}
+	return generated
+}
+
+// groupThousands inserts commas between the thousands of a formatted amount,
+// e.g. 100000.00 becomes 100,000.00.
+func groupThousands(amount string) string {
+	sign := ""
+	if strings.HasPrefix(amount, "-") {
+		sign, amount = "-", amount[1:]
+	}
+	whole, fraction := amount, ""
+	if i := strings.IndexByte(amount, '.'); i >= 0 {
+		whole, fraction = amount[:i], amount[i:]
+	}
+
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String() + fraction
+}
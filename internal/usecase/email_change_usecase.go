@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/pkg/notify"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// defaultEmailChangeTTL is how long a verification link stays valid when
+// none is configured.
+const defaultEmailChangeTTL = 24 * time.Hour
+
+// RequestEmailChange starts moving the user to a new email. The email on
+// record stays in use until the link sent to the new address is confirmed,
+// so a mistyped address cannot lock the user out.
+func (s *AuthUsecaseImpl) RequestEmailChange(ctx context.Context, userID uuid.UUID, req *params.ChangeEmailRequest) *response.CustomError {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.NotFoundError("user not found")
+		}
+		return response.RepositoryError("failed to get user")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		s.logger.WithField("user_id", userID).Warn("Email change attempt with invalid password")
+		return response.BadRequestError("invalid password")
+	}
+	if strings.EqualFold(req.Email, user.Email) {
+		return response.BadRequestError("new email is the same as the current email")
+	}
+	if _, err := s.userRepo.GetByEmail(req.Email); err == nil {
+		return response.ConflictError("email is already in use")
+	}
+
+	token, err := newVerificationToken()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to generate verification token")
+		return response.GeneralError("failed to generate verification token")
+	}
+
+	now := time.Now()
+	change := &entity.EmailChange{
+		UserID:    userID,
+		NewEmail:  req.Email,
+		TokenHash: hashVerificationToken(token),
+		ExpiresAt: now.Add(s.config.EmailChangeTTL),
+		CreatedAt: now,
+	}
+	if err := s.userRepo.SaveEmailChange(change); err != nil {
+		return response.RepositoryError("failed to save email change")
+	}
+
+	err = s.notifier.Notify(ctx, notify.Message{
+		UserID:  userID,
+		Channel: notify.ChannelEmail,
+		To:      req.Email,
+		Subject: "Confirm your new email",
+		Body:    fmt.Sprintf("Open %s to confirm this email for your wallet account. The link expires at %s.", s.verificationLink(token), change.ExpiresAt.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Error("Failed to send email verification")
+		return response.GeneralError("failed to send verification email")
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"event":   eventEmailChangeRequested,
+		"user_id": userID,
+	}).Info("Email change requested")
+
+	return nil
+}
+
+// ConfirmEmailChange applies the email change the verification token was
+// issued for.
+func (s *AuthUsecaseImpl) ConfirmEmailChange(ctx context.Context, req *params.ConfirmEmailChangeRequest) *response.CustomError {
+	change, err := s.userRepo.GetEmailChangeByTokenHash(hashVerificationToken(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.BadRequestError("invalid or expired verification link")
+		}
+		return response.RepositoryError("failed to get email change")
+	}
+	if change.Expired(time.Now()) {
+		return response.BadRequestError("invalid or expired verification link")
+	}
+
+	if err := s.userRepo.ApplyEmailChange(change); err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return response.ConflictError("email is already in use")
+		}
+		return response.RepositoryError("failed to change email")
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"event":   eventEmailChangeCompleted,
+		"user_id": change.UserID,
+	}).Info("Email changed")
+
+	return nil
+}
+
+func (s *AuthUsecaseImpl) verificationLink(token string) string {
+	link, err := url.Parse(s.config.EmailVerificationURL)
+	if err != nil {
+		return s.config.EmailVerificationURL + "?token=" + url.QueryEscape(token)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// newVerificationToken returns a random URL-safe token. Only its hash is
+// stored, so a leaked table cannot be used to confirm changes.
+func newVerificationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,168 @@
+package usecase_test
+
+import (
+	"context"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/internal/usecase"
+	"go-digital-wallet/pkg/notify"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// emailChangeUserRepository keeps users and pending email changes in memory.
+type emailChangeUserRepository struct {
+	repository.UserRepository
+	users   map[uuid.UUID]*entity.User
+	changes map[string]*entity.EmailChange
+}
+
+func (r *emailChangeUserRepository) GetByID(id uuid.UUID) (*entity.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *emailChangeUserRepository) GetByEmail(email string) (*entity.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *emailChangeUserRepository) SaveEmailChange(change *entity.EmailChange) error {
+	for hash, c := range r.changes {
+		if c.UserID == change.UserID {
+			delete(r.changes, hash)
+		}
+	}
+	r.changes[change.TokenHash] = change
+	return nil
+}
+
+func (r *emailChangeUserRepository) GetEmailChangeByTokenHash(tokenHash string) (*entity.EmailChange, error) {
+	if change, ok := r.changes[tokenHash]; ok {
+		return change, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *emailChangeUserRepository) ApplyEmailChange(change *entity.EmailChange) error {
+	if _, err := r.GetByEmail(change.NewEmail); err == nil {
+		return repository.ErrEmailTaken
+	}
+	r.users[change.UserID].Email = change.NewEmail
+	delete(r.changes, change.TokenHash)
+	return nil
+}
+
+// recordingNotifier keeps every message it is asked to send.
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func setupEmailChangeTest(t *testing.T, ttl time.Duration) (*emailChangeUserRepository, *recordingNotifier, usecase.AuthUsecase, *entity.User) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &entity.User{ID: uuid.New(), Name: "Jane", Email: "jane@example.com", Password: string(hashed), Role: entity.RoleUser}
+
+	repo := &emailChangeUserRepository{
+		users:   map[uuid.UUID]*entity.User{user.ID: user},
+		changes: map[string]*entity.EmailChange{},
+	}
+	notifier := &recordingNotifier{}
+	uc := usecase.NewAuthUsecase(repo, logger, nil, notifier, usecase.AuthUsecaseConfig{
+		EmailVerificationURL: "https://wallet.example.com/verify-email",
+		EmailChangeTTL:       ttl,
+	})
+	return repo, notifier, uc, user
+}
+
+// sentToken extracts the verification token from the link in msg.
+func sentToken(t *testing.T, msg notify.Message) string {
+	t.Helper()
+	link := regexp.MustCompile(`https://\S+`).FindString(msg.Body)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+func TestEmailChange_AppliesAfterVerification(t *testing.T) {
+	repo, notifier, uc, user := setupEmailChangeTest(t, time.Hour)
+	ctx := context.Background()
+
+	custErr := uc.RequestEmailChange(ctx, user.ID, &params.ChangeEmailRequest{Email: "jane@new.example.com", Password: "secret"})
+	require.Nil(t, custErr)
+	assert.Equal(t, "jane@example.com", repo.users[user.ID].Email)
+
+	require.Len(t, notifier.messages, 1)
+	assert.Equal(t, notify.ChannelEmail, notifier.messages[0].Channel)
+	assert.Equal(t, "jane@new.example.com", notifier.messages[0].To)
+	token := sentToken(t, notifier.messages[0])
+	require.NotEmpty(t, token)
+
+	custErr = uc.ConfirmEmailChange(ctx, &params.ConfirmEmailChangeRequest{Token: token})
+	require.Nil(t, custErr)
+	assert.Equal(t, "jane@new.example.com", repo.users[user.ID].Email)
+
+	// The link works only once.
+	custErr = uc.ConfirmEmailChange(ctx, &params.ConfirmEmailChangeRequest{Token: token})
+	require.NotNil(t, custErr)
+	assert.Equal(t, http.StatusBadRequest, custErr.StatusCode)
+}
+
+func TestRequestEmailChange_EmailTaken(t *testing.T) {
+	repo, notifier, uc, user := setupEmailChangeTest(t, time.Hour)
+	other := &entity.User{ID: uuid.New(), Email: "john@example.com"}
+	repo.users[other.ID] = other
+
+	custErr := uc.RequestEmailChange(context.Background(), user.ID, &params.ChangeEmailRequest{Email: "john@example.com", Password: "secret"})
+	require.NotNil(t, custErr)
+	assert.Equal(t, http.StatusConflict, custErr.StatusCode)
+	assert.Empty(t, notifier.messages)
+}
+
+func TestRequestEmailChange_WrongPassword(t *testing.T) {
+	_, notifier, uc, user := setupEmailChangeTest(t, time.Hour)
+
+	custErr := uc.RequestEmailChange(context.Background(), user.ID, &params.ChangeEmailRequest{Email: "jane@new.example.com", Password: "wrong"})
+	require.NotNil(t, custErr)
+	assert.Equal(t, http.StatusBadRequest, custErr.StatusCode)
+	assert.Empty(t, notifier.messages)
+}
+
+func TestConfirmEmailChange_Expired(t *testing.T) {
+	repo, notifier, uc, user := setupEmailChangeTest(t, time.Nanosecond)
+	ctx := context.Background()
+
+	require.Nil(t, uc.RequestEmailChange(ctx, user.ID, &params.ChangeEmailRequest{Email: "jane@new.example.com", Password: "secret"}))
+	time.Sleep(time.Millisecond)
+
+	custErr := uc.ConfirmEmailChange(ctx, &params.ConfirmEmailChangeRequest{Token: sentToken(t, notifier.messages[0])})
+	require.NotNil(t, custErr)
+	assert.Equal(t, http.StatusBadRequest, custErr.StatusCode)
+	assert.Equal(t, "jane@example.com", repo.users[user.ID].Email)
+}
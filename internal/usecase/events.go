@@ -0,0 +1,54 @@
+package usecase
+
+// Event names logged in the "event" field of business outcomes. Log
+// aggregation groups by these, so rename one only together with the
+// dashboards built on it; the message text next to it is free to change.
+const (
+	eventWalletCreated      = "wallet.created"
+	eventWalletCreateFailed = "wallet.create.failed"
+
+	eventDepositCompleted = "wallet.deposit.completed"
+	eventDepositFailed    = "wallet.deposit.failed"
+
+	eventWithdrawCompleted           = "wallet.withdraw.completed"
+	eventWithdrawFailed              = "wallet.withdraw.failed"
+	eventWithdrawInsufficientBalance = "wallet.withdraw.insufficient_balance"
+	eventWithdrawInitiated           = "wallet.withdraw.initiated"
+
+	eventTransferCompleted           = "wallet.transfer.completed"
+	eventTransferFailed              = "wallet.transfer.failed"
+	eventTransferInsufficientBalance = "wallet.transfer.insufficient_balance"
+
+	eventTransferRequestCreated             = "wallet.transfer_request.created"
+	eventTransferRequestApproved            = "wallet.transfer_request.approved"
+	eventTransferRequestRejected            = "wallet.transfer_request.rejected"
+	eventTransferRequestExpired             = "wallet.transfer_request.expired"
+	eventTransferRequestFailed              = "wallet.transfer_request.failed"
+	eventTransferRequestInsufficientBalance = "wallet.transfer_request.insufficient_balance"
+
+	eventAlertThresholdFailed = "wallet.alert_threshold.failed"
+
+	eventSoftLockSet     = "wallet.soft_lock.set"
+	eventSoftLockCleared = "wallet.soft_lock.cleared"
+	eventSoftLockFailed  = "wallet.soft_lock.failed"
+
+	eventPayoutDestinationCreated  = "wallet.payout_destination.created"
+	eventPayoutDestinationVerified = "wallet.payout_destination.verified"
+
+	eventInterestAccrued   = "wallet.interest.accrued"
+	eventInterestFailed    = "wallet.interest.failed"
+	eventInterestCompleted = "wallet.interest.completed"
+
+	eventTransactionsArchived = "wallet.transactions.archived"
+	eventTransactionsImported = "wallet.transactions.imported"
+
+	eventStatementVerificationFailed = "wallet.statement.verification_failed"
+
+	eventCacheInvalidationFailed = "wallet.cache.invalidation_failed"
+
+	eventDuplicateRejected   = "wallet.request.duplicate_rejected"
+	eventOperationInProgress = "wallet.request.operation_in_progress"
+
+	eventEmailChangeRequested = "user.email_change.requested"
+	eventEmailChangeCompleted = "user.email_change.completed"
+)
@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/pkg/fxrate"
+	"go-digital-wallet/pkg/money"
+	"math/big"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fxQuote is the conversion applied to a transfer between currencies.
+type fxQuote struct {
+	Rate float64
+	// Converted is the amount credited, in the recipient's currency,
+	// rounded to the cent.
+	Converted float64
+}
+
+// quoteTransfer converts amount from the sender's currency into the
+// recipient's. It returns nil for wallets in the same currency, and rejects
+// the transfer when no rate provider is configured or it has no rate for the
+// pair.
+func (u *WalletUsecaseImpl) quoteTransfer(ctx context.Context, from, to *entity.Wallet, amount float64) (*fxQuote, *response.CustomError) {
+	if from.Currency == to.Currency {
+		return nil, nil
+	}
+	if u.config.RateProvider == nil {
+		return nil, response.BadRequestError("transfers between different currencies are not supported")
+	}
+
+	rate, err := u.config.RateProvider.Rate(ctx, from.Currency, to.Currency)
+	if err != nil {
+		if errors.Is(err, fxrate.ErrNoRate) {
+			return nil, response.UnprocessableEntityError(fmt.Sprintf("no exchange rate available from %s to %s", from.Currency, to.Currency))
+		}
+		u.logger.WithError(err).WithFields(logrus.Fields{
+			"from_currency": from.Currency,
+			"to_currency":   to.Currency,
+		}).Error("Failed to get exchange rate")
+		return nil, response.ServiceUnavailableError("exchange rates are unavailable, please retry later")
+	}
+
+	converted := u.config.Rounding.Round(new(big.Rat).Mul(money.Decimal(amount), money.Decimal(rate)), 2)
+	if converted <= 0 {
+		return nil, response.BadRequestError(fmt.Sprintf("amount is too small to convert to %s", to.Currency))
+	}
+
+	return &fxQuote{Rate: rate, Converted: converted}, nil
+}
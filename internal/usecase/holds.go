@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	holdTypeTransferRequest = "transfer_request"
+	holdTypeDeposit         = "deposit"
+)
+
+// ListHolds lists what makes the user's balance exceed the available balance:
+// the amounts held for pending transfer requests and, with a hold period, the
+// deposits still clearing, newest first.
+func (u *WalletUsecaseImpl) ListHolds(ctx context.Context, userID uuid.UUID) (*params.HoldsResponse, *response.CustomError) {
+	wallet, err := u.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		u.logger.WithError(err).WithField("user_id", userID).Error("Failed to get wallet")
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+
+	requests, err := u.repo.ListPendingTransferRequests(ctx, wallet.ID)
+	if err != nil {
+		return nil, response.RepositoryError("failed to get transfer requests")
+	}
+
+	holds := make([]*params.HoldResponse, 0, len(requests))
+	var total float64
+	for _, request := range requests {
+		holds = append(holds, &params.HoldResponse{
+			ID:        request.ID,
+			Type:      holdTypeTransferRequest,
+			Amount:    request.Amount,
+			CreatedAt: request.CreatedAt,
+			ExpiresAt: request.ExpiresAt,
+		})
+		total += request.Amount
+	}
+
+	// Without a hold period deposits are not held, whatever their cleared_at
+	// says; see loadClearingBalance.
+	if u.config.DepositHold > 0 {
+		deposits, err := u.repo.ListClearingDeposits(ctx, wallet.ID, time.Now())
+		if err != nil {
+			return nil, response.RepositoryError("failed to get clearing deposits")
+		}
+		for _, deposit := range deposits {
+			holds = append(holds, &params.HoldResponse{
+				ID:        deposit.ID,
+				Type:      holdTypeDeposit,
+				Amount:    deposit.Amount,
+				CreatedAt: deposit.CreatedAt,
+				ExpiresAt: *deposit.ClearedAt,
+			})
+			total += deposit.Amount
+		}
+	}
+
+	sort.SliceStable(holds, func(i, j int) bool {
+		return holds[i].CreatedAt.After(holds[j].CreatedAt)
+	})
+
+	// Amounts are in cents; rounding drops the error of summing them as
+	// floats.
+	total = math.Round(total*100) / 100
+	return &params.HoldsResponse{
+		WalletID:         wallet.ID,
+		Holds:            holds,
+		TotalHeld:        total,
+		Balance:          wallet.Balance,
+		AvailableBalance: math.Round((wallet.Balance-total)*100) / 100,
+		Currency:         wallet.Currency,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/pkg/money"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	errInterestAlreadyAccrued = errors.New("interest already accrued for this day")
+	errNoInterestDue          = errors.New("no interest due")
+)
+
+type InterestUsecase interface {
+	AccrueDailyInterest(ctx context.Context, day time.Time) (int, error)
+}
+
+type InterestUsecaseImpl struct {
+	repo        repository.WalletRepository
+	logger      *logrus.Logger
+	cache       *redis.Client
+	defaultRate float64
+	daysInYear  int
+	rounding    money.RoundingMode
+}
+
+// NewInterestUsecase pays defaultRate a year, or a wallet's own rate, spread
+// over daysInYear days, rounding each day's interest to the cent by rounding.
+func NewInterestUsecase(repo repository.WalletRepository, logger *logrus.Logger, cache *redis.Client, defaultRate float64, daysInYear int, rounding money.RoundingMode) InterestUsecase {
+	if daysInYear <= 0 {
+		daysInYear = 365
+	}
+	return &InterestUsecaseImpl{
+		repo:        repo,
+		logger:      logger,
+		cache:       cache,
+		defaultRate: defaultRate,
+		daysInYear:  daysInYear,
+		rounding:    rounding,
+	}
+}
+
+// AccrueDailyInterest pays one day of interest into every interest bearing
+// wallet and returns how many wallets were credited. Running it more than once
+// for the same day is safe: wallets that already have an accrual marker for
+// that day are skipped.
+func (u *InterestUsecaseImpl) AccrueDailyInterest(ctx context.Context, day time.Time) (int, error) {
+	accrualDate := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	wallets, err := u.repo.ListInterestBearingWallets(ctx, u.defaultRate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list interest bearing wallets: %w", err)
+	}
+
+	credited := 0
+	for _, w := range wallets {
+		if err := u.accrueWallet(ctx, w.UserID, accrualDate); err != nil {
+			if errors.Is(err, errInterestAlreadyAccrued) || errors.Is(err, errNoInterestDue) {
+				continue
+			}
+			u.logger.WithError(err).WithFields(logrus.Fields{
+				"event":        eventInterestFailed,
+				"wallet_id":    w.ID,
+				"accrual_date": accrualDate.Format("2006-01-02"),
+			}).Error("Failed to accrue interest")
+			continue
+		}
+		credited++
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":        eventInterestCompleted,
+		"accrual_date": accrualDate.Format("2006-01-02"),
+		"wallets":      len(wallets),
+		"credited":     credited,
+	}).Info("Daily interest accrual completed")
+
+	return credited, nil
+}
+
+func (u *InterestUsecaseImpl) accrueWallet(ctx context.Context, userID uuid.UUID, accrualDate time.Time) error {
+	tx := u.repo.BeginTx(ctx)
+	if tx.Error != nil {
+		return fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+	txRepo := u.repo.WithTx(tx)
+	defer tx.Rollback()
+
+	// Re-read the wallet under lock so the interest is computed from the
+	// balance that is actually being credited.
+	wallet, err := txRepo.GetByUserIDForUpdate(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get wallet for update: %w", err)
+	}
+
+	rate := u.defaultRate
+	if wallet.InterestRate != nil {
+		rate = *wallet.InterestRate
+	}
+
+	daily := new(big.Rat).Mul(money.Decimal(wallet.Balance), money.Decimal(rate))
+	daily.Quo(daily, big.NewRat(int64(u.daysInYear), 1))
+	interest := u.rounding.Round(daily, 2)
+	if interest <= 0 {
+		return errNoInterestDue
+	}
+
+	newBalance := wallet.Balance + interest
+	newVersion := wallet.Version + 1
+
+	transaction := &entity.Transaction{
+		ID:          uuid.New(),
+		WalletID:    wallet.ID,
+		Type:        entity.TransactionTypeInterest,
+		Amount:      interest,
+		Status:      entity.TransactionStatusCompleted,
+		Description: fmt.Sprintf("Interest for %s", accrualDate.Format("2006-01-02")),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+
+		BalanceAfter: &newBalance,
+	}
+
+	if err := txRepo.CreateTransaction(ctx, tx, transaction); err != nil {
+		return err
+	}
+
+	created, err := txRepo.CreateInterestAccrual(ctx, tx, &entity.InterestAccrual{
+		WalletID:      wallet.ID,
+		AccrualDate:   accrualDate,
+		TransactionID: transaction.ID,
+		Amount:        interest,
+	})
+	if err != nil {
+		return err
+	}
+	if !created {
+		return errInterestAlreadyAccrued
+	}
+
+	if err := txRepo.UpdateBalance(ctx, tx, wallet.ID, newBalance, newVersion); err != nil {
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if u.cache != nil {
+		cachePattern := fmt.Sprintf("transactions:%s:*", userID.String())
+		if keys, err := u.cache.Keys(ctx, cachePattern).Result(); err == nil && len(keys) > 0 {
+			if err := u.cache.Del(ctx, keys...).Err(); err != nil {
+				u.logger.WithError(err).Warn("Failed to invalidate transaction cache")
+			}
+		}
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":          eventInterestAccrued,
+		"user_id":        userID,
+		"transaction_id": transaction.ID,
+		"amount":         interest,
+		"new_balance":    newBalance,
+	}).Info("Interest accrued successfully")
+
+	return nil
+}
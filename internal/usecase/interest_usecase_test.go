@@ -0,0 +1,67 @@
+package usecase_test
+
+import (
+	"context"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/usecase"
+	"go-digital-wallet/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccrueDailyInterest_Success(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewInterestUsecase(mockRepo, logger, rdb, 0.1, 365, money.RoundHalfUp)
+
+	userID, walletID := uuid.New(), uuid.New()
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 365000.0, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("ListInterestBearingWallets", mock.Anything, 0.1).Return([]*entity.Wallet{mockWallet}, nil)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("CreateInterestAccrual", mock.Anything, realTx, mock.AnythingOfType("*entity.InterestAccrual")).Return(true, nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 365100.0, 2).Return(nil)
+
+	credited, err := uc.AccrueDailyInterest(context.Background(), time.Now())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, credited)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAccrueDailyInterest_AlreadyAccrued(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewInterestUsecase(mockRepo, logger, rdb, 0.1, 365, money.RoundHalfUp)
+
+	userID, walletID := uuid.New(), uuid.New()
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 365000.0, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("ListInterestBearingWallets", mock.Anything, 0.1).Return([]*entity.Wallet{mockWallet}, nil)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("CreateInterestAccrual", mock.Anything, realTx, mock.AnythingOfType("*entity.InterestAccrual")).Return(false, nil)
+
+	credited, err := uc.AccrueDailyInterest(context.Background(), time.Now())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, credited)
+	mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// maintenanceKey is the Redis flag that switches maintenance mode on at
+// runtime, across all instances.
+const maintenanceKey = "maintenance:enabled"
+
+type MaintenanceUsecase interface {
+	IsEnabled(ctx context.Context) (bool, error)
+	GetStatus(ctx context.Context) (*params.MaintenanceResponse, *response.CustomError)
+	SetEnabled(ctx context.Context, enabled bool) (*params.MaintenanceResponse, *response.CustomError)
+}
+
+type MaintenanceUsecaseImpl struct {
+	cache  *redis.Client
+	logger *logrus.Logger
+	// forced is the static MAINTENANCE_MODE setting; when true the Redis flag
+	// cannot switch maintenance off.
+	forced bool
+}
+
+func NewMaintenanceUsecase(cache *redis.Client, logger *logrus.Logger, forced bool) MaintenanceUsecase {
+	return &MaintenanceUsecaseImpl{
+		cache:  cache,
+		logger: logger,
+		forced: forced,
+	}
+}
+
+func (u *MaintenanceUsecaseImpl) IsEnabled(ctx context.Context) (bool, error) {
+	if u.forced {
+		return true, nil
+	}
+	if u.cache == nil {
+		return false, nil
+	}
+
+	err := u.cache.Get(ctx, maintenanceKey).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (u *MaintenanceUsecaseImpl) GetStatus(ctx context.Context) (*params.MaintenanceResponse, *response.CustomError) {
+	enabled, err := u.IsEnabled(ctx)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to read maintenance flag")
+		return nil, response.GeneralError("failed to read maintenance status")
+	}
+
+	return &params.MaintenanceResponse{Enabled: enabled, Forced: u.forced}, nil
+}
+
+func (u *MaintenanceUsecaseImpl) SetEnabled(ctx context.Context, enabled bool) (*params.MaintenanceResponse, *response.CustomError) {
+	if u.cache == nil {
+		return nil, response.ServiceUnavailableError("maintenance mode can only be toggled at runtime when Redis is available")
+	}
+
+	var err error
+	if enabled {
+		err = u.cache.Set(ctx, maintenanceKey, "1", 0).Err()
+	} else {
+		err = u.cache.Del(ctx, maintenanceKey).Err()
+	}
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to update maintenance flag")
+		return nil, response.GeneralError("failed to update maintenance status")
+	}
+
+	u.logger.WithField("enabled", enabled).Warn("Maintenance mode updated")
+
+	return &params.MaintenanceResponse{Enabled: enabled || u.forced, Forced: u.forced}, nil
+}
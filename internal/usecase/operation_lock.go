@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// releaseOperationLock deletes the lock only while it still holds the token
+// of the request releasing it. A request that outlived the TTL must not free
+// a lock another request has taken since.
+var releaseOperationLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// operationLock is the per-user lock held by one deposit, withdrawal or
+// transfer. A nil lock means none is configured and release is a no-op.
+type operationLock struct {
+	u        *WalletUsecaseImpl
+	cacheKey string
+	token    string
+}
+
+// acquireOperationLock keeps a user to one money movement at a time, on top
+// of the row locks that already keep balances consistent, so clients do not
+// race their own requests. The lock expires after OperationLockTTL in case
+// the holder dies without releasing it.
+//
+// Like the dedup window it is off unless configured and fails open: without
+// Redis, or when Redis errors, the request goes through.
+func (u *WalletUsecaseImpl) acquireOperationLock(ctx context.Context, userID uuid.UUID, operation string) (*operationLock, *response.CustomError) {
+	if u.config.OperationLockTTL <= 0 || u.cache == nil {
+		return nil, nil
+	}
+
+	cacheKey := fmt.Sprintf("operation-lock:%s", userID)
+	token := uuid.NewString()
+	claimed, err := u.cache.SetNX(ctx, cacheKey, token, u.config.OperationLockTTL).Result()
+	if err != nil {
+		u.logger.WithError(err).Warn("Failed to acquire operation lock")
+		return nil, nil
+	}
+	if !claimed {
+		u.logger.WithFields(logrus.Fields{
+			"event":     eventOperationInProgress,
+			"user_id":   userID,
+			"operation": operation,
+		}).Warn("Rejected request while another operation is in progress")
+		return nil, response.ConflictErrorWithAdditionalInfo(
+			map[string]interface{}{"reason": "operation_in_progress"},
+			"another operation is in progress, please try again",
+		)
+	}
+
+	return &operationLock{u: u, cacheKey: cacheKey, token: token}, nil
+}
+
+func (l *operationLock) release(ctx context.Context) {
+	if l == nil {
+		return
+	}
+	if err := releaseOperationLock.Run(ctx, l.u.cache, []string{l.cacheKey}, l.token).Err(); err != nil {
+		l.u.logger.WithError(err).Warn("Failed to release operation lock")
+	}
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type OverviewUsecase interface {
+	GetOverview(ctx context.Context, userID uuid.UUID) (*params.OverviewResponse, *response.CustomError)
+}
+
+type OverviewUsecaseImpl struct {
+	userRepository   repository.UserRepository
+	walletRepository repository.WalletRepository
+	logger           *logrus.Logger
+}
+
+func NewOverviewUsecase(userRepository repository.UserRepository, walletRepository repository.WalletRepository, logger *logrus.Logger) OverviewUsecase {
+	return &OverviewUsecaseImpl{
+		userRepository:   userRepository,
+		walletRepository: walletRepository,
+		logger:           logger,
+	}
+}
+
+// GetOverview returns the user's profile and wallets in one response, for
+// clients starting up after login. It reads the user and then all of their
+// wallets in a single query; a user without a wallet gets an empty list.
+// Deposits still clearing would take a query per wallet, so the spendable
+// balance is left to the balance endpoint.
+func (u *OverviewUsecaseImpl) GetOverview(ctx context.Context, userID uuid.UUID) (*params.OverviewResponse, *response.CustomError) {
+	user, err := u.userRepository.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("user not found")
+		}
+		return nil, response.RepositoryError("failed to get user")
+	}
+
+	wallets, err := u.walletRepository.ListByUserID(ctx, userID)
+	if err != nil {
+		u.logger.WithError(err).WithField("user_id", userID).Error("Failed to list wallets for overview")
+		return nil, response.RepositoryError("failed to get wallets")
+	}
+
+	resp := &params.OverviewResponse{
+		User: params.UserProfileResponse{
+			ID:        user.ID,
+			Name:      user.Name,
+			Email:     user.Email,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+		},
+		Wallets: make([]*params.OverviewWalletResponse, len(wallets)),
+	}
+	for i, wallet := range wallets {
+		resp.Wallets[i] = &params.OverviewWalletResponse{
+			ID:          wallet.ID,
+			Balance:     wallet.Balance,
+			HeldBalance: wallet.HeldBalance,
+			Currency:    wallet.Currency,
+			Type:        walletType(wallet),
+			CreatedAt:   wallet.CreatedAt,
+		}
+	}
+
+	return resp, nil
+}
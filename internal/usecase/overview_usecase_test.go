@@ -0,0 +1,54 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/internal/usecase"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetOverview_ReturnsProfileAndWallets(t *testing.T) {
+	mockRepo := new(repository.MockWalletRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	user := &entity.User{ID: uuid.New(), Name: "Jane", Email: "jane@example.com", Role: entity.RoleUser}
+	uc := usecase.NewOverviewUsecase(&stubUserRepository{users: map[uuid.UUID]*entity.User{user.ID: user}}, mockRepo, logger)
+
+	walletID := uuid.New()
+	mockRepo.On("ListByUserID", mock.Anything, user.ID).Return([]*entity.Wallet{{ID: walletID, UserID: user.ID, Balance: 500, HeldBalance: 100, Currency: "IDR"}}, nil).Once()
+
+	overview, custErr := uc.GetOverview(context.Background(), user.ID)
+
+	assert.Nil(t, custErr)
+	assert.Equal(t, "jane@example.com", overview.User.Email)
+	if assert.Len(t, overview.Wallets, 1) {
+		assert.Equal(t, walletID, overview.Wallets[0].ID)
+		assert.Equal(t, 500.0, overview.Wallets[0].Balance)
+		assert.Equal(t, 100.0, overview.Wallets[0].HeldBalance)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetOverview_UserWithoutWallet(t *testing.T) {
+	mockRepo := new(repository.MockWalletRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	user := &entity.User{ID: uuid.New(), Name: "Jane", Email: "jane@example.com", Role: entity.RoleUser}
+	uc := usecase.NewOverviewUsecase(&stubUserRepository{users: map[uuid.UUID]*entity.User{user.ID: user}}, mockRepo, logger)
+
+	mockRepo.On("ListByUserID", mock.Anything, user.ID).Return([]*entity.Wallet{}, nil)
+
+	overview, custErr := uc.GetOverview(context.Background(), user.ID)
+
+	assert.Nil(t, custErr)
+	body, err := json.Marshal(overview)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"wallets":[]`)
+}
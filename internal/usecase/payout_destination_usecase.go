@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// CreatePayoutDestination registers an account for the user to withdraw to.
+// It starts unverified and cannot be used until an admin verifies it.
+func (u *WalletUsecaseImpl) CreatePayoutDestination(ctx context.Context, userID uuid.UUID, req *params.PayoutDestinationRequest) (*params.PayoutDestinationResponse, *response.CustomError) {
+	destination := &entity.PayoutDestination{
+		ID:            uuid.New(),
+		UserID:        userID,
+		Type:          req.Type,
+		Provider:      strings.TrimSpace(req.Provider),
+		AccountName:   strings.TrimSpace(req.AccountName),
+		AccountNumber: req.AccountNumber,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	created, err := u.repo.CreatePayoutDestination(ctx, destination)
+	if err != nil {
+		return nil, response.RepositoryError("failed to create payout destination")
+	}
+	if !created {
+		return nil, response.ConflictError("this account is already registered as a payout destination")
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":                 eventPayoutDestinationCreated,
+		"user_id":               userID,
+		"payout_destination_id": destination.ID,
+	}).Info("Payout destination registered")
+
+	return toPayoutDestinationResponse(destination), nil
+}
+
+func (u *WalletUsecaseImpl) ListPayoutDestinations(ctx context.Context, userID uuid.UUID) ([]*params.PayoutDestinationResponse, *response.CustomError) {
+	destinations, err := u.repo.ListPayoutDestinations(ctx, userID)
+	if err != nil {
+		return nil, response.RepositoryError("failed to list payout destinations")
+	}
+
+	resp := make([]*params.PayoutDestinationResponse, len(destinations))
+	for i, d := range destinations {
+		resp[i] = toPayoutDestinationResponse(d)
+	}
+	return resp, nil
+}
+
+// DeletePayoutDestination removes one of the user's destinations. Past
+// withdrawals to it still reference it.
+func (u *WalletUsecaseImpl) DeletePayoutDestination(ctx context.Context, userID, destinationID uuid.UUID) *response.CustomError {
+	if _, custErr := u.ownPayoutDestination(ctx, userID, destinationID); custErr != nil {
+		return custErr
+	}
+
+	if err := u.repo.DeletePayoutDestination(ctx, destinationID); err != nil {
+		return response.RepositoryError("failed to delete payout destination")
+	}
+	return nil
+}
+
+// VerifyPayoutDestination marks a destination as checked by an admin, after
+// which it can receive withdrawals.
+func (u *WalletUsecaseImpl) VerifyPayoutDestination(ctx context.Context, adminID, destinationID uuid.UUID) (*params.PayoutDestinationResponse, *response.CustomError) {
+	destination, err := u.repo.GetPayoutDestination(ctx, destinationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("payout destination not found")
+		}
+		return nil, response.RepositoryError("failed to get payout destination")
+	}
+	if destination.Verified() {
+		return nil, response.ConflictError("payout destination is already verified")
+	}
+
+	now := time.Now()
+	if err := u.repo.VerifyPayoutDestination(ctx, destination.ID, adminID, now); err != nil {
+		return nil, response.RepositoryError("failed to verify payout destination")
+	}
+	destination.VerifiedAt = &now
+	destination.VerifiedBy = &adminID
+
+	u.logger.WithFields(logrus.Fields{
+		"event":                 eventPayoutDestinationVerified,
+		"payout_destination_id": destination.ID,
+		"verified_by":           adminID,
+	}).Info("Payout destination verified")
+
+	return toPayoutDestinationResponse(destination), nil
+}
+
+// ownPayoutDestination loads a destination of the user. Another user's
+// destination is reported as not found so that ids cannot be probed.
+func (u *WalletUsecaseImpl) ownPayoutDestination(ctx context.Context, userID, destinationID uuid.UUID) (*entity.PayoutDestination, *response.CustomError) {
+	destination, err := u.repo.GetPayoutDestination(ctx, destinationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("payout destination not found")
+		}
+		return nil, response.RepositoryError("failed to get payout destination")
+	}
+	if destination.UserID != userID {
+		return nil, response.NotFoundError("payout destination not found")
+	}
+	return destination, nil
+}
+
+// maskAccountNumber keeps only the last four characters visible.
+func maskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+func toPayoutDestinationResponse(d *entity.PayoutDestination) *params.PayoutDestinationResponse {
+	return &params.PayoutDestinationResponse{
+		ID:            d.ID,
+		Type:          d.Type,
+		Provider:      d.Provider,
+		AccountName:   d.AccountName,
+		AccountNumber: maskAccountNumber(d.AccountNumber),
+		Verified:      d.Verified(),
+		VerifiedAt:    d.VerifiedAt,
+		CreatedAt:     d.CreatedAt,
+	}
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"go-digital-wallet/internal/repository"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RetentionUsecase interface {
+	ArchiveOldTransactions(ctx context.Context) (int64, error)
+}
+
+type RetentionUsecaseImpl struct {
+	repo      repository.WalletRepository
+	logger    *logrus.Logger
+	retention time.Duration
+	batchSize int
+}
+
+func NewRetentionUsecase(repo repository.WalletRepository, logger *logrus.Logger, retention time.Duration, batchSize int) RetentionUsecase {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	return &RetentionUsecaseImpl{
+		repo:      repo,
+		logger:    logger,
+		retention: retention,
+		batchSize: batchSize,
+	}
+}
+
+// ArchiveOldTransactions moves every settled transaction older than the
+// retention period to the archive table, in batches so a large backlog does
+// not hold long locks on the hot table.
+func (u *RetentionUsecaseImpl) ArchiveOldTransactions(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-u.retention)
+
+	var total int64
+	for {
+		moved, err := u.repo.ArchiveTransactionsBefore(ctx, cutoff, u.batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += moved
+		if moved < int64(u.batchSize) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":    eventTransactionsArchived,
+		"cutoff":   cutoff.Format(time.RFC3339),
+		"archived": total,
+	}).Info("Transaction archival completed")
+
+	return total, nil
+}
@@ -0,0 +1,373 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/pkg/statementsig"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxStatementTransactions caps a single export; larger ranges must be split.
+const maxStatementTransactions = 10000
+
+// statementFormatters render a statement in each supported export format.
+var statementFormatters = map[string]struct {
+	contentType string
+	extension   string
+	render      func(s *statement) ([]byte, error)
+}{
+	"csv": {"text/csv; charset=utf-8", "csv", renderCSV},
+	"ofx": {"application/x-ofx", "ofx", renderOFX},
+	"qif": {"application/qif", "qif", renderQIF},
+}
+
+// statement is the format independent content of an export. Transactions are
+// completed ones only, oldest first.
+type statement struct {
+	wallet        *entity.Wallet
+	transactions  []*entity.Transaction
+	start         time.Time
+	end           time.Time
+	closingAmount float64
+	generatedAt   time.Time
+}
+
+// ExportStatement renders the wallet's completed transactions within filter as
+// a downloadable file in format (csv, ofx or qif). With sign, the file comes
+// with a detached signature over its content, the wallet version and the
+// generation time, which VerifyStatement checks later.
+func (u *WalletUsecaseImpl) ExportStatement(ctx context.Context, userID uuid.UUID, format string, filter params.TransactionHistoryFilter, sign bool) (*params.StatementFile, *response.CustomError) {
+	formatter, ok := statementFormatters[format]
+	if !ok {
+		return nil, response.BadRequestError("format must be one of: csv, ofx, qif")
+	}
+	if sign && u.config.StatementSigner == nil {
+		return nil, response.BadRequestError("statement signing is not enabled")
+	}
+
+	wallet, err := u.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+
+	// Statements read oldest first.
+	repoFilter := u.transactionFilter(filter)
+	repoFilter.Ascending = true
+
+	total, err := u.repo.CountTransactionsByWalletID(ctx, wallet.ID, repoFilter)
+	if err != nil {
+		return nil, response.RepositoryError("failed to count transactions")
+	}
+	if total > maxStatementTransactions {
+		return nil, response.BadRequestError(fmt.Sprintf("statement exceeds %d transactions, please narrow the date range", maxStatementTransactions))
+	}
+
+	transactions, err := u.repo.GetTransactionsByWalletID(ctx, wallet.ID, maxStatementTransactions, 0, repoFilter)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to get statement transactions")
+		return nil, response.RepositoryError("failed to get statement transactions")
+	}
+
+	now := time.Now()
+	st := &statement{
+		wallet:      wallet,
+		start:       wallet.CreatedAt,
+		end:         now,
+		generatedAt: now,
+	}
+	if filter.From != nil {
+		st.start = *filter.From
+	}
+	if filter.To != nil {
+		st.end = *filter.To
+	}
+
+	for _, t := range transactions {
+		if t.Status == entity.TransactionStatusCompleted {
+			st.transactions = append(st.transactions, t)
+		}
+	}
+
+	st.closingAmount = wallet.Balance
+	if filter.To != nil {
+		closing, custErr := u.GetBalanceAt(ctx, userID, wallet.ID, st.end)
+		if custErr != nil {
+			return nil, custErr
+		}
+		st.closingAmount = closing.Balance
+	}
+
+	data, err := formatter.render(st)
+	if err != nil {
+		u.logger.WithError(err).WithField("format", format).Error("Failed to render statement")
+		return nil, response.GeneralError("failed to render statement")
+	}
+
+	file := &params.StatementFile{
+		Filename:    fmt.Sprintf("statement-%s-%s.%s", wallet.ID, now.Format("20060102"), formatter.extension),
+		ContentType: formatter.contentType,
+		Data:        data,
+	}
+	if sign {
+		// Whole microseconds, as kept by the database, so the timestamp
+		// reads back the same from every client.
+		generatedAt := now.UTC().Truncate(time.Microsecond)
+		meta := statementsig.Metadata{WalletID: wallet.ID.String(), WalletVersion: wallet.Version, GeneratedAt: generatedAt}
+		signature, err := u.config.StatementSigner.Sign(statementsig.Message(data, meta))
+		if err != nil {
+			u.logger.WithError(err).WithField("wallet_id", wallet.ID).Error("Failed to sign statement")
+			return nil, response.GeneralError("failed to sign statement")
+		}
+		file.Signature = &params.StatementSignature{
+			Algorithm:     u.config.StatementSigner.Algorithm(),
+			Value:         base64.StdEncoding.EncodeToString(signature),
+			WalletID:      wallet.ID,
+			WalletVersion: wallet.Version,
+			GeneratedAt:   generatedAt,
+		}
+	}
+	return file, nil
+}
+
+// VerifyStatement checks that content is a statement exported signed with
+// signature and not altered since. A signature that does not match is a
+// valid result rather than an error; only a malformed request is.
+func (u *WalletUsecaseImpl) VerifyStatement(ctx context.Context, content []byte, signature *params.StatementSignature) (*params.StatementVerification, *response.CustomError) {
+	signer := u.config.StatementSigner
+	if signer == nil {
+		return nil, response.BadRequestError("statement signing is not enabled")
+	}
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return nil, response.BadRequestError("signature must be base64 encoded")
+	}
+
+	result := &params.StatementVerification{
+		Algorithm:     signature.Algorithm,
+		WalletID:      signature.WalletID,
+		WalletVersion: signature.WalletVersion,
+		GeneratedAt:   signature.GeneratedAt,
+	}
+	if signature.Algorithm == signer.Algorithm() {
+		meta := statementsig.Metadata{WalletID: signature.WalletID.String(), WalletVersion: signature.WalletVersion, GeneratedAt: signature.GeneratedAt}
+		result.Valid = signer.Verify(statementsig.Message(content, meta), value) == nil
+	}
+	if !result.Valid {
+		u.logger.WithFields(logrus.Fields{
+			"event":     eventStatementVerificationFailed,
+			"wallet_id": signature.WalletID,
+			"algorithm": signature.Algorithm,
+		}).Warn("Statement signature did not verify")
+	}
+	return result, nil
+}
+
+func isCredit(t entity.TransactionType) bool {
+	return t == entity.TransactionTypeDeposit || t == entity.TransactionTypeInterest || t == entity.TransactionTypeTransferIn
+}
+
+// signedAmount is negative for money leaving the wallet.
+func signedAmount(t *entity.Transaction) float64 {
+	if isCredit(t.Type) {
+		return t.Amount
+	}
+	return -t.Amount
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func renderCSV(s *statement) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write([]string{"id", "created_at", "type", "amount", "currency", "balance_after", "description"}); err != nil {
+		return nil, err
+	}
+	for _, t := range s.transactions {
+		balanceAfter := ""
+		if t.BalanceAfter != nil {
+			balanceAfter = formatAmount(*t.BalanceAfter)
+		}
+		record := []string{
+			t.ID.String(),
+			t.CreatedAt.UTC().Format(time.RFC3339),
+			string(t.Type),
+			formatAmount(signedAmount(t)),
+			s.wallet.Currency,
+			balanceAfter,
+			t.Description,
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// renderQIF writes a QIF bank register. QIF has no currency field, so the
+// currency is recorded in the account description.
+func renderQIF(s *statement) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "!Account\nN%s\nTBank\nDWallet %s (%s)\n^\n", s.wallet.ID, s.wallet.ID, s.wallet.Currency)
+	buf.WriteString("!Type:Bank\n")
+	for _, t := range s.transactions {
+		fmt.Fprintf(&buf, "D%s\n", t.CreatedAt.UTC().Format("01/02/2006"))
+		fmt.Fprintf(&buf, "T%s\n", formatAmount(signedAmount(t)))
+		fmt.Fprintf(&buf, "N%s\n", t.ID)
+		fmt.Fprintf(&buf, "P%s\n", qifLine(qifPayee(t)))
+		if t.Description != "" {
+			fmt.Fprintf(&buf, "M%s\n", qifLine(t.Description))
+		}
+		buf.WriteString("^\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func qifPayee(t *entity.Transaction) string {
+	if t.Description != "" {
+		return t.Description
+	}
+	return strings.ToUpper(string(t.Type[:1])) + string(t.Type[1:])
+}
+
+// qifLine keeps a value on one line, since QIF fields are newline delimited.
+func qifLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+const ofxTimeLayout = "20060102150405"
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		Response struct {
+			Status   ofxStatus `xml:"STATUS"`
+			DTServer string    `xml:"DTSERVER"`
+			Language string    `xml:"LANGUAGE"`
+		} `xml:"SONRS"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		Transaction struct {
+			TrnUID    string    `xml:"TRNUID"`
+			Status    ofxStatus `xml:"STATUS"`
+			Statement struct {
+				Currency string `xml:"CURDEF"`
+				Account  struct {
+					BankID   string `xml:"BANKID"`
+					AcctID   string `xml:"ACCTID"`
+					AcctType string `xml:"ACCTTYPE"`
+				} `xml:"BANKACCTFROM"`
+				List struct {
+					DTStart      string           `xml:"DTSTART"`
+					DTEnd        string           `xml:"DTEND"`
+					Transactions []ofxTransaction `xml:"STMTTRN"`
+				} `xml:"BANKTRANLIST"`
+				Ledger struct {
+					Amount string `xml:"BALAMT"`
+					AsOf   string `xml:"DTASOF"`
+				} `xml:"LEDGERBAL"`
+			} `xml:"STMTRS"`
+		} `xml:"STMTTRNRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxTransaction struct {
+	Type     string `xml:"TRNTYPE"`
+	DTPosted string `xml:"DTPOSTED"`
+	Amount   string `xml:"TRNAMT"`
+	FitID    string `xml:"FITID"`
+	Name     string `xml:"NAME"`
+	Memo     string `xml:"MEMO,omitempty"`
+}
+
+// ofxTransactionType maps wallet transaction types onto OFX TRNTYPE values.
+func ofxTransactionType(t entity.TransactionType) string {
+	switch t {
+	case entity.TransactionTypeInterest:
+		return "INT"
+	case entity.TransactionTypeDeposit:
+		return "CREDIT"
+	case entity.TransactionTypeTransferIn, entity.TransactionTypeTransferOut:
+		return "XFER"
+	default:
+		return "DEBIT"
+	}
+}
+
+// renderOFX writes an OFX 2.2 bank statement.
+func renderOFX(s *statement) ([]byte, error) {
+	var doc ofxDocument
+	ok := ofxStatus{Code: 0, Severity: "INFO"}
+
+	doc.SignOn.Response.Status = ok
+	doc.SignOn.Response.DTServer = s.generatedAt.UTC().Format(ofxTimeLayout)
+	doc.SignOn.Response.Language = "ENG"
+
+	trn := &doc.Bank.Transaction
+	trn.TrnUID = "0"
+	trn.Status = ok
+	trn.Statement.Currency = s.wallet.Currency
+	trn.Statement.Account.BankID = "DIGITALWALLET"
+	trn.Statement.Account.AcctID = s.wallet.ID.String()
+	trn.Statement.Account.AcctType = "CHECKING"
+	trn.Statement.List.DTStart = s.start.UTC().Format(ofxTimeLayout)
+	trn.Statement.List.DTEnd = s.end.UTC().Format(ofxTimeLayout)
+	trn.Statement.Ledger.Amount = formatAmount(s.closingAmount)
+	trn.Statement.Ledger.AsOf = s.end.UTC().Format(ofxTimeLayout)
+
+	for _, t := range s.transactions {
+		name := qifPayee(t)
+		// OFX limits NAME to 32 characters; the full text goes in MEMO.
+		if r := []rune(name); len(r) > 32 {
+			name = string(r[:32])
+		}
+		trn.Statement.List.Transactions = append(trn.Statement.List.Transactions, ofxTransaction{
+			Type:     ofxTransactionType(t.Type),
+			DTPosted: t.CreatedAt.UTC().Format(ofxTimeLayout),
+			Amount:   formatAmount(signedAmount(t)),
+			FitID:    t.ID.String(),
+			Name:     name,
+			Memo:     t.Description,
+		})
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	buf.WriteString(`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	buf.Write(body)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,121 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/repository"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// lockForExternalMovement locks the user's wallet for a deposit or a
+// withdrawal. When a system account is configured it is locked too, in the
+// usual wallet order, and returned as the second wallet; otherwise that
+// wallet is nil.
+//
+// Every deposit and withdrawal locks the system account, so they are
+// serialized with each other while it is enabled.
+func (u *WalletUsecaseImpl) lockForExternalMovement(ctx context.Context, tx *gorm.DB, txRepo repository.WalletRepository, userID uuid.UUID) (*entity.Wallet, *entity.Wallet, *response.CustomError) {
+	if u.config.SystemWalletID == uuid.Nil {
+		wallet, err := txRepo.GetByUserIDForUpdate(ctx, tx, userID)
+		if err != nil {
+			return nil, nil, u.lockWalletError(err, userID)
+		}
+		return wallet, nil, nil
+	}
+
+	wallet, err := txRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, response.NotFoundError("wallet not found")
+		}
+		return nil, nil, response.RepositoryError("failed to get wallet")
+	}
+	if wallet.ID == u.config.SystemWalletID {
+		return nil, nil, response.BadRequestError("the system account cannot deposit or withdraw")
+	}
+
+	system, err := txRepo.GetByID(ctx, u.config.SystemWalletID)
+	if err != nil {
+		u.logger.WithError(err).WithField("wallet_id", u.config.SystemWalletID).Error("Failed to get system account")
+		return nil, nil, response.GeneralError("system account is unavailable")
+	}
+
+	locked, err := lockWallets([]*entity.Wallet{wallet, system}, func(userID uuid.UUID) (*entity.Wallet, error) {
+		return txRepo.GetByUserIDForUpdate(ctx, tx, userID)
+	})
+	if err != nil {
+		return nil, nil, u.lockWalletError(err, userID)
+	}
+	wallet, system = locked[wallet.ID], locked[system.ID]
+
+	if !system.IsSystem {
+		u.logger.WithField("wallet_id", system.ID).Error("Configured system wallet is not flagged as a system account")
+		return nil, nil, response.GeneralError("system account is misconfigured")
+	}
+	if system.Currency != wallet.Currency {
+		return nil, nil, response.UnprocessableEntityError(fmt.Sprintf("deposits and withdrawals in %s are not supported", wallet.Currency))
+	}
+
+	return wallet, system, nil
+}
+
+func (u *WalletUsecaseImpl) lockWalletError(err error, userID uuid.UUID) *response.CustomError {
+	if errors.Is(err, repository.ErrLockTimeout) {
+		return walletBusyError()
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return response.NotFoundError("wallet not found")
+	}
+	u.logger.WithError(err).WithField("user_id", userID).Error("Failed to get wallet for update")
+	return response.RepositoryError("failed to get wallet for update")
+}
+
+// bookSystemLeg writes the system account's side of a deposit or withdrawal
+// of amount on wallet: a deposit debits the system account and a withdrawal
+// credits it, so the two legs always sum to zero.
+func (u *WalletUsecaseImpl) bookSystemLeg(ctx context.Context, tx *gorm.DB, txRepo repository.WalletRepository, system, wallet *entity.Wallet, userType entity.TransactionType, amount float64) *response.CustomError {
+	legType, newBalance, description := entity.TransactionTypeDeposit, system.Balance+amount, "Withdrawal from wallet %s"
+	if userType == entity.TransactionTypeDeposit {
+		legType, newBalance, description = entity.TransactionTypeWithdraw, system.Balance-amount, "Deposit to wallet %s"
+	}
+	if math.Abs(newBalance) > MaxStorableAmount {
+		return response.UnprocessableEntityError("system account balance limit reached")
+	}
+
+	now := time.Now()
+	leg := &entity.Transaction{
+		ID:           uuid.New(),
+		WalletID:     system.ID,
+		Type:         legType,
+		Amount:       amount,
+		Status:       entity.TransactionStatusCompleted,
+		Description:  fmt.Sprintf(description, wallet.ID),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		BalanceAfter: &newBalance,
+	}
+	if err := txRepo.CreateTransaction(ctx, tx, leg); err != nil {
+		u.logger.WithError(err).Error("Failed to create system account transaction")
+		return response.RepositoryError("failed to create transaction")
+	}
+	if err := txRepo.UpdateBalance(ctx, tx, system.ID, newBalance, system.Version+1); err != nil {
+		u.logger.WithError(err).Error("Failed to update system account balance")
+		return response.RepositoryError("failed to update wallet balance")
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"wallet_id":      wallet.ID,
+		"transaction_id": leg.ID,
+		"amount":         amount,
+	}).Debug("Booked system account leg")
+
+	return nil
+}
@@ -0,0 +1,341 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/pkg/money"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxImportRows caps a single import file; larger migrations must be split.
+const maxImportRows = 5000
+
+// importRow is one transaction as read from an import file, before
+// validation.
+type importRow struct {
+	WalletID    string      `json:"wallet_id"`
+	Type        string      `json:"type"`
+	Amount      json.Number `json:"amount"`
+	Currency    string      `json:"currency"`
+	Description string      `json:"description"`
+	Timestamp   string      `json:"timestamp"`
+}
+
+// importParsers read the rows of an import file in each supported format.
+var importParsers = map[string]func(r io.Reader) ([]*importRow, error){
+	"csv":  parseImportCSV,
+	"json": parseImportJSON,
+}
+
+// importedTransaction is a validated row ready to be written.
+type importedTransaction struct {
+	row         int
+	wallet      *entity.Wallet
+	txType      entity.TransactionType
+	amount      float64
+	description string
+	createdAt   time.Time
+}
+
+// ImportTransactions records historical deposits and withdrawals from a
+// legacy system, read from r in format (csv or json). Every row is validated
+// first; valid rows are then written one by one with their original
+// timestamps, and the rest are reported with the reason they were rejected.
+// A dry run only validates.
+//
+// Imported rows move the wallet balance like a live deposit or withdrawal,
+// and are booked against the system account when one is configured. They
+// carry no balance snapshot, as the balance at their original date is not
+// known.
+func (u *WalletUsecaseImpl) ImportTransactions(ctx context.Context, adminID uuid.UUID, format string, r io.Reader, dryRun bool) (*params.TransactionImportReport, *response.CustomError) {
+	defer u.config.InFlight.Start()()
+
+	parse, ok := importParsers[format]
+	if !ok {
+		return nil, response.BadRequestError("format must be one of: csv, json")
+	}
+	rows, err := parse(r)
+	if err != nil {
+		return nil, response.BadRequestError(fmt.Sprintf("invalid %s file: %v", format, err))
+	}
+	if len(rows) == 0 {
+		return nil, response.BadRequestError("import file has no rows")
+	}
+	if len(rows) > maxImportRows {
+		return nil, response.BadRequestErrorWithAdditionalInfo(
+			map[string]int{"max_rows": maxImportRows},
+			fmt.Sprintf("import file has more than %d rows; split it into smaller files", maxImportRows),
+		)
+	}
+
+	report := &params.TransactionImportReport{
+		DryRun:   dryRun,
+		Total:    len(rows),
+		Rejected: []*params.TransactionImportRejection{},
+	}
+	reject := func(row int, reason string) {
+		report.Rejected = append(report.Rejected, &params.TransactionImportRejection{Row: row, Reason: reason})
+	}
+
+	// Withdrawals are checked against the balance left by the rows before
+	// them, so a file that overdraws a wallet is caught on a dry run too.
+	wallets := make(map[uuid.UUID]*entity.Wallet)
+	balances := make(map[uuid.UUID]float64)
+	var valid []*importedTransaction
+	now := time.Now()
+	for i, row := range rows {
+		t, reason, custErr := u.validateImportRow(ctx, i+1, row, wallets, now)
+		if custErr != nil {
+			return nil, custErr
+		}
+		if reason != "" {
+			reject(t.row, reason)
+			continue
+		}
+
+		if _, ok := balances[t.wallet.ID]; !ok {
+			balances[t.wallet.ID] = t.wallet.AvailableBalance()
+		}
+		switch t.txType {
+		case entity.TransactionTypeWithdraw:
+			if balances[t.wallet.ID] < t.amount {
+				reject(t.row, "insufficient balance")
+				continue
+			}
+			balances[t.wallet.ID] -= t.amount
+		default:
+			balances[t.wallet.ID] += t.amount
+		}
+		valid = append(valid, t)
+	}
+	report.Valid = len(valid)
+
+	if dryRun {
+		return report, nil
+	}
+
+	touched := make(map[uuid.UUID]bool)
+	for _, t := range valid {
+		userIDs, custErr := u.importTransaction(ctx, t)
+		if custErr != nil {
+			reject(t.row, custErr.Message)
+			continue
+		}
+		report.Imported++
+		for _, id := range userIDs {
+			touched[id] = true
+		}
+	}
+	for userID := range touched {
+		u.invalidateTransactionCache(ctx, userID)
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":    eventTransactionsImported,
+		"admin_id": adminID,
+		"total":    report.Total,
+		"imported": report.Imported,
+		"rejected": len(report.Rejected),
+	}).Info("Transaction import completed")
+
+	return report, nil
+}
+
+// validateImportRow checks one row, returning the reason it is rejected, if
+// any. wallets caches the wallets already looked up. Only a failure to read
+// from the database is returned as an error, aborting the import.
+func (u *WalletUsecaseImpl) validateImportRow(ctx context.Context, n int, row *importRow, wallets map[uuid.UUID]*entity.Wallet, now time.Time) (*importedTransaction, string, *response.CustomError) {
+	t := &importedTransaction{row: n, description: strings.TrimSpace(row.Description)}
+
+	walletID, err := uuid.Parse(strings.TrimSpace(row.WalletID))
+	if err != nil {
+		return t, "wallet_id is not a valid id", nil
+	}
+	wallet, ok := wallets[walletID]
+	if !ok {
+		wallet, err = u.repo.GetByID(ctx, walletID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return t, "wallet not found", nil
+			}
+			return nil, "", response.RepositoryError("failed to get wallet")
+		}
+		wallets[walletID] = wallet
+	}
+	t.wallet = wallet
+
+	switch entity.TransactionType(strings.ToLower(strings.TrimSpace(row.Type))) {
+	case entity.TransactionTypeDeposit:
+		t.txType = entity.TransactionTypeDeposit
+	case entity.TransactionTypeWithdraw:
+		t.txType = entity.TransactionTypeWithdraw
+	default:
+		return t, "type must be one of: deposit, withdraw", nil
+	}
+
+	amount, err := money.Parse(strings.TrimSpace(row.Amount.String()))
+	if err != nil {
+		return t, "amount is not a number", nil
+	}
+	// Amounts are checked as exact decimals, as in requests, so a row the
+	// amount columns would round is rejected rather than altered.
+	if _, err := money.Format(amount, 2); err != nil {
+		return t, "amount must have at most 2 decimal places", nil
+	}
+	t.amount, _ = amount.Float64()
+	if custErr := u.validateAmount(t.amount, "invalid amount"); custErr != nil {
+		return t, custErr.Message, nil
+	}
+	if custErr := checkCurrency(wallet, strings.TrimSpace(row.Currency)); custErr != nil {
+		return t, custErr.Message, nil
+	}
+	if len(t.description) > 500 {
+		return t, "description exceeds 500 characters", nil
+	}
+
+	t.createdAt, err = time.Parse(time.RFC3339, strings.TrimSpace(row.Timestamp))
+	if err != nil {
+		return t, "timestamp must be an RFC 3339 time such as 2024-01-31T09:30:00Z", nil
+	}
+	if !t.createdAt.Before(now) {
+		return t, "timestamp must be in the past", nil
+	}
+
+	return t, "", nil
+}
+
+// importTransaction writes one validated row in its own database
+// transaction, so a row failing here does not undo the others. It returns the
+// users whose transactions changed.
+func (u *WalletUsecaseImpl) importTransaction(ctx context.Context, t *importedTransaction) ([]uuid.UUID, *response.CustomError) {
+	tx := u.repo.BeginTx(ctx)
+	if tx.Error != nil {
+		u.logger.WithError(tx.Error).Error("Failed to begin transaction")
+		return nil, response.GeneralError("failed to begin transaction")
+	}
+	txRepo := u.repo.WithTx(tx)
+	defer tx.Rollback()
+
+	wallet, system, custErr := u.lockForExternalMovement(ctx, tx, txRepo, t.wallet.UserID)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	op, newBalance := operationDeposit, wallet.Balance+t.amount
+	if t.txType == entity.TransactionTypeWithdraw {
+		op, newBalance = operationWithdraw, wallet.Balance-t.amount
+	}
+	if custErr := checkWalletOperation(wallet, op); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkSoftLock(wallet, time.Now()); custErr != nil {
+		return nil, custErr
+	}
+	if t.txType == entity.TransactionTypeWithdraw && wallet.AvailableBalance() < t.amount {
+		return nil, response.BadRequestError("insufficient balance")
+	}
+	if newBalance > MaxStorableAmount {
+		return nil, response.UnprocessableEntityError("deposit would exceed the maximum wallet balance")
+	}
+
+	transaction := &entity.Transaction{
+		ID:          uuid.New(),
+		WalletID:    wallet.ID,
+		Type:        t.txType,
+		Amount:      t.amount,
+		Status:      entity.TransactionStatusCompleted,
+		Description: t.description,
+		CreatedAt:   t.createdAt,
+		UpdatedAt:   t.createdAt,
+	}
+	if err := txRepo.CreateTransaction(ctx, tx, transaction); err != nil {
+		u.logger.WithError(err).WithField("wallet_id", wallet.ID).Error("Failed to create imported transaction")
+		return nil, response.RepositoryError("failed to create transaction")
+	}
+	if err := txRepo.UpdateBalance(ctx, tx, wallet.ID, newBalance, wallet.Version+1); err != nil {
+		u.logger.WithError(err).WithField("wallet_id", wallet.ID).Error("Failed to update wallet balance")
+		return nil, response.RepositoryError("failed to update wallet balance")
+	}
+
+	userIDs := []uuid.UUID{wallet.UserID}
+	if system != nil {
+		if custErr := u.bookSystemLeg(ctx, tx, txRepo, system, wallet, t.txType, t.amount); custErr != nil {
+			return nil, custErr
+		}
+		userIDs = append(userIDs, system.UserID)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		u.logger.WithError(err).Error("Failed to commit imported transaction")
+		return nil, response.RepositoryError("failed to commit transaction")
+	}
+	return userIDs, nil
+}
+
+// parseImportCSV reads rows from a CSV file whose header names the columns:
+// wallet_id, type, amount and timestamp, and optionally currency and
+// description, in any order.
+func parseImportCSV(r io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(r)
+	// Short rows are reported per row rather than failing the file.
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"wallet_id", "type", "amount", "timestamp"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	rows := make([]*importRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		rows = append(rows, &importRow{
+			WalletID:    field(record, "wallet_id"),
+			Type:        field(record, "type"),
+			Amount:      json.Number(field(record, "amount")),
+			Currency:    field(record, "currency"),
+			Description: field(record, "description"),
+			Timestamp:   field(record, "timestamp"),
+		})
+	}
+	return rows, nil
+}
+
+// parseImportJSON reads rows from a JSON array of objects with the same
+// fields as the CSV columns.
+func parseImportJSON(r io.Reader) ([]*importRow, error) {
+	var rows []*importRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
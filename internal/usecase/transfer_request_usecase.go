@@ -0,0 +1,458 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// defaultTransferRequestTTL is how long a transfer request waits for approval
+// when neither the request nor the configuration sets a window, and
+// defaultTransferRequestMaxTTL the longest window a request may ask for when
+// none is configured.
+const (
+	defaultTransferRequestTTL    = 24 * time.Hour
+	defaultTransferRequestMaxTTL = 7 * 24 * time.Hour
+)
+
+// expiryLockKey keeps expiry runs of several instances from overlapping, and
+// expiryLockTTL frees it should the instance running one die.
+const (
+	expiryLockKey = "transfer-request-expiry-lock"
+	expiryLockTTL = 5 * time.Minute
+)
+
+// expireBatchSize bounds how many transfer requests one expiry run loads.
+const expireBatchSize = 100
+
+// CreateTransferRequest holds the amount on the user's wallet and records a
+// transfer that only moves the money once an approver confirms it.
+func (u *WalletUsecaseImpl) CreateTransferRequest(ctx context.Context, userID uuid.UUID, req *params.PendingTransferRequest) (*params.TransferRequestResponse, *response.CustomError) {
+	defer u.config.InFlight.Start()()
+
+	if custErr := u.validateAmount(req.Amount, "invalid transfer amount"); custErr != nil {
+		return nil, custErr
+	}
+	window, custErr := u.transferRequestWindow(req)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	recipient, err := u.repo.GetByID(ctx, req.ToWalletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("recipient wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get recipient wallet")
+	}
+
+	tx := u.repo.BeginTx(ctx)
+	if tx.Error != nil {
+		u.logger.WithError(tx.Error).WithField("event", eventTransferRequestFailed).Error("Failed to begin transaction")
+		return nil, response.GeneralError("failed to begin transaction")
+	}
+	txRepo := u.repo.WithTx(tx)
+	defer tx.Rollback()
+
+	wallet, err := txRepo.GetByUserIDForUpdate(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrLockTimeout) {
+			return nil, walletBusyError()
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		u.logger.WithError(err).WithFields(logrus.Fields{"event": eventTransferRequestFailed, "user_id": userID}).Error("Failed to get wallet for update")
+		return nil, response.RepositoryError("failed to get wallet for update")
+	}
+
+	if wallet.ID == recipient.ID {
+		return nil, response.BadRequestError("cannot transfer to the same wallet")
+	}
+	if custErr := checkTransferOperations(wallet, recipient); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkSoftLock(wallet, time.Now()); custErr != nil {
+		return nil, custErr
+	}
+	if wallet.Currency != recipient.Currency {
+		return nil, response.BadRequestError("transfers between different currencies are not supported")
+	}
+	if custErr := u.loadClearingBalance(ctx, tx, txRepo, wallet); custErr != nil {
+		return nil, custErr
+	}
+	if wallet.AvailableBalance() < req.Amount {
+		u.logger.WithFields(logrus.Fields{
+			"event":             eventTransferRequestInsufficientBalance,
+			"user_id":           userID,
+			"available_balance": wallet.AvailableBalance(),
+			"transfer_amount":   req.Amount,
+		}).Warn("Insufficient balance for transfer request")
+		return nil, response.BadRequestError("insufficient balance")
+	}
+
+	if err := txRepo.UpdateHeldBalance(ctx, tx, wallet.ID, wallet.HeldBalance+req.Amount, wallet.Version+1); err != nil {
+		u.logger.WithError(err).WithField("event", eventTransferRequestFailed).Error("Failed to hold transfer amount")
+		return nil, response.RepositoryError("failed to hold transfer amount")
+	}
+
+	now := time.Now()
+	request := &entity.TransferRequest{
+		ID:           uuid.New(),
+		FromWalletID: wallet.ID,
+		ToWalletID:   recipient.ID,
+		RequestedBy:  userID,
+		Amount:       req.Amount,
+		Description:  req.Description,
+		Status:       entity.TransferRequestStatusPending,
+		ExpiresAt:    now.Add(window),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := txRepo.CreateTransferRequest(ctx, tx, request); err != nil {
+		return nil, response.RepositoryError("failed to create transfer request")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		u.logger.WithError(err).WithField("event", eventTransferRequestFailed).Error("Failed to commit transaction")
+		return nil, response.RepositoryError("failed to commit transaction")
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":               eventTransferRequestCreated,
+		"user_id":             userID,
+		"transfer_request_id": request.ID,
+		"to_wallet_id":        recipient.ID,
+		"amount":              req.Amount,
+	}).Info("Transfer request created")
+
+	resp := toTransferRequestResponse(request)
+
+	return resp, nil
+}
+
+// ApproveTransferRequest releases the hold and moves the money. The approver
+// must be someone other than the requester; a request past its window is
+// expired instead.
+func (u *WalletUsecaseImpl) ApproveTransferRequest(ctx context.Context, approverID, requestID uuid.UUID) (*params.TransferRequestResponse, *response.CustomError) {
+	defer u.config.InFlight.Start()()
+
+	tx := u.repo.BeginTx(ctx)
+	if tx.Error != nil {
+		u.logger.WithError(tx.Error).WithField("event", eventTransferRequestFailed).Error("Failed to begin transaction")
+		return nil, response.GeneralError("failed to begin transaction")
+	}
+	txRepo := u.repo.WithTx(tx)
+	defer tx.Rollback()
+
+	request, custErr := u.lockPendingTransferRequest(ctx, tx, requestID)
+	if custErr != nil {
+		return nil, custErr
+	}
+	if request.RequestedBy == approverID {
+		return nil, response.ForbiddenError("transfer requests must be approved by another user")
+	}
+
+	now := time.Now()
+	if now.After(request.ExpiresAt) {
+		if custErr := u.closeTransferRequest(ctx, tx, request, entity.TransferRequestStatusExpired, nil, now); custErr != nil {
+			return nil, custErr
+		}
+		if err := tx.Commit().Error; err != nil {
+			u.logger.WithError(err).WithField("event", eventTransferRequestFailed).Error("Failed to commit transaction")
+			return nil, response.RepositoryError("failed to commit transaction")
+		}
+		return nil, response.UnprocessableEntityError("transfer request has expired")
+	}
+
+	sender, err := u.repo.GetByID(ctx, request.FromWalletID)
+	if err != nil {
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+	recipient, err := u.repo.GetByID(ctx, request.ToWalletID)
+	if err != nil {
+		return nil, response.RepositoryError("failed to get recipient wallet")
+	}
+
+	locked, err := lockWallets([]*entity.Wallet{sender, recipient}, func(userID uuid.UUID) (*entity.Wallet, error) {
+		return txRepo.GetByUserIDForUpdate(ctx, tx, userID)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrLockTimeout) {
+			return nil, walletBusyError()
+		}
+		u.logger.WithError(err).WithFields(logrus.Fields{"event": eventTransferRequestFailed, "transfer_request_id": requestID}).Error("Failed to lock wallets for transfer request")
+		return nil, response.RepositoryError("failed to lock wallets")
+	}
+	from, to := locked[sender.ID], locked[recipient.ID]
+
+	if custErr := checkTransferOperations(from, to); custErr != nil {
+		return nil, custErr
+	}
+	// The request stays pending, so it can still be approved once the lock
+	// lapses or be rejected.
+	if custErr := checkTransferSoftLocks(from, to); custErr != nil {
+		return nil, custErr
+	}
+
+	from.HeldBalance -= request.Amount
+	from.Version++
+	if err := txRepo.UpdateHeldBalance(ctx, tx, from.ID, from.HeldBalance, from.Version); err != nil {
+		u.logger.WithError(err).WithField("event", eventTransferRequestFailed).Error("Failed to release held amount")
+		return nil, response.RepositoryError("failed to release held amount")
+	}
+
+	out, fromBalance, toBalance, custErr := u.applyTransfer(ctx, tx, txRepo, from, to, request.Amount, nil, request.Description)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	request.Status = entity.TransferRequestStatusApproved
+	request.DecidedBy = &approverID
+	request.DecidedAt = &now
+	request.TransactionID = &out.ID
+	if err := txRepo.UpdateTransferRequest(ctx, tx, request); err != nil {
+		return nil, response.RepositoryError("failed to update transfer request")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		u.logger.WithError(err).WithField("event", eventTransferRequestFailed).Error("Failed to commit transaction")
+		return nil, response.RepositoryError("failed to commit transaction")
+	}
+
+	u.invalidateTransactionCache(ctx, from.UserID)
+	u.invalidateTransactionCache(ctx, to.UserID)
+
+	u.logger.WithFields(logrus.Fields{
+		"event":               eventTransferRequestApproved,
+		"approver_id":         approverID,
+		"transfer_request_id": request.ID,
+		"transaction_id":      out.ID,
+		"amount":              request.Amount,
+	}).Info("Transfer request approved")
+
+	u.alertIfAboveThreshold(from, entity.TransactionTypeTransferOut, request.Amount, fromBalance)
+	u.alertIfAboveThreshold(to, entity.TransactionTypeTransferIn, request.Amount, toBalance)
+
+	return toTransferRequestResponse(request), nil
+}
+
+// RejectTransferRequest releases the hold without moving any money.
+func (u *WalletUsecaseImpl) RejectTransferRequest(ctx context.Context, approverID, requestID uuid.UUID) (*params.TransferRequestResponse, *response.CustomError) {
+	defer u.config.InFlight.Start()()
+
+	tx := u.repo.BeginTx(ctx)
+	if tx.Error != nil {
+		u.logger.WithError(tx.Error).WithField("event", eventTransferRequestFailed).Error("Failed to begin transaction")
+		return nil, response.GeneralError("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	request, custErr := u.lockPendingTransferRequest(ctx, tx, requestID)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	if custErr := u.closeTransferRequest(ctx, tx, request, entity.TransferRequestStatusRejected, &approverID, time.Now()); custErr != nil {
+		return nil, custErr
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		u.logger.WithError(err).WithField("event", eventTransferRequestFailed).Error("Failed to commit transaction")
+		return nil, response.RepositoryError("failed to commit transaction")
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":               eventTransferRequestRejected,
+		"approver_id":         approverID,
+		"transfer_request_id": request.ID,
+	}).Info("Transfer request rejected")
+
+	return toTransferRequestResponse(request), nil
+}
+
+// ExpireTransferRequests releases the hold of every pending request whose
+// approval window has closed. Requests decided meanwhile are skipped.
+//
+// A Redis lock keeps one instance at a time expiring; the others skip the
+// run. Each request is expired under its row lock anyway, so without Redis,
+// or when it errors, the run goes ahead.
+func (u *WalletUsecaseImpl) ExpireTransferRequests(ctx context.Context) (int, error) {
+	defer u.config.InFlight.Start()()
+
+	if u.cache != nil {
+		token := uuid.NewString()
+		claimed, err := u.cache.SetNX(ctx, expiryLockKey, token, expiryLockTTL).Result()
+		if err != nil {
+			u.logger.WithError(err).Warn("Failed to acquire transfer request expiry lock")
+		} else if !claimed {
+			u.logger.Debug("Transfer request expiry running on another instance")
+			return 0, nil
+		} else {
+			defer func() {
+				// The same compare-and-delete as the operation lock: a run
+				// that outlived the TTL must not free another's lock.
+				if err := releaseOperationLock.Run(context.WithoutCancel(ctx), u.cache, []string{expiryLockKey}, token).Err(); err != nil {
+					u.logger.WithError(err).Warn("Failed to release transfer request expiry lock")
+				}
+			}()
+		}
+	}
+
+	var total int
+	for {
+		requests, err := u.repo.ListExpiredTransferRequests(ctx, time.Now(), expireBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		expired := 0
+		for _, request := range requests {
+			if custErr := u.expireTransferRequest(ctx, request.ID); custErr != nil {
+				u.logger.WithFields(logrus.Fields{
+					"event":               eventTransferRequestFailed,
+					"transfer_request_id": request.ID,
+					"error":               custErr.Message,
+				}).Error("Failed to expire transfer request")
+				continue
+			}
+			expired++
+		}
+		total += expired
+
+		if len(requests) < expireBatchSize || expired == 0 || ctx.Err() != nil {
+			break
+		}
+	}
+
+	log := u.logger.WithFields(logrus.Fields{"event": eventTransferRequestExpired, "expired": total})
+	if total > 0 {
+		log.Info("Transfer requests expired")
+	} else {
+		log.Debug("Transfer requests expired")
+	}
+
+	return total, nil
+}
+
+func (u *WalletUsecaseImpl) expireTransferRequest(ctx context.Context, requestID uuid.UUID) *response.CustomError {
+	tx := u.repo.BeginTx(ctx)
+	if tx.Error != nil {
+		return response.GeneralError("failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	request, custErr := u.lockPendingTransferRequest(ctx, tx, requestID)
+	if custErr != nil {
+		// Approved or rejected since it was listed.
+		if custErr.Category == response.CategoryConflict {
+			return nil
+		}
+		return custErr
+	}
+
+	if custErr := u.closeTransferRequest(ctx, tx, request, entity.TransferRequestStatusExpired, nil, time.Now()); custErr != nil {
+		return custErr
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return response.RepositoryError("failed to commit transaction")
+	}
+	return nil
+}
+
+// lockPendingTransferRequest loads the request for update and checks it is
+// still awaiting a decision.
+func (u *WalletUsecaseImpl) lockPendingTransferRequest(ctx context.Context, tx *gorm.DB, requestID uuid.UUID) (*entity.TransferRequest, *response.CustomError) {
+	request, err := u.repo.WithTx(tx).GetTransferRequestForUpdate(ctx, tx, requestID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("transfer request not found")
+		}
+		return nil, response.RepositoryError("failed to get transfer request")
+	}
+	if request.Status != entity.TransferRequestStatusPending {
+		return nil, response.ConflictError("transfer request is already " + string(request.Status))
+	}
+	return request, nil
+}
+
+// closeTransferRequest releases the held amount on the sender's wallet and
+// records the final status of the request within tx.
+func (u *WalletUsecaseImpl) closeTransferRequest(ctx context.Context, tx *gorm.DB, request *entity.TransferRequest, status entity.TransferRequestStatus, decidedBy *uuid.UUID, now time.Time) *response.CustomError {
+	txRepo := u.repo.WithTx(tx)
+
+	sender, err := u.repo.GetByID(ctx, request.FromWalletID)
+	if err != nil {
+		return response.RepositoryError("failed to get wallet")
+	}
+	wallet, err := txRepo.GetByUserIDForUpdate(ctx, tx, sender.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrLockTimeout) {
+			return walletBusyError()
+		}
+		return response.RepositoryError("failed to get wallet for update")
+	}
+
+	if err := txRepo.UpdateHeldBalance(ctx, tx, wallet.ID, wallet.HeldBalance-request.Amount, wallet.Version+1); err != nil {
+		u.logger.WithError(err).WithField("event", eventTransferRequestFailed).Error("Failed to release held amount")
+		return response.RepositoryError("failed to release held amount")
+	}
+
+	request.Status = status
+	request.DecidedBy = decidedBy
+	request.DecidedAt = &now
+	if err := txRepo.UpdateTransferRequest(ctx, tx, request); err != nil {
+		return response.RepositoryError("failed to update transfer request")
+	}
+	return nil
+}
+
+// transferRequestWindow is how long the request waits for approval: the
+// window it asks for, up to the configured maximum, or the default.
+func (u *WalletUsecaseImpl) transferRequestWindow(req *params.PendingTransferRequest) (time.Duration, *response.CustomError) {
+	if req.ExpiresInMinutes == nil {
+		if u.config.TransferRequestTTL > 0 {
+			return u.config.TransferRequestTTL, nil
+		}
+		return defaultTransferRequestTTL, nil
+	}
+
+	maxTTL := u.config.TransferRequestMaxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultTransferRequestMaxTTL
+	}
+	window := time.Duration(*req.ExpiresInMinutes) * time.Minute
+	if *req.ExpiresInMinutes <= 0 || window > maxTTL {
+		maxMinutes := int(maxTTL / time.Minute)
+		return 0, response.BadRequestErrorWithAdditionalInfo(
+			map[string]int{"max_expires_in_minutes": maxMinutes},
+			fmt.Sprintf("expires_in_minutes must be between 1 and %d", maxMinutes),
+		)
+	}
+	return window, nil
+}
+
+func toTransferRequestResponse(request *entity.TransferRequest) *params.TransferRequestResponse {
+	return &params.TransferRequestResponse{
+		ID:            request.ID,
+		FromWalletID:  request.FromWalletID,
+		ToWalletID:    request.ToWalletID,
+		Amount:        request.Amount,
+		Description:   request.Description,
+		Status:        request.Status,
+		TransactionID: request.TransactionID,
+		ExpiresAt:     request.ExpiresAt,
+		DecidedAt:     request.DecidedAt,
+		CreatedAt:     request.CreatedAt,
+	}
+}
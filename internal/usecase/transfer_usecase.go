@@ -0,0 +1,404 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/readpref"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Transfer moves money from the user's wallet to another wallet in the same
+// currency. Both balance updates and both transaction legs are written in a
+// single database transaction. With a tip the sender is debited once for the
+// total and both recipients are credited in that same transaction.
+func (u *WalletUsecaseImpl) Transfer(ctx context.Context, userID uuid.UUID, req *params.TransferRequest) (*params.TransferResponse, *response.CustomError) {
+	defer u.config.InFlight.Start()()
+
+	if custErr := u.validateAmount(req.Amount, "invalid transfer amount"); custErr != nil {
+		return nil, custErr
+	}
+	total := req.Amount
+	if req.Tip != nil {
+		if custErr := u.validateAmount(req.Tip.Amount, "invalid tip amount"); custErr != nil {
+			return nil, custErr
+		}
+		total += req.Tip.Amount
+		if custErr := u.validateAmount(total, "invalid transfer amount"); custErr != nil {
+			return nil, custErr
+		}
+	}
+
+	opLock, custErr := u.acquireOperationLock(ctx, userID, "transfer")
+	if custErr != nil {
+		return nil, custErr
+	}
+	defer opLock.release(ctx)
+
+	dedup, custErr := u.claimDedupWindow(ctx, userID, "transfer", req.IdempotencyKey, req)
+	if custErr != nil {
+		return nil, custErr
+	}
+	defer dedup.release(ctx)
+
+	// The sender may have created their wallet moments ago, so look it up
+	// on the primary rather than a replica.
+	sender, err := u.repo.GetByUserID(readpref.WithPrimary(ctx), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+
+	recipient, err := u.repo.GetByID(ctx, req.ToWalletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("recipient wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get recipient wallet")
+	}
+
+	if sender.ID == recipient.ID {
+		return nil, response.BadRequestError("cannot transfer to the same wallet")
+	}
+
+	var tipRecipient *entity.Wallet
+	if req.Tip != nil {
+		if tipRecipient, custErr = u.tipRecipient(ctx, sender, recipient, req.Tip); custErr != nil {
+			return nil, custErr
+		}
+	}
+
+	// Quoted before any row is locked, so that a slow rate provider does not
+	// hold up other operations on these wallets. Wallet currencies never
+	// change, so the quote still applies once locked.
+	quote, custErr := u.quoteTransfer(ctx, sender, recipient, req.Amount)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	// Transfers run at SERIALIZABLE, so that no concurrent write to either
+	// wallet can slip in between reading and updating the balances. Postgres
+	// aborts the losing side of such a conflict; that attempt changed nothing
+	// and is run again from the start.
+	var result *transferResult
+	for attempt := 1; ; attempt++ {
+		result, custErr = u.transferOnce(ctx, userID, sender, recipient, tipRecipient, req, quote)
+		if !isSerializationConflict(custErr) || attempt == serializableAttempts || ctx.Err() != nil {
+			break
+		}
+		u.logger.WithFields(logrus.Fields{
+			"user_id": userID,
+			"attempt": attempt,
+		}).Warn("Retrying transfer after serialization failure")
+	}
+	if custErr != nil {
+		return nil, custErr
+	}
+	out, from, to := result.out, result.from, result.to
+	fromBalance, toBalance := result.fromBalance, result.toBalance
+
+	u.invalidateTransactionCache(ctx, from.UserID)
+	u.invalidateTransactionCache(ctx, to.UserID)
+	if result.tipTo != nil {
+		u.invalidateTransactionCache(ctx, result.tipTo.UserID)
+	}
+
+	fields := logrus.Fields{
+		"event":          eventTransferCompleted,
+		"user_id":        userID,
+		"transaction_id": out.ID,
+		"to_wallet_id":   to.ID,
+		"amount":         req.Amount,
+		"new_balance":    fromBalance,
+	}
+	if result.tipTo != nil {
+		fields["tip_wallet_id"] = result.tipTo.ID
+		fields["tip_amount"] = req.Tip.Amount
+	}
+	u.logger.WithFields(fields).Info("Transfer completed successfully")
+
+	credited := req.Amount
+	if quote != nil {
+		credited = quote.Converted
+	}
+	u.alertIfAboveThreshold(from, entity.TransactionTypeTransferOut, total, fromBalance)
+	u.alertIfAboveThreshold(to, entity.TransactionTypeTransferIn, credited, toBalance)
+	if result.tipTo != nil {
+		u.alertIfAboveThreshold(result.tipTo, entity.TransactionTypeTransferIn, req.Tip.Amount, result.tipBalance)
+	}
+
+	resp := &params.TransferResponse{
+		TransactionID: out.ID,
+		ToWalletID:    to.ID,
+		Amount:        req.Amount,
+		NewBalance:    fromBalance,
+		Status:        out.Status,
+		Timestamp:     out.UpdatedAt,
+
+		ExchangeRate:    out.ExchangeRate,
+		ConvertedAmount: out.ConvertedAmount,
+		Tip:             req.Tip,
+	}
+	dedup.complete()
+
+	return resp, nil
+}
+
+// tipRecipient loads the wallet a tip goes to. Tips are only paid within one
+// currency, so the debit of the total is in the currency of both credits.
+func (u *WalletUsecaseImpl) tipRecipient(ctx context.Context, sender, recipient *entity.Wallet, tip *params.TransferTip) (*entity.Wallet, *response.CustomError) {
+	wallet, err := u.repo.GetByID(ctx, tip.ToWalletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("tip recipient wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get tip recipient wallet")
+	}
+	if wallet.ID == sender.ID {
+		return nil, response.BadRequestError("cannot tip your own wallet")
+	}
+	if wallet.ID == recipient.ID {
+		return nil, response.BadRequestError("tip recipient must differ from the transfer recipient")
+	}
+	if recipient.Currency != sender.Currency || wallet.Currency != sender.Currency {
+		return nil, response.BadRequestError("tipped transfers between different currencies are not supported")
+	}
+	return wallet, nil
+}
+
+// transferResult is what a committed transfer attempt wrote. tipTo is nil
+// for a transfer without a tip.
+type transferResult struct {
+	out                    *entity.Transaction
+	from, to, tipTo        *entity.Wallet
+	fromBalance, toBalance float64
+	tipBalance             float64
+}
+
+// transferOnce makes one attempt at a transfer in its own SERIALIZABLE
+// transaction and commits it. A conflict with a concurrent transaction is
+// reported as serializationConflictError.
+func (u *WalletUsecaseImpl) transferOnce(ctx context.Context, userID uuid.UUID, sender, recipient, tipRecipient *entity.Wallet, req *params.TransferRequest, quote *fxQuote) (*transferResult, *response.CustomError) {
+	tx := u.repo.BeginTxWithOptions(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if tx.Error != nil {
+		u.logger.WithError(tx.Error).WithField("event", eventTransferFailed).Error("Failed to begin transaction")
+		return nil, response.GeneralError("failed to begin transaction")
+	}
+	txRepo := u.repo.WithTx(tx)
+	defer tx.Rollback()
+
+	wallets := []*entity.Wallet{sender, recipient}
+	if tipRecipient != nil {
+		wallets = append(wallets, tipRecipient)
+	}
+	locked, err := lockWallets(wallets, func(userID uuid.UUID) (*entity.Wallet, error) {
+		return txRepo.GetByUserIDForUpdate(ctx, tx, userID)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrLockTimeout) {
+			return nil, walletBusyError()
+		}
+		if repository.IsSerializationFailure(err) {
+			return nil, serializationConflictError()
+		}
+		u.logger.WithError(err).WithFields(logrus.Fields{"event": eventTransferFailed, "user_id": userID}).Error("Failed to lock wallets for transfer")
+		return nil, response.RepositoryError("failed to lock wallets")
+	}
+	from, to := locked[sender.ID], locked[recipient.ID]
+
+	credits := []transferCredit{{to: to, amount: req.Amount, quote: quote}}
+	var tipTo *entity.Wallet
+	if tipRecipient != nil {
+		tipTo = locked[tipRecipient.ID]
+		credits = append(credits, transferCredit{to: tipTo, amount: req.Tip.Amount})
+	}
+	if custErr := u.loadClearingBalance(ctx, tx, txRepo, from); custErr != nil {
+		return nil, custErr
+	}
+
+	total := 0.0
+	for _, c := range credits {
+		if custErr := checkTransferOperations(from, c.to); custErr != nil {
+			return nil, custErr
+		}
+		if custErr := checkTransferSoftLocks(from, c.to); custErr != nil {
+			return nil, custErr
+		}
+		total += c.amount
+	}
+
+	if from.AvailableBalance() < total {
+		u.logger.WithFields(logrus.Fields{
+			"event":             eventTransferInsufficientBalance,
+			"user_id":           userID,
+			"current_balance":   from.Balance,
+			"available_balance": from.AvailableBalance(),
+			"transfer_amount":   total,
+		}).Warn("Insufficient balance for transfer")
+		return nil, response.BadRequestError("insufficient balance")
+	}
+
+	out, fromBalance, balances, custErr := u.applySplitTransfer(ctx, tx, txRepo, from, credits, req.Description)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		if repository.IsSerializationFailure(err) {
+			return nil, serializationConflictError()
+		}
+		u.logger.WithError(err).WithField("event", eventTransferFailed).Error("Failed to commit transaction")
+		return nil, response.RepositoryError("failed to commit transaction")
+	}
+
+	result := &transferResult{out: out, from: from, to: to, fromBalance: fromBalance, toBalance: balances[0]}
+	if tipTo != nil {
+		result.tipTo, result.tipBalance = tipTo, balances[1]
+	}
+	return result, nil
+}
+
+// applyTransfer writes both legs of a transfer from one locked wallet to
+// another and updates both balances within tx. Callers check funds
+// beforehand. Between currencies, quote gives the amount credited; it must be
+// nil for wallets in the same currency. It returns the sender's leg and both
+// new balances.
+func (u *WalletUsecaseImpl) applyTransfer(ctx context.Context, tx *gorm.DB, txRepo repository.WalletRepository, from, to *entity.Wallet, amount float64, quote *fxQuote, description string) (*entity.Transaction, float64, float64, *response.CustomError) {
+	out, fromBalance, balances, custErr := u.applySplitTransfer(ctx, tx, txRepo, from, []transferCredit{{to: to, amount: amount, quote: quote}}, description)
+	if custErr != nil {
+		return nil, 0, 0, custErr
+	}
+	return out, fromBalance, balances[0], nil
+}
+
+// transferCredit is the share of a transfer paid to one recipient. amount is
+// what the sender pays for it; quote converts it for a recipient in another
+// currency and is nil otherwise.
+type transferCredit struct {
+	to     *entity.Wallet
+	amount float64
+	quote  *fxQuote
+}
+
+// applySplitTransfer debits the locked sender once for the sum of credits and
+// credits each recipient within tx, linking every leg by the id of the
+// sender's. Callers check funds beforehand. It returns the sender's leg, the
+// sender's new balance and the recipients' new balances in the order of
+// credits.
+//
+// The exchange details of a conversion are recorded on the sender's leg only
+// when it pays a single recipient; split transfers are in one currency.
+func (u *WalletUsecaseImpl) applySplitTransfer(ctx context.Context, tx *gorm.DB, txRepo repository.WalletRepository, from *entity.Wallet, credits []transferCredit, description string) (*entity.Transaction, float64, []float64, *response.CustomError) {
+	total := 0.0
+	for _, c := range credits {
+		total += c.amount
+	}
+	fromBalance := from.Balance - total
+	now := time.Now()
+
+	out := &entity.Transaction{
+		ID:           uuid.New(),
+		WalletID:     from.ID,
+		Type:         entity.TransactionTypeTransferOut,
+		Amount:       total,
+		Status:       entity.TransactionStatusCompleted,
+		Description:  transferDescription(description, "Transfer to", credits[0].to.ID),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		BalanceAfter: &fromBalance,
+	}
+	out.TransferID = &out.ID
+	legs := []*entity.Transaction{out}
+
+	balances := make([]float64, len(credits))
+	for i, c := range credits {
+		amount, credit := c.amount, c.amount
+		if c.quote != nil {
+			credit = c.quote.Converted
+		}
+		balances[i] = c.to.Balance + credit
+		if balances[i] > MaxStorableAmount {
+			return nil, 0, nil, response.UnprocessableEntityError("transfer would exceed the recipient's maximum wallet balance")
+		}
+
+		in := &entity.Transaction{
+			ID:           uuid.New(),
+			WalletID:     c.to.ID,
+			Type:         entity.TransactionTypeTransferIn,
+			Amount:       credit,
+			Status:       entity.TransactionStatusCompleted,
+			Description:  transferDescription(description, "Transfer from", from.ID),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			BalanceAfter: &balances[i],
+			TransferID:   &out.ID,
+		}
+		if c.quote != nil {
+			in.ExchangeRate, in.OriginalAmount, in.ConvertedAmount = &c.quote.Rate, &amount, &c.quote.Converted
+			if len(credits) == 1 {
+				out.ExchangeRate, out.OriginalAmount, out.ConvertedAmount = &c.quote.Rate, &amount, &c.quote.Converted
+			}
+		}
+		legs = append(legs, in)
+	}
+
+	for _, t := range legs {
+		if err := txRepo.CreateTransaction(ctx, tx, t); err != nil {
+			if repository.IsSerializationFailure(err) {
+				return nil, 0, nil, serializationConflictError()
+			}
+			u.logger.WithError(err).WithField("event", eventTransferFailed).Error("Failed to create transfer transaction")
+			return nil, 0, nil, response.RepositoryError("failed to create transaction")
+		}
+	}
+
+	if err := txRepo.UpdateBalance(ctx, tx, from.ID, fromBalance, from.Version+1); err != nil {
+		if repository.IsSerializationFailure(err) {
+			return nil, 0, nil, serializationConflictError()
+		}
+		u.logger.WithError(err).WithField("event", eventTransferFailed).Error("Failed to update sender balance")
+		return nil, 0, nil, response.RepositoryError("failed to update wallet balance")
+	}
+	for i, c := range credits {
+		if err := txRepo.UpdateBalance(ctx, tx, c.to.ID, balances[i], c.to.Version+1); err != nil {
+			if repository.IsSerializationFailure(err) {
+				return nil, 0, nil, serializationConflictError()
+			}
+			u.logger.WithError(err).WithField("event", eventTransferFailed).Error("Failed to update recipient balance")
+			return nil, 0, nil, response.RepositoryError("failed to update wallet balance")
+		}
+	}
+
+	return out, fromBalance, balances, nil
+}
+
+// checkTransferSoftLocks rejects a transfer when either wallet is
+// soft-locked. The recipient's expiry is not disclosed to the sender.
+func checkTransferSoftLocks(from, to *entity.Wallet) *response.CustomError {
+	now := time.Now()
+	if custErr := checkSoftLock(from, now); custErr != nil {
+		return custErr
+	}
+	if to.SoftLocked(now) {
+		return response.ForbiddenError("recipient wallet cannot receive funds right now")
+	}
+	return nil
+}
+
+func transferDescription(description, direction string, counterparty uuid.UUID) string {
+	if description != "" {
+		return description
+	}
+	return fmt.Sprintf("%s wallet %s", direction, counterparty)
+}
@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// velocityCacheTTL is how long a wallet's velocity is served from the cache.
+// Writes do not invalidate it; analysts reviewing a wallet get figures at
+// most this old.
+const velocityCacheTTL = 30 * time.Second
+
+func velocityCacheKey(walletID uuid.UUID) string {
+	return "wallets:velocity:" + walletID.String()
+}
+
+// GetTransactionVelocity returns how many completed transactions the wallet
+// made, and for how much, within the last hour, day and week, for admins
+// reviewing it for fraud.
+func (u *WalletUsecaseImpl) GetTransactionVelocity(ctx context.Context, walletID uuid.UUID) (*params.TransactionVelocityResponse, *response.CustomError) {
+	cacheKey := velocityCacheKey(walletID)
+	if val, err := u.cacheGet(ctx, cacheKey); err == nil {
+		var cached params.TransactionVelocityResponse
+		if json.Unmarshal([]byte(val), &cached) == nil {
+			return &cached, nil
+		}
+	}
+
+	wallet, err := u.repo.GetByID(ctx, walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+
+	now := time.Now()
+	velocity, err := u.repo.GetTransactionVelocity(ctx, walletID, now)
+	if err != nil {
+		return nil, response.RepositoryError("failed to get transaction velocity")
+	}
+
+	resp := &params.TransactionVelocityResponse{
+		WalletID:    wallet.ID,
+		Currency:    wallet.Currency,
+		LastHour:    params.VelocityWindowResponse{Count: velocity.HourCount, Total: velocity.HourTotal},
+		LastDay:     params.VelocityWindowResponse{Count: velocity.DayCount, Total: velocity.DayTotal},
+		LastWeek:    params.VelocityWindowResponse{Count: velocity.WeekCount, Total: velocity.WeekTotal},
+		GeneratedAt: now,
+	}
+
+	if data, err := json.Marshal(resp); err == nil {
+		if err := u.cacheSet(ctx, cacheKey, data, velocityCacheTTL); err != nil {
+			u.logger.WithError(err).WithField("wallet_id", walletID).Warn("Failed to cache transaction velocity")
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"go-digital-wallet/internal/params"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// recordWalletEvent stores a state change of the wallet within tx, so that
+// the event is only kept if the change itself is committed.
+func (u *WalletUsecaseImpl) recordWalletEvent(ctx context.Context, tx *gorm.DB, walletID, actorID uuid.UUID, eventType entity.WalletEventType, before, after interface{}) error {
+	event := &entity.WalletEvent{
+		ID:       uuid.New(),
+		WalletID: walletID,
+		Type:     eventType,
+		ActorID:  actorID,
+	}
+
+	var err error
+	if event.Before, err = marshalEventState(before); err != nil {
+		return err
+	}
+	if event.After, err = marshalEventState(after); err != nil {
+		return err
+	}
+
+	return u.repo.CreateWalletEvent(ctx, tx, event)
+}
+
+func marshalEventState(state interface{}) (*string, error) {
+	if state == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
+
+// GetWalletEvents returns the change log of a wallet, newest first. Only the
+// owner and admins may read it; for anyone else the wallet is reported as not
+// found so that wallet ids cannot be probed.
+func (u *WalletUsecaseImpl) GetWalletEvents(ctx context.Context, userID, walletID uuid.UUID, isAdmin bool, limit, offset int) (*params.WalletEventsResponse, *response.CustomError) {
+	wallet, err := u.repo.GetByID(ctx, walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+	if wallet.UserID != userID && !isAdmin {
+		return nil, response.NotFoundError("wallet not found")
+	}
+
+	events, err := u.repo.GetWalletEvents(ctx, walletID, limit, offset)
+	if err != nil {
+		return nil, response.RepositoryError("failed to get wallet events")
+	}
+	total, err := u.repo.CountWalletEvents(ctx, walletID)
+	if err != nil {
+		return nil, response.RepositoryError("failed to count wallet events")
+	}
+
+	resp := &params.WalletEventsResponse{
+		WalletID: walletID,
+		Events:   make([]*params.WalletEventResponse, 0, len(events)),
+		Total:    total,
+		Limit:    limit,
+	}
+	resp.Page, resp.TotalPages = paginate(total, (offset/limit)+1, limit)
+	for _, event := range events {
+		item := &params.WalletEventResponse{
+			ID:        event.ID,
+			Type:      string(event.Type),
+			ActorID:   event.ActorID,
+			CreatedAt: event.CreatedAt,
+		}
+		if event.Before != nil {
+			item.Before = json.RawMessage(*event.Before)
+		}
+		if event.After != nil {
+			item.After = json.RawMessage(*event.After)
+		}
+		resp.Events = append(resp.Events, item)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// lockWallets row-locks every wallet through lock, always in ascending
+// wallet id order, and returns the locked rows keyed by wallet id.
+//
+// Any operation that locks more than one wallet must go through here. With a
+// single global order two concurrent operations over the same wallets (such
+// as transfers A->B and B->A) contend on the same first row, instead of each
+// holding one lock while waiting for the other and deadlocking in Postgres.
+// The order matches Postgres' own uuid ordering.
+func lockWallets(wallets []*entity.Wallet, lock func(userID uuid.UUID) (*entity.Wallet, error)) (map[uuid.UUID]*entity.Wallet, error) {
+	ordered := make([]*entity.Wallet, len(wallets))
+	copy(ordered, wallets)
+	sort.Slice(ordered, func(i, j int) bool {
+		return bytes.Compare(ordered[i].ID[:], ordered[j].ID[:]) < 0
+	})
+
+	locked := make(map[uuid.UUID]*entity.Wallet, len(ordered))
+	for _, w := range ordered {
+		if _, ok := locked[w.ID]; ok {
+			continue
+		}
+
+		l, err := lock(w.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if l.ID != w.ID {
+			return nil, fmt.Errorf("wallet %s changed while locking", w.ID)
+		}
+		locked[w.ID] = l
+	}
+
+	return locked, nil
+}
+
+// walletBusyError reports that a wallet stayed locked by another operation
+// past the lock timeout. Nothing was changed and the client may retry.
+func walletBusyError() *response.CustomError {
+	return response.ConflictError("wallet is busy, please retry")
+}
+
+// serializableAttempts is how many times an operation run at SERIALIZABLE is
+// attempted before a serialization failure is returned to the client.
+const serializableAttempts = 3
+
+// serializationConflictError reports that Postgres aborted a SERIALIZABLE
+// transaction because a concurrent one touched the same wallets. Nothing was
+// changed and the operation may be retried.
+func serializationConflictError() *response.CustomError {
+	return response.ConflictErrorWithAdditionalInfo(
+		map[string]interface{}{"reason": "serialization_failure"},
+		"wallet was changed concurrently, please retry",
+	)
+}
+
+func isSerializationConflict(err *response.CustomError) bool {
+	if err == nil {
+		return false
+	}
+	info, ok := err.AdditionalInfo.(map[string]interface{})
+	return ok && info["reason"] == "serialization_failure"
+}
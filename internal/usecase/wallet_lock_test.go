@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"go-digital-wallet/internal/entity"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func lockTestWallets() (*entity.Wallet, *entity.Wallet) {
+	low := &entity.Wallet{ID: uuid.MustParse("00000000-0000-0000-0000-000000000001"), UserID: uuid.New()}
+	high := &entity.Wallet{ID: uuid.MustParse("ffffffff-0000-0000-0000-000000000000"), UserID: uuid.New()}
+	return low, high
+}
+
+func TestLockWallets_AscendingOrderRegardlessOfDirection(t *testing.T) {
+	low, high := lockTestWallets()
+	byUser := map[uuid.UUID]*entity.Wallet{low.UserID: low, high.UserID: high}
+
+	for _, wallets := range [][]*entity.Wallet{{low, high}, {high, low}} {
+		var order []uuid.UUID
+		locked, err := lockWallets(wallets, func(userID uuid.UUID) (*entity.Wallet, error) {
+			order = append(order, byUser[userID].ID)
+			return byUser[userID], nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{low.ID, high.ID}, order)
+		assert.Same(t, low, locked[low.ID])
+		assert.Same(t, high, locked[high.ID])
+	}
+}
+
+func TestLockWallets_ReciprocalTransfersDoNotDeadlock(t *testing.T) {
+	low, high := lockTestWallets()
+	byUser := map[uuid.UUID]*entity.Wallet{low.UserID: low, high.UserID: high}
+	// Stand-ins for the Postgres row locks, held until the "transaction" ends.
+	rowLocks := map[uuid.UUID]*sync.Mutex{low.UserID: {}, high.UserID: {}}
+
+	transfer := func(from, to *entity.Wallet) {
+		var held []*sync.Mutex
+		_, err := lockWallets([]*entity.Wallet{from, to}, func(userID uuid.UUID) (*entity.Wallet, error) {
+			rowLocks[userID].Lock()
+			held = append(held, rowLocks[userID])
+			// Widen the window between the two lock acquisitions.
+			time.Sleep(time.Microsecond)
+			return byUser[userID], nil
+		})
+		assert.NoError(t, err)
+		for _, m := range held {
+			m.Unlock()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 200; i++ {
+			wg.Add(2)
+			go func() { defer wg.Done(); transfer(low, high) }()
+			go func() { defer wg.Done(); transfer(high, low) }()
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reciprocal transfers deadlocked")
+	}
+}
+
+func TestLockWallets_SameWalletLockedOnce(t *testing.T) {
+	low, _ := lockTestWallets()
+	calls := 0
+
+	locked, err := lockWallets([]*entity.Wallet{low, low}, func(userID uuid.UUID) (*entity.Wallet, error) {
+		calls++
+		return low, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, locked, 1)
+}
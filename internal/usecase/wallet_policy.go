@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/entity"
+)
+
+// walletOperation is a money movement the API performs on a wallet.
+type walletOperation string
+
+const (
+	operationDeposit     walletOperation = "deposit"
+	operationWithdraw    walletOperation = "withdraw"
+	operationTransferOut walletOperation = "transfer_out"
+	operationTransferIn  walletOperation = "transfer_in"
+)
+
+// walletTypeOperations lists the operations each wallet type allows through
+// the API. The system account is only ever moved by the ledger legs booked
+// alongside user deposits and withdrawals, which do not go through here.
+var walletTypeOperations = map[entity.WalletType]map[walletOperation]bool{
+	entity.WalletTypePersonal: {
+		operationDeposit:     true,
+		operationWithdraw:    true,
+		operationTransferOut: true,
+		operationTransferIn:  true,
+	},
+	entity.WalletTypeMerchant: {
+		operationDeposit:     true,
+		operationWithdraw:    true,
+		operationTransferOut: true,
+		operationTransferIn:  true,
+	},
+	entity.WalletTypeSystem: {},
+}
+
+// walletType returns the type of wallet. Wallets without one predate wallet
+// types and are personal.
+func walletType(wallet *entity.Wallet) entity.WalletType {
+	if wallet.Type == "" {
+		return entity.WalletTypePersonal
+	}
+	return wallet.Type
+}
+
+func allowsOperation(wallet *entity.Wallet, op walletOperation) bool {
+	return walletTypeOperations[walletType(wallet)][op]
+}
+
+// checkWalletOperation rejects op on the caller's own wallet when its type
+// does not allow it.
+func checkWalletOperation(wallet *entity.Wallet, op walletOperation) *response.CustomError {
+	if allowsOperation(wallet, op) {
+		return nil
+	}
+	kind := walletType(wallet)
+	return response.ForbiddenErrorWithAdditionalInfo(
+		map[string]string{"wallet_type": string(kind), "operation": string(op)},
+		fmt.Sprintf("%s wallets do not allow %s", kind, op),
+	)
+}
+
+// checkTransferOperations rejects a transfer the type of either wallet does
+// not allow. The recipient's type is not disclosed to the sender.
+func checkTransferOperations(from, to *entity.Wallet) *response.CustomError {
+	if custErr := checkWalletOperation(from, operationTransferOut); custErr != nil {
+		return custErr
+	}
+	if !allowsOperation(to, operationTransferIn) {
+		return response.ForbiddenError("recipient wallet cannot receive transfers")
+	}
+	return nil
+}
@@ -5,71 +5,405 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go-digital-wallet/internal/commons/inflight"
+	"go-digital-wallet/internal/commons/readpref"
 	"go-digital-wallet/internal/commons/response"
 	"go-digital-wallet/internal/entity"
 	"go-digital-wallet/internal/params"
 	"go-digital-wallet/internal/repository"
+	"go-digital-wallet/pkg/displayid"
+	"go-digital-wallet/pkg/featureflag"
+	"go-digital-wallet/pkg/fxrate"
+	"go-digital-wallet/pkg/money"
+	"go-digital-wallet/pkg/notify"
+	"go-digital-wallet/pkg/statementsig"
+	"io"
 	"math"
+	"strconv"
+	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 type WalletUsecase interface {
 	CreateWallet(ctx context.Context, req *params.CreateWalletRequest) (*params.WalletResponse, *response.CustomError)
-	GetBalance(ctx context.Context, userID uuid.UUID) (*params.BalanceResponse, *response.CustomError)
+	EnsureWallet(ctx context.Context, req *params.CreateWalletRequest) (*params.EnsureWalletResponse, *response.CustomError)
+	GetBalance(ctx context.Context, userID uuid.UUID, includeCount bool) (*params.BalanceResponse, *response.CustomError)
+	ListHolds(ctx context.Context, userID uuid.UUID) (*params.HoldsResponse, *response.CustomError)
+	WalletExists(ctx context.Context, userID uuid.UUID) (*params.WalletExistsResponse, *response.CustomError)
 	Withdraw(ctx context.Context, userID uuid.UUID, req *params.WithdrawRequest) (*params.WithdrawResponse, *response.CustomError)
+	InitiateWithdraw(ctx context.Context, userID uuid.UUID, req *params.WithdrawRequest) (*params.WithdrawInitiateResponse, *response.CustomError)
+	ConfirmWithdraw(ctx context.Context, userID uuid.UUID, req *params.ConfirmWithdrawRequest) (*params.WithdrawResponse, *response.CustomError)
 	Deposit(ctx context.Context, userID uuid.UUID, req *params.DepositRequest) (*params.DepositResponse, *response.CustomError)
-	GetTransactionHistory(ctx context.Context, userID uuid.UUID, limit, offset int) (*params.TransactionHistoryResponse, *response.CustomError)
+	Transfer(ctx context.Context, userID uuid.UUID, req *params.TransferRequest) (*params.TransferResponse, *response.CustomError)
+	CreateTransferRequest(ctx context.Context, userID uuid.UUID, req *params.PendingTransferRequest) (*params.TransferRequestResponse, *response.CustomError)
+	ApproveTransferRequest(ctx context.Context, approverID, requestID uuid.UUID) (*params.TransferRequestResponse, *response.CustomError)
+	RejectTransferRequest(ctx context.Context, approverID, requestID uuid.UUID) (*params.TransferRequestResponse, *response.CustomError)
+	ExpireTransferRequests(ctx context.Context) (int, error)
+	RetryCacheInvalidations(ctx context.Context) int
+	GetTotalBalance(ctx context.Context) (*params.TotalBalanceResponse, *response.CustomError)
+	GetTransactionVelocity(ctx context.Context, walletID uuid.UUID) (*params.TransactionVelocityResponse, *response.CustomError)
+	ListPlatformTransactions(ctx context.Context, limit, offset int, filter params.TransactionHistoryFilter) (*params.PlatformTransactionsResponse, *response.CustomError)
+	GetTransaction(ctx context.Context, userID uuid.UUID, id string) (*params.TransactionResponse, *response.CustomError)
+	GetTransactionHistory(ctx context.Context, userID uuid.UUID, limit, offset int, filter params.TransactionHistoryFilter) (*params.TransactionHistoryResponse, *response.CustomError)
+	GetInsights(ctx context.Context, userID uuid.UUID, granularity string, filter params.TransactionHistoryFilter) (*params.InsightsResponse, *response.CustomError)
+	SetAlertThreshold(ctx context.Context, userID uuid.UUID, req *params.AlertThresholdRequest) (*params.AlertThresholdResponse, *response.CustomError)
+	SoftLockWallet(ctx context.Context, actorID, walletID uuid.UUID, req *params.SoftLockRequest) (*params.SoftLockResponse, *response.CustomError)
+	ClearSoftLock(ctx context.Context, actorID, walletID uuid.UUID) (*params.SoftLockResponse, *response.CustomError)
+	CreatePayoutDestination(ctx context.Context, userID uuid.UUID, req *params.PayoutDestinationRequest) (*params.PayoutDestinationResponse, *response.CustomError)
+	ListPayoutDestinations(ctx context.Context, userID uuid.UUID) ([]*params.PayoutDestinationResponse, *response.CustomError)
+	DeletePayoutDestination(ctx context.Context, userID, destinationID uuid.UUID) *response.CustomError
+	VerifyPayoutDestination(ctx context.Context, adminID, destinationID uuid.UUID) (*params.PayoutDestinationResponse, *response.CustomError)
+	GetBalanceAt(ctx context.Context, userID, walletID uuid.UUID, at time.Time) (*params.BalanceAtResponse, *response.CustomError)
+	GetBalanceHistory(ctx context.Context, userID, walletID uuid.UUID, granularity string, from, to *time.Time) (*params.BalanceHistoryResponse, *response.CustomError)
+	ExportStatement(ctx context.Context, userID uuid.UUID, format string, filter params.TransactionHistoryFilter, sign bool) (*params.StatementFile, *response.CustomError)
+	VerifyStatement(ctx context.Context, content []byte, signature *params.StatementSignature) (*params.StatementVerification, *response.CustomError)
+	GetActivity(ctx context.Context, userID uuid.UUID, limit, offset int, filter params.TransactionHistoryFilter) (*params.ActivityResponse, *response.CustomError)
+	GetWalletEvents(ctx context.Context, userID, walletID uuid.UUID, isAdmin bool, limit, offset int) (*params.WalletEventsResponse, *response.CustomError)
+	ImportTransactions(ctx context.Context, adminID uuid.UUID, format string, r io.Reader, dryRun bool) (*params.TransactionImportReport, *response.CustomError)
+}
+
+// totalBalanceCacheKey caches the system-wide balance totals. The full table
+// aggregate is only refreshed once totalBalanceCacheTTL passes, so writes do
+// not invalidate it.
+const (
+	totalBalanceCacheKey = "wallets:total_balance"
+	totalBalanceCacheTTL = 30 * time.Second
+)
+
+// notifyTimeout bounds how long a background notification may take.
+const notifyTimeout = 10 * time.Second
+
+// insightGranularities are the bucket sizes accepted by GetInsights.
+var insightGranularities = map[string]bool{
+	"day":   true,
+	"week":  true,
+	"month": true,
+}
+
+// WalletUsecaseConfig holds the tunable behaviour of the wallet usecase. The
+// zero value matches the default behaviour.
+type WalletUsecaseConfig struct {
+	// TransactionRetention is how long transactions stay in the hot table
+	// before being archived. Zero means archiving is disabled.
+	TransactionRetention time.Duration
+	// AlertThreshold is the default transaction amount above which the user
+	// is notified, for wallets without their own threshold. Zero disables the
+	// default alert.
+	AlertThreshold float64
+	// MaxAmount is the largest amount a single deposit, withdrawal or
+	// transfer may carry. Zero uses MaxStorableAmount.
+	MaxAmount float64
+	// DefaultCurrency is used for new wallets when the request names none.
+	// Empty makes the currency required.
+	DefaultCurrency string
+	// TransferRequestTTL is how long a transfer request may wait for
+	// approval before its hold is released, unless the request sets its own
+	// window. Zero uses 24 hours.
+	TransferRequestTTL time.Duration
+	// Rounding brings converted amounts back to whole cents. Empty rounds
+	// half up.
+	Rounding money.RoundingMode
+	// TransferRequestMaxTTL is the longest window a transfer request may set
+	// for itself. Zero uses 7 days.
+	TransferRequestMaxTTL time.Duration
+	// DisplayIDPrefix starts the short transaction ids shown to users. Empty
+	// uses "TXN".
+	DisplayIDPrefix string
+	// SystemWalletID is the system account every deposit and withdrawal is
+	// balanced against. uuid.Nil disables the system account.
+	SystemWalletID uuid.UUID
+	// DedupWindow is how long an identical deposit, withdrawal or transfer
+	// sent without an Idempotency-Key is rejected as a suspected duplicate.
+	// Zero disables the check.
+	DedupWindow time.Duration
+	// Flags switches optional features. Nil uses featureflag.Defaults.
+	Flags *featureflag.Flags
+	// RateProvider quotes exchange rates for transfers between wallets of
+	// different currencies. Nil rejects such transfers.
+	RateProvider fxrate.Provider
+	// MinimumBalances is the balance, per currency code, a withdrawal must
+	// leave in the wallet. Currencies not listed have no floor.
+	MinimumBalances map[string]float64
+	// MinimumDeposits is the smallest deposit accepted, per currency code.
+	// Currencies not listed accept any positive amount.
+	MinimumDeposits map[string]float64
+	// OperationLockTTL enables the per-user lock that rejects a deposit,
+	// withdrawal or transfer while another one of the user is in flight. It
+	// is how long a lock outlives a crashed holder. Zero disables the lock.
+	OperationLockTTL time.Duration
+	// CacheInvalidationRetries is how many more times a failed invalidation
+	// of a user's transaction cache is tried, with a growing backoff, before
+	// giving up on it for the request.
+	CacheInvalidationRetries int
+	// QueueFailedInvalidations keeps the users whose invalidation still
+	// failed for RetryCacheInvalidations. Leave it off unless a worker calls
+	// that method.
+	QueueFailedInvalidations bool
+	// DepositHold is how long deposited funds stay on hold before they can
+	// be withdrawn or transferred. Zero makes deposits available at once.
+	DepositHold time.Duration
+	// WithdrawConfirmationThreshold is the amount from which a withdrawal
+	// must be initiated and then confirmed with a single-use token. Zero
+	// executes every withdrawal at once.
+	WithdrawConfirmationThreshold float64
+	// WithdrawConfirmationTTL is how long a confirmation token stays valid.
+	// Zero uses two minutes.
+	WithdrawConfirmationTTL time.Duration
+	// InFlight tracks the money movements in progress, so shutdown can let
+	// them finish. Nil tracks nothing.
+	InFlight *inflight.Tracker
+	// DescriptionTemplate generates the description of a deposit or
+	// withdrawal sent without one. Nil stores an empty description.
+	DescriptionTemplate *template.Template
+	// StatementSigner signs exported statements on request and verifies
+	// them. Nil disables signing.
+	StatementSigner statementsig.Signer
+}
+
+// MaxStorableAmount is the largest value the decimal(15,2) amount and balance
+// columns can hold.
+const MaxStorableAmount = 9999999999999.99
+
+// validateAmount rejects amounts the database could not store exactly, so
+// they fail with a clear error rather than overflowing or being rounded.
+func (u *WalletUsecaseImpl) validateAmount(amount float64, message string) *response.CustomError {
+	if amount <= 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return response.BadRequestError(message)
+	}
+	limit := u.config.MaxAmount
+	if limit <= 0 || limit > MaxStorableAmount {
+		limit = MaxStorableAmount
+	}
+	if amount > limit {
+		return response.BadRequestErrorWithAdditionalInfo(map[string]float64{"max_amount": limit}, fmt.Sprintf("amount exceeds the maximum of %.2f", limit))
+	}
+	return nil
+}
+
+// checkCurrency rejects an operation naming a currency other than the
+// wallet's. An empty currency means the wallet's own.
+func checkCurrency(wallet *entity.Wallet, currency string) *response.CustomError {
+	if currency == "" || strings.EqualFold(currency, wallet.Currency) {
+		return nil
+	}
+	return response.BadRequestErrorWithAdditionalInfo(
+		map[string]string{"wallet_currency": wallet.Currency, "currency": currency},
+		fmt.Sprintf("currency %s does not match the wallet currency %s", currency, wallet.Currency),
+	)
+}
+
+// checkMinimumBalance rejects a withdrawal that would leave less than the
+// floor configured for the wallet's currency, naming how much can still be
+// withdrawn.
+func (u *WalletUsecaseImpl) checkMinimumBalance(wallet *entity.Wallet, amount float64) *response.CustomError {
+	floor := u.config.MinimumBalances[strings.ToUpper(wallet.Currency)]
+	if floor <= 0 || math.Round((wallet.AvailableBalance()-amount)*100)/100 >= floor {
+		return nil
+	}
+	withdrawable := math.Max(0, math.Round((wallet.AvailableBalance()-floor)*100)/100)
+	return response.UnprocessableEntityErrorWithAdditionalInfo(
+		map[string]float64{"minimum_balance": floor, "max_withdrawable": withdrawable},
+		fmt.Sprintf("withdrawal would leave less than the minimum balance of %.2f %s; at most %.2f can be withdrawn", floor, wallet.Currency, withdrawable),
+	)
+}
+
+// checkMinimumDeposit rejects a deposit below the minimum configured for the
+// wallet's currency. It applies on top of the per-transaction maximum.
+func (u *WalletUsecaseImpl) checkMinimumDeposit(wallet *entity.Wallet, amount float64) *response.CustomError {
+	minimum := u.config.MinimumDeposits[strings.ToUpper(wallet.Currency)]
+	if minimum <= 0 || amount >= minimum {
+		return nil
+	}
+	return response.BadRequestErrorWithAdditionalInfo(
+		map[string]float64{"minimum_deposit": minimum},
+		fmt.Sprintf("deposit is below the minimum of %.2f %s", minimum, wallet.Currency),
+	)
+}
+
+// checkSoftLock rejects moving money in or out of a wallet under an active
+// soft-lock. Callers check it on the locked row so that a lock placed
+// concurrently is always seen.
+func checkSoftLock(wallet *entity.Wallet, now time.Time) *response.CustomError {
+	if !wallet.SoftLocked(now) {
+		return nil
+	}
+	return response.ForbiddenErrorWithAdditionalInfo(
+		map[string]time.Time{"locked_until": *wallet.LockedUntil},
+		"wallet is temporarily locked",
+	)
 }
 
 type WalletUsecaseImpl struct {
-	repo   repository.WalletRepository
-	logger *logrus.Logger
-	mutex  sync.RWMutex
-	cache  *redis.Client
+	repo     repository.WalletRepository
+	logger   *logrus.Logger
+	mutex    sync.RWMutex
+	cache    *redis.Client
+	notifier notify.Notifier
+	config   WalletUsecaseConfig
+
+	displayIDs displayid.Format
+
+	// balanceReads coalesces concurrent balance reads of the same user.
+	balanceReads singleflight.Group
+
+	// pendingInvalidations are the users whose transaction cache could not
+	// be invalidated yet.
+	pendingMu            sync.Mutex
+	pendingInvalidations map[uuid.UUID]bool
 }
 
-func NewWalletUsecase(repo repository.WalletRepository, logger *logrus.Logger, cache *redis.Client) WalletUsecase {
+func NewWalletUsecase(repo repository.WalletRepository, logger *logrus.Logger, cache *redis.Client, notifier notify.Notifier, config WalletUsecaseConfig) WalletUsecase {
+	if notifier == nil {
+		notifier = notify.NewNoopNotifier()
+	}
 	return &WalletUsecaseImpl{
-		repo:   repo,
-		logger: logger,
-		cache:  cache,
+		repo:     repo,
+		logger:   logger,
+		cache:    cache,
+		notifier: notifier,
+		config:   config,
+
+		displayIDs: displayid.New(config.DisplayIDPrefix),
 	}
 }
 
 func (u *WalletUsecaseImpl) CreateWallet(ctx context.Context, req *params.CreateWalletRequest) (*params.WalletResponse, *response.CustomError) {
+	if req.Currency == "" {
+		if u.config.DefaultCurrency == "" {
+			return nil, response.BadRequestError("currency is required")
+		}
+		req.Currency = u.config.DefaultCurrency
+	}
+
 	wallet := &entity.Wallet{
 		UserID:   req.UserID,
 		Balance:  0.0,
 		Currency: req.Currency,
 		Version:  1,
+		Type:     entity.WalletTypePersonal,
 	}
 
 	if err := u.repo.Create(ctx, wallet); err != nil {
-		u.logger.WithError(err).Error("Failed to create wallet")
+		if errors.Is(err, repository.ErrWalletExists) {
+			return nil, response.ConflictError("user already has a wallet; a user may only have one")
+		}
+		u.logger.WithError(err).WithField("event", eventWalletCreateFailed).Error("Failed to create wallet")
 		return nil, response.RepositoryError("failed to create wallet")
 	}
 
+	u.logger.WithFields(logrus.Fields{
+		"event":     eventWalletCreated,
+		"user_id":   wallet.UserID,
+		"wallet_id": wallet.ID,
+		"currency":  wallet.Currency,
+	}).Info("Wallet created")
+
+	return toWalletResponse(wallet), nil
+}
+
+// EnsureWallet returns the user's wallet, creating it first if there is none,
+// so that onboarding needs a single idempotent call. Concurrent calls create
+// one wallet between them; the others return it as existing.
+func (u *WalletUsecaseImpl) EnsureWallet(ctx context.Context, req *params.CreateWalletRequest) (*params.EnsureWalletResponse, *response.CustomError) {
+	// Read from the primary so that a wallet created moments ago is found.
+	ctx = readpref.WithPrimary(ctx)
+
+	wallet, custErr := u.existingWallet(ctx, req)
+	if custErr != nil {
+		return nil, custErr
+	}
+	if wallet != nil {
+		return &params.EnsureWalletResponse{WalletResponse: toWalletResponse(wallet)}, nil
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = u.config.DefaultCurrency
+	}
+	if currency == "" {
+		return nil, response.BadRequestError("currency is required")
+	}
+
+	wallet = &entity.Wallet{
+		UserID:   req.UserID,
+		Currency: currency,
+		Version:  1,
+		Type:     entity.WalletTypePersonal,
+	}
+	created, err := u.repo.CreateIfAbsent(ctx, wallet)
+	if err != nil {
+		u.logger.WithError(err).WithField("event", eventWalletCreateFailed).Error("Failed to create wallet")
+		return nil, response.RepositoryError("failed to create wallet")
+	}
+	if !created {
+		// A concurrent call created it first.
+		wallet, custErr = u.existingWallet(ctx, req)
+		if custErr != nil {
+			return nil, custErr
+		}
+		if wallet == nil {
+			return nil, response.RepositoryError("failed to get wallet")
+		}
+		return &params.EnsureWalletResponse{WalletResponse: toWalletResponse(wallet)}, nil
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":     eventWalletCreated,
+		"user_id":   wallet.UserID,
+		"wallet_id": wallet.ID,
+		"currency":  wallet.Currency,
+	}).Info("Wallet created")
+
+	return &params.EnsureWalletResponse{WalletResponse: toWalletResponse(wallet), Created: true}, nil
+}
+
+// existingWallet returns the user's wallet, or nil when there is none. A
+// wallet in another currency than the one asked for is a conflict rather than
+// a silent mismatch.
+func (u *WalletUsecaseImpl) existingWallet(ctx context.Context, req *params.CreateWalletRequest) (*entity.Wallet, *response.CustomError) {
+	wallet, err := u.repo.GetByUserID(ctx, req.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+	if req.Currency != "" && !strings.EqualFold(req.Currency, wallet.Currency) {
+		return nil, response.ConflictErrorWithAdditionalInfo(
+			map[string]string{"wallet_currency": wallet.Currency},
+			fmt.Sprintf("user already has a wallet in %s", wallet.Currency),
+		)
+	}
+	return wallet, nil
+}
+
+func toWalletResponse(wallet *entity.Wallet) *params.WalletResponse {
 	return &params.WalletResponse{
 		ID:        wallet.ID,
 		UserID:    wallet.UserID,
 		Balance:   wallet.Balance,
 		Currency:  wallet.Currency,
+		Type:      walletType(wallet),
 		CreatedAt: wallet.CreatedAt,
 		UpdatedAt: wallet.UpdatedAt,
-	}, nil
+	}
 }
 
-func (u *WalletUsecaseImpl) GetBalance(ctx context.Context, userID uuid.UUID) (*params.BalanceResponse, *response.CustomError) {
+// GetBalance returns the user's balance and, with includeCount, the number of
+// transactions in their history.
+func (u *WalletUsecaseImpl) GetBalance(ctx context.Context, userID uuid.UUID, includeCount bool) (*params.BalanceResponse, *response.CustomError) {
 	u.mutex.RLock()
 	defer u.mutex.RUnlock()
 
-	wallet, err := u.repo.GetByUserID(ctx, userID)
+	wallet, err := u.readWalletShared(ctx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, response.NotFoundError("wallet not found")
@@ -78,22 +412,245 @@ func (u *WalletUsecaseImpl) GetBalance(ctx context.Context, userID uuid.UUID) (*
 		return nil, response.RepositoryError("failed to get wallet")
 	}
 
-	return &params.BalanceResponse{
-		UserID:    wallet.UserID,
-		Balance:   wallet.Balance,
+	// The wallet may be shared with concurrent readers, so the clearing
+	// balance is loaded into a copy.
+	balance := *wallet
+	if u.config.DepositHold > 0 {
+		balance.ClearingBalance, err = u.repo.SumClearingDeposits(ctx, nil, wallet.ID, time.Now())
+		if err != nil {
+			return nil, response.RepositoryError("failed to get clearing deposits")
+		}
+	}
+
+	// Money only leaves a wallet through completed transactions, so holds
+	// and deposits still clearing are the only part of the balance that is
+	// not spendable.
+	resp := &params.BalanceResponse{
+		UserID:           balance.UserID,
+		Balance:          balance.Balance,
+		AvailableBalance: balance.AvailableBalance(),
+		HeldBalance:      balance.HeldBalance,
+		ClearingBalance:  balance.ClearingBalance,
+		Currency:         balance.Currency,
+		Timestamp:        time.Now(),
+	}
+
+	if includeCount {
+		// The same count as the total of the unfiltered history, so both
+		// share one cache entry.
+		var filter params.TransactionHistoryFilter
+		count, custErr := u.countTransactions(ctx, userID, wallet.ID, filter, u.transactionFilter(filter))
+		if custErr != nil {
+			return nil, custErr
+		}
+		resp.TransactionCount = &count
+	}
+
+	return resp, nil
+}
+
+// provisionWallet creates a wallet for a user depositing without one, in the
+// currency the deposit names or else the default currency. It runs in the
+// deposit's transaction so that a failed deposit leaves no empty wallet
+// behind.
+func (u *WalletUsecaseImpl) provisionWallet(ctx context.Context, txRepo repository.WalletRepository, userID uuid.UUID, currency string) *response.CustomError {
+	exists, err := txRepo.ExistsByUserID(ctx, userID)
+	if err != nil {
+		return response.RepositoryError("failed to check wallet")
+	}
+	if exists {
+		return nil
+	}
+
+	if currency == "" {
+		currency = u.config.DefaultCurrency
+	}
+	if currency == "" {
+		return response.NotFoundError("wallet not found")
+	}
+
+	wallet := &entity.Wallet{
+		UserID:   userID,
+		Currency: strings.ToUpper(currency),
+		Version:  1,
+		Type:     entity.WalletTypePersonal,
+	}
+	// A concurrent first deposit may have created it in the meantime, in
+	// which case that wallet is used.
+	created, err := txRepo.CreateIfAbsent(ctx, wallet)
+	if err != nil {
+		return response.RepositoryError("failed to create wallet")
+	}
+	if created {
+		u.logger.WithFields(logrus.Fields{
+			"event":    eventWalletCreated,
+			"user_id":  userID,
+			"currency": wallet.Currency,
+		}).Info("Wallet created on first deposit")
+	}
+	return nil
+}
+
+// readWalletShared loads the user's wallet, sharing one query between all
+// callers asking for the same wallet at the same time: clients poll the
+// balance from several tabs and devices at once. Reads that asked for the
+// primary are not mixed with replica reads.
+//
+// The query runs detached from the cancellation of the caller that started
+// it, since others may be waiting on it; each caller still stops waiting when
+// its own context is done.
+func (u *WalletUsecaseImpl) readWalletShared(ctx context.Context, userID uuid.UUID) (*entity.Wallet, error) {
+	key := userID.String()
+	if readpref.PrimaryRequested(ctx) {
+		key += ":primary"
+	}
+
+	ch := u.balanceReads.DoChan(key, func() (interface{}, error) {
+		return u.repo.GetByUserID(context.WithoutCancel(ctx), userID)
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*entity.Wallet), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WalletExists reports whether the user has a wallet. Having none yet is a
+// normal state for new users, so unlike GetBalance it is not an error.
+func (u *WalletUsecaseImpl) WalletExists(ctx context.Context, userID uuid.UUID) (*params.WalletExistsResponse, *response.CustomError) {
+	exists, err := u.repo.ExistsByUserID(ctx, userID)
+	if err != nil {
+		return nil, response.RepositoryError("failed to check wallet")
+	}
+
+	return &params.WalletExistsResponse{Exists: exists}, nil
+}
+
+// GetBalanceAt returns the wallet balance as of at, taken from the balance
+// snapshot of the latest completed transaction at or before that time. For
+// older transactions without a snapshot the balance is replayed from the
+// completed transactions instead.
+func (u *WalletUsecaseImpl) GetBalanceAt(ctx context.Context, userID, walletID uuid.UUID, at time.Time) (*params.BalanceAtResponse, *response.CustomError) {
+	wallet, custErr := u.ownWallet(ctx, userID, walletID)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	balance, latest, custErr := u.balanceAt(ctx, wallet.ID, at)
+	if custErr != nil {
+		return nil, custErr
+	}
+
+	resp := &params.BalanceAtResponse{
+		WalletID:  wallet.ID,
+		Balance:   balance,
 		Currency:  wallet.Currency,
-		Timestamp: time.Now(),
-	}, nil
+		Timestamp: at,
+	}
+	if latest != nil {
+		resp.TransactionID = &latest.ID
+	}
+
+	return resp, nil
+}
+
+// ownWallet loads a wallet of the user. Other users' wallets are reported as
+// missing rather than forbidden so that wallet ids cannot be probed.
+func (u *WalletUsecaseImpl) ownWallet(ctx context.Context, userID, walletID uuid.UUID) (*entity.Wallet, *response.CustomError) {
+	wallet, err := u.repo.GetByID(ctx, walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+	if wallet.UserID != userID {
+		return nil, response.NotFoundError("wallet not found")
+	}
+	return wallet, nil
 }
 
+// balanceAt returns the wallet balance as of at together with the latest
+// completed transaction at or before that time, nil if there was none yet.
+func (u *WalletUsecaseImpl) balanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (float64, *entity.Transaction, *response.CustomError) {
+	includeArchived := u.includesArchived(at)
+
+	latest, err := u.repo.GetLatestTransactionAt(ctx, walletID, at, includeArchived)
+	if err != nil {
+		return 0, nil, response.RepositoryError("failed to get balance history")
+	}
+	if latest == nil {
+		// Nothing had happened yet, so the wallet still held its opening
+		// zero balance.
+		return 0, nil, nil
+	}
+
+	if latest.BalanceAfter != nil {
+		return *latest.BalanceAfter, latest, nil
+	}
+
+	deposited, withdrawn, err := u.repo.SumTransactionsByWalletID(ctx, walletID, repository.TransactionFilter{To: &at, IncludeArchived: includeArchived})
+	if err != nil {
+		return 0, nil, response.RepositoryError("failed to get balance history")
+	}
+	return math.Round((deposited-withdrawn)*100) / 100, latest, nil
+}
+
+// includesArchived reports whether reading back to since needs transactions
+// the retention job may already have archived.
+func (u *WalletUsecaseImpl) includesArchived(since time.Time) bool {
+	return u.config.TransactionRetention > 0 && since.Before(time.Now().Add(-u.config.TransactionRetention))
+}
+
+// Withdraw executes a withdrawal at once, unless its amount calls for
+// confirmation through InitiateWithdraw and ConfirmWithdraw.
 func (u *WalletUsecaseImpl) Withdraw(ctx context.Context, userID uuid.UUID, req *params.WithdrawRequest) (*params.WithdrawResponse, *response.CustomError) {
-	if req.Amount <= 0 {
-		return nil, response.BadRequestError("invalid amount")
+	if u.requiresWithdrawConfirmation(req.Amount) {
+		return nil, response.ForbiddenErrorWithAdditionalInfo(
+			map[string]interface{}{"reason": "confirmation_required", "threshold": u.config.WithdrawConfirmationThreshold},
+			fmt.Sprintf("withdrawals of %.2f or more must be initiated and then confirmed", u.config.WithdrawConfirmationThreshold),
+		)
+	}
+	return u.withdraw(ctx, userID, req)
+}
+
+func (u *WalletUsecaseImpl) withdraw(ctx context.Context, userID uuid.UUID, req *params.WithdrawRequest) (*params.WithdrawResponse, *response.CustomError) {
+	defer u.config.InFlight.Start()()
+
+	if custErr := u.validateAmount(req.Amount, "invalid amount"); custErr != nil {
+		return nil, custErr
+	}
+
+	opLock, custErr := u.acquireOperationLock(ctx, userID, "withdraw")
+	if custErr != nil {
+		return nil, custErr
+	}
+	defer opLock.release(ctx)
+
+	dedup, custErr := u.claimDedupWindow(ctx, userID, "withdraw", req.IdempotencyKey, req)
+	if custErr != nil {
+		return nil, custErr
+	}
+	defer dedup.release(ctx)
+
+	if req.DestinationID != nil {
+		destination, custErr := u.ownPayoutDestination(ctx, userID, *req.DestinationID)
+		if custErr != nil {
+			return nil, custErr
+		}
+		if !destination.Verified() {
+			return nil, response.UnprocessableEntityError("payout destination is not verified")
+		}
 	}
 
 	tx := u.repo.BeginTx(ctx)
 	if tx.Error != nil {
-		u.logger.WithError(tx.Error).Error("Failed to begin transaction")
+		u.logger.WithError(tx.Error).WithField("event", eventWithdrawFailed).Error("Failed to begin transaction")
 		return nil, response.GeneralError("failed to begin transaction")
 	}
 
@@ -103,23 +660,36 @@ func (u *WalletUsecaseImpl) Withdraw(ctx context.Context, userID uuid.UUID, req
 
 	defer tx.Rollback()
 
-	wallet, err := txRepo.GetByUserIDForUpdate(ctx, tx, userID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, response.NotFoundError("wallet not found")
-		}
-		u.logger.WithError(err).WithField("user_id", userID).Error("Failed to get wallet for update")
-		return nil, response.RepositoryError("failed to get wallet for update")
+	wallet, system, custErr := u.lockForExternalMovement(ctx, tx, txRepo, userID)
+	if custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkCurrency(wallet, req.Currency); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkWalletOperation(wallet, operationWithdraw); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkSoftLock(wallet, time.Now()); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := u.loadClearingBalance(ctx, tx, txRepo, wallet); custErr != nil {
+		return nil, custErr
 	}
 
-	if wallet.Balance < req.Amount {
+	if wallet.AvailableBalance() < req.Amount {
 		u.logger.WithFields(logrus.Fields{
-			"user_id":         userID,
-			"current_balance": wallet.Balance,
-			"withdraw_amount": req.Amount,
+			"event":             eventWithdrawInsufficientBalance,
+			"user_id":           userID,
+			"current_balance":   wallet.Balance,
+			"available_balance": wallet.AvailableBalance(),
+			"withdraw_amount":   req.Amount,
 		}).Warn("Insufficient balance for withdrawal")
 		return nil, response.BadRequestError("insufficient balance")
 	}
+	if custErr := u.checkMinimumBalance(wallet, req.Amount); custErr != nil {
+		return nil, custErr
+	}
 
 	newBalance := wallet.Balance - req.Amount
 	newVersion := wallet.Version + 1
@@ -130,153 +700,207 @@ func (u *WalletUsecaseImpl) Withdraw(ctx context.Context, userID uuid.UUID, req
 		Type:        entity.TransactionTypeWithdraw,
 		Amount:      req.Amount,
 		Status:      entity.TransactionStatusPending,
-		Description: req.Description,
+		Description: u.transactionDescription(req.Description, entity.TransactionTypeWithdraw, req.Amount, wallet.Currency, time.Now()),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+
+		BalanceAfter:        &newBalance,
+		PayoutDestinationID: req.DestinationID,
 	}
 
 	if err := txRepo.CreateTransaction(ctx, tx, transaction); err != nil {
-		u.logger.WithError(err).Error("Failed to create transaction")
+		u.logger.WithError(err).WithField("event", eventWithdrawFailed).Error("Failed to create transaction")
 		return nil, response.RepositoryError("failed to create transaction")
 	}
 
 	if err := txRepo.UpdateBalance(ctx, tx, wallet.ID, newBalance, newVersion); err != nil {
-		u.logger.WithError(err).Error("Failed to update wallet balance")
+		u.logger.WithError(err).WithField("event", eventWithdrawFailed).Error("Failed to update wallet balance")
 		return nil, response.RepositoryError("failed to update wallet balance")
 	}
 
+	if system != nil {
+		if custErr := u.bookSystemLeg(ctx, tx, txRepo, system, wallet, entity.TransactionTypeWithdraw, req.Amount); custErr != nil {
+			return nil, custErr
+		}
+	}
+
 	transaction.Status = entity.TransactionStatusCompleted
 
 	if err := txRepo.UpdateTransactionStatus(ctx, tx, transaction.ID, transaction); err != nil {
-		u.logger.WithError(err).Error("Failed to update transaction status")
+		u.logger.WithError(err).WithField("event", eventWithdrawFailed).Error("Failed to update transaction status")
 		return nil, response.RepositoryError("failed to update transaction status")
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		u.logger.WithError(err).Error("Failed to commit transaction")
+		u.logger.WithError(err).WithField("event", eventWithdrawFailed).Error("Failed to commit transaction")
 		return nil, response.RepositoryError("failed to commit transaction")
 	}
 
-	cachePattern := fmt.Sprintf("transactions:%s:*", userID.String())
-	if keys, err := u.cache.Keys(ctx, cachePattern).Result(); err == nil {
-		if len(keys) > 0 {
-			if err := u.cache.Del(ctx, keys...).Err(); err != nil {
-				u.logger.WithError(err).Warn("Failed to invalidate transaction cache")
-			} else {
-				u.logger.WithField("cache_keys", keys).Info("Invalidated transaction cache after withdrawal")
-			}
-		}
-	} else {
-		u.logger.WithError(err).Warn("Failed to fetch transaction cache keys for invalidation")
+	u.invalidateTransactionCache(ctx, userID)
+	if system != nil {
+		u.invalidateTransactionCache(ctx, system.UserID)
 	}
 
 	u.logger.WithFields(logrus.Fields{
+		"event":          eventWithdrawCompleted,
 		"user_id":        userID,
 		"transaction_id": transaction.ID,
 		"amount":         req.Amount,
 		"new_balance":    newBalance,
 	}).Info("Withdrawal completed successfully")
 
-	return &params.WithdrawResponse{
+	u.alertIfAboveThreshold(wallet, entity.TransactionTypeWithdraw, req.Amount, newBalance)
+
+	resp := &params.WithdrawResponse{
 		TransactionID: transaction.ID,
 		Amount:        req.Amount,
 		NewBalance:    newBalance,
 		Status:        transaction.Status,
 		Timestamp:     transaction.UpdatedAt,
-	}, nil
+		DestinationID: req.DestinationID,
+	}
+	dedup.complete()
+
+	return resp, nil
 }
 
 func (u *WalletUsecaseImpl) Deposit(ctx context.Context, userID uuid.UUID, req *params.DepositRequest) (*params.DepositResponse, *response.CustomError) {
-	if req.Amount <= 0 {
-		return nil, response.BadRequestError("invalid deposit amount")
+	defer u.config.InFlight.Start()()
+
+	if custErr := u.validateAmount(req.Amount, "invalid deposit amount"); custErr != nil {
+		return nil, custErr
+	}
+
+	opLock, custErr := u.acquireOperationLock(ctx, userID, "deposit")
+	if custErr != nil {
+		return nil, custErr
 	}
+	defer opLock.release(ctx)
+
+	dedup, custErr := u.claimDedupWindow(ctx, userID, "deposit", req.IdempotencyKey, req)
+	if custErr != nil {
+		return nil, custErr
+	}
+	defer dedup.release(ctx)
 
 	tx := u.repo.BeginTx(ctx)
 	if tx.Error != nil {
-		u.logger.WithError(tx.Error).Error("Failed to begin transaction")
+		u.logger.WithError(tx.Error).WithField("event", eventDepositFailed).Error("Failed to begin transaction")
 		return nil, response.GeneralError("failed to begin transaction")
 	}
 	txRepo := u.repo.WithTx(tx)
 	defer tx.Rollback()
 
-	wallet, err := txRepo.GetByUserIDForUpdate(ctx, tx, userID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, response.NotFoundError("wallet not found")
+	if u.config.Flags.AutoCreateWalletEnabled() {
+		if custErr := u.provisionWallet(ctx, txRepo, userID, req.Currency); custErr != nil {
+			return nil, custErr
 		}
-		u.logger.WithError(err).WithField("user_id", userID).Error("Failed to get wallet for update")
-		return nil, response.RepositoryError("failed to get wallet for update")
+	}
+
+	wallet, system, custErr := u.lockForExternalMovement(ctx, tx, txRepo, userID)
+	if custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkCurrency(wallet, req.Currency); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkWalletOperation(wallet, operationDeposit); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := u.checkMinimumDeposit(wallet, req.Amount); custErr != nil {
+		return nil, custErr
+	}
+	if custErr := checkSoftLock(wallet, time.Now()); custErr != nil {
+		return nil, custErr
 	}
 
 	newBalance := wallet.Balance + req.Amount
+	if newBalance > MaxStorableAmount {
+		return nil, response.UnprocessableEntityError("deposit would exceed the maximum wallet balance")
+	}
 	newVersion := wallet.Version + 1
 
+	now := time.Now()
 	transaction := &entity.Transaction{
 		ID:          uuid.New(),
 		WalletID:    wallet.ID,
 		Type:        entity.TransactionTypeDeposit,
 		Amount:      req.Amount,
 		Status:      entity.TransactionStatusPending,
-		Description: req.Description,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Description: u.transactionDescription(req.Description, entity.TransactionTypeDeposit, req.Amount, wallet.Currency, now),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+
+		BalanceAfter: &newBalance,
+		ClearedAt:    u.depositClearedAt(now),
 	}
 
 	if err := txRepo.CreateTransaction(ctx, tx, transaction); err != nil {
-		u.logger.WithError(err).Error("Failed to create transaction")
+		u.logger.WithError(err).WithField("event", eventDepositFailed).Error("Failed to create transaction")
 		return nil, response.RepositoryError("failed to create transaction")
 	}
 
 	if err := txRepo.UpdateBalance(ctx, tx, wallet.ID, newBalance, newVersion); err != nil {
-		u.logger.WithError(err).Error("Failed to update wallet balance")
+		u.logger.WithError(err).WithField("event", eventDepositFailed).Error("Failed to update wallet balance")
 		return nil, response.RepositoryError("failed to update wallet balance")
 	}
 
+	if system != nil {
+		if custErr := u.bookSystemLeg(ctx, tx, txRepo, system, wallet, entity.TransactionTypeDeposit, req.Amount); custErr != nil {
+			return nil, custErr
+		}
+	}
+
 	transaction.Status = entity.TransactionStatusCompleted
 	if err := txRepo.UpdateTransactionStatus(ctx, tx, transaction.ID, transaction); err != nil {
-		u.logger.WithError(err).Error("Failed to update transaction status")
+		u.logger.WithError(err).WithField("event", eventDepositFailed).Error("Failed to update transaction status")
 		return nil, response.RepositoryError("failed to update transaction status")
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		u.logger.WithError(err).Error("Failed to commit transaction")
+		u.logger.WithError(err).WithField("event", eventDepositFailed).Error("Failed to commit transaction")
 		return nil, response.RepositoryError("failed to commit transaction")
 	}
 
-	cachePattern := fmt.Sprintf("transactions:%s:*", userID.String())
-	if keys, err := u.cache.Keys(ctx, cachePattern).Result(); err == nil {
-		if len(keys) > 0 {
-			if err := u.cache.Del(ctx, keys...).Err(); err != nil {
-				u.logger.WithError(err).Warn("Failed to invalidate transaction cache")
-			}
-		}
+	u.invalidateTransactionCache(ctx, userID)
+	if system != nil {
+		u.invalidateTransactionCache(ctx, system.UserID)
 	}
 
 	u.logger.WithFields(logrus.Fields{
+		"event":          eventDepositCompleted,
 		"user_id":        userID,
 		"transaction_id": transaction.ID,
 		"amount":         req.Amount,
 		"new_balance":    newBalance,
 	}).Info("Deposit completed successfully")
 
-	return &params.DepositResponse{
+	u.alertIfAboveThreshold(wallet, entity.TransactionTypeDeposit, req.Amount, newBalance)
+
+	resp := &params.DepositResponse{
 		TransactionID: transaction.ID,
 		Amount:        req.Amount,
 		NewBalance:    newBalance,
 		Status:        transaction.Status,
 		Timestamp:     transaction.UpdatedAt,
-	}, nil
+		ClearedAt:     transaction.ClearedAt,
+	}
+	dedup.complete()
+
+	return resp, nil
 }
 
-func (u *WalletUsecaseImpl) GetTransactionHistory(ctx context.Context, userID uuid.UUID, limit, offset int) (*params.TransactionHistoryResponse, *response.CustomError) {
+func (u *WalletUsecaseImpl) GetTransactionHistory(ctx context.Context, userID uuid.UUID, limit, offset int, filter params.TransactionHistoryFilter) (*params.TransactionHistoryResponse, *response.CustomError) {
 	page := (offset / limit) + 1
-	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID, page, limit)
+	cacheKey := transactionHistoryCacheKey(userID, page, limit, offset, filter)
 
-	if val, err := u.cache.Get(ctx, cacheKey).Result(); err == nil {
-		var cached params.TransactionHistoryResponse
-		if json.Unmarshal([]byte(val), &cached) == nil {
-			u.logger.WithField("cache_key", cacheKey).Info("Cache hit for transaction history")
-			return &cached, nil
+	if !filter.SkipCache {
+		if val, err := u.transactionCacheGet(ctx, cacheKey); err == nil {
+			var cached params.TransactionHistoryResponse
+			if json.Unmarshal([]byte(val), &cached) == nil {
+				u.logger.WithField("cache_key", cacheKey).Info("Cache hit for transaction history")
+				return &cached, nil
+			}
 		}
 	}
 
@@ -288,32 +912,41 @@ func (u *WalletUsecaseImpl) GetTransactionHistory(ctx context.Context, userID uu
 		return nil, response.RepositoryError("failed to get wallet")
 	}
 
-	transactions, err := u.repo.GetTransactionsByWalletID(ctx, wallet.ID, limit, offset)
+	repoFilter := u.transactionFilter(filter)
+
+	transactions, err := u.repo.GetTransactionsByWalletID(ctx, wallet.ID, limit, offset, repoFilter)
 	if err != nil {
 		u.logger.WithError(err).Error("Failed to get transaction history")
 		return nil, response.RepositoryError("failed to get transaction history")
 	}
 
-	total, err := u.repo.CountTransactionsByWalletID(ctx, wallet.ID)
-	if err != nil {
-		u.logger.WithError(err).Error("Failed to get total transactions")
-		return nil, response.RepositoryError("failed to get total transactions")
+	total, custErr := u.countTransactions(ctx, userID, wallet.ID, filter, repoFilter)
+	if custErr != nil {
+		return nil, custErr
 	}
 
 	transactionResponses := make([]*params.TransactionResponse, len(transactions))
 	for i, t := range transactions {
 		transactionResponses[i] = &params.TransactionResponse{
 			ID:          t.ID,
+			DisplayID:   u.displayIDs.Encode(t.ID),
 			Type:        t.Type,
 			Amount:      t.Amount,
 			Description: &t.Description,
 			Status:      t.Status,
 			CreatedAt:   t.CreatedAt,
 			UpdatedAt:   t.UpdatedAt,
+
+			PayoutDestinationID: t.PayoutDestinationID,
+			ExchangeRate:        t.ExchangeRate,
+			OriginalAmount:      t.OriginalAmount,
+			ConvertedAmount:     t.ConvertedAmount,
+			TransferID:          t.TransferID,
+			ClearedAt:           t.ClearedAt,
 		}
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	page, totalPages := paginate(total, page, limit)
 
 	resp := &params.TransactionHistoryResponse{
 		Transactions: transactionResponses,
@@ -323,11 +956,491 @@ func (u *WalletUsecaseImpl) GetTransactionHistory(ctx context.Context, userID uu
 		TotalPages:   totalPages,
 	}
 
+	if filter.IncludeTotals {
+		deposited, withdrawn, err := u.repo.SumTransactionsByWalletID(ctx, wallet.ID, repoFilter)
+		if err != nil {
+			u.logger.WithError(err).Error("Failed to get transaction totals")
+			return nil, response.RepositoryError("failed to get transaction totals")
+		}
+		resp.TotalDeposited = &deposited
+		resp.TotalWithdrawn = &withdrawn
+	}
+
 	if data, err := json.Marshal(resp); err == nil {
-		if err := u.cache.Set(ctx, cacheKey, data, 5*time.Minute).Err(); err != nil {
+		if err := u.transactionCacheSet(ctx, cacheKey, data); err != nil {
 			u.logger.WithError(err).Warn("Failed to cache transaction history")
 		}
 	}
 
 	return resp, nil
 }
+
+// countTransactions returns the total of a filtered history. The count query
+// scans every matching transaction, so its result is cached apart from the
+// pages and for longer: moving between pages does not change it, and writes
+// invalidate it together with the pages.
+func (u *WalletUsecaseImpl) countTransactions(ctx context.Context, userID, walletID uuid.UUID, filter params.TransactionHistoryFilter, repoFilter repository.TransactionFilter) (int64, *response.CustomError) {
+	cacheKey := transactionCountCacheKey(userID, filter)
+	if !filter.SkipCache {
+		if val, err := u.transactionCacheGet(ctx, cacheKey); err == nil {
+			if total, err := strconv.ParseInt(val, 10, 64); err == nil {
+				return total, nil
+			}
+		}
+	}
+
+	total, err := u.repo.CountTransactionsByWalletID(ctx, walletID, repoFilter)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to get total transactions")
+		return 0, response.RepositoryError("failed to get total transactions")
+	}
+
+	if u.config.Flags.HistoryCacheEnabled() {
+		if err := u.cacheSet(ctx, cacheKey, total, transactionCountCacheTTL); err != nil {
+			u.logger.WithError(err).Warn("Failed to cache total transactions")
+		}
+	}
+	return total, nil
+}
+
+func (u *WalletUsecaseImpl) GetInsights(ctx context.Context, userID uuid.UUID, granularity string, filter params.TransactionHistoryFilter) (*params.InsightsResponse, *response.CustomError) {
+	if !insightGranularities[granularity] {
+		return nil, response.BadRequestError("granularity must be one of: day, week, month")
+	}
+
+	// Keyed under the transactions prefix so that writes invalidate it.
+	cacheKey := fmt.Sprintf("transactions:%s:insights:%s", userID, granularity)
+	if filter.From != nil {
+		cacheKey += ":from=" + filter.From.UTC().Format(time.RFC3339)
+	}
+	if filter.To != nil {
+		cacheKey += ":to=" + filter.To.UTC().Format(time.RFC3339)
+	}
+
+	if val, err := u.transactionCacheGet(ctx, cacheKey); err == nil {
+		var cached params.InsightsResponse
+		if json.Unmarshal([]byte(val), &cached) == nil {
+			return &cached, nil
+		}
+	}
+
+	wallet, err := u.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+
+	buckets, err := u.repo.GetTransactionInsights(ctx, wallet.ID, granularity, filter.From, filter.To)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to get transaction insights")
+		return nil, response.RepositoryError("failed to get transaction insights")
+	}
+
+	bucketResponses := make([]*params.InsightBucketResponse, len(buckets))
+	for i, b := range buckets {
+		bucketResponses[i] = &params.InsightBucketResponse{
+			Bucket:        b.Bucket,
+			TotalDeposit:  b.TotalDeposit,
+			TotalWithdraw: b.TotalWithdraw,
+			Net:           math.Round((b.TotalDeposit-b.TotalWithdraw)*100) / 100,
+		}
+	}
+
+	resp := &params.InsightsResponse{
+		Granularity: granularity,
+		From:        filter.From,
+		To:          filter.To,
+		Buckets:     bucketResponses,
+	}
+
+	if data, err := json.Marshal(resp); err == nil {
+		if err := u.transactionCacheSet(ctx, cacheKey, data); err != nil {
+			u.logger.WithError(err).Warn("Failed to cache transaction insights")
+		}
+	}
+
+	return resp, nil
+}
+
+// GetActivity returns one page of transactions across all of the user's
+// wallets. Amounts are never summed across wallets since they may differ in
+// currency; each row carries its own wallet and currency instead.
+func (u *WalletUsecaseImpl) GetActivity(ctx context.Context, userID uuid.UUID, limit, offset int, filter params.TransactionHistoryFilter) (*params.ActivityResponse, *response.CustomError) {
+	page := (offset / limit) + 1
+	// Keyed under the transactions prefix so that writes invalidate it.
+	cacheKey := fmt.Sprintf("transactions:%s:activity:%d:%d", userID, page, limit) + offsetCacheSuffix(limit, offset)
+	if filter.From != nil {
+		cacheKey += ":from=" + filter.From.UTC().Format(time.RFC3339)
+	}
+	if filter.To != nil {
+		cacheKey += ":to=" + filter.To.UTC().Format(time.RFC3339)
+	}
+	if filter.Ascending {
+		cacheKey += ":order=asc"
+	}
+
+	if val, err := u.transactionCacheGet(ctx, cacheKey); err == nil {
+		var cached params.ActivityResponse
+		if json.Unmarshal([]byte(val), &cached) == nil {
+			return &cached, nil
+		}
+	}
+
+	repoFilter := u.transactionFilter(filter)
+
+	transactions, err := u.repo.GetTransactionsByUserID(ctx, userID, limit, offset, repoFilter)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to get activity")
+		return nil, response.RepositoryError("failed to get activity")
+	}
+
+	total, err := u.repo.CountTransactionsByUserID(ctx, userID, repoFilter)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to get total activity")
+		return nil, response.RepositoryError("failed to get total activity")
+	}
+
+	activity := make([]*params.ActivityTransactionResponse, len(transactions))
+	for i, t := range transactions {
+		activity[i] = &params.ActivityTransactionResponse{
+			TransactionResponse: params.TransactionResponse{
+				ID:          t.ID,
+				DisplayID:   u.displayIDs.Encode(t.ID),
+				Type:        t.Type,
+				Amount:      t.Amount,
+				Description: &t.Description,
+				Status:      t.Status,
+				CreatedAt:   t.CreatedAt,
+				UpdatedAt:   t.UpdatedAt,
+			},
+			WalletID: t.WalletID,
+			Currency: t.Currency,
+		}
+	}
+
+	page, totalPages := paginate(total, page, limit)
+
+	resp := &params.ActivityResponse{
+		Transactions: activity,
+		Total:        total,
+		Page:         page,
+		Limit:        limit,
+		TotalPages:   totalPages,
+	}
+
+	if data, err := json.Marshal(resp); err == nil {
+		if err := u.transactionCacheSet(ctx, cacheKey, data); err != nil {
+			u.logger.WithError(err).Warn("Failed to cache activity")
+		}
+	}
+
+	return resp, nil
+}
+
+func (u *WalletUsecaseImpl) SetAlertThreshold(ctx context.Context, userID uuid.UUID, req *params.AlertThresholdRequest) (*params.AlertThresholdResponse, *response.CustomError) {
+	tx := u.repo.BeginTx(ctx)
+	if tx.Error != nil {
+		u.logger.WithError(tx.Error).WithField("event", eventAlertThresholdFailed).Error("Failed to begin transaction")
+		return nil, response.GeneralError("failed to begin transaction")
+	}
+	txRepo := u.repo.WithTx(tx)
+	defer tx.Rollback()
+
+	wallet, err := txRepo.GetByUserIDForUpdate(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrLockTimeout) {
+			return nil, walletBusyError()
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+
+	if err := txRepo.UpdateAlertThreshold(ctx, wallet.ID, req.Threshold); err != nil {
+		return nil, response.RepositoryError("failed to update alert threshold")
+	}
+
+	before := map[string]*float64{"alert_threshold": wallet.AlertThreshold}
+	after := map[string]*float64{"alert_threshold": req.Threshold}
+	if err := u.recordWalletEvent(ctx, tx, wallet.ID, userID, entity.WalletEventAlertThresholdUpdated, before, after); err != nil {
+		u.logger.WithError(err).WithFields(logrus.Fields{"event": eventAlertThresholdFailed, "wallet_id": wallet.ID}).Error("Failed to record wallet event")
+		return nil, response.RepositoryError("failed to record wallet event")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		u.logger.WithError(err).WithField("event", eventAlertThresholdFailed).Error("Failed to commit transaction")
+		return nil, response.RepositoryError("failed to commit transaction")
+	}
+
+	effective := u.config.AlertThreshold
+	if req.Threshold != nil {
+		effective = *req.Threshold
+	}
+
+	return &params.AlertThresholdResponse{
+		WalletID:  wallet.ID,
+		Threshold: req.Threshold,
+		Effective: effective,
+	}, nil
+}
+
+// alertIfAboveThreshold notifies the wallet owner when a completed transaction
+// moved more than the wallet's alert threshold, or the configured default when
+// the wallet has none.
+func (u *WalletUsecaseImpl) alertIfAboveThreshold(wallet *entity.Wallet, txType entity.TransactionType, amount, newBalance float64) {
+	threshold := u.config.AlertThreshold
+	if wallet.AlertThreshold != nil {
+		threshold = *wallet.AlertThreshold
+	}
+	if threshold <= 0 || amount <= threshold {
+		return
+	}
+
+	u.notifyAsync(notify.Message{
+		UserID:  wallet.UserID,
+		Channel: notify.ChannelEmail,
+		Subject: fmt.Sprintf("Large %s on your wallet", txType),
+		Body: fmt.Sprintf("A %s of %.2f %s was made on your wallet. Your new balance is %.2f %s.",
+			txType, amount, wallet.Currency, newBalance, wallet.Currency),
+	})
+}
+
+// notifyAsync sends msg in the background so that a slow or failing notifier
+// never delays or fails the operation that triggered it.
+func (u *WalletUsecaseImpl) notifyAsync(msg notify.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+
+		if err := u.notifier.Notify(ctx, msg); err != nil {
+			u.logger.WithError(err).WithFields(logrus.Fields{
+				"user_id": msg.UserID,
+				"subject": msg.Subject,
+			}).Warn("Failed to send notification")
+		}
+	}()
+}
+
+// transactionFilter maps a history filter onto the repository filter.
+// Archived rows are only read when the requested range reaches back past the
+// retention period.
+func (u *WalletUsecaseImpl) transactionFilter(filter params.TransactionHistoryFilter) repository.TransactionFilter {
+	return repository.TransactionFilter{
+		From:      filter.From,
+		To:        filter.To,
+		Ascending: filter.Ascending,
+		Types:     filter.Types,
+		MinAmount: filter.MinAmount,
+		MaxAmount: filter.MaxAmount,
+		Search:    filter.Search,
+		Status:    filter.Status,
+		IncludeArchived: u.config.TransactionRetention > 0 &&
+			filter.From != nil && filter.From.Before(time.Now().Add(-u.config.TransactionRetention)),
+	}
+}
+
+// paginate returns the page to report and the total page count. An empty
+// result has no pages, so it is reported as page 0 of 0 rather than page 1 of
+// 0.
+func paginate(total int64, page, limit int) (int, int) {
+	if total == 0 {
+		return 0, 0
+	}
+	return page, int(math.Ceil(float64(total) / float64(limit)))
+}
+
+// offsetCacheSuffix distinguishes cache entries for offsets that do not fall
+// on a page boundary, which would otherwise share the key of their page.
+func offsetCacheSuffix(limit, offset int) string {
+	if offset%limit == 0 {
+		return ""
+	}
+	return fmt.Sprintf(":offset=%d", offset)
+}
+
+func transactionHistoryCacheKey(userID uuid.UUID, page, limit, offset int, filter params.TransactionHistoryFilter) string {
+	key := fmt.Sprintf("transactions:%s:%d:%d", userID, page, limit) + offsetCacheSuffix(limit, offset)
+	key += transactionFilterCacheSuffix(filter)
+	if filter.IncludeTotals {
+		key += ":totals"
+	}
+	if filter.Ascending {
+		key += ":order=asc"
+	}
+	return key
+}
+
+// transactionCountCacheKey keys the total of a filtered history, which is
+// shared by every page and sort order of it.
+func transactionCountCacheKey(userID uuid.UUID, filter params.TransactionHistoryFilter) string {
+	return fmt.Sprintf("transactions:%s:count", userID) + transactionFilterCacheSuffix(filter)
+}
+
+// transactionFilterCacheSuffix distinguishes cache entries by the filters
+// that select which transactions are listed.
+func transactionFilterCacheSuffix(filter params.TransactionHistoryFilter) string {
+	var key string
+	if filter.From != nil {
+		key += ":from=" + filter.From.UTC().Format(time.RFC3339)
+	}
+	if filter.To != nil {
+		key += ":to=" + filter.To.UTC().Format(time.RFC3339)
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		key += ":types=" + strings.Join(types, ",")
+	}
+	if filter.MinAmount != nil {
+		key += fmt.Sprintf(":min=%.2f", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		key += fmt.Sprintf(":max=%.2f", *filter.MaxAmount)
+	}
+	if filter.Search != "" {
+		key += ":search=" + strings.ToLower(filter.Search)
+	}
+	return key
+}
+
+// ListPlatformTransactions lists transactions across every wallet for admins,
+// e.g. all failed withdrawals. It is not cached since admins look at it to see
+// the current state.
+func (u *WalletUsecaseImpl) ListPlatformTransactions(ctx context.Context, limit, offset int, filter params.TransactionHistoryFilter) (*params.PlatformTransactionsResponse, *response.CustomError) {
+	repoFilter := u.transactionFilter(filter)
+
+	transactions, err := u.repo.ListPlatformTransactions(ctx, limit, offset, repoFilter)
+	if err != nil {
+		return nil, response.RepositoryError("failed to list transactions")
+	}
+
+	total, err := u.repo.CountPlatformTransactions(ctx, repoFilter)
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to count platform transactions")
+		return nil, response.RepositoryError("failed to count transactions")
+	}
+
+	resp := &params.PlatformTransactionsResponse{
+		Transactions: make([]*params.PlatformTransactionResponse, len(transactions)),
+		Total:        total,
+		Limit:        limit,
+	}
+	for i, t := range transactions {
+		resp.Transactions[i] = &params.PlatformTransactionResponse{
+			ActivityTransactionResponse: params.ActivityTransactionResponse{
+				TransactionResponse: params.TransactionResponse{
+					ID:          t.ID,
+					DisplayID:   u.displayIDs.Encode(t.ID),
+					Type:        t.Type,
+					Amount:      t.Amount,
+					Description: &t.Description,
+					Status:      t.Status,
+					CreatedAt:   t.CreatedAt,
+					UpdatedAt:   t.UpdatedAt,
+				},
+				WalletID: t.WalletID,
+				Currency: t.Currency,
+			},
+			UserID:    t.UserID,
+			UserName:  t.UserName,
+			UserEmail: t.UserEmail,
+		}
+	}
+	resp.Page, resp.TotalPages = paginate(total, (offset/limit)+1, limit)
+
+	return resp, nil
+}
+
+// GetTotalBalance returns the money held across all customer wallets per
+// currency; the system wallet is not counted.
+func (u *WalletUsecaseImpl) GetTotalBalance(ctx context.Context) (*params.TotalBalanceResponse, *response.CustomError) {
+	if val, err := u.cacheGet(ctx, totalBalanceCacheKey); err == nil {
+		var cached params.TotalBalanceResponse
+		if json.Unmarshal([]byte(val), &cached) == nil {
+			return &cached, nil
+		}
+	}
+
+	totals, err := u.repo.SumAllBalances(ctx)
+	if err != nil {
+		return nil, response.RepositoryError("failed to sum wallet balances")
+	}
+
+	resp := &params.TotalBalanceResponse{
+		Currencies:  make([]*params.CurrencyBalanceResponse, len(totals)),
+		GeneratedAt: time.Now(),
+	}
+	for i, total := range totals {
+		resp.Currencies[i] = &params.CurrencyBalanceResponse{
+			Currency: total.Currency,
+			Total:    total.Total,
+			Wallets:  total.Wallets,
+		}
+	}
+
+	if data, err := json.Marshal(resp); err == nil {
+		if err := u.cacheSet(ctx, totalBalanceCacheKey, data, totalBalanceCacheTTL); err != nil {
+			u.logger.WithError(err).Warn("Failed to cache total balance")
+		}
+	}
+
+	return resp, nil
+}
+
+// GetTransaction looks up one of the user's transactions by its UUID or its
+// display id.
+func (u *WalletUsecaseImpl) GetTransaction(ctx context.Context, userID uuid.UUID, id string) (*params.TransactionResponse, *response.CustomError) {
+	from, err := uuid.Parse(id)
+	to := from
+	if err != nil {
+		if from, to, err = u.displayIDs.Decode(id); err != nil {
+			return nil, response.BadRequestError("invalid transaction id")
+		}
+	}
+
+	wallet, err := u.repo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, response.NotFoundError("wallet not found")
+		}
+		return nil, response.RepositoryError("failed to get wallet")
+	}
+
+	// Two matches means a display id collision, which the caller can only
+	// resolve by using the UUID.
+	transactions, err := u.repo.GetTransactionsByIDRange(ctx, wallet.ID, from, to, 2)
+	if err != nil {
+		return nil, response.RepositoryError("failed to get transaction")
+	}
+	switch len(transactions) {
+	case 0:
+		return nil, response.NotFoundError("transaction not found")
+	case 1:
+	default:
+		return nil, response.ConflictError("display id matches several transactions, use the transaction UUID")
+	}
+
+	t := transactions[0]
+	return &params.TransactionResponse{
+		ID:          t.ID,
+		DisplayID:   u.displayIDs.Encode(t.ID),
+		Type:        t.Type,
+		Amount:      t.Amount,
+		Description: &t.Description,
+		Status:      t.Status,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+
+		PayoutDestinationID: t.PayoutDestinationID,
+		ExchangeRate:        t.ExchangeRate,
+		OriginalAmount:      t.OriginalAmount,
+		ConvertedAmount:     t.ConvertedAmount,
+		TransferID:          t.TransferID,
+		ClearedAt:           t.ClearedAt,
+	}, nil
+}
@@ -2,6 +2,7 @@ package usecase_test
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,13 +10,21 @@ import (
 	"go-digital-wallet/internal/params"
 	"go-digital-wallet/internal/repository"
 	"go-digital-wallet/internal/usecase"
+	"go-digital-wallet/pkg/featureflag"
+	"go-digital-wallet/pkg/fxrate"
+	"go-digital-wallet/pkg/notify"
+	"go-digital-wallet/pkg/statementsig"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/driver/sqlite"
@@ -38,7 +47,7 @@ func setupTest(t *testing.T) (*repository.MockWalletRepository, *miniredis.Minir
 		t.Fatalf("failed to connect to in-memory database: %v", err)
 	}
 
-	wu := usecase.NewWalletUsecase(mockRepo, logger, rdb)
+	wu := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{})
 
 	return mockRepo, mr, rdb, wu, db
 }
@@ -83,6 +92,97 @@ func TestCreateWallet_Fail(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreateWallet_AlreadyHasWallet(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*entity.Wallet")).Return(repository.ErrWalletExists)
+
+	resp, err := uc.CreateWallet(context.Background(), &params.CreateWalletRequest{UserID: uuid.New(), Currency: "IDR"})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.StatusCode)
+	assert.Equal(t, "user already has a wallet; a user may only have one", err.Message)
+}
+
+func TestCreateWallet_AppliesDefaultCurrency(t *testing.T) {
+	mockRepo, _, rdb, _, _ := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DefaultCurrency: "USD"})
+
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(w *entity.Wallet) bool {
+		return w.Currency == "USD"
+	})).Return(nil)
+
+	resp, err := uc.CreateWallet(context.Background(), &params.CreateWalletRequest{UserID: uuid.New()})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "USD", resp.Currency)
+}
+
+func TestCreateWallet_CurrencyRequiredWithoutDefault(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+
+	resp, err := uc.CreateWallet(context.Background(), &params.CreateWalletRequest{UserID: uuid.New()})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "currency is required", err.Message)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestEnsureWallet_CreatesWhenAbsent(t *testing.T) {
+	mockRepo, _, rdb, _, _ := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DefaultCurrency: "IDR"})
+	userID := uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("CreateIfAbsent", mock.Anything, mock.MatchedBy(func(w *entity.Wallet) bool {
+		return w.UserID == userID && w.Currency == "IDR"
+	})).Return(true, nil).Once()
+
+	resp, err := uc.EnsureWallet(context.Background(), &params.CreateWalletRequest{UserID: userID})
+
+	assert.Nil(t, err)
+	assert.True(t, resp.Created)
+	assert.Equal(t, "IDR", resp.Currency)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEnsureWallet_ReturnsWalletCreatedConcurrently(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("CreateIfAbsent", mock.Anything, mock.Anything).Return(false, nil).Once()
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "USD"}, nil).Once()
+
+	resp, err := uc.EnsureWallet(context.Background(), &params.CreateWalletRequest{UserID: userID, Currency: "USD"})
+
+	assert.Nil(t, err)
+	assert.False(t, resp.Created)
+	assert.Equal(t, walletID, resp.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEnsureWallet_ExistingWalletInOtherCurrency(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{UserID: userID, Currency: "IDR"}, nil)
+
+	resp, err := uc.EnsureWallet(context.Background(), &params.CreateWalletRequest{UserID: userID})
+	assert.Nil(t, err)
+	assert.False(t, resp.Created)
+
+	resp, err = uc.EnsureWallet(context.Background(), &params.CreateWalletRequest{UserID: userID, Currency: "USD"})
+	assert.Nil(t, resp)
+	assert.Equal(t, 409, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "CreateIfAbsent", mock.Anything, mock.Anything)
+}
+
 func TestGetBalance_Success(t *testing.T) {
 	mockRepo, _, _, uc, _ := setupTest(t)
 
@@ -96,23 +196,149 @@ func TestGetBalance_Success(t *testing.T) {
 
 	mockRepo.On("GetByUserID", mock.Anything, userID).Return(mockWallet, nil)
 
-	resp, err := uc.GetBalance(context.Background(), userID)
+	resp, err := uc.GetBalance(context.Background(), userID, false)
 
 	assert.Nil(t, err)
 	assert.NotNil(t, resp)
 	assert.Equal(t, 10000.0, resp.Balance)
+	assert.Equal(t, 10000.0, resp.AvailableBalance)
 	assert.Equal(t, "IDR", resp.Currency)
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetBalance_AvailableExcludesHolds(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{UserID: userID, Balance: 1000, HeldBalance: 300, Currency: "IDR"}, nil)
+
+	resp, err := uc.GetBalance(context.Background(), userID, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1000.0, resp.Balance)
+	assert.Equal(t, 300.0, resp.HeldBalance)
+	assert.Equal(t, 700.0, resp.AvailableBalance)
+}
+
+func TestGetBalance_IncludesCachedTransactionCount(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Balance: 1000, Currency: "IDR"}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, mock.Anything).Return(int64(42), nil).Once()
+
+	without, err := uc.GetBalance(context.Background(), userID, false)
+	assert.Nil(t, err)
+	assert.Nil(t, without.TransactionCount)
+
+	for i := 0; i < 2; i++ {
+		resp, err := uc.GetBalance(context.Background(), userID, true)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(42), *resp.TransactionCount)
+	}
+	mockRepo.AssertNumberOfCalls(t, "CountTransactionsByWalletID", 1)
+}
+
+func TestListHolds_TransferRequestsAndClearingDeposits(t *testing.T) {
+	mockRepo, _, rdb, _, _ := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DepositHold: 24 * time.Hour})
+	userID, walletID := uuid.New(), uuid.New()
+	now := time.Now()
+	requestID, depositID := uuid.New(), uuid.New()
+	clearedAt := now.Add(12 * time.Hour)
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Balance: 1000, HeldBalance: 0.1, Currency: "IDR"}, nil)
+	mockRepo.On("ListPendingTransferRequests", mock.Anything, walletID).Return([]*entity.TransferRequest{
+		{ID: requestID, FromWalletID: walletID, Amount: 0.1, CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(22 * time.Hour)},
+	}, nil)
+	mockRepo.On("ListClearingDeposits", mock.Anything, walletID, mock.AnythingOfType("time.Time")).Return([]*entity.Transaction{
+		{ID: depositID, WalletID: walletID, Type: entity.TransactionTypeDeposit, Amount: 0.2, CreatedAt: now.Add(-time.Hour), ClearedAt: &clearedAt},
+	}, nil)
+
+	resp, err := uc.ListHolds(context.Background(), userID)
+
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Holds, 2) {
+		assert.Equal(t, depositID, resp.Holds[0].ID)
+		assert.Equal(t, "deposit", resp.Holds[0].Type)
+		assert.Equal(t, clearedAt, resp.Holds[0].ExpiresAt)
+		assert.Equal(t, requestID, resp.Holds[1].ID)
+		assert.Equal(t, "transfer_request", resp.Holds[1].Type)
+	}
+	assert.Equal(t, 0.3, resp.TotalHeld)
+	assert.Equal(t, 999.7, resp.AvailableBalance)
+}
+
+func TestListHolds_EmptyWithoutHoldPeriod(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Balance: 1000, Currency: "IDR"}, nil)
+	mockRepo.On("ListPendingTransferRequests", mock.Anything, walletID).Return([]*entity.TransferRequest{}, nil)
+
+	resp, err := uc.ListHolds(context.Background(), userID)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp.Holds)
+	assert.Empty(t, resp.Holds)
+	assert.Equal(t, 1000.0, resp.AvailableBalance)
+	mockRepo.AssertNotCalled(t, "ListClearingDeposits", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetBalance_CoalescesConcurrentReads(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockRepo.On("GetByUserID", mock.Anything, userID).
+		Run(func(args mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return(&entity.Wallet{UserID: userID, Balance: 500, Currency: "IDR"}, nil).Once()
+
+	const readers = 10
+	var wg sync.WaitGroup
+	balances := make(chan float64, readers)
+	read := func() {
+		defer wg.Done()
+		resp, err := uc.GetBalance(context.Background(), userID, false)
+		if assert.Nil(t, err) {
+			balances <- resp.Balance
+		}
+	}
+
+	wg.Add(1)
+	go read()
+	<-started
+	for i := 1; i < readers; i++ {
+		wg.Add(1)
+		go read()
+	}
+	// Give the other readers time to join the query in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(balances)
+
+	assert.Len(t, balances, readers)
+	for balance := range balances {
+		assert.Equal(t, 500.0, balance)
+	}
+	mockRepo.AssertNumberOfCalls(t, "GetByUserID", 1)
+}
+
 func TestGetBalance_NotFound(t *testing.T) {
 	mockRepo, _, _, uc, _ := setupTest(t)
 
 	userID := uuid.New()
 	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, gorm.ErrRecordNotFound)
 
-	resp, err := uc.GetBalance(context.Background(), userID)
+	resp, err := uc.GetBalance(context.Background(), userID, false)
 
 	assert.Nil(t, resp)
 	assert.NotNil(t, err)
@@ -128,7 +354,7 @@ func TestGetBalance_RepositoryError(t *testing.T) {
 
 	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, expectedErr)
 
-	balance, customErr := uc.GetBalance(context.Background(), userID)
+	balance, customErr := uc.GetBalance(context.Background(), userID, false)
 
 	assert.Nil(t, balance)
 	assert.NotNil(t, customErr)
@@ -177,314 +403,2254 @@ func TestWithdraw_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
-func TestWithdraw_InvalidAmount(t *testing.T) {
-	_, _, _, uc, _ := setupTest(t)
-	userID := uuid.New()
-	req := &params.WithdrawRequest{Amount: -100}
+func TestDeposit_WalletThresholdOverridesDefault(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	notifier := &captureNotifier{sent: make(chan notify.Message, 1)}
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, notifier, usecase.WalletUsecaseConfig{AlertThreshold: 100})
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	userID, walletID := uuid.New(), uuid.New()
+	threshold := 10000.0
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 0, Currency: "IDR", Version: 1, AlertThreshold: &threshold}
+	realTx := db.Begin()
+	defer realTx.Rollback()
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "invalid amount", err.Message)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	_, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 500.0})
+
+	assert.Nil(t, err)
+	select {
+	case msg := <-notifier.sent:
+		t.Fatalf("unexpected alert: %s", msg.Subject)
+	case <-time.After(100 * time.Millisecond):
+	}
 }
 
-func TestWithdraw_BeginTxFails(t *testing.T) {
+func TestGetBalanceAt_UsesSnapshot(t *testing.T) {
 	mockRepo, _, _, uc, _ := setupTest(t)
-	userID := uuid.New()
-	req := &params.WithdrawRequest{Amount: 100.0}
-
-	mockTxWithError := &gorm.DB{Error: errors.New("failed to connect")}
+	userID, walletID, txID := uuid.New(), uuid.New(), uuid.New()
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	balance := 750.0
 
-	mockRepo.On("BeginTx", mock.Anything).Return(mockTxWithError)
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR"}, nil)
+	mockRepo.On("GetLatestTransactionAt", mock.Anything, walletID, at, false).Return(&entity.Transaction{ID: txID, BalanceAfter: &balance}, nil)
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.GetBalanceAt(context.Background(), userID, walletID, at)
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "failed to begin transaction", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, 750.0, resp.Balance)
+	assert.Equal(t, &txID, resp.TransactionID)
+	mockRepo.AssertNotCalled(t, "SumTransactionsByWalletID", mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestWithdraw_InsufficientBalance(t *testing.T) {
-	mockRepo, _, _, uc, db := setupTest(t)
-	userID := uuid.New()
-	req := &params.WithdrawRequest{Amount: 1500.0}
-	mockWallet := &entity.Wallet{Balance: 1000.0}
-	realTx := db.Begin()
-	defer realTx.Rollback()
+func TestGetBalanceHistory_CarriesBalanceForward(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)
+	opening := 100.0
+	until := time.Date(2025, 3, 6, 0, 0, 0, 0, time.UTC).Add(-time.Microsecond)
 
-	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
-	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR"}, nil)
+	mockRepo.On("GetLatestTransactionAt", mock.Anything, walletID, from.Add(-time.Microsecond), false).Return(&entity.Transaction{ID: uuid.New(), BalanceAfter: &opening}, nil)
+	mockRepo.On("GetBalanceHistory", mock.Anything, walletID, "day", repository.TransactionFilter{From: &from, To: &until}).Return([]*repository.BalanceBucket{
+		{Bucket: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), Balance: 150},
+		{Bucket: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), Balance: 80},
+	}, nil)
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.GetBalanceHistory(context.Background(), userID, walletID, "day", &from, &to)
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "insufficient balance", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Nil(t, err)
+	var balances []float64
+	for _, b := range resp.Buckets {
+		balances = append(balances, b.Balance)
+	}
+	assert.Equal(t, []float64{100, 150, 150, 80, 80}, balances)
+	assert.Equal(t, from, resp.Buckets[0].Bucket)
+	assert.Equal(t, time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC), resp.To)
 }
 
-func TestWithdraw_WalletNotFound(t *testing.T) {
-	mockRepo, _, _, uc, db := setupTest(t)
-	userID := uuid.New()
-	req := &params.WithdrawRequest{Amount: 100.0}
-	realTx := db.Begin()
-	defer realTx.Rollback()
+func TestGetBalanceHistory_WeeksStartOnMonday(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	from := time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC)
 
-	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
-	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR"}, nil)
+	mockRepo.On("GetLatestTransactionAt", mock.Anything, walletID, mock.Anything, false).Return(nil, nil)
+	mockRepo.On("GetBalanceHistory", mock.Anything, walletID, "week", mock.Anything).Return([]*repository.BalanceBucket{}, nil)
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.GetBalanceHistory(context.Background(), userID, walletID, "week", &from, &to)
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "wallet not found", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Buckets, 3) {
+		assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), resp.Buckets[0].Bucket)
+		assert.Equal(t, time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC), resp.Buckets[2].Bucket)
+		assert.Equal(t, 0.0, resp.Buckets[2].Balance)
+	}
 }
 
-func TestWithdraw_GetForUpdateFails_GenericError(t *testing.T) {
-	mockRepo, _, _, uc, db := setupTest(t)
-	userID := uuid.New()
-	req := &params.WithdrawRequest{Amount: 100.0}
-	realTx := db.Begin()
-	defer realTx.Rollback()
-
-	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
-	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(nil, errors.New("unexpected db error"))
+func TestGetBalanceHistory_RejectsTooManyBuckets(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.GetBalanceHistory(context.Background(), uuid.New(), uuid.New(), "day", &from, &to)
 
 	assert.Nil(t, resp)
 	assert.NotNil(t, err)
-	assert.Equal(t, "failed to get wallet for update", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Equal(t, 400, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
 }
 
-func TestWithdraw_CreateTransactionFails(t *testing.T) {
-	mockRepo, _, _, uc, db := setupTest(t)
+func TestGetBalanceAt_NoTransactionsYet(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
 	userID, walletID := uuid.New(), uuid.New()
-	req := &params.WithdrawRequest{Amount: 500.0}
-	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
-	realTx := db.Begin()
-	defer realTx.Rollback()
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
 
-	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
-	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
-	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(errors.New("db write error"))
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR"}, nil)
+	mockRepo.On("GetLatestTransactionAt", mock.Anything, walletID, at, false).Return(nil, nil)
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.GetBalanceAt(context.Background(), userID, walletID, at)
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "failed to create transaction", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, resp.Balance)
+	assert.Nil(t, resp.TransactionID)
 }
 
-func TestWithdraw_UpdateBalanceFails(t *testing.T) {
-	mockRepo, _, _, uc, db := setupTest(t)
+func TestGetBalanceAt_ReplaysWithoutSnapshot(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
 	userID, walletID := uuid.New(), uuid.New()
-	req := &params.WithdrawRequest{Amount: 500.0}
-	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
-	realTx := db.Begin()
-	defer realTx.Rollback()
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
 
-	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
-	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
-	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
-	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(errors.New("db conflict"))
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR"}, nil)
+	mockRepo.On("GetLatestTransactionAt", mock.Anything, walletID, at, false).Return(&entity.Transaction{ID: uuid.New()}, nil)
+	mockRepo.On("SumTransactionsByWalletID", mock.Anything, walletID, repository.TransactionFilter{To: &at}).Return(1000.0, 250.5, nil)
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.GetBalanceAt(context.Background(), userID, walletID, at)
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "failed to update wallet balance", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, 749.5, resp.Balance)
 }
 
-func TestWithdraw_UpdateTransactionStatusFails(t *testing.T) {
-	mockRepo, _, _, uc, db := setupTest(t)
-	userID, walletID := uuid.New(), uuid.New()
-	req := &params.WithdrawRequest{Amount: 500.0}
-	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
-	realTx := db.Begin()
-	defer realTx.Rollback()
+func TestGetBalanceAt_OtherUsersWallet(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	walletID := uuid.New()
 
-	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
-	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
-	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
-	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(nil)
-	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(errors.New("db status update error"))
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: uuid.New()}, nil)
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.GetBalanceAt(context.Background(), uuid.New(), walletID, time.Now())
 
 	assert.Nil(t, resp)
 	assert.NotNil(t, err)
-	assert.Equal(t, "failed to update transaction status", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Equal(t, "wallet not found", err.Message)
+	mockRepo.AssertNotCalled(t, "GetLatestTransactionAt", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestWithdraw_CommitFails(t *testing.T) {
-	mockRepo, _, _, uc, db := setupTest(t)
+func setupStatementTest(t *testing.T) (*repository.MockWalletRepository, usecase.WalletUsecase, uuid.UUID) {
+	mockRepo, _, _, uc, _ := setupTest(t)
 	userID, walletID := uuid.New(), uuid.New()
-	req := &params.WithdrawRequest{Amount: 500.0}
-	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
-	realTx := db.Begin()
-
-	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
-	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
-	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
-	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(nil)
-	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	created := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
 
-	realTx.Rollback()
+	transactions := []*entity.Transaction{
+		{ID: uuid.New(), WalletID: walletID, Type: entity.TransactionTypeDeposit, Amount: 1000, Status: entity.TransactionStatusCompleted, Description: "Salary", CreatedAt: created},
+		{ID: uuid.New(), WalletID: walletID, Type: entity.TransactionTypeDeposit, Amount: 1000, Status: entity.TransactionStatusFailed, CreatedAt: created.Add(30 * time.Minute)},
+		{ID: uuid.New(), WalletID: walletID, Type: entity.TransactionTypeWithdraw, Amount: 250, Status: entity.TransactionStatusCompleted, Description: "ATM & cash", CreatedAt: created.Add(time.Hour)},
+	}
 
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Balance: 750, Currency: "USD", CreatedAt: created}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, repository.TransactionFilter{Ascending: true}).Return(int64(3), nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, 10000, 0, repository.TransactionFilter{Ascending: true}).Return(transactions, nil)
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "failed to commit transaction", err.Message)
-	mockRepo.AssertExpectations(t)
+	return mockRepo, uc, userID
 }
 
-func TestWithdraw_CacheInvalidationFails(t *testing.T) {
+func TestExportStatement_OFX(t *testing.T) {
+	_, uc, userID := setupStatementTest(t)
+
+	file, err := uc.ExportStatement(context.Background(), userID, "ofx", params.TransactionHistoryFilter{}, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-ofx", file.ContentType)
+	body := string(file.Data)
+	assert.Contains(t, body, `<?OFX OFXHEADER="200" VERSION="220"`)
+	assert.Contains(t, body, "<CURDEF>USD</CURDEF>")
+	assert.Contains(t, body, "<TRNTYPE>CREDIT</TRNTYPE>")
+	assert.Contains(t, body, "<TRNAMT>1000.00</TRNAMT>")
+	assert.Contains(t, body, "<TRNTYPE>DEBIT</TRNTYPE>")
+	assert.Contains(t, body, "<TRNAMT>-250.00</TRNAMT>")
+	assert.Contains(t, body, "<NAME>ATM &amp; cash</NAME>")
+	assert.Contains(t, body, "<BALAMT>750.00</BALAMT>")
+	assert.Equal(t, 2, strings.Count(body, "<STMTTRN>"), "failed transactions are excluded")
+}
+
+func TestExportStatement_QIF(t *testing.T) {
+	_, uc, userID := setupStatementTest(t)
+
+	file, err := uc.ExportStatement(context.Background(), userID, "qif", params.TransactionHistoryFilter{}, false)
+
+	assert.Nil(t, err)
+	body := string(file.Data)
+	assert.True(t, strings.HasPrefix(body, "!Account\n"))
+	assert.Contains(t, body, "!Type:Bank\nD03/01/2025\nT1000.00\n")
+	assert.Contains(t, body, "T-250.00\n")
+	assert.Contains(t, body, "(USD)")
+	assert.Equal(t, 3, strings.Count(body, "^\n"))
+}
+
+func TestExportStatement_UnknownFormat(t *testing.T) {
+	_, _, _, uc, _ := setupTest(t)
+
+	file, err := uc.ExportStatement(context.Background(), uuid.New(), "xlsx", params.TransactionHistoryFilter{}, false)
+
+	assert.Nil(t, file)
+	assert.Equal(t, 400, err.StatusCode)
+}
+
+func TestExportStatement_SignedStatementVerifies(t *testing.T) {
+	mockRepo, _, userID := setupStatementTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, nil, nil, usecase.WalletUsecaseConfig{StatementSigner: statementsig.NewHMAC([]byte("secret"))})
+
+	file, err := uc.ExportStatement(context.Background(), userID, "csv", params.TransactionHistoryFilter{}, true)
+	assert.Nil(t, err)
+	if !assert.NotNil(t, file.Signature) {
+		return
+	}
+	assert.Equal(t, statementsig.AlgorithmHMACSHA256, file.Signature.Algorithm)
+
+	result, err := uc.VerifyStatement(context.Background(), file.Data, file.Signature)
+	assert.Nil(t, err)
+	assert.True(t, result.Valid)
+
+	// Any change to the amounts or the signed metadata is detected.
+	tampered := []byte(strings.Replace(string(file.Data), "-250.00", "-25.00", 1))
+	result, err = uc.VerifyStatement(context.Background(), tampered, file.Signature)
+	assert.Nil(t, err)
+	assert.False(t, result.Valid)
+
+	otherVersion := *file.Signature
+	otherVersion.WalletVersion++
+	result, err = uc.VerifyStatement(context.Background(), file.Data, &otherVersion)
+	assert.Nil(t, err)
+	assert.False(t, result.Valid)
+}
+
+func TestExportStatement_SigningDisabled(t *testing.T) {
+	_, uc, userID := setupStatementTest(t)
+
+	file, err := uc.ExportStatement(context.Background(), userID, "csv", params.TransactionHistoryFilter{}, true)
+
+	assert.Nil(t, file)
+	assert.Equal(t, 400, err.StatusCode)
+}
+
+func TestTransfer_Success(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, recipientID := uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000, Currency: "IDR", Version: 3}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Balance: 50, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, &sql.TxOptions{Isolation: sql.LevelSerializable}).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, senderID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipientID).Return(recipient, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.MatchedBy(func(tr *entity.Transaction) bool {
+		return tr.Type == entity.TransactionTypeTransferOut && tr.WalletID == sender.ID && *tr.BalanceAfter == 700
+	})).Return(nil).Once()
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.MatchedBy(func(tr *entity.Transaction) bool {
+		return tr.Type == entity.TransactionTypeTransferIn && tr.WalletID == recipient.ID && *tr.BalanceAfter == 350
+	})).Return(nil).Once()
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, sender.ID, 700.0, 4).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, recipient.ID, 350.0, 2).Return(nil)
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 300})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 700.0, resp.NewBalance)
+	assert.Equal(t, entity.TransactionStatusCompleted, resp.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransfer_RetriesSerializationFailure(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, recipientID := uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000, Currency: "IDR", Version: 3}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Balance: 50, Currency: "IDR", Version: 1}
+	firstTx, secondTx := db.Begin(), db.Begin()
+	defer secondTx.Rollback()
+
+	serializable := &sql.TxOptions{Isolation: sql.LevelSerializable}
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, serializable).Return(firstTx).Once()
+	mockRepo.On("BeginTxWithOptions", mock.Anything, serializable).Return(secondTx).Once()
+	mockRepo.On("WithTx", mock.Anything).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, mock.Anything, recipientID).Return(recipient, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, firstTx, sender.ID, 700.0, 4).Return(&pgconn.PgError{Code: "40001"}).Once()
+	mockRepo.On("UpdateBalance", mock.Anything, secondTx, sender.ID, 700.0, 4).Return(nil).Once()
+	mockRepo.On("UpdateBalance", mock.Anything, secondTx, recipient.ID, 350.0, 2).Return(nil).Once()
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 300})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 700.0, resp.NewBalance)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransfer_GivesUpAfterRepeatedSerializationFailures(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, recipientID := uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000, Currency: "IDR", Version: 3}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Balance: 50, Currency: "IDR", Version: 1}
+
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	for i := 0; i < 3; i++ {
+		mockRepo.On("BeginTxWithOptions", mock.Anything, mock.Anything).Return(db.Begin()).Once()
+	}
+	mockRepo.On("WithTx", mock.Anything).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, mock.Anything, mock.Anything).Return(nil, &pgconn.PgError{Code: "40001"})
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 300})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.StatusCode)
+	mockRepo.AssertNumberOfCalls(t, "BeginTxWithOptions", 3)
+}
+
+func TestTransfer_ConvertsBetweenCurrencies(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{
+		RateProvider: fxrate.NewStatic(map[string]float64{"USD/IDR": 16000}),
+	})
+	senderID, recipientID := uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000000, Currency: "IDR", Version: 1}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Balance: 10, Currency: "USD", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	// 200,000 IDR at 1/16000 is 12.50 USD; both legs record the conversion.
+	converted := func(tr *entity.Transaction) bool {
+		return tr.ExchangeRate != nil && *tr.ExchangeRate == 1/16000.0 &&
+			*tr.OriginalAmount == 200000 && *tr.ConvertedAmount == 12.5
+	}
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, &sql.TxOptions{Isolation: sql.LevelSerializable}).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, senderID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipientID).Return(recipient, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.MatchedBy(func(tr *entity.Transaction) bool {
+		return tr.Type == entity.TransactionTypeTransferOut && tr.Amount == 200000 && converted(tr)
+	})).Return(nil).Once()
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.MatchedBy(func(tr *entity.Transaction) bool {
+		return tr.Type == entity.TransactionTypeTransferIn && tr.Amount == 12.5 && *tr.BalanceAfter == 22.5 && converted(tr)
+	})).Return(nil).Once()
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, sender.ID, 800000.0, 2).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, recipient.ID, 22.5, 2).Return(nil)
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 200000})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 800000.0, resp.NewBalance)
+	assert.Equal(t, 12.5, *resp.ConvertedAmount)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransfer_RejectedWithoutExchangeRate(t *testing.T) {
+	mockRepo, _, rdb, _, _ := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{
+		RateProvider: fxrate.NewStatic(map[string]float64{"USD/IDR": 16000}),
+	})
+	senderID := uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000, Currency: "IDR", Version: 1}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "EUR", Version: 1}
+
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 100})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 422, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+}
+
+func TestTransfer_InsufficientBalance(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, recipientID := uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 100, Currency: "IDR", Version: 1}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, &sql.TxOptions{Isolation: sql.LevelSerializable}).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, senderID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipientID).Return(recipient, nil)
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 300})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, "insufficient balance", err.Message)
+	mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTransfer_WithTipDebitsSenderOnceAndLinksLegs(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, recipientID, tipID := uuid.New(), uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000, Currency: "IDR", Version: 3}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Balance: 50, Currency: "IDR", Version: 1}
+	tipWallet := &entity.Wallet{ID: uuid.New(), UserID: tipID, Balance: 10, Currency: "IDR", Version: 2}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("GetByID", mock.Anything, tipWallet.ID).Return(tipWallet, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, &sql.TxOptions{Isolation: sql.LevelSerializable}).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, senderID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipientID).Return(recipient, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, tipID).Return(tipWallet, nil)
+	var legs []*entity.Transaction
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Run(func(args mock.Arguments) {
+		legs = append(legs, args.Get(2).(*entity.Transaction))
+	}).Return(nil).Times(3)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, sender.ID, 670.0, 4).Return(nil).Once()
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, recipient.ID, 350.0, 2).Return(nil).Once()
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, tipWallet.ID, 40.0, 3).Return(nil).Once()
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{
+		ToWalletID: recipient.ID,
+		Amount:     300,
+		Tip:        &params.TransferTip{ToWalletID: tipWallet.ID, Amount: 30},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 670.0, resp.NewBalance)
+	assert.Equal(t, 30.0, resp.Tip.Amount)
+	mockRepo.AssertExpectations(t)
+
+	if assert.Len(t, legs, 3) {
+		out := legs[0]
+		assert.Equal(t, entity.TransactionTypeTransferOut, out.Type)
+		assert.Equal(t, 330.0, out.Amount)
+		for _, leg := range legs {
+			assert.Equal(t, out.ID, *leg.TransferID)
+		}
+		assert.Equal(t, []float64{300, 30}, []float64{legs[1].Amount, legs[2].Amount})
+	}
+}
+
+func TestTransfer_WithTipInsufficientForTotal(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, recipientID, tipID := uuid.New(), uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 310, Currency: "IDR", Version: 1}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Currency: "IDR", Version: 1}
+	tipWallet := &entity.Wallet{ID: uuid.New(), UserID: tipID, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByUserID", mock.Anything, senderID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("GetByID", mock.Anything, tipWallet.ID).Return(tipWallet, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, &sql.TxOptions{Isolation: sql.LevelSerializable}).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, senderID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipientID).Return(recipient, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, tipID).Return(tipWallet, nil)
+
+	resp, err := uc.Transfer(context.Background(), senderID, &params.TransferRequest{
+		ToWalletID: recipient.ID,
+		Amount:     300,
+		Tip:        &params.TransferTip{ToWalletID: tipWallet.ID, Amount: 30},
+	})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, "insufficient balance", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTransfer_SameWallet(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 100, Currency: "IDR"}
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(wallet, nil)
+	mockRepo.On("GetByID", mock.Anything, wallet.ID).Return(wallet, nil)
+
+	resp, err := uc.Transfer(context.Background(), userID, &params.TransferRequest{ToWalletID: wallet.ID, Amount: 10})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, "cannot transfer to the same wallet", err.Message)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+}
+
+func TestCreateTransferRequest_HoldsAmount(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, HeldBalance: 200, Currency: "IDR", Version: 2}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("UpdateHeldBalance", mock.Anything, realTx, wallet.ID, 500.0, 3).Return(nil)
+	mockRepo.On("CreateTransferRequest", mock.Anything, realTx, mock.MatchedBy(func(r *entity.TransferRequest) bool {
+		return r.Status == entity.TransferRequestStatusPending && r.Amount == 300 && r.ExpiresAt.After(time.Now().Add(23*time.Hour))
+	})).Return(nil)
+
+	resp, err := uc.CreateTransferRequest(context.Background(), userID, &params.PendingTransferRequest{ToWalletID: recipient.ID, Amount: 300})
+
+	assert.Nil(t, err)
+	assert.Equal(t, entity.TransferRequestStatusPending, resp.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTransferRequest_HeldFundsUnavailable(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, HeldBalance: 800, Currency: "IDR", Version: 2}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+
+	resp, err := uc.CreateTransferRequest(context.Background(), userID, &params.PendingTransferRequest{ToWalletID: recipient.ID, Amount: 300})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, "insufficient balance", err.Message)
+	mockRepo.AssertNotCalled(t, "UpdateHeldBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateTransferRequest_OwnExpiryWindow(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, Currency: "IDR", Version: 1}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	tooLong := 8 * 24 * 60
+	resp, err := uc.CreateTransferRequest(context.Background(), userID, &params.PendingTransferRequest{ToWalletID: recipient.ID, Amount: 300, ExpiresInMinutes: &tooLong})
+	assert.Nil(t, resp)
+	assert.Equal(t, 400, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("UpdateHeldBalance", mock.Anything, realTx, wallet.ID, 300.0, 2).Return(nil)
+	mockRepo.On("CreateTransferRequest", mock.Anything, realTx, mock.MatchedBy(func(r *entity.TransferRequest) bool {
+		return r.ExpiresAt.Before(time.Now().Add(31*time.Minute)) && r.ExpiresAt.After(time.Now().Add(29*time.Minute))
+	})).Return(nil)
+
+	window := 30
+	resp, err = uc.CreateTransferRequest(context.Background(), userID, &params.PendingTransferRequest{ToWalletID: recipient.ID, Amount: 300, ExpiresInMinutes: &window})
+
+	assert.Nil(t, err)
+	assert.Equal(t, entity.TransferRequestStatusPending, resp.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExpireTransferRequests_SkipsWhileAnotherInstanceRuns(t *testing.T) {
+	mockRepo, mr, _, uc, _ := setupTest(t)
+	mr.Set("transfer-request-expiry-lock", "other-instance")
+
+	expired, err := uc.ExpireTransferRequests(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	mockRepo.AssertNotCalled(t, "ListExpiredTransferRequests", mock.Anything, mock.Anything, mock.Anything)
+
+	mr.Del("transfer-request-expiry-lock")
+	mockRepo.On("ListExpiredTransferRequests", mock.Anything, mock.AnythingOfType("time.Time"), 100).Return([]*entity.TransferRequest{}, nil).Once()
+
+	expired, err = uc.ExpireTransferRequests(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	assert.False(t, mr.Exists("transfer-request-expiry-lock"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestApproveTransferRequest_RequesterCannotApprove(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	request := &entity.TransferRequest{ID: uuid.New(), RequestedBy: userID, Amount: 100, Status: entity.TransferRequestStatusPending, ExpiresAt: time.Now().Add(time.Hour)}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetTransferRequestForUpdate", mock.Anything, realTx, request.ID).Return(request, nil)
+
+	resp, err := uc.ApproveTransferRequest(context.Background(), userID, request.ID)
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 403, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "UpdateTransferRequest", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApproveTransferRequest_MovesHeldFunds(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, recipientID, approverID := uuid.New(), uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000, HeldBalance: 300, Currency: "IDR", Version: 3}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: recipientID, Balance: 50, Currency: "IDR", Version: 1}
+	request := &entity.TransferRequest{ID: uuid.New(), FromWalletID: sender.ID, ToWalletID: recipient.ID, RequestedBy: senderID, Amount: 300, Status: entity.TransferRequestStatusPending, ExpiresAt: time.Now().Add(time.Hour)}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetTransferRequestForUpdate", mock.Anything, realTx, request.ID).Return(request, nil)
+	mockRepo.On("GetByID", mock.Anything, sender.ID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, senderID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipientID).Return(recipient, nil)
+	mockRepo.On("UpdateHeldBalance", mock.Anything, realTx, sender.ID, 0.0, 4).Return(nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil).Twice()
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, sender.ID, 700.0, 5).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, recipient.ID, 350.0, 2).Return(nil)
+	mockRepo.On("UpdateTransferRequest", mock.Anything, realTx, mock.MatchedBy(func(r *entity.TransferRequest) bool {
+		return r.Status == entity.TransferRequestStatusApproved && *r.DecidedBy == approverID && r.TransactionID != nil
+	})).Return(nil)
+
+	resp, err := uc.ApproveTransferRequest(context.Background(), approverID, request.ID)
+
+	assert.Nil(t, err)
+	assert.Equal(t, entity.TransferRequestStatusApproved, resp.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRejectTransferRequest_ReleasesHold(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	senderID, approverID := uuid.New(), uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: senderID, Balance: 1000, HeldBalance: 300, Currency: "IDR", Version: 3}
+	request := &entity.TransferRequest{ID: uuid.New(), FromWalletID: sender.ID, RequestedBy: senderID, Amount: 300, Status: entity.TransferRequestStatusPending, ExpiresAt: time.Now().Add(time.Hour)}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetTransferRequestForUpdate", mock.Anything, realTx, request.ID).Return(request, nil)
+	mockRepo.On("GetByID", mock.Anything, sender.ID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, senderID).Return(sender, nil)
+	mockRepo.On("UpdateHeldBalance", mock.Anything, realTx, sender.ID, 0.0, 4).Return(nil)
+	mockRepo.On("UpdateTransferRequest", mock.Anything, realTx, mock.MatchedBy(func(r *entity.TransferRequest) bool {
+		return r.Status == entity.TransferRequestStatusRejected
+	})).Return(nil)
+
+	resp, err := uc.RejectTransferRequest(context.Background(), approverID, request.ID)
+
+	assert.Nil(t, err)
+	assert.Equal(t, entity.TransferRequestStatusRejected, resp.Status)
+	mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRejectTransferRequest_AlreadyDecided(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	request := &entity.TransferRequest{ID: uuid.New(), Status: entity.TransferRequestStatusApproved}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetTransferRequestForUpdate", mock.Anything, realTx, request.ID).Return(request, nil)
+
+	resp, err := uc.RejectTransferRequest(context.Background(), uuid.New(), request.ID)
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 409, err.StatusCode)
+}
+
+type captureNotifier struct {
+	sent chan notify.Message
+	err  error
+}
+
+func (n *captureNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	n.sent <- msg
+	return n.err
+}
+
+func TestWithdraw_AboveThresholdSendsAlert(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	// A failing notifier must not fail the withdrawal.
+	notifier := &captureNotifier{sent: make(chan notify.Message, 1), err: errors.New("smtp down")}
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, notifier, usecase.WalletUsecaseConfig{AlertThreshold: 1000})
+
+	userID, walletID := uuid.New(), uuid.New()
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 5000.0, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 3000.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 2000.0})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+
+	select {
+	case msg := <-notifier.sent:
+		assert.Equal(t, userID, msg.UserID)
+		assert.Contains(t, msg.Body, "2000.00 IDR")
+	case <-time.After(time.Second):
+		t.Fatal("expected a large withdrawal alert")
+	}
+}
+
+func TestWithdraw_InvalidAmount(t *testing.T) {
+	_, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+	req := &params.WithdrawRequest{Amount: -100}
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "invalid amount", err.Message)
+}
+
+func TestDeposit_AtMaxStorableAmount(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 0, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, usecase.MaxStorableAmount, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: usecase.MaxStorableAmount})
+
+	assert.Nil(t, err)
+	assert.Equal(t, usecase.MaxStorableAmount, resp.NewBalance)
+}
+
+func TestDeposit_AboveMaxStorableAmount(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+
+	resp, err := uc.Deposit(context.Background(), uuid.New(), &params.DepositRequest{Amount: 10000000000000.00})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "ERR0005", err.Code)
+	assert.Equal(t, "amount exceeds the maximum of 9999999999999.99", err.Message)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+}
+
+func TestDeposit_WouldOverflowBalance(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	mockWallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: usecase.MaxStorableAmount - 1, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 2})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "ERR0007", err.Code)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeposit_BelowMinimumDeposit(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{MinimumDeposits: map[string]float64{"IDR": 10000}})
+	userID := uuid.New()
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(&entity.Wallet{ID: uuid.New(), UserID: userID, Currency: "IDR", Version: 1}, nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 9999.99})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, 400, err.StatusCode)
+	assert.Equal(t, "deposit is below the minimum of 10000.00 IDR", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeposit_AtMinimumDeposit(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{MinimumDeposits: map[string]float64{"IDR": 10000}})
+	userID, walletID := uuid.New(), uuid.New()
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR", Version: 1}, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 10000.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 10000})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 10000.0, resp.NewBalance)
+}
+
+func TestDeposit_GeneratesMissingDescription(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	tmpl, tmplErr := usecase.ParseDescriptionTemplate("")
+	assert.NoError(t, tmplErr)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DescriptionTemplate: tmpl})
+	userID, walletID := uuid.New(), uuid.New()
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	var description string
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR", Version: 1}, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Run(func(args mock.Arguments) {
+		description = args.Get(2).(*entity.Transaction).Description
+	}).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, mock.Anything, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	_, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 1234567.5})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Deposit of IDR 1,234,567.50 on "+time.Now().UTC().Format("2006-01-02"), description)
+}
+
+func TestParseDescriptionTemplate_UnknownField(t *testing.T) {
+	_, err := usecase.ParseDescriptionTemplate("{{.Operation}} by {{.User}}")
+	assert.Error(t, err)
+}
+
+func TestWithdraw_ConfiguredMaxAmount(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{MaxAmount: 1000})
+	userID, walletID := uuid.New(), uuid.New()
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 5000, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx).Once()
+	mockRepo.On("WithTx", realTx).Return(mockRepo).Once()
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil).Once()
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil).Once()
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 4000.0, 2).Return(nil).Once()
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil).Once()
+
+	_, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 1000})
+	assert.Nil(t, err)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 1000.01})
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "amount exceeds the maximum of 1000.00", err.Message)
+}
+
+func TestWithdraw_BeginTxFails(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+	req := &params.WithdrawRequest{Amount: 100.0}
+
+	mockTxWithError := &gorm.DB{Error: errors.New("failed to connect")}
+
+	mockRepo.On("BeginTx", mock.Anything).Return(mockTxWithError)
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to begin transaction", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_InsufficientBalance(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	req := &params.WithdrawRequest{Amount: 1500.0}
+	mockWallet := &entity.Wallet{Balance: 1000.0}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "insufficient balance", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_InsufficientBalanceLogsEvent(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger, hook := test.NewNullLogger()
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{})
+	userID := uuid.New()
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(&entity.Wallet{Balance: 1000.0}, nil)
+
+	_, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 1500.0})
+
+	assert.NotNil(t, err)
+	if assert.NotNil(t, hook.LastEntry()) {
+		assert.Equal(t, "wallet.withdraw.insufficient_balance", hook.LastEntry().Data["event"])
+	}
+}
+
+func TestWithdraw_BelowMinimumBalance(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{MinimumBalances: map[string]float64{"IDR": 200}})
+	userID := uuid.New()
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(&entity.Wallet{Balance: 1000, Currency: "IDR"}, nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 900})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, 422, err.StatusCode)
+	assert.Contains(t, err.Message, "at most 800.00 can be withdrawn")
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWithdraw_WalletNotFound(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	req := &params.WithdrawRequest{Amount: 100.0}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(nil, gorm.ErrRecordNotFound)
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "wallet not found", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_GetForUpdateFails_GenericError(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	req := &params.WithdrawRequest{Amount: 100.0}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(nil, errors.New("unexpected db error"))
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to get wallet for update", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_LockTimeoutReturnsConflict(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	req := &params.WithdrawRequest{Amount: 100.0}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(nil, repository.ErrLockTimeout)
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.StatusCode)
+	assert.Equal(t, "wallet is busy, please retry", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_CreateTransactionFails(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	req := &params.WithdrawRequest{Amount: 500.0}
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(errors.New("db write error"))
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to create transaction", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_UpdateBalanceFails(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	req := &params.WithdrawRequest{Amount: 500.0}
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(errors.New("db conflict"))
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to update wallet balance", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_UpdateTransactionStatusFails(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	req := &params.WithdrawRequest{Amount: 500.0}
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(errors.New("db status update error"))
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to update transaction status", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_CommitFails(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	req := &params.WithdrawRequest{Amount: 500.0}
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
+	realTx := db.Begin()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	realTx.Rollback()
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to commit transaction", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_CacheInvalidationFails(t *testing.T) {
 	mockRepo, mr, _, uc, db := setupTest(t)
 	userID, walletID := uuid.New(), uuid.New()
-	req := &params.WithdrawRequest{Amount: 500.0}
-	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
+	req := &params.WithdrawRequest{Amount: 500.0}
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000.0, Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	mr.SetError("redis is down")
+
+	resp, err := uc.Withdraw(context.Background(), userID, req)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, 500.0, resp.NewBalance)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_CacheHit(t *testing.T) {
+	mockRepo, _, rdb, uc, _ := setupTest(t)
+	userID := uuid.New()
+	limit, offset, page := 10, 0, 1
+	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID.String(), page, limit)
+
+	expectedResp := &params.TransactionHistoryResponse{Total: 1, Page: page}
+	cachedData, _ := json.Marshal(expectedResp)
+	rdb.Set(context.Background(), cacheKey, cachedData, time.Minute)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, expectedResp.Total, resp.Total)
+	mockRepo.AssertNotCalled(t, "GetByUserID")
+}
+
+// commandRecorder is a redis hook recording the name of every command sent.
+type commandRecorder struct {
+	names []string
+}
+
+func (r *commandRecorder) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (r *commandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		r.names = append(r.names, cmd.Name())
+		return next(ctx, cmd)
+	}
+}
+
+func (r *commandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestGetTransactionHistory_SkipCacheSkipsReadAndRefreshesCache(t *testing.T) {
+	mockRepo, _, rdb, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID.String(), 1, 10)
+	stale, _ := json.Marshal(&params.TransactionHistoryResponse{Total: 1, Page: 1})
+	rdb.Set(context.Background(), cacheKey, stale, time.Minute)
+	commands := &commandRecorder{}
+	rdb.AddHook(commands)
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, 10, 0, mock.Anything).Return([]*entity.Transaction{{ID: uuid.New(), Amount: 100}, {ID: uuid.New(), Amount: 50}}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, mock.Anything).Return(int64(2), nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, 10, 0, params.TransactionHistoryFilter{SkipCache: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), resp.Total)
+	assert.NotContains(t, commands.names, "get")
+	assert.Contains(t, commands.names, "set")
+	var cached params.TransactionHistoryResponse
+	val, _ := rdb.Get(context.Background(), cacheKey).Result()
+	assert.NoError(t, json.Unmarshal([]byte(val), &cached))
+	assert.Equal(t, int64(2), cached.Total)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_CacheFlagOffBypassesCache(t *testing.T) {
+	mockRepo, _, rdb, _, _ := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{Flags: &featureflag.Flags{HistoryCache: false}})
+	userID, walletID := uuid.New(), uuid.New()
+	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID.String(), 1, 10)
+	stale, _ := json.Marshal(&params.TransactionHistoryResponse{Total: 99, Page: 1})
+	rdb.Set(context.Background(), cacheKey, stale, time.Minute)
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, 10, 0, mock.Anything).Return([]*entity.Transaction{{ID: uuid.New(), Amount: 100}}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, mock.Anything).Return(int64(1), nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, 10, 0, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), resp.Total)
+	val, _ := rdb.Get(context.Background(), cacheKey).Result()
+	assert.Equal(t, string(stale), val)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_UnalignedOffsetSkipsPageCache(t *testing.T) {
+	mockRepo, _, rdb, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID.String(), 1, 10)
+	cachedData, _ := json.Marshal(&params.TransactionHistoryResponse{Total: 99, Page: 1})
+	rdb.Set(context.Background(), cacheKey, cachedData, time.Minute)
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, 10, 5, mock.Anything).Return([]*entity.Transaction{}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, mock.Anything).Return(int64(12), nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, 10, 5, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), resp.Total)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_CacheMiss_Success(t *testing.T) {
+	mockRepo, _, rdb, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	limit, offset, page := 10, 0, 1
+	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID.String(), page, limit)
+
+	mockWallet := &entity.Wallet{ID: walletID}
+	mockTransactions := []*entity.Transaction{{ID: uuid.New(), Amount: 100}}
+	var totalCount int64 = 1
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(mockWallet, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset, repository.TransactionFilter{}).Return(mockTransactions, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, repository.TransactionFilter{}).Return(totalCount, nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+	assert.Len(t, resp.Transactions, 1)
+	assert.Equal(t, totalCount, resp.Total)
+	mockRepo.AssertExpectations(t)
+
+	cachedVal, cacheErr := rdb.Get(context.Background(), cacheKey).Result()
+	assert.NoError(t, cacheErr)
+	assert.NotEmpty(t, cachedVal)
+}
+
+func TestGetTransactionHistory_ReusesCachedCountAcrossPages(t *testing.T) {
+	mockRepo, mr, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, 10, mock.Anything, mock.Anything).Return([]*entity.Transaction{{ID: uuid.New(), Amount: 100}}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, mock.Anything).Return(int64(25), nil).Once()
+
+	for _, offset := range []int{0, 10, 20} {
+		resp, err := uc.GetTransactionHistory(context.Background(), userID, 10, offset, params.TransactionHistoryFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(25), resp.Total)
+		assert.Equal(t, 3, resp.TotalPages)
+	}
+
+	mockRepo.AssertNumberOfCalls(t, "CountTransactionsByWalletID", 1)
+	countKey := fmt.Sprintf("transactions:%s:count", userID)
+	pageKey := fmt.Sprintf("transactions:%s:%d:%d", userID, 1, 10)
+	assert.Greater(t, mr.TTL(countKey), mr.TTL(pageKey))
+}
+
+func TestGetTransactionHistory_EmptyWallet(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	limit, offset := 10, 0
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset, repository.TransactionFilter{}).Return([]*entity.Transaction{}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, repository.TransactionFilter{}).Return(int64(0), nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, err)
+	assert.Empty(t, resp.Transactions)
+	assert.Equal(t, int64(0), resp.Total)
+	assert.Equal(t, 0, resp.Page)
+	assert.Equal(t, 0, resp.TotalPages)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_WalletNotFound(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+	limit, offset := 10, 0
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, gorm.ErrRecordNotFound)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "wallet not found", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_GetForUpdateFails_GenericError(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+	limit, offset := 10, 0
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, errors.New("unexpected db error"))
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to get wallet", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_GetTransactionsFails(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	limit, offset := 10, 0
+	mockWallet := &entity.Wallet{ID: walletID}
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(mockWallet, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset, repository.TransactionFilter{}).Return(nil, errors.New("db error"))
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to get transaction history", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_CountFails(t *testing.T) {
+	mockRepo, mr, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	limit, offset := 10, 0
+	mockWallet := &entity.Wallet{ID: walletID}
+
+	mr.SetError("cache miss")
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(mockWallet, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset, repository.TransactionFilter{}).Return([]*entity.Transaction{}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, repository.TransactionFilter{}).Return(int64(0), errors.New("db count error"))
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "failed to get total transactions", err.Message)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_IncludesArchivedBeyondRetention(t *testing.T) {
+	mockRepo := new(repository.MockWalletRepository)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{TransactionRetention: 24 * time.Hour})
+
+	userID, walletID := uuid.New(), uuid.New()
+	limit, offset := 10, 0
+	from := time.Now().Add(-48 * time.Hour)
+	expectedFilter := repository.TransactionFilter{From: &from, IncludeArchived: true}
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset, expectedFilter).Return([]*entity.Transaction{}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, expectedFilter).Return(int64(0), nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{From: &from})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTransactionHistory_IncludeTotals(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	limit, offset := 10, 0
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset, repository.TransactionFilter{}).Return([]*entity.Transaction{{ID: uuid.New(), Amount: 100}}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, repository.TransactionFilter{}).Return(int64(30), nil)
+	mockRepo.On("SumTransactionsByWalletID", mock.Anything, walletID, repository.TransactionFilter{}).Return(1500.0, 400.0, nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{IncludeTotals: true})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp.TotalDeposited)
+	assert.NotNil(t, resp.TotalWithdrawn)
+	assert.Equal(t, 1500.0, *resp.TotalDeposited)
+	assert.Equal(t, 400.0, *resp.TotalWithdrawn)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetActivity_AcrossWallets(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID := uuid.New()
+	idrWallet, usdWallet := uuid.New(), uuid.New()
+	limit, offset := 10, 0
+
+	rows := []*repository.UserTransaction{
+		{ID: uuid.New(), WalletID: usdWallet, Currency: "USD", Type: entity.TransactionTypeDeposit, Amount: 10},
+		{ID: uuid.New(), WalletID: idrWallet, Currency: "IDR", Type: entity.TransactionTypeWithdraw, Amount: 5000},
+	}
+	mockRepo.On("GetTransactionsByUserID", mock.Anything, userID, limit, offset, repository.TransactionFilter{}).Return(rows, nil)
+	mockRepo.On("CountTransactionsByUserID", mock.Anything, userID, repository.TransactionFilter{}).Return(int64(12), nil)
+
+	resp, err := uc.GetActivity(context.Background(), userID, limit, offset, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, err)
+	assert.Len(t, resp.Transactions, 2)
+	assert.Equal(t, usdWallet, resp.Transactions[0].WalletID)
+	assert.Equal(t, "USD", resp.Transactions[0].Currency)
+	assert.Equal(t, "IDR", resp.Transactions[1].Currency)
+	assert.Equal(t, 2, resp.TotalPages)
+	mockRepo.AssertNotCalled(t, "GetByUserID", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeposit_CreatesWalletOnFirstDeposit(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{
+		DefaultCurrency: "IDR",
+		Flags:           &featureflag.Flags{AutoCreateWallet: true},
+	})
+	userID, walletID := uuid.New(), uuid.New()
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("ExistsByUserID", mock.Anything, userID).Return(false, nil)
+	mockRepo.On("CreateIfAbsent", mock.Anything, mock.MatchedBy(func(w *entity.Wallet) bool {
+		return w.UserID == userID && w.Currency == "IDR"
+	})).Return(true, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(&entity.Wallet{ID: walletID, UserID: userID, Currency: "IDR", Version: 1}, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 250.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 250})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 250.0, resp.NewBalance)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetAlertThreshold_RecordsWalletEvent(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	previous, threshold := 100.0, 500.0
+	mockWallet := &entity.Wallet{ID: walletID, UserID: userID, Version: 1, AlertThreshold: &previous}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("UpdateAlertThreshold", mock.Anything, walletID, &threshold).Return(nil)
+	mockRepo.On("CreateWalletEvent", mock.Anything, realTx, mock.MatchedBy(func(e *entity.WalletEvent) bool {
+		return e.WalletID == walletID && e.ActorID == userID &&
+			e.Type == entity.WalletEventAlertThresholdUpdated &&
+			*e.Before == `{"alert_threshold":100}` && *e.After == `{"alert_threshold":500}`
+	})).Return(nil)
+
+	resp, err := uc.SetAlertThreshold(context.Background(), userID, &params.AlertThresholdRequest{Threshold: &threshold})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 500.0, resp.Effective)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetWalletEvents_OtherUsersWallet(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	walletID := uuid.New()
+
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: uuid.New()}, nil)
+
+	resp, err := uc.GetWalletEvents(context.Background(), uuid.New(), walletID, false, 10, 0)
+
+	assert.Nil(t, resp)
+	assert.NotNil(t, err)
+	assert.Equal(t, "wallet not found", err.Message)
+	mockRepo.AssertNotCalled(t, "GetWalletEvents", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetWalletEvents_AdminCanRead(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	walletID := uuid.New()
+	after := `{"alert_threshold":500}`
+
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, UserID: uuid.New()}, nil)
+	mockRepo.On("GetWalletEvents", mock.Anything, walletID, 10, 0).Return([]*entity.WalletEvent{
+		{ID: uuid.New(), WalletID: walletID, Type: entity.WalletEventAlertThresholdUpdated, After: &after},
+	}, nil)
+	mockRepo.On("CountWalletEvents", mock.Anything, walletID).Return(int64(1), nil)
+
+	resp, err := uc.GetWalletEvents(context.Background(), uuid.New(), walletID, true, 10, 0)
+
+	assert.Nil(t, err)
+	assert.Len(t, resp.Events, 1)
+	assert.Nil(t, resp.Events[0].Before)
+	assert.JSONEq(t, after, string(resp.Events[0].After))
+	assert.Equal(t, 1, resp.TotalPages)
+}
+
+func TestGetTransactionHistory_NilCacheFallsBackToDatabase(t *testing.T) {
+	mockRepo := new(repository.MockWalletRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, nil, nil, usecase.WalletUsecaseConfig{})
+	userID, walletID := uuid.New(), uuid.New()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID}, nil)
+	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, 10, 0, mock.Anything).Return([]*entity.Transaction{
+		{ID: uuid.New(), WalletID: walletID, Type: entity.TransactionTypeDeposit, Amount: 100, Status: entity.TransactionStatusCompleted},
+	}, nil)
+	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID, mock.Anything).Return(int64(1), nil)
+
+	resp, err := uc.GetTransactionHistory(context.Background(), userID, 10, 0, params.TransactionHistoryFilter{})
+
+	assert.Nil(t, err)
+	assert.Len(t, resp.Transactions, 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTotalBalance_PerCurrencyAndCached(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+
+	mockRepo.On("SumAllBalances", mock.Anything).Return([]*repository.CurrencyBalance{
+		{Currency: "IDR", Total: 1500000, Wallets: 3},
+		{Currency: "USD", Total: 250.5, Wallets: 1},
+	}, nil).Once()
+
+	first, err := uc.GetTotalBalance(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, first.Currencies, 2)
+	assert.Equal(t, "USD", first.Currencies[1].Currency)
+	assert.Equal(t, 250.5, first.Currencies[1].Total)
+
+	second, err := uc.GetTotalBalance(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, first.Currencies[0].Total, second.Currencies[0].Total)
+	mockRepo.AssertNumberOfCalls(t, "SumAllBalances", 1)
+}
+
+func TestGetTransactionVelocity_Cached(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	walletID := uuid.New()
+
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(&entity.Wallet{ID: walletID, Currency: "IDR"}, nil).Once()
+	mockRepo.On("GetTransactionVelocity", mock.Anything, walletID, mock.AnythingOfType("time.Time")).Return(&repository.TransactionVelocity{
+		HourCount: 1, HourTotal: 100, DayCount: 4, DayTotal: 700, WeekCount: 9, WeekTotal: 1200,
+	}, nil).Once()
+
+	first, err := uc.GetTransactionVelocity(context.Background(), walletID)
+	assert.Nil(t, err)
+	assert.Equal(t, "IDR", first.Currency)
+	assert.Equal(t, int64(4), first.LastDay.Count)
+	assert.Equal(t, 1200.0, first.LastWeek.Total)
+
+	second, err := uc.GetTransactionVelocity(context.Background(), walletID)
+	assert.Nil(t, err)
+	assert.Equal(t, first.LastHour, second.LastHour)
+	mockRepo.AssertNumberOfCalls(t, "GetTransactionVelocity", 1)
+}
+
+func TestGetTransactionVelocity_WalletNotFound(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	walletID := uuid.New()
+
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(nil, gorm.ErrRecordNotFound)
+
+	resp, err := uc.GetTransactionVelocity(context.Background(), walletID)
+
+	assert.Nil(t, resp)
+	assert.Equal(t, "wallet not found", err.Message)
+	mockRepo.AssertNotCalled(t, "GetTransactionVelocity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetTransaction_ByDisplayID(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	userID, walletID := uuid.New(), uuid.New()
+	transaction := &entity.Transaction{ID: uuid.New(), WalletID: walletID, Type: entity.TransactionTypeDeposit, Amount: 50, Status: entity.TransactionStatusCompleted}
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(&entity.Wallet{ID: walletID, UserID: userID}, nil)
+	mockRepo.On("GetTransactionsByIDRange", mock.Anything, walletID, transaction.ID, transaction.ID, 2).Return([]*entity.Transaction{transaction}, nil).Once()
+
+	byUUID, err := uc.GetTransaction(context.Background(), userID, transaction.ID.String())
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(byUUID.DisplayID, "TXN-"))
+
+	mockRepo.On("GetTransactionsByIDRange", mock.Anything, walletID, mock.Anything, mock.Anything, 2).Return([]*entity.Transaction{transaction}, nil).Once()
+
+	byDisplayID, err := uc.GetTransaction(context.Background(), userID, strings.ToLower(byUUID.DisplayID))
+	assert.Nil(t, err)
+	assert.Equal(t, transaction.ID, byDisplayID.ID)
+}
+
+func TestGetTransaction_InvalidID(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+
+	resp, err := uc.GetTransaction(context.Background(), uuid.New(), "not-an-id")
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 400, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "GetByUserID", mock.Anything, mock.Anything)
+}
+
+func TestDeposit_CurrencyMismatch(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 100, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 100, Currency: "USD"})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 400, err.StatusCode)
+	assert.Equal(t, "currency USD does not match the wallet currency IDR", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWithdraw_MatchingCurrencyAccepted(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, wallet.ID, 900.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 100, Currency: "IDR"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 900.0, resp.NewBalance)
+}
+
+func TestDeposit_BalancedAgainstSystemAccount(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 100, Currency: "IDR", Version: 1}
+	system := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Balance: -100, Currency: "IDR", Version: 4, IsSystem: true}
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{SystemWalletID: system.ID})
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	var legs []*entity.Transaction
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(wallet, nil)
+	mockRepo.On("GetByID", mock.Anything, system.ID).Return(system, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, system.UserID).Return(system, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).
+		Run(func(args mock.Arguments) { legs = append(legs, args.Get(2).(*entity.Transaction)) }).
+		Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, wallet.ID, 150.0, 2).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, system.ID, -150.0, 5).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 50})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 150.0, resp.NewBalance)
+	assert.Len(t, legs, 2)
+	assert.Equal(t, wallet.ID, legs[0].WalletID)
+	assert.Equal(t, entity.TransactionTypeDeposit, legs[0].Type)
+	assert.Equal(t, system.ID, legs[1].WalletID)
+	assert.Equal(t, entity.TransactionTypeWithdraw, legs[1].Type)
+	assert.Equal(t, legs[0].Amount, legs[1].Amount)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWithdraw_SystemAccountNotFlagged(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 100, Currency: "IDR", Version: 1}
+	system := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "IDR", Version: 1}
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{SystemWalletID: system.ID})
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(wallet, nil)
+	mockRepo.On("GetByID", mock.Anything, system.ID).Return(system, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, system.UserID).Return(system, nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 50})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, "system account is misconfigured", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeposit_SystemAccountCannotDeposit(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	userID := uuid.New()
+	system := &entity.Wallet{ID: uuid.New(), UserID: userID, Currency: "IDR", Version: 1, IsSystem: true}
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{SystemWalletID: system.ID})
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(system, nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 50})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 400, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "GetByUserIDForUpdate", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWithdraw_RejectedWhileSoftLocked(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	lockedUntil := time.Now().Add(time.Hour)
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, Currency: "IDR", Version: 1, LockedUntil: &lockedUntil}
 	realTx := db.Begin()
 	defer realTx.Rollback()
 
 	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
 	mockRepo.On("WithTx", realTx).Return(mockRepo)
-	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(mockWallet, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 100})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 403, err.StatusCode)
+	assert.Equal(t, "wallet is temporarily locked", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeposit_AllowedAfterSoftLockExpires(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	lockedUntil := time.Now().Add(-time.Minute)
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 100, Currency: "IDR", Version: 1, LockedUntil: &lockedUntil}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
 	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
-	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 500.0, 2).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, wallet.ID, 150.0, 2).Return(nil)
 	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
 
-	mr.SetError("redis is down")
-
-	resp, err := uc.Withdraw(context.Background(), userID, req)
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 50})
 
 	assert.Nil(t, err)
-	assert.NotNil(t, resp)
-	assert.Equal(t, 500.0, resp.NewBalance)
-	mockRepo.AssertExpectations(t)
+	assert.Equal(t, 150.0, resp.NewBalance)
 }
 
-func TestGetTransactionHistory_CacheHit(t *testing.T) {
-	mockRepo, _, rdb, uc, _ := setupTest(t)
+func TestTransfer_RejectedWhenRecipientSoftLocked(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
 	userID := uuid.New()
-	limit, offset, page := 10, 0, 1
-	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID.String(), page, limit)
+	lockedUntil := time.Now().Add(time.Hour)
+	sender := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, Currency: "IDR", Version: 1}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "IDR", Version: 1, LockedUntil: &lockedUntil}
+	realTx := db.Begin()
+	defer realTx.Rollback()
 
-	expectedResp := &params.TransactionHistoryResponse{Total: 1, Page: page}
-	cachedData, _ := json.Marshal(expectedResp)
-	rdb.Set(context.Background(), cacheKey, cachedData, time.Minute)
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, &sql.TxOptions{Isolation: sql.LevelSerializable}).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, sender.UserID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipient.UserID).Return(recipient, nil)
+
+	resp, err := uc.Transfer(context.Background(), userID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 100})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 403, err.StatusCode)
+	assert.Equal(t, "recipient wallet cannot receive funds right now", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWithdraw_RejectedForSystemWallet(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(&entity.Wallet{Balance: 1000, Currency: "IDR", Type: entity.WalletTypeSystem}, nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 100})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 403, err.StatusCode)
+	assert.Equal(t, "system wallets do not allow withdraw", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTransfer_RejectedWhenRecipientIsSystemWallet(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	sender := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, Currency: "IDR", Version: 1, Type: entity.WalletTypeMerchant}
+	recipient := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "IDR", Version: 1, Type: entity.WalletTypeSystem}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByUserID", mock.Anything, userID).Return(sender, nil)
+	mockRepo.On("GetByID", mock.Anything, recipient.ID).Return(recipient, nil)
+	mockRepo.On("BeginTxWithOptions", mock.Anything, &sql.TxOptions{Isolation: sql.LevelSerializable}).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, sender.UserID).Return(sender, nil)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, recipient.UserID).Return(recipient, nil)
+
+	resp, err := uc.Transfer(context.Background(), userID, &params.TransferRequest{ToWalletID: recipient.ID, Amount: 100})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, 403, err.StatusCode)
+	assert.Equal(t, "recipient wallet cannot receive transfers", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSoftLockWallet_SetsExpiryAndRecordsEvent(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	adminID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("GetByID", mock.Anything, wallet.ID).Return(wallet, nil)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, wallet.UserID).Return(wallet, nil)
+	mockRepo.On("UpdateLockedUntil", mock.Anything, wallet.ID, mock.AnythingOfType("*time.Time")).Return(nil)
+	mockRepo.On("CreateWalletEvent", mock.Anything, realTx, mock.MatchedBy(func(e *entity.WalletEvent) bool {
+		return e.Type == entity.WalletEventSoftLockPlaced && e.ActorID == adminID
+	})).Return(nil)
 
-	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset)
+	before := time.Now()
+	resp, err := uc.SoftLockWallet(context.Background(), adminID, wallet.ID, &params.SoftLockRequest{DurationMinutes: 30, Reason: "velocity check"})
 
 	assert.Nil(t, err)
-	assert.Equal(t, expectedResp.Total, resp.Total)
-	mockRepo.AssertNotCalled(t, "GetByUserID")
+	assert.Equal(t, wallet.ID, resp.WalletID)
+	assert.WithinDuration(t, before.Add(30*time.Minute), *resp.LockedUntil, time.Second)
+	mockRepo.AssertExpectations(t)
 }
 
-func TestGetTransactionHistory_CacheMiss_Success(t *testing.T) {
-	mockRepo, _, rdb, uc, _ := setupTest(t)
-	userID, walletID := uuid.New(), uuid.New()
-	limit, offset, page := 10, 0, 1
-	cacheKey := fmt.Sprintf("transactions:%s:%d:%d", userID.String(), page, limit)
+func TestClearSoftLock_WalletNotFound(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	walletID := uuid.New()
 
-	mockWallet := &entity.Wallet{ID: walletID}
-	mockTransactions := []*entity.Transaction{{ID: uuid.New(), Amount: 100}}
-	var totalCount int64 = 1
+	mockRepo.On("GetByID", mock.Anything, walletID).Return(nil, gorm.ErrRecordNotFound)
 
-	mockRepo.On("GetByUserID", mock.Anything, userID).Return(mockWallet, nil)
-	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset).Return(mockTransactions, nil)
-	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID).Return(totalCount, nil)
+	resp, err := uc.ClearSoftLock(context.Background(), uuid.New(), walletID)
+
+	assert.Nil(t, resp)
+	assert.Equal(t, "wallet not found", err.Message)
+	mockRepo.AssertNotCalled(t, "UpdateLockedUntil", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeposit_DedupWindowRejectsIdenticalRequest(t *testing.T) {
+	mockRepo, mr, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DedupWindow: 5 * time.Second})
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 100, Currency: "IDR", Version: 1}
+
+	// expectDeposit sets up one deposit that goes through to the database.
+	expectDeposit := func(newBalance float64) {
+		realTx := db.Begin()
+		t.Cleanup(func() { realTx.Rollback() })
+		mockRepo.On("BeginTx", mock.Anything).Return(realTx).Once()
+		mockRepo.On("WithTx", realTx).Return(mockRepo).Once()
+		mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil).Once()
+		mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil).Once()
+		mockRepo.On("UpdateBalance", mock.Anything, realTx, wallet.ID, newBalance, 2).Return(nil).Once()
+		mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil).Once()
+	}
+
+	req := &params.DepositRequest{Amount: 50, Description: "top up"}
+	expectDeposit(150)
+	_, err := uc.Deposit(context.Background(), userID, req)
+	assert.Nil(t, err)
 
-	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset)
+	_, err = uc.Deposit(context.Background(), userID, req)
+	assert.NotNil(t, err)
+	assert.Equal(t, 409, err.StatusCode)
 
+	// A different amount is not a duplicate.
+	expectDeposit(160)
+	_, err = uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 60, Description: "top up"})
+	assert.Nil(t, err)
+
+	mr.FastForward(6 * time.Second)
+	expectDeposit(150)
+	_, err = uc.Deposit(context.Background(), userID, req)
 	assert.Nil(t, err)
-	assert.NotNil(t, resp)
-	assert.Len(t, resp.Transactions, 1)
-	assert.Equal(t, totalCount, resp.Total)
 	mockRepo.AssertExpectations(t)
+}
 
-	cachedVal, cacheErr := rdb.Get(context.Background(), cacheKey).Result()
-	assert.NoError(t, cacheErr)
-	assert.NotEmpty(t, cachedVal)
+func TestWithdraw_DedupWindowReleasedOnFailure(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DedupWindow: time.Minute})
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 10, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+
+	req := &params.WithdrawRequest{Amount: 50}
+	_, err := uc.Withdraw(context.Background(), userID, req)
+	assert.Equal(t, "insufficient balance", err.Message)
+
+	_, err = uc.Withdraw(context.Background(), userID, req)
+	assert.Equal(t, "insufficient balance", err.Message)
 }
 
-func TestGetTransactionHistory_WalletNotFound(t *testing.T) {
+func TestWithdraw_OperationLockRejectsConcurrentOperation(t *testing.T) {
+	mockRepo, mr, rdb, _, _ := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{OperationLockTTL: 30 * time.Second})
+	userID := uuid.New()
+	// Another request of the user holds the lock.
+	assert.NoError(t, mr.Set(fmt.Sprintf("operation-lock:%s", userID), "other"))
+
+	_, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 50})
+
+	assert.Equal(t, 409, err.StatusCode)
+	assert.Equal(t, map[string]interface{}{"reason": "operation_in_progress"}, err.AdditionalInfo)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+	holder, _ := mr.Get(fmt.Sprintf("operation-lock:%s", userID))
+	assert.Equal(t, "other", holder)
+}
+
+func TestWithdraw_OperationLockReleasedAfterOperation(t *testing.T) {
+	mockRepo, mr, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{OperationLockTTL: 30 * time.Second})
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 10, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+
+	_, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 50})
+	assert.Equal(t, "insufficient balance", err.Message)
+	assert.False(t, mr.Exists(fmt.Sprintf("operation-lock:%s", userID)))
+}
+
+func TestWalletExists_NoWalletIsNotAnError(t *testing.T) {
 	mockRepo, _, _, uc, _ := setupTest(t)
 	userID := uuid.New()
-	limit, offset := 10, 0
 
-	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("ExistsByUserID", mock.Anything, userID).Return(false, nil)
 
-	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset)
+	resp, err := uc.WalletExists(context.Background(), userID)
 
-	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "wallet not found", err.Message)
+	assert.Nil(t, err)
+	assert.False(t, resp.Exists)
+	mockRepo.AssertNotCalled(t, "GetByUserID", mock.Anything, mock.Anything)
+}
+
+func TestWithdraw_ToVerifiedPayoutDestination(t *testing.T) {
+	mockRepo, _, _, uc, db := setupTest(t)
+	userID := uuid.New()
+	walletID := uuid.New()
+	verifiedAt := time.Now()
+	destination := &entity.PayoutDestination{ID: uuid.New(), UserID: userID, VerifiedAt: &verifiedAt}
+	wallet := &entity.Wallet{ID: walletID, UserID: userID, Balance: 1000, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	var stored *entity.Transaction
+	mockRepo.On("GetPayoutDestination", mock.Anything, destination.ID).Return(destination, nil)
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).
+		Run(func(args mock.Arguments) { stored = args.Get(2).(*entity.Transaction) }).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, walletID, 900.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 100, DestinationID: &destination.ID})
+
+	assert.Nil(t, err)
+	assert.Equal(t, destination.ID, *resp.DestinationID)
+	assert.Equal(t, destination.ID, *stored.PayoutDestinationID)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestGetTransactionHistory_GetForUpdateFails_GenericError(t *testing.T) {
+func TestWithdraw_RejectsUnverifiedPayoutDestination(t *testing.T) {
 	mockRepo, _, _, uc, _ := setupTest(t)
 	userID := uuid.New()
-	limit, offset := 10, 0
+	destination := &entity.PayoutDestination{ID: uuid.New(), UserID: userID}
 
-	mockRepo.On("GetByUserID", mock.Anything, userID).Return(nil, errors.New("unexpected db error"))
+	mockRepo.On("GetPayoutDestination", mock.Anything, destination.ID).Return(destination, nil)
 
-	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset)
+	resp, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 100, DestinationID: &destination.ID})
 
 	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "failed to get wallet", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Equal(t, 422, err.StatusCode)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
 }
 
-func TestGetTransactionHistory_GetTransactionsFails(t *testing.T) {
+func TestWithdraw_RejectsAnotherUsersPayoutDestination(t *testing.T) {
 	mockRepo, _, _, uc, _ := setupTest(t)
-	userID, walletID := uuid.New(), uuid.New()
-	limit, offset := 10, 0
-	mockWallet := &entity.Wallet{ID: walletID}
+	verifiedAt := time.Now()
+	destination := &entity.PayoutDestination{ID: uuid.New(), UserID: uuid.New(), VerifiedAt: &verifiedAt}
 
-	mockRepo.On("GetByUserID", mock.Anything, userID).Return(mockWallet, nil)
-	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset).Return(nil, errors.New("db error"))
+	mockRepo.On("GetPayoutDestination", mock.Anything, destination.ID).Return(destination, nil)
 
-	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset)
+	resp, err := uc.Withdraw(context.Background(), uuid.New(), &params.WithdrawRequest{Amount: 100, DestinationID: &destination.ID})
 
 	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "failed to get transaction history", err.Message)
-	mockRepo.AssertExpectations(t)
+	assert.Equal(t, "payout destination not found", err.Message)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
 }
 
-func TestGetTransactionHistory_CountFails(t *testing.T) {
-	mockRepo, mr, _, uc, _ := setupTest(t)
-	userID, walletID := uuid.New(), uuid.New()
-	limit, offset := 10, 0
-	mockWallet := &entity.Wallet{ID: walletID}
+func TestCreatePayoutDestination_DuplicateConflicts(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
 
-	mr.SetError("cache miss")
+	mockRepo.On("CreatePayoutDestination", mock.Anything, mock.AnythingOfType("*entity.PayoutDestination")).Return(false, nil)
 
-	mockRepo.On("GetByUserID", mock.Anything, userID).Return(mockWallet, nil)
-	mockRepo.On("GetTransactionsByWalletID", mock.Anything, walletID, limit, offset).Return([]*entity.Transaction{}, nil)
-	mockRepo.On("CountTransactionsByWalletID", mock.Anything, walletID).Return(int64(0), errors.New("db count error"))
+	resp, err := uc.CreatePayoutDestination(context.Background(), uuid.New(), &params.PayoutDestinationRequest{
+		Type: entity.PayoutDestinationBankAccount, Provider: "BCA", AccountName: "Jane", AccountNumber: "1234567890",
+	})
 
-	resp, err := uc.GetTransactionHistory(context.Background(), userID, limit, offset)
 	assert.Nil(t, resp)
-	assert.NotNil(t, err)
-	assert.Equal(t, "failed to get total transactions", err.Message)
+	assert.Equal(t, 409, err.StatusCode)
+}
+
+func TestImportTransactions_DryRunReportsRejectedRows(t *testing.T) {
+	mockRepo, _, _, uc, _ := setupTest(t)
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: uuid.New(), Balance: 100, Currency: "IDR"}
+	mockRepo.On("GetByID", mock.Anything, wallet.ID).Return(wallet, nil)
+
+	file := "wallet_id,type,amount,timestamp\n" +
+		wallet.ID.String() + ",deposit,50,2023-01-01T00:00:00Z\n" +
+		wallet.ID.String() + ",withdraw,500,2023-01-02T00:00:00Z\n" +
+		wallet.ID.String() + ",refund,10,2023-01-03T00:00:00Z\n" +
+		"not-an-id,deposit,10,2023-01-04T00:00:00Z\n" +
+		wallet.ID.String() + ",deposit,10.005,2023-01-05T00:00:00Z\n" +
+		wallet.ID.String() + ",deposit,ten,2023-01-06T00:00:00Z\n"
+
+	report, err := uc.ImportTransactions(context.Background(), uuid.New(), "csv", strings.NewReader(file), true)
+
+	assert.Nil(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 6, report.Total)
+	assert.Equal(t, 1, report.Valid)
+	assert.Equal(t, 0, report.Imported)
+	assert.Equal(t, []*params.TransactionImportRejection{
+		{Row: 2, Reason: "insufficient balance"},
+		{Row: 3, Reason: "type must be one of: deposit, withdraw"},
+		{Row: 4, Reason: "wallet_id is not a valid id"},
+		{Row: 5, Reason: "amount must have at most 2 decimal places"},
+		{Row: 6, Reason: "amount is not a number"},
+	}, report.Rejected)
+	mockRepo.AssertNotCalled(t, "BeginTx", mock.Anything)
+}
+
+func TestWithdraw_FailedCacheInvalidationIsRetriedLater(t *testing.T) {
+	mockRepo, mr, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{CacheInvalidationRetries: 2, QueueFailedInvalidations: true})
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, Currency: "IDR", Version: 1}
+	cacheKey := fmt.Sprintf("transactions:%s:10:0", userID)
+	assert.NoError(t, mr.Set(cacheKey, "stale"))
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.AnythingOfType("*entity.Transaction")).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, wallet.ID, 500.0, wallet.Version+1).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	mr.SetError("ERR unavailable")
+	_, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 500})
+	assert.Nil(t, err)
+	assert.True(t, mr.Exists(cacheKey))
+
+	// Still down: the user stays queued.
+	assert.Equal(t, 1, uc.RetryCacheInvalidations(context.Background()))
+
+	mr.SetError("")
+	assert.Equal(t, 0, uc.RetryCacheInvalidations(context.Background()))
+	assert.False(t, mr.Exists(cacheKey))
+}
+
+func TestDeposit_HoldPeriodSetsClearedAt(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DepositHold: 72 * time.Hour})
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.MatchedBy(func(tx *entity.Transaction) bool {
+		return tx.ClearedAt != nil && tx.ClearedAt.Equal(tx.CreatedAt.Add(72*time.Hour))
+	})).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, wallet.ID, 500.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.Deposit(context.Background(), userID, &params.DepositRequest{Amount: 500})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, resp.ClearedAt)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestWithdraw_ExcludesDepositsStillClearing(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{DepositHold: 72 * time.Hour})
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 1000, Currency: "IDR", Version: 1}
+	realTx := db.Begin()
+	defer realTx.Rollback()
+
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("SumClearingDeposits", mock.Anything, realTx, wallet.ID, mock.AnythingOfType("time.Time")).Return(600.0, nil)
+
+	_, err := uc.Withdraw(context.Background(), userID, &params.WithdrawRequest{Amount: 500})
+
+	assert.Equal(t, "insufficient balance", err.Message)
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWithdraw_ConfirmationFlowAboveThreshold(t *testing.T) {
+	mockRepo, _, rdb, _, db := setupTest(t)
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	uc := usecase.NewWalletUsecase(mockRepo, logger, rdb, nil, usecase.WalletUsecaseConfig{WithdrawConfirmationThreshold: 1000})
+	userID := uuid.New()
+	wallet := &entity.Wallet{ID: uuid.New(), UserID: userID, Balance: 5000, Currency: "IDR", Version: 1}
+	req := &params.WithdrawRequest{Amount: 2000, Description: "rent"}
+
+	_, err := uc.Withdraw(context.Background(), userID, req)
+	assert.Equal(t, 403, err.StatusCode)
+	assert.Equal(t, map[string]interface{}{"reason": "confirmation_required", "threshold": 1000.0}, err.AdditionalInfo)
+
+	initiated, err := uc.InitiateWithdraw(context.Background(), userID, req)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, initiated.Token)
+
+	// The token belongs to the user who initiated the withdrawal.
+	_, err = uc.ConfirmWithdraw(context.Background(), uuid.New(), &params.ConfirmWithdrawRequest{Token: initiated.Token})
+	assert.Equal(t, 400, err.StatusCode)
+
+	realTx := db.Begin()
+	defer realTx.Rollback()
+	mockRepo.On("BeginTx", mock.Anything).Return(realTx)
+	mockRepo.On("WithTx", realTx).Return(mockRepo)
+	mockRepo.On("GetByUserIDForUpdate", mock.Anything, realTx, userID).Return(wallet, nil)
+	mockRepo.On("CreateTransaction", mock.Anything, realTx, mock.MatchedBy(func(tx *entity.Transaction) bool {
+		return tx.Amount == 2000 && tx.Description == "rent"
+	})).Return(nil)
+	mockRepo.On("UpdateBalance", mock.Anything, realTx, wallet.ID, 3000.0, 2).Return(nil)
+	mockRepo.On("UpdateTransactionStatus", mock.Anything, realTx, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("*entity.Transaction")).Return(nil)
+
+	resp, err := uc.ConfirmWithdraw(context.Background(), userID, &params.ConfirmWithdrawRequest{Token: initiated.Token})
+	assert.Nil(t, err)
+	assert.Equal(t, 3000.0, resp.NewBalance)
+
+	// The token works only once.
+	_, err = uc.ConfirmWithdraw(context.Background(), userID, &params.ConfirmWithdrawRequest{Token: initiated.Token})
+	assert.Equal(t, "invalid or expired confirmation token", err.Message)
+	mockRepo.AssertNumberOfCalls(t, "CreateTransaction", 1)
+}
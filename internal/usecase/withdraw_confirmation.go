@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-digital-wallet/internal/commons/response"
+	"go-digital-wallet/internal/params"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultWithdrawConfirmationTTL is how long a confirmation token stays
+// valid when none is configured.
+const defaultWithdrawConfirmationTTL = 2 * time.Minute
+
+// requiresWithdrawConfirmation reports whether a withdrawal of amount must go
+// through InitiateWithdraw and ConfirmWithdraw.
+func (u *WalletUsecaseImpl) requiresWithdrawConfirmation(amount float64) bool {
+	return u.config.WithdrawConfirmationThreshold > 0 && amount >= u.config.WithdrawConfirmationThreshold
+}
+
+func (u *WalletUsecaseImpl) withdrawConfirmationTTL() time.Duration {
+	if u.config.WithdrawConfirmationTTL > 0 {
+		return u.config.WithdrawConfirmationTTL
+	}
+	return defaultWithdrawConfirmationTTL
+}
+
+// withdrawConfirmationKey scopes a token to the user who asked for it. Only
+// the hash of the token is part of the key.
+func withdrawConfirmationKey(userID uuid.UUID, token string) string {
+	return fmt.Sprintf("withdraw-confirmation:%s:%s", userID, hashVerificationToken(token))
+}
+
+// InitiateWithdraw records a withdrawal to be executed by ConfirmWithdraw,
+// and returns the single-use token that confirms it. Nothing is checked
+// against the wallet yet; that happens on confirmation.
+func (u *WalletUsecaseImpl) InitiateWithdraw(ctx context.Context, userID uuid.UUID, req *params.WithdrawRequest) (*params.WithdrawInitiateResponse, *response.CustomError) {
+	if custErr := u.validateAmount(req.Amount, "invalid amount"); custErr != nil {
+		return nil, custErr
+	}
+	if u.cache == nil {
+		return nil, response.ServiceUnavailableError("withdrawal confirmation is temporarily unavailable")
+	}
+
+	token, err := newVerificationToken()
+	if err != nil {
+		u.logger.WithError(err).Error("Failed to generate withdrawal confirmation token")
+		return nil, response.GeneralError("failed to generate confirmation token")
+	}
+	pending, err := json.Marshal(req)
+	if err != nil {
+		return nil, response.GeneralError("failed to store withdrawal")
+	}
+
+	ttl := u.withdrawConfirmationTTL()
+	if err := u.cache.Set(ctx, withdrawConfirmationKey(userID, token), pending, ttl).Err(); err != nil {
+		u.logger.WithError(err).WithField("user_id", userID).Error("Failed to store pending withdrawal")
+		return nil, response.GeneralError("failed to store withdrawal")
+	}
+
+	u.logger.WithFields(logrus.Fields{
+		"event":   eventWithdrawInitiated,
+		"user_id": userID,
+		"amount":  req.Amount,
+	}).Info("Withdrawal initiated")
+
+	return &params.WithdrawInitiateResponse{
+		Token:     token,
+		Amount:    req.Amount,
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// ConfirmWithdraw executes the withdrawal the token was issued for. The
+// token is consumed before the withdrawal runs, so it works once even when
+// the withdrawal then fails.
+func (u *WalletUsecaseImpl) ConfirmWithdraw(ctx context.Context, userID uuid.UUID, req *params.ConfirmWithdrawRequest) (*params.WithdrawResponse, *response.CustomError) {
+	if u.cache == nil {
+		return nil, response.ServiceUnavailableError("withdrawal confirmation is temporarily unavailable")
+	}
+
+	pending, err := u.cache.GetDel(ctx, withdrawConfirmationKey(userID, req.Token)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, response.BadRequestError("invalid or expired confirmation token")
+		}
+		u.logger.WithError(err).WithField("user_id", userID).Error("Failed to read pending withdrawal")
+		return nil, response.GeneralError("failed to read withdrawal")
+	}
+
+	var withdrawal params.WithdrawRequest
+	if err := json.Unmarshal(pending, &withdrawal); err != nil {
+		return nil, response.GeneralError("failed to read withdrawal")
+	}
+	return u.withdraw(ctx, userID, &withdrawal)
+}
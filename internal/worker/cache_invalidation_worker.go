@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"go-digital-wallet/internal/usecase"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CacheInvalidationWorker periodically retries the transaction cache
+// invalidations that still failed after their in-request retries, so a
+// Redis outage does not leave stale history cached until it expires.
+type CacheInvalidationWorker struct {
+	usecase  usecase.WalletUsecase
+	logger   *logrus.Logger
+	interval time.Duration
+}
+
+func NewCacheInvalidationWorker(usecase usecase.WalletUsecase, logger *logrus.Logger, interval time.Duration) *CacheInvalidationWorker {
+	return &CacheInvalidationWorker{
+		usecase:  usecase,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+func (w *CacheInvalidationWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				w.logger.Info("Cache invalidation worker stopped")
+				return
+			case <-ticker.C:
+				if pending := w.usecase.RetryCacheInvalidations(ctx); pending > 0 {
+					w.logger.WithField("pending", pending).Warn("Transaction cache invalidations still pending")
+				}
+			}
+		}
+	}()
+}
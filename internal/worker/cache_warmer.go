@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"go-digital-wallet/internal/params"
+	"go-digital-wallet/internal/usecase"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// cacheWarmPageSize matches the default page size of the transaction history
+// endpoint, so the warmed entry is the one the first request will hit.
+const cacheWarmPageSize = 10
+
+type CacheWarmer struct {
+	usecase usecase.WalletUsecase
+	logger  *logrus.Logger
+	userIDs []uuid.UUID
+}
+
+// NewCacheWarmer builds a warmer for the wallets of the given users, typically
+// high-traffic system or merchant accounts.
+func NewCacheWarmer(usecase usecase.WalletUsecase, logger *logrus.Logger, userIDs []string) (*CacheWarmer, error) {
+	ids := make([]uuid.UUID, 0, len(userIDs))
+	for _, raw := range userIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache warm user id %q: %w", raw, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return &CacheWarmer{
+		usecase: usecase,
+		logger:  logger,
+		userIDs: ids,
+	}, nil
+}
+
+// Start warms the caches once in the background. It goes through the regular
+// read paths, so the first page of transaction history ends up in Redis
+// exactly as a client request would have put it there.
+func (w *CacheWarmer) Start(ctx context.Context) {
+	go func() {
+		start := time.Now()
+		warmed := 0
+
+		for _, userID := range w.userIDs {
+			if ctx.Err() != nil {
+				w.logger.Info("Cache warming cancelled")
+				return
+			}
+
+			log := w.logger.WithField("user_id", userID)
+			if _, err := w.usecase.GetBalance(ctx, userID, false); err != nil {
+				log.WithField("error", err.Message).Warn("Failed to warm balance")
+				continue
+			}
+			if _, err := w.usecase.GetTransactionHistory(ctx, userID, cacheWarmPageSize, 0, params.TransactionHistoryFilter{}); err != nil {
+				log.WithField("error", err.Message).Warn("Failed to warm transaction history")
+				continue
+			}
+			warmed++
+		}
+
+		w.logger.WithFields(logrus.Fields{
+			"wallets":  warmed,
+			"failed":   len(w.userIDs) - warmed,
+			"duration": time.Since(start).String(),
+		}).Info("Cache warming completed")
+	}()
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"go-digital-wallet/internal/usecase"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type InterestWorker struct {
+	usecase usecase.InterestUsecase
+	logger  *logrus.Logger
+	runAt   time.Duration
+}
+
+// NewInterestWorker builds a worker that accrues interest once a day at runAt,
+// given as "HH:MM" in UTC.
+func NewInterestWorker(usecase usecase.InterestUsecase, logger *logrus.Logger, runAt string) (*InterestWorker, error) {
+	t, err := time.Parse("15:04", runAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interest schedule %q, expected HH:MM: %w", runAt, err)
+	}
+
+	return &InterestWorker{
+		usecase: usecase,
+		logger:  logger,
+		runAt:   time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute,
+	}, nil
+}
+
+func (w *InterestWorker) Start(ctx context.Context) {
+	go func() {
+		for {
+			next := w.nextRun(time.Now().UTC())
+			w.logger.WithField("next_run", next.Format(time.RFC3339)).Info("Interest accrual scheduled")
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				w.logger.Info("Interest worker stopped")
+				return
+			case <-timer.C:
+			}
+
+			// Interest for a day is paid once the day is over.
+			day := next.AddDate(0, 0, -1)
+			if _, err := w.usecase.AccrueDailyInterest(ctx, day); err != nil {
+				w.logger.WithError(err).Error("Interest accrual run failed")
+			}
+		}
+	}()
+}
+
+func (w *InterestWorker) nextRun(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	next := midnight.Add(w.runAt)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"go-digital-wallet/internal/usecase"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RetentionWorker struct {
+	usecase  usecase.RetentionUsecase
+	logger   *logrus.Logger
+	interval time.Duration
+}
+
+func NewRetentionWorker(usecase usecase.RetentionUsecase, logger *logrus.Logger, interval time.Duration) *RetentionWorker {
+	return &RetentionWorker{
+		usecase:  usecase,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+func (w *RetentionWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				w.logger.Info("Retention worker stopped")
+				return
+			case <-ticker.C:
+				if _, err := w.usecase.ArchiveOldTransactions(ctx); err != nil {
+					w.logger.WithError(err).Error("Transaction archival run failed")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"go-digital-wallet/internal/usecase"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TransferRequestWorker periodically expires transfer requests that were not
+// decided within their approval window, releasing the held funds.
+type TransferRequestWorker struct {
+	usecase  usecase.WalletUsecase
+	logger   *logrus.Logger
+	interval time.Duration
+}
+
+func NewTransferRequestWorker(usecase usecase.WalletUsecase, logger *logrus.Logger, interval time.Duration) *TransferRequestWorker {
+	return &TransferRequestWorker{
+		usecase:  usecase,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+func (w *TransferRequestWorker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				w.logger.Info("Transfer request worker stopped")
+				return
+			case <-ticker.C:
+				if _, err := w.usecase.ExpireTransferRequests(ctx); err != nil {
+					w.logger.WithError(err).Error("Transfer request expiry run failed")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"go-digital-wallet/internal/commons/requestid"
+	"go-digital-wallet/internal/commons/timing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogger routes GORM logs to logrus. Failed queries are logged at error
+// level and queries slower than slowThreshold at warn level; everything else is
+// dropped. A zero threshold disables slow query logging.
+type gormLogger struct {
+	log           *logrus.Logger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+func NewGormLogger(log *logrus.Logger, slowThreshold time.Duration) logger.Interface {
+	return &gormLogger{
+		log:           log,
+		level:         logger.Warn,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		l.entry(ctx).Infof(msg, args...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		l.entry(ctx).Warnf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		l.entry(ctx).Errorf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	timing.Add(ctx, "db", elapsed)
+
+	if l.level <= logger.Silent {
+		return
+	}
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		sql, rows := fc()
+		l.entry(ctx).WithError(err).WithFields(logrus.Fields{
+			"sql":         sql,
+			"rows":        rows,
+			"duration_ms": elapsed.Milliseconds(),
+		}).Error("Database query failed")
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		sql, rows := fc()
+		l.entry(ctx).WithFields(logrus.Fields{
+			"sql":          sql,
+			"rows":         rows,
+			"duration_ms":  elapsed.Milliseconds(),
+			"threshold_ms": l.slowThreshold.Milliseconds(),
+		}).Warn("Slow database query")
+	case l.level >= logger.Info:
+		sql, rows := fc()
+		l.entry(ctx).WithFields(logrus.Fields{
+			"sql":         sql,
+			"rows":        rows,
+			"duration_ms": elapsed.Milliseconds(),
+		}).Debug("Database query")
+	}
+}
+
+func (l *gormLogger) entry(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(l.log)
+	if id := requestid.FromContext(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	return entry
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"go-digital-wallet/internal/commons/requestid"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGormLogger_LogsSlowQueryWithRequestID(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	l := NewGormLogger(log, 50*time.Millisecond)
+	ctx := requestid.NewContext(context.Background(), "req-123")
+
+	l.Trace(ctx, time.Now().Add(-100*time.Millisecond), func() (string, int64) {
+		return "SELECT * FROM wallets", 1
+	}, nil)
+
+	entry := hook.LastEntry()
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, logrus.WarnLevel, entry.Level)
+		assert.Equal(t, "SELECT * FROM wallets", entry.Data["sql"])
+		assert.Equal(t, "req-123", entry.Data["request_id"])
+		assert.GreaterOrEqual(t, entry.Data["duration_ms"], int64(100))
+	}
+}
+
+func TestGormLogger_IgnoresFastQuery(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	l := NewGormLogger(log, 50*time.Millisecond)
+
+	l.Trace(context.Background(), time.Now(), func() (string, int64) {
+		return "SELECT 1", 1
+	}, nil)
+
+	assert.Empty(t, hook.AllEntries())
+}
+
+func TestGormLogger_ZeroThresholdDisablesSlowLog(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	l := NewGormLogger(log, 0)
+
+	l.Trace(context.Background(), time.Now().Add(-time.Second), func() (string, int64) {
+		return "SELECT 1", 1
+	}, nil)
+
+	assert.Empty(t, hook.AllEntries())
+}
@@ -1,9 +1,12 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"go-digital-wallet/internal/config"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
@@ -13,21 +16,112 @@ import (
 )
 
 func RunMigrations(cfg *config.DatabaseConfig, log *logrus.Logger) error {
+	m, closeFn, err := newMigrate(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	log.Info("Running database migrations...")
+	err = m.Up()
+	if err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	if err == migrate.ErrNoChange {
+		log.Info("No new migrations to apply.")
+	} else {
+		log.Info("Database migrations applied successfully!")
+	}
+
+	return nil
+}
+
+// MigrationVersion returns the schema version the database is at, 0 when no
+// migration has run, and whether the last migration failed halfway.
+func MigrationVersion(cfg *config.DatabaseConfig, log *logrus.Logger) (version uint, dirty bool, err error) {
+	m, closeFn, err := newMigrate(cfg, log)
+	if err != nil {
+		return 0, false, err
+	}
+	defer closeFn()
+
+	return currentVersion(m)
+}
+
+// RollbackMigration reverts the most recent migration and returns the version
+// the database is left at. Down migrations drop columns and tables along with
+// their data, so the caller must name the version being rolled back:
+// nothing is done unless the database is at exactly expectedVersion and not
+// dirty, which also keeps two rollbacks from running back to back by
+// accident.
+func RollbackMigration(cfg *config.DatabaseConfig, log *logrus.Logger, expectedVersion uint) (uint, error) {
+	m, closeFn, err := newMigrate(cfg, log)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFn()
+
+	version, dirty, err := currentVersion(m)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return version, fmt.Errorf("database is dirty at version %d, fix it by hand before rolling back", version)
+	}
+	if version == 0 {
+		return 0, errors.New("no migration to roll back")
+	}
+	if version != expectedVersion {
+		return version, fmt.Errorf("database is at version %d, not %d; nothing was rolled back", version, expectedVersion)
+	}
+
+	log.WithField("version", version).Warn("Rolling back database migration...")
+	if err := m.Steps(-1); err != nil {
+		return version, fmt.Errorf("failed to roll back migration %d: %w", version, err)
+	}
+
+	version, _, err = currentVersion(m)
+	if err != nil {
+		return 0, err
+	}
+	log.WithField("version", version).Info("Database migration rolled back")
+
+	return version, nil
+}
+
+func currentVersion(m *migrate.Migrate) (uint, bool, error) {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// newMigrate opens a migrate instance on the database, retrying the first
+// ping as configured so that a database still starting up is waited for. The
+// returned function closes the connection.
+func newMigrate(cfg *config.DatabaseConfig, log *logrus.Logger) (*migrate.Migrate, func(), error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database for migrations: %w", err)
+		return nil, nil, fmt.Errorf("failed to connect to database for migrations: %w", err)
 	}
-	defer db.Close()
 
-	if err = db.Ping(); err != nil {
-		return fmt.Errorf("could not ping database: %w", err)
+	interval := time.Duration(cfg.MigrationRetryIntervalMs) * time.Millisecond
+	if err = Retry(context.Background(), "postgres (migrations)", log, cfg.MigrationRetries, interval, db.Ping); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("could not ping database: %w", err)
 	}
 
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		return fmt.Errorf("failed to create postgres migration driver: %w", err)
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to create postgres migration driver: %w", err)
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
@@ -36,20 +130,9 @@ func RunMigrations(cfg *config.DatabaseConfig, log *logrus.Logger) error {
 		driver,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
-	}
-
-	log.Info("Running database migrations...")
-	err = m.Up()
-	if err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to create migrate instance: %w", err)
 	}
 
-	if err == migrate.ErrNoChange {
-		log.Info("No new migrations to apply.")
-	} else {
-		log.Info("Database migrations applied successfully!")
-	}
-
-	return nil
+	return m, func() { db.Close() }, nil
 }
@@ -0,0 +1,14 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS interest_rate DECIMAL(9,6) CHECK (interest_rate >= 0);
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_type_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_type_check
+    CHECK (type IN ('withdraw', 'deposit', 'interest'));
+
+CREATE TABLE IF NOT EXISTS interest_accruals (
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    accrual_date DATE NOT NULL,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (wallet_id, accrual_date)
+);
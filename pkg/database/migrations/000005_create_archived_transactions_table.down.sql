@@ -0,0 +1,12 @@
+INSERT INTO transactions (id, wallet_id, type, amount, status, description, created_at, updated_at)
+SELECT id, wallet_id, type, amount, status, description, created_at, updated_at
+FROM archived_transactions
+ON CONFLICT (id) DO NOTHING;
+
+DROP INDEX IF EXISTS idx_archived_transactions_wallet_id_created_at;
+DROP TABLE IF EXISTS archived_transactions;
+
+DELETE FROM interest_accruals a
+WHERE NOT EXISTS (SELECT 1 FROM transactions t WHERE t.id = a.transaction_id);
+ALTER TABLE interest_accruals ADD CONSTRAINT interest_accruals_transaction_id_fkey
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE;
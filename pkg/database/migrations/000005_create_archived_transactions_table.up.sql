@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS archived_transactions (
+    id UUID PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_transactions_wallet_id_created_at
+    ON archived_transactions (wallet_id, created_at DESC);
+
+-- Accrual markers must survive their transaction being archived, otherwise
+-- the idempotency record for that day would be lost.
+ALTER TABLE interest_accruals DROP CONSTRAINT IF EXISTS interest_accruals_transaction_id_fkey;
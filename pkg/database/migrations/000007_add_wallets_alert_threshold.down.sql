@@ -0,0 +1 @@
+ALTER TABLE wallets DROP COLUMN IF EXISTS alert_threshold;
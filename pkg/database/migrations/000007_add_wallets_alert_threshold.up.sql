@@ -0,0 +1 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS alert_threshold DECIMAL(15,2) CHECK (alert_threshold > 0);
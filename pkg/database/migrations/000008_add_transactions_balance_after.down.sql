@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_wallet_id_created_at;
+
+ALTER TABLE archived_transactions DROP COLUMN IF EXISTS balance_after;
+ALTER TABLE transactions DROP COLUMN IF EXISTS balance_after;
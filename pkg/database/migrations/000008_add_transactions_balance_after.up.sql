@@ -0,0 +1,7 @@
+-- Wallet balance right after the transaction was applied. Rows written before
+-- this column existed are left NULL.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS balance_after DECIMAL(15,2);
+ALTER TABLE archived_transactions ADD COLUMN IF NOT EXISTS balance_after DECIMAL(15,2);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_wallet_id_created_at
+    ON transactions (wallet_id, created_at DESC);
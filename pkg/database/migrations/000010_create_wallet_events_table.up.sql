@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS wallet_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    actor_id UUID NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_events_wallet_id_created_at ON wallet_events(wallet_id, created_at DESC);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS transfer_requests;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_held_balance_check;
+ALTER TABLE wallets DROP COLUMN IF EXISTS held_balance;
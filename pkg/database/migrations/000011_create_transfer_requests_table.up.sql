@@ -0,0 +1,29 @@
+-- Funds reserved by pending transfer requests. They stay in the balance but
+-- cannot be withdrawn or transferred until the hold is released.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS held_balance DECIMAL(15,2) NOT NULL DEFAULT 0.00;
+ALTER TABLE wallets ADD CONSTRAINT wallets_held_balance_check
+    CHECK (held_balance >= 0 AND held_balance <= balance);
+
+CREATE TABLE IF NOT EXISTS transfer_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    from_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    to_wallet_id UUID NOT NULL REFERENCES wallets(id) ON DELETE CASCADE,
+    requested_by UUID NOT NULL REFERENCES users(id),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'expired')),
+    decided_by UUID REFERENCES users(id),
+    decided_at TIMESTAMP,
+    transaction_id UUID REFERENCES transactions(id),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_requests_pending_expires_at
+    ON transfer_requests (expires_at) WHERE status = 'pending';
+
+CREATE TRIGGER update_transfer_requests_updated_at
+    BEFORE UPDATE ON transfer_requests
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,9 @@
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_held_balance_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_held_balance_check
+    CHECK (held_balance >= 0 AND held_balance <= balance);
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_check
+    CHECK (balance >= 0);
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS is_system;
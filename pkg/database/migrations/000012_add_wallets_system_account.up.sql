@@ -0,0 +1,12 @@
+-- The system account is the counterparty of deposits and withdrawals. It
+-- goes negative by the amount of money held in user wallets, so it is the
+-- only wallet exempt from the non-negative balance checks.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS is_system BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_check
+    CHECK (balance >= 0 OR is_system);
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_held_balance_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_held_balance_check
+    CHECK (held_balance >= 0 AND (held_balance <= balance OR is_system));
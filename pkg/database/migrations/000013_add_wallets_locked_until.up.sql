@@ -0,0 +1,3 @@
+-- Temporary soft-lock placed by fraud checks. Money movements are rejected
+-- while locked_until is in the future; it lapses on its own afterwards.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
@@ -0,0 +1,4 @@
+ALTER TABLE archived_transactions DROP COLUMN IF EXISTS payout_destination_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS payout_destination_id;
+
+DROP TABLE IF EXISTS payout_destinations;
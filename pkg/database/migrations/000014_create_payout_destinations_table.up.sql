@@ -0,0 +1,28 @@
+-- Bank accounts and e-wallets users withdraw to. A destination can only be
+-- used once verified, and is soft-deleted so that past withdrawals keep
+-- pointing at it.
+CREATE TABLE IF NOT EXISTS payout_destinations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('bank_account', 'e_wallet')),
+    provider VARCHAR(100) NOT NULL,
+    account_name VARCHAR(255) NOT NULL,
+    account_number VARCHAR(64) NOT NULL,
+    verified_at TIMESTAMP,
+    verified_by UUID REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payout_destinations_user_id ON payout_destinations(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payout_destinations_user_account
+    ON payout_destinations (user_id, provider, account_number) WHERE deleted_at IS NULL;
+
+CREATE TRIGGER update_payout_destinations_updated_at
+    BEFORE UPDATE ON payout_destinations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS payout_destination_id UUID REFERENCES payout_destinations(id);
+ALTER TABLE archived_transactions ADD COLUMN IF NOT EXISTS payout_destination_id UUID;
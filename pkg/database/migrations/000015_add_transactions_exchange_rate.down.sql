@@ -0,0 +1,6 @@
+ALTER TABLE archived_transactions DROP COLUMN IF EXISTS converted_amount;
+ALTER TABLE archived_transactions DROP COLUMN IF EXISTS original_amount;
+ALTER TABLE archived_transactions DROP COLUMN IF EXISTS exchange_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS converted_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS original_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS exchange_rate;
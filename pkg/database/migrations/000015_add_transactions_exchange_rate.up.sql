@@ -0,0 +1,9 @@
+-- Cross-currency transfers record the rate applied and the amount on both
+-- sides: original_amount in the sender's currency and converted_amount in
+-- the recipient's. Both legs carry the same values.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(20,10);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_amount DECIMAL(15,2);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS converted_amount DECIMAL(15,2);
+ALTER TABLE archived_transactions ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(20,10);
+ALTER TABLE archived_transactions ADD COLUMN IF NOT EXISTS original_amount DECIMAL(15,2);
+ALTER TABLE archived_transactions ADD COLUMN IF NOT EXISTS converted_amount DECIMAL(15,2);
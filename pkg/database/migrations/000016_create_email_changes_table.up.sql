@@ -0,0 +1,10 @@
+-- Email changes waiting for the user to follow the link sent to the new
+-- address. The old email stays in use until then. Only a hash of the link's
+-- token is stored, and a user has at most one pending change.
+CREATE TABLE IF NOT EXISTS email_changes (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
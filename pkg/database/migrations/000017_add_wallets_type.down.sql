@@ -0,0 +1,2 @@
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_type_check;
+ALTER TABLE wallets DROP COLUMN IF EXISTS type;
@@ -0,0 +1,9 @@
+-- The wallet type decides which operations the API allows on a wallet.
+-- Existing wallets become personal wallets, except the system account.
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'personal';
+
+UPDATE wallets SET type = 'system' WHERE is_system;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_type_check;
+ALTER TABLE wallets ADD CONSTRAINT wallets_type_check
+    CHECK (type IN ('personal', 'merchant', 'system'));
@@ -0,0 +1,7 @@
+-- transfer_id links the legs of one transfer: the sender's debit and every
+-- credit it paid for, such as a payment and its tip. It holds the id of the
+-- sender's leg.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS transfer_id UUID;
+ALTER TABLE archived_transactions ADD COLUMN IF NOT EXISTS transfer_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer_id ON transactions (transfer_id) WHERE transfer_id IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_clearing;
+ALTER TABLE archived_transactions DROP COLUMN IF EXISTS cleared_at;
+ALTER TABLE transactions DROP COLUMN IF EXISTS cleared_at;
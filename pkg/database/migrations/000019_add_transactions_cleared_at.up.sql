@@ -0,0 +1,6 @@
+-- cleared_at is when a deposit made during a hold period can be withdrawn.
+-- It is NULL for deposits that were available at once.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS cleared_at TIMESTAMP;
+ALTER TABLE archived_transactions ADD COLUMN IF NOT EXISTS cleared_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_clearing ON transactions (wallet_id, cleared_at) WHERE cleared_at IS NOT NULL;
@@ -0,0 +1,5 @@
+UPDATE transfer_requests r SET transaction_id = NULL
+WHERE transaction_id IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.id = r.transaction_id);
+ALTER TABLE transfer_requests ADD CONSTRAINT transfer_requests_transaction_id_fkey
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id);
@@ -0,0 +1,5 @@
+-- Archiving deletes transactions from the hot table, so an approved request
+-- cannot hold a foreign key to its transfer_out leg: once that leg passed the
+-- retention cutoff the delete would fail, and with it every archive batch.
+-- The id still finds the leg in archived_transactions.
+ALTER TABLE transfer_requests DROP CONSTRAINT IF EXISTS transfer_requests_transaction_id_fkey;
@@ -0,0 +1,8 @@
+-- Emails are lower-cased when bound from requests, so stored ones must be too
+-- or users who registered with capitals could no longer sign in. The index is
+-- created first: if two accounts differ only by case it fails before anything
+-- is rewritten, and they have to be merged by hand.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
+
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+UPDATE email_changes SET new_email = lower(new_email) WHERE new_email <> lower(new_email);
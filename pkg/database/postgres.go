@@ -7,16 +7,42 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func NewPostgresConnection(cfg *config.DatabaseConfig) (*gorm.DB, error) {
+func NewPostgresConnection(cfg *config.DatabaseConfig, logger *logrus.Logger) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
 
+	db, err := openPostgres(dsn, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Successfully connected to PostgreSQL database")
+
+	return db, nil
+}
+
+// NewPostgresReplicaConnection connects to the read replica at cfg.ReplicaDSN.
+func NewPostgresReplicaConnection(cfg *config.DatabaseConfig, logger *logrus.Logger) (*gorm.DB, error) {
+	db, err := openPostgres(cfg.ReplicaDSN, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println("Successfully connected to PostgreSQL read replica")
+
+	return db, nil
+}
+
+func openPostgres(dsn string, cfg *config.DatabaseConfig, logger *logrus.Logger) (*gorm.DB, error) {
 	// Connect to PostgreSQL
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: NewGormLogger(logger, time.Duration(cfg.SlowQueryMs)*time.Millisecond),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -31,10 +57,9 @@ func NewPostgresConnection(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
 	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("Successfully connected to PostgreSQL database")
-
 	return db, nil
 }
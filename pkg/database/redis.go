@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"go-digital-wallet/internal/config"
 	"time"
 
@@ -9,7 +10,10 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func ConnectRedis(cfg *config.RedisConfig, log *logrus.Logger) *redis.Client {
+// ConnectRedis opens a client and checks that the server answers. On failure
+// the client is closed and an error returned, so callers never hold a client
+// that was never reachable.
+func ConnectRedis(cfg *config.RedisConfig, log *logrus.Logger) (*redis.Client, error) {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:         cfg.Host + ":" + cfg.Port,
 		Password:     cfg.Password,
@@ -23,12 +27,11 @@ func ConnectRedis(cfg *config.RedisConfig, log *logrus.Logger) *redis.Client {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := rdb.Ping(ctx).Result()
-	if err != nil {
-		log.Errorf("Failed to connect to Redis: %v", err)
-		return nil
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
 	log.Info("Successfully connected to Redis")
-	return rdb
+	return rdb, nil
 }
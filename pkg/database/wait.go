@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	initialRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 5 * time.Second
+)
+
+// WaitFor calls connect until it succeeds, backing off exponentially between
+// attempts, and gives up once timeout has elapsed. It lets the service start
+// while its dependencies are still coming up instead of failing on the first
+// attempt.
+func WaitFor(ctx context.Context, name string, log *logrus.Logger, timeout time.Duration, connect func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			return nil
+		}
+
+		log.WithError(err).WithFields(logrus.Fields{
+			"dependency": name,
+			"attempt":    attempt,
+			"retry_in":   delay.String(),
+		}).Warn("Dependency not ready, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not ready after %s (%d attempts): %w", name, timeout, attempt, err)
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// Retry calls connect once and then up to retries more times, waiting interval
+// before the first retry and doubling the wait, up to 5 seconds, before each
+// one after it. It returns the last error once the retries are exhausted.
+func Retry(ctx context.Context, name string, log *logrus.Logger, retries int, interval time.Duration, connect func() error) error {
+	delay := interval
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			return nil
+		}
+		if attempt > retries {
+			return fmt.Errorf("%s not ready after %d attempts: %w", name, attempt, err)
+		}
+
+		log.WithError(err).WithFields(logrus.Fields{
+			"dependency": name,
+			"attempt":    attempt,
+			"retry_in":   delay.String(),
+		}).Warn("Dependency not ready, retrying")
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not ready after %d attempts: %w", name, attempt, err)
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
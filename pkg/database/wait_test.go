@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitFor_RetriesUntilReady(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	attempts := 0
+
+	err := WaitFor(context.Background(), "redis", log, 5*time.Second, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, hook.AllEntries(), 2)
+}
+
+func TestWaitFor_GivesUpAfterTimeout(t *testing.T) {
+	log, _ := test.NewNullLogger()
+
+	err := WaitFor(context.Background(), "postgres", log, 100*time.Millisecond, func() error {
+		return errors.New("connection refused")
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "postgres not ready")
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+func TestRetry_GivesUpAfterRetries(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	attempts := 0
+
+	err := Retry(context.Background(), "postgres", log, 2, time.Millisecond, func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "postgres not ready after 3 attempts")
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, hook.AllEntries(), 2)
+
+	attempts = 0
+	err = Retry(context.Background(), "postgres", log, 5, time.Millisecond, func() error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,108 @@
+// Package displayid derives short, human friendly ids such as
+// TXN-8F3K-2Q4M-Z9B1 from UUIDs.
+//
+// A display id encodes the leading 60 bits of the UUID in Crockford base32,
+// so it needs no storage and maps back to the small range of UUIDs sharing
+// those bits. Looking an id up is a primary key range scan; two UUIDs sharing
+// the prefix is possible but needs on the order of a hundred million ids.
+package displayid
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultPrefix is used when a Format has no prefix.
+const DefaultPrefix = "TXN"
+
+const (
+	alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	chars    = 12 // 60 bits
+	group    = 4
+)
+
+var ErrInvalid = errors.New("invalid display id")
+
+// Format renders display ids with a prefix, e.g. "TXN".
+type Format struct {
+	Prefix string
+}
+
+func New(prefix string) Format {
+	prefix = strings.ToUpper(strings.TrimSpace(prefix))
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return Format{Prefix: prefix}
+}
+
+// Encode returns the display id of id.
+func (f Format) Encode(id uuid.UUID) string {
+	bits := leading(id) >> 4
+
+	var b strings.Builder
+	b.WriteString(f.prefix())
+	for i := 0; i < chars; i++ {
+		if i%group == 0 {
+			b.WriteByte('-')
+		}
+		shift := uint(5 * (chars - 1 - i))
+		b.WriteByte(alphabet[(bits>>shift)&0x1f])
+	}
+	return b.String()
+}
+
+// Decode returns the lowest and highest UUID that encode to s. The prefix and
+// dashes are optional, case is ignored, and the commonly confused letters I,
+// L and O are read as 1 and 0.
+func (f Format) Decode(s string) (lo, hi uuid.UUID, err error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, f.prefix()+"-")
+	s = strings.ReplaceAll(s, "-", "")
+	if len(s) != chars {
+		return uuid.Nil, uuid.Nil, ErrInvalid
+	}
+
+	var bits uint64
+	for _, c := range s {
+		switch c {
+		case 'I', 'L':
+			c = '1'
+		case 'O':
+			c = '0'
+		}
+		v := strings.IndexRune(alphabet, c)
+		if v < 0 {
+			return uuid.Nil, uuid.Nil, ErrInvalid
+		}
+		bits = bits<<5 | uint64(v)
+	}
+
+	bits <<= 4
+	for i := 0; i < 8; i++ {
+		lo[i] = byte(bits >> (56 - 8*i))
+	}
+	hi = lo
+	hi[7] |= 0x0f
+	for i := 8; i < len(hi); i++ {
+		hi[i] = 0xff
+	}
+	return lo, hi, nil
+}
+
+func (f Format) prefix() string {
+	if f.Prefix == "" {
+		return DefaultPrefix
+	}
+	return f.Prefix
+}
+
+func leading(id uuid.UUID) uint64 {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<8 | uint64(id[i])
+	}
+	return v
+}
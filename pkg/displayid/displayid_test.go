@@ -0,0 +1,58 @@
+package displayid_test
+
+import (
+	"bytes"
+	"go-digital-wallet/pkg/displayid"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode_Format(t *testing.T) {
+	id := uuid.MustParse("00000000-0000-4000-8000-000000000000")
+
+	assert.Equal(t, "TXN-0000-0000-0100", displayid.New("").Encode(id))
+	assert.Equal(t, "PAY-0000-0000-0100", displayid.New("pay").Encode(id))
+}
+
+func TestDecode_RangeContainsOriginal(t *testing.T) {
+	f := displayid.New("TXN")
+
+	for i := 0; i < 100; i++ {
+		id := uuid.New()
+		lo, hi, err := f.Decode(f.Encode(id))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, bytes.Compare(lo[:], id[:]), 0)
+		assert.GreaterOrEqual(t, bytes.Compare(hi[:], id[:]), 0)
+	}
+}
+
+func TestDecode_Lenient(t *testing.T) {
+	f := displayid.New("TXN")
+	id := uuid.New()
+	want, _, err := f.Decode(f.Encode(id))
+	require.NoError(t, err)
+
+	bare := strings.ReplaceAll(strings.TrimPrefix(f.Encode(id), "TXN-"), "-", "")
+	got, _, err := f.Decode(strings.ToLower(bare))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	oneAndZero, _, err := f.Decode("TXN-1000-0000-0000")
+	require.NoError(t, err)
+	confusable, _, err := f.Decode("txn-l00o-0000-0000")
+	require.NoError(t, err)
+	assert.Equal(t, oneAndZero, confusable)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	f := displayid.New("TXN")
+
+	for _, s := range []string{"", "TXN-1234", "TXN-UUUU-0000-0000", uuid.New().String()} {
+		_, _, err := f.Decode(s)
+		assert.ErrorIs(t, err, displayid.ErrInvalid, s)
+	}
+}
@@ -0,0 +1,49 @@
+// Package featureflag holds the switches for optional features, so that
+// whether a feature is on is decided in one place instead of by scattered
+// configuration checks.
+package featureflag
+
+// Flags lists the optional features that are switched on. A nil *Flags
+// stands for Defaults, so components built without flags keep the standard
+// behaviour.
+type Flags struct {
+	// HistoryCache caches transaction history, insights and activity pages
+	// in Redis.
+	HistoryCache bool
+	// Interest runs the daily interest accrual.
+	Interest bool
+	// Retention archives old transactions in the background.
+	Retention bool
+	// AutoCreateWallet provisions a wallet on the first deposit to a user
+	// who has none.
+	AutoCreateWallet bool
+}
+
+// Defaults returns the flags used when none are configured: history caching
+// on, background jobs off.
+func Defaults() *Flags {
+	return &Flags{HistoryCache: true}
+}
+
+func (f *Flags) HistoryCacheEnabled() bool {
+	return f.get().HistoryCache
+}
+
+func (f *Flags) InterestEnabled() bool {
+	return f.get().Interest
+}
+
+func (f *Flags) RetentionEnabled() bool {
+	return f.get().Retention
+}
+
+func (f *Flags) AutoCreateWalletEnabled() bool {
+	return f.get().AutoCreateWallet
+}
+
+func (f *Flags) get() *Flags {
+	if f == nil {
+		return Defaults()
+	}
+	return f
+}
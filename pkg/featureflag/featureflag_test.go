@@ -0,0 +1,24 @@
+package featureflag_test
+
+import (
+	"go-digital-wallet/pkg/featureflag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNilFlagsUseDefaults(t *testing.T) {
+	var flags *featureflag.Flags
+
+	assert.True(t, flags.HistoryCacheEnabled())
+	assert.False(t, flags.InterestEnabled())
+	assert.False(t, flags.RetentionEnabled())
+	assert.False(t, flags.AutoCreateWalletEnabled())
+}
+
+func TestFlagsOverrideDefaults(t *testing.T) {
+	flags := &featureflag.Flags{Interest: true}
+
+	assert.False(t, flags.HistoryCacheEnabled())
+	assert.True(t, flags.InterestEnabled())
+}
@@ -0,0 +1,73 @@
+// Package fxrate quotes the exchange rates used to convert money between
+// wallets of different currencies.
+package fxrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNoRate is returned when no rate is known for a currency pair.
+var ErrNoRate = errors.New("no exchange rate available")
+
+// Provider quotes exchange rates. Implementations must be safe for
+// concurrent use.
+type Provider interface {
+	// Rate returns how many units of to one unit of from buys.
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+type staticProvider struct {
+	rates map[string]float64
+}
+
+// NewStatic returns a Provider quoting fixed rates, keyed "FROM/TO". The
+// inverse of each pair is derived unless configured separately.
+func NewStatic(rates map[string]float64) Provider {
+	p := &staticProvider{rates: make(map[string]float64, len(rates)*2)}
+	for pair, rate := range rates {
+		from, to, _ := strings.Cut(strings.ToUpper(pair), "/")
+		p.rates[from+"/"+to] = rate
+	}
+	for pair, rate := range rates {
+		from, to, _ := strings.Cut(strings.ToUpper(pair), "/")
+		if _, ok := p.rates[to+"/"+from]; !ok {
+			p.rates[to+"/"+from] = 1 / rate
+		}
+	}
+	return p
+}
+
+func (p *staticProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	rate, ok := p.rates[strings.ToUpper(from)+"/"+strings.ToUpper(to)]
+	if !ok {
+		return 0, ErrNoRate
+	}
+	return rate, nil
+}
+
+// ParseRates reads rates written as "USD/IDR=15500,EUR/IDR=16800".
+func ParseRates(spec string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		pair, value, ok := strings.Cut(entry, "=")
+		from, to, okPair := strings.Cut(strings.TrimSpace(pair), "/")
+		if !ok || !okPair || len(from) != 3 || len(to) != 3 {
+			return nil, fmt.Errorf("invalid exchange rate %q, want FROM/TO=rate", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q, rate must be a positive number", entry)
+		}
+		rates[strings.ToUpper(from)+"/"+strings.ToUpper(to)] = rate
+	}
+	return rates, nil
+}
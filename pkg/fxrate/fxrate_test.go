@@ -0,0 +1,34 @@
+package fxrate_test
+
+import (
+	"context"
+	"go-digital-wallet/pkg/fxrate"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatic_DerivesInverse(t *testing.T) {
+	rates, err := fxrate.ParseRates("usd/idr=16000, EUR/USD=1.1")
+	require.NoError(t, err)
+	p := fxrate.NewStatic(rates)
+
+	rate, err := p.Rate(context.Background(), "USD", "IDR")
+	require.NoError(t, err)
+	assert.Equal(t, 16000.0, rate)
+
+	rate, err = p.Rate(context.Background(), "idr", "usd")
+	require.NoError(t, err)
+	assert.InDelta(t, 1/16000.0, rate, 1e-12)
+
+	_, err = p.Rate(context.Background(), "EUR", "IDR")
+	assert.ErrorIs(t, err, fxrate.ErrNoRate)
+}
+
+func TestParseRates_RejectsMalformed(t *testing.T) {
+	for _, spec := range []string{"USD=1", "USD/IDR", "USD/IDR=abc", "USD/IDR=0", "USDX/IDR=2"} {
+		_, err := fxrate.ParseRates(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,106 @@
+// Package health reports whether the service's dependencies are reachable,
+// debounced so that a brief blip during a reconnect does not flip readiness
+// back and forth.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check returns an error when a dependency cannot be used.
+type Check func(ctx context.Context) error
+
+// Config sets how many probes in a row it takes to change readiness.
+// Thresholds below 1 mean 1, which reports every probe as it is.
+type Config struct {
+	// SuccessThreshold is how many passing probes in a row it takes to
+	// report ready, at startup and after having reported not ready.
+	SuccessThreshold int
+	// FailureThreshold is how many failing probes in a row it takes to
+	// report not ready once ready.
+	FailureThreshold int
+	// Timeout bounds the checks of one probe. Zero waits for them.
+	Timeout time.Duration
+}
+
+// Result is the outcome of one probe: the debounced readiness and what each
+// check returned, "ok" or its error.
+type Result struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Checker runs named checks and debounces their combined outcome.
+type Checker struct {
+	config Config
+	checks map[string]Check
+
+	mu        sync.Mutex
+	ready     bool
+	successes int
+	failures  int
+}
+
+func NewChecker(config Config, checks map[string]Check) *Checker {
+	if config.SuccessThreshold < 1 {
+		config.SuccessThreshold = 1
+	}
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	return &Checker{config: config, checks: checks}
+}
+
+// Probe runs every check once and returns the readiness it leads to.
+func (c *Checker) Probe(ctx context.Context) Result {
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := Result{Checks: make(map[string]string, len(names))}
+	passed := true
+	for _, name := range names {
+		if err := c.checks[name](ctx); err != nil {
+			result.Checks[name] = err.Error()
+			passed = false
+			continue
+		}
+		result.Checks[name] = "ok"
+	}
+
+	result.Ready = c.record(passed)
+	return result
+}
+
+// record counts the outcome of a probe and returns the readiness it leads
+// to.
+func (c *Checker) record(passed bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if passed {
+		c.successes++
+		c.failures = 0
+		if c.successes >= c.config.SuccessThreshold {
+			c.ready = true
+		}
+	} else {
+		c.failures++
+		c.successes = 0
+		if c.failures >= c.config.FailureThreshold {
+			c.ready = false
+		}
+	}
+	return c.ready
+}
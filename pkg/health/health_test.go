@@ -0,0 +1,57 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"go-digital-wallet/pkg/health"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_DefaultsReportEveryProbe(t *testing.T) {
+	var err error
+	checker := health.NewChecker(health.Config{}, map[string]health.Check{
+		"database": func(ctx context.Context) error { return err },
+	})
+
+	assert.True(t, checker.Probe(context.Background()).Ready)
+
+	err = errors.New("connection refused")
+	result := checker.Probe(context.Background())
+	assert.False(t, result.Ready)
+	assert.Equal(t, map[string]string{"database": "connection refused"}, result.Checks)
+
+	err = nil
+	assert.True(t, checker.Probe(context.Background()).Ready)
+}
+
+func TestChecker_Debounces(t *testing.T) {
+	var err error
+	checker := health.NewChecker(health.Config{SuccessThreshold: 2, FailureThreshold: 3}, map[string]health.Check{
+		"database": func(ctx context.Context) error { return nil },
+		"redis":    func(ctx context.Context) error { return err },
+	})
+	probe := func() bool { return checker.Probe(context.Background()).Ready }
+
+	// Warmup: two passes in a row before the first ready.
+	assert.False(t, probe())
+	assert.True(t, probe())
+
+	// Blips shorter than three probes keep it ready, and a pass resets
+	// the count.
+	err = errors.New("i/o timeout")
+	assert.True(t, probe())
+	assert.True(t, probe())
+	err = nil
+	assert.True(t, probe())
+	err = errors.New("i/o timeout")
+	assert.True(t, probe())
+	assert.True(t, probe())
+	assert.False(t, probe())
+
+	// Recovering takes two passes again.
+	err = nil
+	assert.False(t, probe())
+	assert.True(t, probe())
+}
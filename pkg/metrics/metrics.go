@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var errorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "digital_wallet",
+		Name:      "errors_total",
+		Help:      "Number of API errors by category and HTTP status code.",
+	},
+	[]string{"category", "status_code"},
+)
+
+func init() {
+	prometheus.MustRegister(errorsTotal)
+}
+
+func IncError(category string, statusCode int) {
+	errorsTotal.WithLabelValues(category, strconv.Itoa(statusCode)).Inc()
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,44 @@
+// Package money reads monetary amounts as exact decimals, so that an amount
+// sent as 0.1 is checked and rounded as 0.1 rather than as the float64 closest
+// to it.
+package money
+
+import (
+	"errors"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrMalformed  = errors.New("amount must be a decimal number such as 10 or 10.50")
+	ErrTooPrecise = errors.New("amount has more decimal places than the currency allows")
+)
+
+// amountPattern is the JSON number grammar with the exponent capped at two
+// digits, so an amount like 1e999999999 cannot make parsing expensive.
+var amountPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]{1,2})?$`)
+
+// Parse reads text, a JSON number or the same written as a string, as an
+// exact decimal.
+func Parse(text string) (*big.Rat, error) {
+	text = strings.TrimSpace(text)
+	if !amountPattern.MatchString(text) {
+		return nil, ErrMalformed
+	}
+	amount, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, ErrMalformed
+	}
+	return amount, nil
+}
+
+// Format writes amount with exactly places decimal places, returning
+// ErrTooPrecise when that would round it.
+func Format(amount *big.Rat, places int) (string, error) {
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil))
+	if !new(big.Rat).Mul(amount, scale).IsInt() {
+		return "", ErrTooPrecise
+	}
+	return amount.FloatString(places), nil
+}
@@ -0,0 +1,46 @@
+package money_test
+
+import (
+	"go-digital-wallet/pkg/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAndFormat(t *testing.T) {
+	for text, want := range map[string]string{
+		"10":       "10.00",
+		"0.1":      "0.10",
+		" 0.30 ":   "0.30",
+		"12345.67": "12345.67",
+		"1.5e2":    "150.00",
+		"125E-2":   "1.25",
+		"-4.2":     "-4.20",
+	} {
+		amount, err := money.Parse(text)
+		if assert.NoError(t, err, text) {
+			formatted, err := money.Format(amount, 2)
+			assert.NoError(t, err, text)
+			assert.Equal(t, want, formatted, text)
+		}
+	}
+}
+
+func TestParse_RejectsMalformed(t *testing.T) {
+	for _, text := range []string{"", "abc", "1,000", "10.", ".5", "+5", "0x10", "1/3", "01", "1e999999999", "NaN", "Infinity"} {
+		_, err := money.Parse(text)
+		assert.ErrorIs(t, err, money.ErrMalformed, text)
+	}
+}
+
+func TestFormat_TooPrecise(t *testing.T) {
+	amount, err := money.Parse("10.005")
+	assert.NoError(t, err)
+
+	_, err = money.Format(amount, 2)
+	assert.ErrorIs(t, err, money.ErrTooPrecise)
+	assert.Equal(t, 10.01, money.RoundHalfUp.Round(amount, 2))
+
+	_, err = money.Format(amount, 3)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,84 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode is how amounts computed from others, such as converted
+// amounts and interest, are brought back to whole minor units.
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds halves away from zero: 0.125 becomes 0.13.
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds halves to the even neighbour, so that rounding
+	// many amounts is not biased upwards: 0.125 becomes 0.12, 0.135 becomes
+	// 0.14.
+	RoundHalfEven RoundingMode = "half_even"
+	// RoundFloor rounds down, towards negative infinity: 0.129 becomes 0.12.
+	RoundFloor RoundingMode = "floor"
+)
+
+// DefaultRoundingMode is used when no mode is configured.
+const DefaultRoundingMode = RoundHalfUp
+
+// ParseRoundingMode reads a mode by name; empty is DefaultRoundingMode.
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	switch mode := RoundingMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return DefaultRoundingMode, nil
+	case RoundHalfUp, RoundHalfEven, RoundFloor:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown rounding mode %q, expected one of: %s, %s, %s", name, RoundHalfUp, RoundHalfEven, RoundFloor)
+	}
+}
+
+// Decimal returns x as the decimal it prints as, so that 0.1 is one tenth
+// rather than the binary fraction closest to it. Computing with decimals
+// keeps an amount that is exactly half a minor unit from landing a hair
+// below or above the half.
+func Decimal(x float64) *big.Rat {
+	amount, _ := new(big.Rat).SetString(strconv.FormatFloat(x, 'g', -1, 64))
+	return amount
+}
+
+// Round rounds amount to places decimal places. An empty mode is
+// DefaultRoundingMode.
+func (m RoundingMode) Round(amount *big.Rat, places int) float64 {
+	rounded, _ := m.RoundDecimal(amount, places).Float64()
+	return rounded
+}
+
+// RoundDecimal is Round returning the exact decimal, for callers that go on
+// to format it rather than compute with it.
+func (m RoundingMode) RoundDecimal(amount *big.Rat, places int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	scaled := new(big.Rat).Mul(amount, new(big.Rat).SetInt(scale))
+
+	// Euclidean division floors, as the denominator is positive.
+	units, rem := new(big.Int).DivMod(scaled.Num(), scaled.Denom(), new(big.Int))
+	// rem/denom against 1/2, in integers.
+	half := new(big.Int).Lsh(rem, 1).Cmp(scaled.Denom())
+
+	switch {
+	case m == RoundFloor || half < 0:
+	case half > 0:
+		units.Add(units, big.NewInt(1))
+	case m == RoundHalfEven:
+		if units.Bit(0) == 1 {
+			units.Add(units, big.NewInt(1))
+		}
+	default:
+		// Away from zero: up for positive amounts, while the floor of a
+		// negative one already is.
+		if amount.Sign() > 0 {
+			units.Add(units, big.NewInt(1))
+		}
+	}
+
+	return new(big.Rat).SetFrac(units, scale)
+}
@@ -0,0 +1,73 @@
+package money_test
+
+import (
+	"go-digital-wallet/pkg/money"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundingMode_Round(t *testing.T) {
+	cases := []struct {
+		amount                  string
+		halfUp, halfEven, floor float64
+	}{
+		{"0.125", 0.13, 0.12, 0.12},
+		{"0.135", 0.14, 0.14, 0.13},
+		{"0.1249", 0.12, 0.12, 0.12},
+		{"0.1251", 0.13, 0.13, 0.12},
+		{"0.129", 0.13, 0.13, 0.12},
+		{"0.12", 0.12, 0.12, 0.12},
+		{"0.005", 0.01, 0, 0},
+		{"0.015", 0.02, 0.02, 0.01},
+		{"-0.125", -0.13, -0.12, -0.13},
+		{"-0.121", -0.12, -0.12, -0.13},
+		{"1234567890123.125", 1234567890123.13, 1234567890123.12, 1234567890123.12},
+	}
+	for _, c := range cases {
+		amount, ok := new(big.Rat).SetString(c.amount)
+		if !assert.True(t, ok, c.amount) {
+			continue
+		}
+		assert.Equal(t, c.halfUp, money.RoundHalfUp.Round(amount, 2), "half_up %s", c.amount)
+		assert.Equal(t, c.halfEven, money.RoundHalfEven.Round(amount, 2), "half_even %s", c.amount)
+		assert.Equal(t, c.floor, money.RoundFloor.Round(amount, 2), "floor %s", c.amount)
+	}
+
+	// No mode is the default, half up.
+	assert.Equal(t, 0.13, money.RoundingMode("").Round(big.NewRat(1, 8), 2))
+	// Whole units.
+	assert.Equal(t, 2.0, money.RoundHalfEven.Round(big.NewRat(5, 2), 0))
+	assert.Equal(t, 4.0, money.RoundHalfEven.Round(big.NewRat(7, 2), 0))
+}
+
+func TestRoundingMode_RoundDecimal(t *testing.T) {
+	// Beyond float64's exact integers, where Round would blur the cents.
+	amount, _ := new(big.Rat).SetString("90071992547409.935")
+	assert.Equal(t, "90071992547409.94", money.RoundHalfUp.RoundDecimal(amount, 2).FloatString(2))
+	assert.Equal(t, "90071992547409.93", money.RoundFloor.RoundDecimal(amount, 2).FloatString(2))
+}
+
+func TestDecimal_KeepsHalvesExact(t *testing.T) {
+	// 1.005 as a float64 is a hair below 1.005, and 0.1*3 a hair above 0.3.
+	assert.Equal(t, 1.01, money.RoundHalfUp.Round(money.Decimal(1.005), 2))
+	product := new(big.Rat).Mul(money.Decimal(0.1), money.Decimal(3))
+	assert.Equal(t, 0.3, money.RoundFloor.Round(product, 2))
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	for name, want := range map[string]money.RoundingMode{
+		"":          money.RoundHalfUp,
+		"half_up":   money.RoundHalfUp,
+		"HALF_EVEN": money.RoundHalfEven,
+		" floor ":   money.RoundFloor,
+	} {
+		mode, err := money.ParseRoundingMode(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, mode, name)
+	}
+
+	_, err := money.ParseRoundingMode("bankers")
+	assert.Error(t, err)
+}
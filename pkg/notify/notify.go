@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+	ChannelPush  Channel = "push"
+)
+
+// Message is a notification addressed to a user. Delivery details such as the
+// email address or phone number are resolved by the Notifier implementation.
+type Message struct {
+	UserID  uuid.UUID
+	Channel Channel
+	// To overrides the user's address on Channel, for messages that must
+	// reach an address not on record yet, such as a new email to verify.
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users. Implementations must be safe for
+// concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier for driver: "noop" (or empty) discards messages
+// and "log" writes them to logger.
+func New(driver string, logger *logrus.Logger) (Notifier, error) {
+	switch driver {
+	case "", "noop":
+		return NewNoopNotifier(), nil
+	case "log":
+		return NewLogNotifier(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", driver)
+	}
+}
+
+type noopNotifier struct{}
+
+// NewNoopNotifier returns a Notifier that discards every message.
+func NewNoopNotifier() Notifier {
+	return noopNotifier{}
+}
+
+func (noopNotifier) Notify(ctx context.Context, msg Message) error {
+	return nil
+}
+
+type logNotifier struct {
+	logger *logrus.Logger
+}
+
+// NewLogNotifier returns a Notifier that only logs messages, for development.
+func NewLogNotifier(logger *logrus.Logger) Notifier {
+	return &logNotifier{logger: logger}
+}
+
+func (n *logNotifier) Notify(ctx context.Context, msg Message) error {
+	n.logger.WithFields(logrus.Fields{
+		"user_id": msg.UserID,
+		"channel": msg.Channel,
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+	}).Info("Notification sent")
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package statementsig signs exported statements, so that a statement handed
+// over in a dispute can be shown not to have been altered since it was
+// generated.
+//
+// A signature is detached: it covers the SHA-256 of the canonicalized
+// statement file together with the wallet it belongs to, the wallet version
+// and the time it was generated, which travel next to the file rather than
+// inside it.
+package statementsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmRSASHA256  = "rsa-sha256"
+
+	// version starts every signed message, so that the layout can change
+	// without old signatures verifying against a new one.
+	version = "digital-wallet-statement/v1"
+)
+
+var (
+	ErrInvalidSignature = errors.New("signature does not match the statement")
+	ErrInvalidKey       = errors.New("invalid RSA private key")
+)
+
+// Metadata is what a signature covers besides the statement file.
+type Metadata struct {
+	WalletID      string
+	WalletVersion int
+	GeneratedAt   time.Time
+}
+
+// Signer signs and verifies statement messages with one key.
+type Signer interface {
+	Algorithm() string
+	Sign(message []byte) ([]byte, error)
+	Verify(message, signature []byte) error
+}
+
+// Canonicalize returns content with a leading UTF-8 byte order mark removed,
+// CRLF and CR line endings turned into LF and trailing line endings dropped,
+// so that a statement opened and saved by another tool still verifies.
+// Anything else, including whitespace inside lines, is significant.
+func Canonicalize(content []byte) []byte {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+	return bytes.TrimRight(content, "\n")
+}
+
+// Message builds the bytes that are signed for a statement: the digest of
+// its canonical content and its metadata, one field per line.
+func Message(content []byte, meta Metadata) []byte {
+	sum := sha256.Sum256(Canonicalize(content))
+	return []byte(fmt.Sprintf("%s\nwallet_id:%s\nwallet_version:%d\ngenerated_at:%s\ncontent_sha256:%x\n",
+		version, meta.WalletID, meta.WalletVersion, meta.GeneratedAt.UTC().Format(time.RFC3339Nano), sum))
+}
+
+type hmacSigner struct {
+	key []byte
+}
+
+// NewHMAC signs with HMAC-SHA256. Only holders of key can verify.
+func NewHMAC(key []byte) Signer {
+	return &hmacSigner{key: key}
+}
+
+func (s *hmacSigner) Algorithm() string {
+	return AlgorithmHMACSHA256
+}
+
+func (s *hmacSigner) Sign(message []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(message)
+	return mac.Sum(nil), nil
+}
+
+func (s *hmacSigner) Verify(message, signature []byte) error {
+	expected, _ := s.Sign(message)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type rsaSigner struct {
+	key *rsa.PrivateKey
+}
+
+// NewRSA signs with RSASSA-PKCS1-v1_5 over SHA-256, which anyone holding
+// the public key can verify.
+func NewRSA(key *rsa.PrivateKey) Signer {
+	return &rsaSigner{key: key}
+}
+
+// ParseRSAPrivateKey reads a PEM encoded PKCS #1 or PKCS #8 RSA private key.
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+func (s *rsaSigner) Algorithm() string {
+	return AlgorithmRSASHA256
+}
+
+func (s *rsaSigner) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	return rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, digest[:])
+}
+
+func (s *rsaSigner) Verify(message, signature []byte) error {
+	digest := sha256.Sum256(message)
+	if err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
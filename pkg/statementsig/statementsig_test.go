@@ -0,0 +1,58 @@
+package statementsig_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"go-digital-wallet/pkg/statementsig"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigners_DetectTampering(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	meta := statementsig.Metadata{WalletID: "wallet-1", WalletVersion: 7, GeneratedAt: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)}
+	content := []byte("id,amount\r\n1,10.00\r\n")
+
+	for _, signer := range []statementsig.Signer{statementsig.NewHMAC([]byte("secret")), statementsig.NewRSA(rsaKey)} {
+		signature, err := signer.Sign(statementsig.Message(content, meta))
+		assert.NoError(t, err, signer.Algorithm())
+
+		// Line endings do not matter; the content and metadata do.
+		assert.NoError(t, signer.Verify(statementsig.Message([]byte("id,amount\n1,10.00"), meta), signature), signer.Algorithm())
+		assert.ErrorIs(t, signer.Verify(statementsig.Message([]byte("id,amount\n1,100.00\n"), meta), signature), statementsig.ErrInvalidSignature, signer.Algorithm())
+
+		altered := meta
+		altered.WalletVersion = 8
+		assert.ErrorIs(t, signer.Verify(statementsig.Message(content, altered), signature), statementsig.ErrInvalidSignature, signer.Algorithm())
+	}
+
+	// Another HMAC key does not verify.
+	signature, _ := statementsig.NewHMAC([]byte("secret")).Sign(statementsig.Message(content, meta))
+	assert.ErrorIs(t, statementsig.NewHMAC([]byte("other")).Verify(statementsig.Message(content, meta), signature), statementsig.ErrInvalidSignature)
+}
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		parsed, err := statementsig.ParseRSAPrivateKey(pem.EncodeToMemory(block))
+		if assert.NoError(t, err, block.Type) {
+			assert.True(t, key.Equal(parsed), block.Type)
+		}
+	}
+
+	_, err = statementsig.ParseRSAPrivateKey([]byte("not a key"))
+	assert.ErrorIs(t, err, statementsig.ErrInvalidKey)
+}
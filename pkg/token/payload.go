@@ -2,8 +2,16 @@ package token
 
 import "time"
 
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
 type Token struct {
 	AuthId  string
 	Expired time.Time
 	Role    string
+	// Type is TypeAccess or TypeRefresh. Tokens issued before token types
+	// existed carry no type and are treated as access tokens.
+	Type string
 }
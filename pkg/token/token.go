@@ -11,21 +11,79 @@ import (
 )
 
 type TokenManager struct {
-	secret string
-	expiry time.Duration
+	secret          string
+	previousSecrets []string
+	expiry          time.Duration
+	refreshExpiry   time.Duration
 }
 
+type Config struct {
+	Secret string
+	// PreviousSecrets are still accepted when validating tokens, so that
+	// tokens signed before a secret rotation keep working. New tokens are
+	// always signed with Secret.
+	PreviousSecrets []string
+	AccessExpiry    time.Duration
+	RefreshExpiry   time.Duration
+	// MaxAccessExpiry and MaxRefreshExpiry cap the configured lifetimes. Zero
+	// means no cap.
+	MaxAccessExpiry  time.Duration
+	MaxRefreshExpiry time.Duration
+}
+
+// NewTokenManager builds a manager that issues access and refresh tokens with
+// the same lifetime. Prefer NewTokenManagerWithConfig.
 func NewTokenManager(secret string, expiryHours int) *TokenManager {
 	return &TokenManager{
-		secret: secret,
-		expiry: time.Duration(expiryHours) * time.Hour,
+		secret:        secret,
+		expiry:        time.Duration(expiryHours) * time.Hour,
+		refreshExpiry: time.Duration(expiryHours) * time.Hour,
+	}
+}
+
+// NewTokenManagerWithConfig builds a manager with separate access and refresh
+// lifetimes, rejecting lifetimes that are not positive or exceed their cap.
+func NewTokenManagerWithConfig(cfg Config) (*TokenManager, error) {
+	if err := validateExpiry(TypeAccess, cfg.AccessExpiry, cfg.MaxAccessExpiry); err != nil {
+		return nil, err
+	}
+	if err := validateExpiry(TypeRefresh, cfg.RefreshExpiry, cfg.MaxRefreshExpiry); err != nil {
+		return nil, err
+	}
+
+	return &TokenManager{
+		secret:          cfg.Secret,
+		previousSecrets: cfg.PreviousSecrets,
+		expiry:          cfg.AccessExpiry,
+		refreshExpiry:   cfg.RefreshExpiry,
+	}, nil
+}
+
+func validateExpiry(tokenType string, expiry, max time.Duration) error {
+	if expiry <= 0 {
+		return fmt.Errorf("%s token expiry must be positive, got %s", tokenType, expiry)
 	}
+	if max > 0 && expiry > max {
+		return fmt.Errorf("%s token expiry %s exceeds the maximum allowed %s", tokenType, expiry, max)
+	}
+	return nil
+}
+
+// GenerateToken issues an access token carrying the user's role.
+func (tm *TokenManager) GenerateToken(userID uuid.UUID, role string) (string, error) {
+	return tm.generate(userID, role, TypeAccess, tm.expiry)
+}
+
+func (tm *TokenManager) GenerateRefreshToken(userID uuid.UUID, role string) (string, error) {
+	return tm.generate(userID, role, TypeRefresh, tm.refreshExpiry)
 }
 
-func (tm *TokenManager) GenerateToken(userID uuid.UUID) (string, error) {
+func (tm *TokenManager) generate(userID uuid.UUID, role, tokenType string, expiry time.Duration) (string, error) {
 	payload := Token{
 		AuthId:  userID.String(),
-		Expired: time.Now().Add(tm.expiry),
+		Expired: time.Now().Add(expiry),
+		Role:    role,
+		Type:    tokenType,
 	}
 	claims := jwt.MapClaims{
 		"payload": payload,
@@ -39,13 +97,39 @@ func (tm *TokenManager) GenerateToken(userID uuid.UUID) (string, error) {
 	return tokenStr, nil
 }
 
+// ValidateToken validates an access token.
 func (tm *TokenManager) ValidateToken(tokenString string) (*Token, error) {
-	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+	payload, err := tm.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Type != "" && payload.Type != TypeAccess {
+		return nil, errors.New("invalid token type")
+	}
+	return payload, nil
+}
+
+func (tm *TokenManager) ValidateRefreshToken(tokenString string) (*Token, error) {
+	payload, err := tm.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Type != TypeRefresh {
+		return nil, errors.New("invalid token type")
+	}
+	return payload, nil
+}
+
+// parse verifies the token against the current secret, then against each
+// previous secret if only the signature did not match.
+func (tm *TokenManager) parse(tokenString string) (*Token, error) {
+	token, err := parseWithSecret(tokenString, tm.secret)
+	for _, secret := range tm.previousSecrets {
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
 		}
-		return []byte(tm.secret), nil
-	})
+		token, err = parseWithSecret(tokenString, secret)
+	}
 
 	if err != nil {
 		return nil, err
@@ -70,3 +154,12 @@ func (tm *TokenManager) ValidateToken(tokenString string) (*Token, error) {
 	}
 	return nil, errors.New("unauthorized")
 }
+
+func parseWithSecret(tokenString, secret string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+}
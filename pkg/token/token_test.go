@@ -0,0 +1,87 @@
+package token_test
+
+import (
+	"go-digital-wallet/pkg/token"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTokenManagerWithConfig_ExceedsMax(t *testing.T) {
+	_, err := token.NewTokenManagerWithConfig(token.Config{
+		Secret:          "secret",
+		AccessExpiry:    90 * 24 * time.Hour,
+		RefreshExpiry:   time.Hour,
+		MaxAccessExpiry: 24 * time.Hour,
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds the maximum")
+}
+
+func TestNewTokenManagerWithConfig_NonPositive(t *testing.T) {
+	_, err := token.NewTokenManagerWithConfig(token.Config{
+		Secret:        "secret",
+		AccessExpiry:  time.Hour,
+		RefreshExpiry: 0,
+	})
+
+	assert.Error(t, err)
+}
+
+func TestTokenTypes_AreNotInterchangeable(t *testing.T) {
+	tm, err := token.NewTokenManagerWithConfig(token.Config{
+		Secret:        "secret",
+		AccessExpiry:  time.Hour,
+		RefreshExpiry: 24 * time.Hour,
+	})
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	access, _ := tm.GenerateToken(userID, "user")
+	refresh, _ := tm.GenerateRefreshToken(userID, "user")
+
+	payload, err := tm.ValidateToken(access)
+	assert.NoError(t, err)
+	assert.Equal(t, userID.String(), payload.AuthId)
+
+	_, err = tm.ValidateToken(refresh)
+	assert.Error(t, err)
+
+	_, err = tm.ValidateRefreshToken(access)
+	assert.Error(t, err)
+
+	payload, err = tm.ValidateRefreshToken(refresh)
+	assert.NoError(t, err)
+	assert.Equal(t, token.TypeRefresh, payload.Type)
+}
+
+func TestValidateToken_PreviousSecretDuringRotation(t *testing.T) {
+	old, err := token.NewTokenManagerWithConfig(token.Config{Secret: "old", AccessExpiry: time.Hour, RefreshExpiry: time.Hour})
+	assert.NoError(t, err)
+	rotated, err := token.NewTokenManagerWithConfig(token.Config{
+		Secret:          "new",
+		PreviousSecrets: []string{"older", "old"},
+		AccessExpiry:    time.Hour,
+		RefreshExpiry:   time.Hour,
+	})
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	oldToken, _ := old.GenerateToken(userID, "user")
+	payload, err := rotated.ValidateToken(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, userID.String(), payload.AuthId)
+
+	// New tokens are signed with the new secret only.
+	newToken, _ := rotated.GenerateToken(userID, "user")
+	_, err = old.ValidateToken(newToken)
+	assert.Error(t, err)
+
+	other, _ := token.NewTokenManagerWithConfig(token.Config{Secret: "unknown", AccessExpiry: time.Hour, RefreshExpiry: time.Hour})
+	otherToken, _ := other.GenerateToken(userID, "user")
+	_, err = rotated.ValidateToken(otherToken)
+	assert.Error(t, err)
+}